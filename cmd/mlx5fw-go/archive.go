@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	cliutil "github.com/Civil/mlx5fw-go/pkg/cliutil"
+	"github.com/Civil/mlx5fw-go/pkg/mfa2"
+	"github.com/Civil/mlx5fw-go/pkg/parser"
+)
+
+// ArchiveImageJSON describes one firmware image contained in an archive
+type ArchiveImageJSON struct {
+	PSID       string `json:"psid"`
+	Component  int    `json:"component"`
+	Identifier uint16 `json:"identifier"`
+	Offset     uint64 `json:"offset"`
+	Size       uint32 `json:"size"`
+}
+
+// ArchiveListJSON is the JSON output of archive list
+type ArchiveListJSON struct {
+	File          string             `json:"file"`
+	NumDevices    int                `json:"num_devices"`
+	NumComponents int                `json:"num_components"`
+	Images        []ArchiveImageJSON `json:"images"`
+}
+
+// CreateArchiveCommand creates the archive command
+func CreateArchiveCommand() *cobra.Command {
+	archiveCmd := &cobra.Command{
+		Use:   "archive",
+		Short: "Inspect MFA2 firmware archives",
+		Long: `Inspect MFA2 firmware bundles (.mfa2) that carry one firmware image per PSID.
+
+Commands that parse an image (query, sections, extract) accept the archive
+directly via -f together with --psid to select the image.`,
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the images contained in an archive",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cliutil.ValidateFirmwarePath(firmwarePath); err != nil {
+				return err
+			}
+			return runArchiveList(cmd, args)
+		},
+	}
+
+	archiveCmd.AddCommand(listCmd)
+	return archiveCmd
+}
+
+func runArchiveList(cmd *cobra.Command, args []string) error {
+	reader, err := parser.NewFirmwareReader(firmwarePath, logger)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer reader.Close()
+
+	archive, err := mfa2.Parse(reader, reader.Size(), logger)
+	if err != nil {
+		return fmt.Errorf("failed to parse archive: %w", err)
+	}
+
+	out := ArchiveListJSON{
+		File:          firmwarePath,
+		NumDevices:    len(archive.Devices),
+		NumComponents: len(archive.Components),
+		Images:        []ArchiveImageJSON{},
+	}
+	for _, dev := range archive.Devices {
+		for _, ptr := range dev.Components {
+			comp := archive.Components[ptr.ComponentIndex]
+			out.Images = append(out.Images, ArchiveImageJSON{
+				PSID:       dev.PSID,
+				Component:  comp.Index,
+				Identifier: comp.Descriptor.Identifier,
+				Offset:     comp.Descriptor.CBOffset(),
+				Size:       comp.Descriptor.Size,
+			})
+		}
+	}
+
+	if jsonOutput {
+		return outputJSON(out)
+	}
+
+	fmt.Printf("Archive:      %s\n", out.File)
+	fmt.Printf("Devices:      %d\n", out.NumDevices)
+	fmt.Printf("Components:   %d\n\n", out.NumComponents)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PSID\tCOMPONENT\tTYPE\tOFFSET\tSIZE")
+	for _, img := range out.Images {
+		fmt.Fprintf(w, "%s\t%d\t%s\t0x%08x\t0x%08x\n",
+			img.PSID, img.Component, componentTypeName(img.Identifier), img.Offset, img.Size)
+	}
+	return w.Flush()
+}
+
+func componentTypeName(id uint16) string {
+	if id == mfa2.ComponentIDFirmware {
+		return "FW"
+	}
+	return fmt.Sprintf("0x%x", id)
+}
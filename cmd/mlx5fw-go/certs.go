@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ansel1/merry/v2"
+	"github.com/spf13/cobra"
+
+	cliutil "github.com/Civil/mlx5fw-go/pkg/cliutil"
+	"github.com/Civil/mlx5fw-go/pkg/security"
+)
+
+// CreateCertsCommand creates the certs command
+func CreateCertsCommand() *cobra.Command {
+	certsCmd := &cobra.Command{
+		Use:   "certs",
+		Short: "Inspect the X.509 certificates of a firmware image",
+		Long: `Inspect the X.509 certificates stored in the NVDA_ROT_CERTIFICATES,
+CERT_CHAIN_0, DIGITAL_CERT_PTR and DIGITAL_CERT_RW sections.
+
+The decoded certificates are also listed per section by sections --json.`,
+	}
+
+	var rootPath, atTime string
+	verifyCmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify the certificate chains against a trust root",
+		Long: `Verify the certificate chains of every certificate section against the
+trust roots in a PEM bundle.
+
+Within a section, each certificate that issued none of the others is checked
+as a leaf, with the rest of the section as intermediates. The command fails
+when the image holds no certificates or any chain does not verify.
+
+Examples:
+  mlx5fw-go certs verify -f firmware.bin --root nvidia-root.pem
+  mlx5fw-go certs verify -f firmware.bin --root nvidia-root.pem --time 2024-01-01T00:00:00Z --json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cliutil.ValidateFirmwarePath(firmwarePath); err != nil {
+				return err
+			}
+			return runCertsVerify(rootPath, atTime)
+		},
+	}
+	verifyCmd.Flags().StringVar(&rootPath, "root", "", "PEM file with the trusted root certificate(s) (required)")
+	verifyCmd.Flags().StringVar(&atTime, "time", "", "Verify validity at this RFC 3339 time instead of now")
+	verifyCmd.MarkFlagRequired("root")
+
+	certsCmd.AddCommand(verifyCmd)
+	return certsCmd
+}
+
+func runCertsVerify(rootPath, atTime string) error {
+	at := time.Now()
+	if atTime != "" {
+		var err error
+		if at, err = time.Parse(time.RFC3339, atTime); err != nil {
+			return merry.Prepend(err, "invalid --time")
+		}
+	}
+	roots, err := security.LoadCertPool(rootPath)
+	if err != nil {
+		return err
+	}
+
+	ctx, err := cliutil.InitializeFirmwareParser(firmwarePath, logger, parserOptions()...)
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	report, err := security.VerifyCertificateChains(ctx.Parser, roots, at)
+	if err != nil {
+		return merry.Prepend(err, "failed to verify certificates")
+	}
+
+	if jsonOutput {
+		if err := cliutil.EncodeJSONIndent(os.Stdout, report); err != nil {
+			return err
+		}
+	} else {
+		displayCertificateReport(report)
+	}
+
+	if len(report.Chains) == 0 {
+		return merry.New("no certificates found")
+	}
+	if !report.OK {
+		return merry.New("certificate verification failed")
+	}
+	return nil
+}
+
+func displayCertificateReport(report *security.CertificateReport) {
+	if len(report.Chains) == 0 {
+		fmt.Println("No certificates found")
+	}
+	for _, c := range report.Chains {
+		fmt.Printf("%-22s @ 0x%08x  %s\n", c.Section, c.Offset, c.Leaf)
+		for i, subject := range c.Path[min(1, len(c.Path)):] {
+			fmt.Printf("  %*s└ %s\n", 2*i, "", subject)
+		}
+		if c.Error != "" {
+			fmt.Printf("  Error:  %s\n", c.Error)
+		}
+		fmt.Printf("  Status: %s\n", c.Status)
+	}
+
+	fmt.Println()
+	if report.OK {
+		fmt.Println("-I- Certificate chain verification succeeded.")
+	} else {
+		fmt.Println("-E- Certificate chain verification failed.")
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/ansel1/merry/v2"
+	"github.com/spf13/cobra"
+
+	cliutil "github.com/Civil/mlx5fw-go/pkg/cliutil"
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+	"github.com/Civil/mlx5fw-go/pkg/security"
+)
+
+// checkUpgradeOptions holds the check-upgrade command flags
+type checkUpgradeOptions struct {
+	fromPath            string
+	toPath              string
+	fromVersion         string
+	fromSecurityVersion int64
+}
+
+// CreateCheckUpgradeCommand creates the check-upgrade command
+func CreateCheckUpgradeCommand() *cobra.Command {
+	var opts checkUpgradeOptions
+
+	cmd := &cobra.Command{
+		Use:   "check-upgrade",
+		Short: "Check whether an upgrade would be rejected by anti-rollback rules",
+		Long: `Check whether burning the target image over the current one would be rejected:
+because either security version is listed in the other image's
+FORBIDDEN_VERSIONS, because the security version would decrease, or because
+the PSID or PCI device ID differ.
+
+The current side is an image (--from) or a FW version (--from-version, with an
+optional --from-security-version); checks that need data a version alone does
+not provide are reported as SKIPPED. The command prints a verdict (ALLOWED or
+REJECTED) with every check, and fails when the upgrade would be rejected.
+
+Examples:
+  mlx5fw-go check-upgrade --from current.bin --to new.bin
+  mlx5fw-go check-upgrade --from-version 28.39.1002 --to new.bin --json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.toPath == "" {
+				opts.toPath = firmwarePath
+			}
+			if err := cliutil.ValidateFirmwarePath(opts.toPath); err != nil {
+				return merry.Prepend(err, "--to")
+			}
+			return runCheckUpgrade(cmd, opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.fromPath, "from", "", "Currently installed firmware image")
+	cmd.Flags().StringVar(&opts.toPath, "to", "", "Target firmware image (defaults to -f)")
+	cmd.Flags().StringVar(&opts.fromVersion, "from-version", "", "Currently installed FW version (major.minor.subminor) instead of --from")
+	cmd.Flags().Int64Var(&opts.fromSecurityVersion, "from-security-version", -1, "Currently installed security version, with --from-version")
+	cmd.MarkFlagsMutuallyExclusive("from", "from-version")
+
+	return cmd
+}
+
+// readUpgradeImage parses the image at path and returns its versions
+func readUpgradeImage(path string) (*security.ImageVersion, error) {
+	ctx, err := cliutil.InitializeFirmwareParser(path, logger, parserOptions()...)
+	if err != nil {
+		return nil, err
+	}
+	defer ctx.Close()
+
+	data, err := ctx.Reader.Bytes()
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+	version, err := security.ReadImageVersion(data, ctx.Parser)
+	if err != nil {
+		return nil, merry.Prependf(err, "%s", path)
+	}
+	return version, nil
+}
+
+func runCheckUpgrade(cmd *cobra.Command, opts checkUpgradeOptions) error {
+	var from *security.ImageVersion
+	var err error
+	switch {
+	case opts.fromPath != "":
+		if cmd.Flags().Changed("from-security-version") {
+			return merry.New("--from-security-version requires --from-version")
+		}
+		from, err = readUpgradeImage(opts.fromPath)
+	case opts.fromVersion != "":
+		if cmd.Flags().Changed("from-security-version") && (opts.fromSecurityVersion < 0 || opts.fromSecurityVersion > math.MaxUint32) {
+			return pkgerrors.InvalidParameterError("from-security-version",
+				fmt.Sprintf("%d is outside 0..%d", opts.fromSecurityVersion, uint32(math.MaxUint32)))
+		}
+		from, err = security.NewImageVersion(opts.fromVersion)
+		if err == nil && opts.fromSecurityVersion >= 0 {
+			securityVersion := uint32(opts.fromSecurityVersion)
+			from.SecurityVersion = &securityVersion
+		}
+	default:
+		return merry.New("either --from or --from-version is required")
+	}
+	if err != nil {
+		return err
+	}
+
+	to, err := readUpgradeImage(opts.toPath)
+	if err != nil {
+		return err
+	}
+
+	verdict := security.CheckUpgrade(from, to)
+	if jsonOutput {
+		if err := cliutil.EncodeJSONIndent(os.Stdout, verdict); err != nil {
+			return err
+		}
+	} else {
+		displayUpgradeVerdict(verdict)
+	}
+
+	if verdict.Verdict != security.UpgradeAllowed {
+		return merry.New("upgrade would be rejected")
+	}
+	return nil
+}
+
+func displayUpgradeVerdict(verdict *security.UpgradeVerdict) {
+	securityVersion := func(v *security.ImageVersion) string {
+		if v.SecurityVersion == nil {
+			return "N/A"
+		}
+		return fmt.Sprint(*v.SecurityVersion)
+	}
+	fmt.Printf("From: FW %s, security version %s\n", verdict.From.FWVersion, securityVersion(verdict.From))
+	fmt.Printf("To:   FW %s, security version %s\n\n", verdict.To.FWVersion, securityVersion(verdict.To))
+
+	for _, c := range verdict.Checks {
+		fmt.Printf("  %-8s %-18s %s\n", c.Status, c.Name, c.Message)
+	}
+	fmt.Printf("\nVerdict: %s\n", verdict.Verdict)
+}
@@ -6,8 +6,10 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"regexp"
 	"strconv"
@@ -18,6 +20,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/Civil/mlx5fw-go/pkg/dev/pcie"
+	"github.com/Civil/mlx5fw-go/pkg/imgfmt"
 )
 
 // small shims to access pcie's BE conversion without import cycles
@@ -1829,6 +1832,7 @@ func createDebugARCommands() *cobra.Command {
 	var offsetBytes int
 	var chunkSize int
 	var useSysfs bool
+	var dumpFormat string
 	flashDump := &cobra.Command{
 		Use:   "flash-dump",
 		Short: "Dump flash using MFPA/MFBA registers",
@@ -1870,12 +1874,22 @@ func createDebugARCommands() *cobra.Command {
 				logger.Debug("flash.mfpa.fail", zap.Error(err))
 			}
 
-			// Dump MFBA in chunks
+			format, err := imgfmt.OutputFormat(dumpFormat, outPath)
+			if err != nil {
+				return err
+			}
+
+			// Dump MFBA in chunks; text formats are encoded once the whole range is read
 			f, err := os.Create(outPath)
 			if err != nil {
 				return err
 			}
 			defer f.Close()
+			var out io.Writer = f
+			var textBuf bytes.Buffer
+			if format != imgfmt.FormatBinary {
+				out = &textBuf
+			}
 			buf := make([]byte, chunkSize)
 			const mfbaSize = 0x10c
 			start := offsetBytes
@@ -1908,13 +1922,19 @@ func createDebugARCommands() *cobra.Command {
 				// Data is the last chunkSize bytes of the register payload
 				data := payload[mfbaSize-toRead : mfbaSize]
 				copy(buf[:toRead], data)
-				if _, err := f.Write(buf[:toRead]); err != nil {
+				if _, err := out.Write(buf[:toRead]); err != nil {
 					return err
 				}
 				if logger != nil && ((off-start)&0xFFFF) == 0 {
 					logger.Info("flash.dump.progress", zap.Int("offset", off))
 				}
 			}
+			if format != imgfmt.FormatBinary {
+				// Records carry real flash addresses, so partial dumps load back in place
+				if err := imgfmt.EncodeAt(format, f, uint32(start), textBuf.Bytes()); err != nil {
+					return err
+				}
+			}
 			if logger != nil {
 				logger.Info("flash.dump.done", zap.String("out", outPath), zap.Int("size", sizeBytes))
 			}
@@ -1926,6 +1946,7 @@ func createDebugARCommands() *cobra.Command {
 	flashDump.Flags().IntVar(&offsetBytes, "offset", 0, "Starting offset in flash")
 	flashDump.Flags().IntVar(&chunkSize, "chunk", 0x40, "Chunk size in bytes (MFBA data)")
 	flashDump.Flags().BoolVar(&useSysfs, "sysfs", true, "Prefer sysfs VSEC backend when -d is a BDF")
+	flashDump.Flags().StringVar(&dumpFormat, "output-format", "", outputFormatUsage)
 	arCmd.AddCommand(flashDump)
 
 	// vsec-set: dump and optionally set VSEC address_space (guarded)
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+// jsonDiagnostics returns diags for JSON output, as an empty list rather than null
+func jsonDiagnostics(diags types.Diagnostics) types.Diagnostics {
+	if diags == nil {
+		return types.Diagnostics{}
+	}
+	return diags
+}
+
+// checkStrict fails when --strict is set and diags hold warnings or errors
+func checkStrict(diags types.Diagnostics) error {
+	if !strictMode {
+		return nil
+	}
+	failing := diags.AtLeast(types.SeverityWarning)
+	if len(failing) == 0 {
+		return nil
+	}
+	lines := make([]string, 0, len(failing))
+	for _, d := range failing {
+		lines = append(lines, "  "+d.String())
+	}
+	return fmt.Errorf("strict mode: %d parse diagnostic(s) at warning level or above:\n%s",
+		len(failing), strings.Join(lines, "\n"))
+}
@@ -11,6 +11,7 @@ import (
 
     cliutil "github.com/Civil/mlx5fw-go/pkg/cliutil"
     "github.com/Civil/mlx5fw-go/pkg/diffutil"
+    "github.com/Civil/mlx5fw-go/pkg/parser"
     "github.com/Civil/mlx5fw-go/pkg/types"
     "github.com/Civil/mlx5fw-go/pkg/bytesutil"
 )
@@ -19,8 +20,6 @@ import (
 
 // moved to bytesutil.SHA256Hex
 
-func readAll(path string) ([]byte, error) { return os.ReadFile(path) }
-
 // moved to bytesutil.DiffRaw
 
 // filter helpers are in pkg/cliutil
@@ -86,12 +85,23 @@ func CreateDiffFirmwareCommand() *cobra.Command {
             if jsonOut {
                 logger = zap.NewNop()
             }
+            if aPath == parser.StdinPath && bPath == parser.StdinPath {
+                return fmt.Errorf("only one of --a and --b can read from stdin")
+            }
+            // Open each image once so that stdin input serves both raw and section diffs
+            readerA, err := parser.NewFirmwareReader(aPath, logger)
+            if err != nil { return err }
+            defer readerA.Close()
+            readerB, err := parser.NewFirmwareReader(bPath, logger)
+            if err != nil { return err }
+            defer readerB.Close()
+
             var rep jsonReport
 
             if doRaw {
-                a, err := readAll(aPath)
+                a, err := readerA.ReadAll()
                 if err != nil { return err }
-                b, err := readAll(bPath)
+                b, err := readerB.ReadAll()
                 if err != nil { return err }
                 rep.Raw.Enabled = true
                 rep.Raw.SizeA = len(a)
@@ -133,12 +143,10 @@ func CreateDiffFirmwareCommand() *cobra.Command {
 
             if doSections {
                 if !jsonOut { fmt.Printf("== SECTIONS ==\n") }
-                ctxA, err := cliutil.InitializeFirmwareParser(aPath, logger)
+                ctxA, err := cliutil.InitializeFirmwareParserFromReader(readerA, logger, parserOptions()...)
                 if err != nil { return err }
-                defer ctxA.Close()
-                ctxB, err := cliutil.InitializeFirmwareParser(bPath, logger)
+                ctxB, err := cliutil.InitializeFirmwareParserFromReader(readerB, logger, parserOptions()...)
                 if err != nil { return err }
-                defer ctxB.Close()
 
                 ga := cliutil.CollectSectionsByType(ctxA)
                 gb := cliutil.CollectSectionsByType(ctxB)
@@ -279,8 +287,8 @@ func CreateDiffFirmwareCommand() *cobra.Command {
         },
     }
 
-    cmd.Flags().StringVar(&aPath, "a", "", "first firmware file (required)")
-    cmd.Flags().StringVar(&bPath, "b", "", "second firmware file (required)")
+    cmd.Flags().StringVar(&aPath, "a", "", "first firmware file, - for stdin (required)")
+    cmd.Flags().StringVar(&bPath, "b", "", "second firmware file, - for stdin (required)")
     cmd.Flags().BoolVar(&doRaw, "raw", true, "perform raw byte diff")
     cmd.Flags().BoolVar(&doSections, "sections", true, "perform section-aware diff")
     cmd.Flags().IntVar(&maxSpans, "max-spans", 40, "maximum raw diff spans to show")
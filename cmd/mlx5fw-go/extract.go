@@ -5,6 +5,7 @@ import (
 	"go.uber.org/zap"
 
 	cliutil "github.com/Civil/mlx5fw-go/pkg/cliutil"
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
 	"github.com/Civil/mlx5fw-go/pkg/extract"
 )
 
@@ -42,7 +43,7 @@ func runExtractCommandCore(cmd *cobra.Command, args []string, opts ExtractOption
 		zap.Bool("exportJSON", opts.ExportJSON))
 
 	// Initialize firmware parser
-	ctx, err := cliutil.InitializeFirmwareParser(firmwarePath, logger)
+	ctx, err := cliutil.InitializeFirmwareParserForPSID(firmwarePath, firmwarePSID, logger, parserOptions()...)
 	if err != nil {
 		return err
 	}
@@ -55,9 +56,15 @@ func runExtractCommandCore(cmd *cobra.Command, args []string, opts ExtractOption
 		IncludeMetadata: opts.IncludeMetadata,
 		RemoveCRC:       opts.RemoveCRC,
 		KeepBinary:      opts.KeepBinary,
+		Encryption:      ctx.Encryption,
+	}
+
+	fwParser, ok := ctx.Parser.(extract.FirmwareParser)
+	if !ok {
+		return pkgerrors.NotSupportedError("extract of " + ctx.Parser.GetFormat().String() + " firmware")
 	}
 
 	// Create and run extractor
-	extractor := extract.New(ctx.Parser, logger, extractOpts)
+	extractor := extract.New(fwParser, logger, extractOpts)
 	return extractor.Extract()
 }
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+
+	cliutil "github.com/Civil/mlx5fw-go/pkg/cliutil"
+)
+
+// ImageQueryJSON is the query output of one failsafe image copy
+type ImageQueryJSON struct {
+	Role   string           `json:"role"`
+	Offset uint32           `json:"offset"`
+	Query  *QueryJSONOutput `json:"query,omitempty"`
+	Error  string           `json:"error,omitempty"`
+}
+
+// ImageSectionsJSON is the sections output of one failsafe image copy
+type ImageSectionsJSON struct {
+	Role     string      `json:"role"`
+	Offset   uint32      `json:"offset"`
+	Sections *JSONOutput `json:"sections,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}
+
+// ImagesJSONOutput is the --json document for query/sections with --image
+type ImagesJSONOutput[T any] struct {
+	Images []T                   `json:"images"`
+	Report []cliutil.ImageStatus `json:"report"`
+}
+
+// openSelectedImages parses all image copies and returns those matching --image
+func openSelectedImages() (*cliutil.ImageSet, []*cliutil.ImageContext, error) {
+	set, err := cliutil.InitializeImageParsers(firmwarePath, firmwarePSID, logger, parserOptions()...)
+	if err != nil {
+		return nil, nil, err
+	}
+	selected, err := set.Select(firmwareImage)
+	if err != nil {
+		set.Close()
+		return nil, nil, err
+	}
+	return set, selected, nil
+}
+
+// selectedImagesError reports copies that could not be parsed
+func selectedImagesError(selected []*cliutil.ImageContext) error {
+	for _, img := range selected {
+		if img.Err != nil {
+			return fmt.Errorf("%s image at 0x%08x: %w", img.Role, img.Offset, img.Err)
+		}
+	}
+	return nil
+}
+
+// selectedImagesResult is selectedImagesError followed by the --strict check
+// of every parsed copy
+func selectedImagesResult(selected []*cliutil.ImageContext) error {
+	if err := selectedImagesError(selected); err != nil {
+		return err
+	}
+	for _, img := range selected {
+		if err := checkStrict(img.Ctx.Parser.Diagnostics()); err != nil {
+			return fmt.Errorf("%s image at 0x%08x: %w", img.Role, img.Offset, err)
+		}
+	}
+	return nil
+}
+
+func printImageHeader(img *cliutil.ImageContext) {
+	fmt.Printf("Image:                 %s (0x%08x)\n", img.Role, img.Offset)
+}
+
+func runQueryImagesCommand(fullOutput bool, jsonOutput bool) error {
+	set, selected, err := openSelectedImages()
+	if err != nil {
+		return err
+	}
+	defer set.Close()
+
+	report := cliutil.AssessImages(set)
+
+	if jsonOutput {
+		out := ImagesJSONOutput[ImageQueryJSON]{Report: report}
+		for _, img := range selected {
+			entry := ImageQueryJSON{Role: img.Role, Offset: img.Offset}
+			if img.Err != nil {
+				entry.Error = img.Err.Error()
+			} else if info, err := img.Ctx.Parser.Query(); err != nil {
+				entry.Error = err.Error()
+			} else {
+				entry.Query = convertToQueryJSON(info)
+				entry.Query.Diagnostics = jsonDiagnostics(img.Ctx.Parser.Diagnostics())
+			}
+			out.Images = append(out.Images, entry)
+		}
+		if err := outputJSON(out); err != nil {
+			return err
+		}
+		return selectedImagesResult(selected)
+	}
+
+	for _, img := range selected {
+		printImageHeader(img)
+		if img.Err != nil {
+			fmt.Printf("Error:                 %v\n\n", img.Err)
+			continue
+		}
+		info, err := img.Ctx.Parser.Query()
+		if err != nil {
+			return fmt.Errorf("failed to query %s image: %w", img.Role, err)
+		}
+		if err := displayQueryInfo(info, nil, fullOutput, false); err != nil {
+			return err
+		}
+		fmt.Println()
+	}
+	fmt.Print(cliutil.FormatImageReport(report))
+
+	return selectedImagesResult(selected)
+}
+
+func runSectionsImagesCommand(showContent bool, outputFormat string) error {
+	set, selected, err := openSelectedImages()
+	if err != nil {
+		return err
+	}
+	defer set.Close()
+
+	report := cliutil.AssessImages(set)
+
+	if outputFormat == "json" {
+		out := ImagesJSONOutput[ImageSectionsJSON]{Report: report}
+		for _, img := range selected {
+			entry := ImageSectionsJSON{Role: img.Role, Offset: img.Offset}
+			if img.Err != nil {
+				entry.Error = img.Err.Error()
+			} else {
+				p := img.Ctx.Parser
+				sections := buildSectionsJSON(p.GetFormat(), p.GetSections(), p)
+				entry.Sections = &sections
+			}
+			out.Images = append(out.Images, entry)
+		}
+		if err := outputJSON(out); err != nil {
+			return err
+		}
+		return selectedImagesResult(selected)
+	}
+
+	var failed error
+	for _, img := range selected {
+		printImageHeader(img)
+		if img.Err != nil {
+			fmt.Printf("Error:                 %v\n\n", img.Err)
+			continue
+		}
+		p := img.Ctx.Parser
+		// Keep going so the remaining copies and the report are still shown
+		if err := displaySections(img.Ctx.FirmwarePath, p.GetFormat(), p.GetSections(), p, showContent, ""); err != nil && failed == nil {
+			failed = fmt.Errorf("%s image: %w", img.Role, err)
+		}
+		fmt.Println()
+	}
+	fmt.Print(cliutil.FormatImageReport(report))
+
+	if failed != nil {
+		return failed
+	}
+	return selectedImagesResult(selected)
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/ansel1/merry/v2"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	cliutil "github.com/Civil/mlx5fw-go/pkg/cliutil"
+	"github.com/Civil/mlx5fw-go/pkg/compressutil"
+	"github.com/Civil/mlx5fw-go/pkg/diffutil"
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+	"github.com/Civil/mlx5fw-go/pkg/imgfmt"
+	"github.com/Civil/mlx5fw-go/pkg/ini"
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/parser/fs4"
+	"github.com/Civil/mlx5fw-go/pkg/section"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+	"github.com/Civil/mlx5fw-go/pkg/types/sections"
+)
+
+// iniEditOptions holds the flags of the ini commands that modify the image
+type iniEditOptions struct {
+	updateHashes bool
+	outputFile   string
+	outputFormat string
+}
+
+// iniChange is the JSON output of ini set and ini edit
+type iniChange struct {
+	Offset  uint64 `json:"offset"`
+	OldSize uint32 `json:"old_size"`
+	NewSize int    `json:"new_size"`
+	Diff    string `json:"diff"`
+}
+
+// CreateINICommand creates the ini command and its subcommands
+func CreateINICommand() *cobra.Command {
+	iniCmd := &cobra.Command{
+		Use:   "ini",
+		Short: "Read and edit the DBG_FW_INI configuration",
+		Long: `Read and edit the firmware INI stored, zlib compressed, in DBG_FW_INI.
+
+Keys are named [section.]key; without a section the key must be unique. Edits
+keep the rest of the text as it is, print a diff of the INI, recompress it and
+replace DBG_FW_INI, relocating the sections after it when its size changes.`,
+	}
+
+	iniCmd.AddCommand(createINIGetCommand())
+	iniCmd.AddCommand(createINISetCommand())
+	iniCmd.AddCommand(createINIEditCommand())
+	return iniCmd
+}
+
+// createINIGetCommand creates ini get
+func createINIGetCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "get [[section.]key]",
+		Short: "Print the INI or the value of one key",
+		Args:  cobra.MaximumNArgs(1),
+		Long: `Print the whole INI, or the value of one key.
+
+Examples:
+  mlx5fw-go ini get -f firmware.bin
+  mlx5fw-go ini get -f firmware.bin HCA.num_pfs`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cliutil.ValidateFirmwarePath(firmwarePath); err != nil {
+				return err
+			}
+			ctx, _, text, err := openDBGFwIni()
+			if err != nil {
+				return err
+			}
+			defer ctx.Close()
+
+			f, err := ini.Parse(text)
+			if err != nil {
+				return err
+			}
+			if len(args) == 0 {
+				if jsonOutput {
+					return cliutil.EncodeJSONIndent(os.Stdout, f.Keys())
+				}
+				fmt.Print(string(text))
+				return nil
+			}
+
+			key, err := lookupINIKey(f, args[0])
+			if err != nil {
+				return err
+			}
+			if jsonOutput {
+				return cliutil.EncodeJSONIndent(os.Stdout, key)
+			}
+			fmt.Println(key.Value)
+			return nil
+		},
+	}
+}
+
+// createINISetCommand creates ini set
+func createINISetCommand() *cobra.Command {
+	var opts iniEditOptions
+
+	cmd := &cobra.Command{
+		Use:   "set [section.]key=value...",
+		Short: "Set INI keys and write the modified image",
+		Args:  cobra.MinimumNArgs(1),
+		Long: `Set one or more INI keys. Existing keys keep their place and spacing; new
+keys are added at the end of their section, and new sections at the end of
+the INI.
+
+Examples:
+  mlx5fw-go ini set -f firmware.bin HCA.num_pfs=2 -o modified.bin`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cliutil.ValidateFirmwarePath(firmwarePath); err != nil {
+				return err
+			}
+			return runINIEditCommand(opts, func(text []byte) ([]byte, error) {
+				f, err := ini.Parse(text)
+				if err != nil {
+					return nil, err
+				}
+				for _, arg := range args {
+					name, value, ok := strings.Cut(arg, "=")
+					if !ok {
+						return nil, pkgerrors.InvalidParameterError("assignment", fmt.Sprintf("%q is not key=value", arg))
+					}
+					sectionName, key, err := splitINIKey(f, strings.TrimSpace(name))
+					if err != nil {
+						return nil, err
+					}
+					if err := f.Set(sectionName, key, strings.TrimSpace(value)); err != nil {
+						return nil, err
+					}
+				}
+				return f.Bytes(), nil
+			})
+		},
+	}
+
+	addINIEditFlags(cmd, &opts)
+	return cmd
+}
+
+// createINIEditCommand creates ini edit
+func createINIEditCommand() *cobra.Command {
+	var opts iniEditOptions
+
+	cmd := &cobra.Command{
+		Use:   "edit",
+		Short: "Edit the INI in $EDITOR and write the modified image",
+		Long: `Open the INI in $EDITOR (vi when unset). Saving an unchanged INI leaves the
+image alone.
+
+Examples:
+  EDITOR=nano mlx5fw-go ini edit -f firmware.bin -o modified.bin`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cliutil.ValidateFirmwarePath(firmwarePath); err != nil {
+				return err
+			}
+			return runINIEditCommand(opts, editINIText)
+		},
+	}
+
+	addINIEditFlags(cmd, &opts)
+	return cmd
+}
+
+// addINIEditFlags adds the flags the modifying ini commands share
+func addINIEditFlags(cmd *cobra.Command, opts *iniEditOptions) {
+	cmd.Flags().BoolVar(&opts.updateHashes, "update-hashes", false, "Regenerate the HASHES_TABLE digests of the modified image")
+	cmd.Flags().StringVarP(&opts.outputFile, "output", "o", "", "Output firmware file (required)")
+	cmd.Flags().StringVar(&opts.outputFormat, "output-format", "", outputFormatUsage)
+	cmd.MarkFlagRequired("output")
+}
+
+// splitINIKey splits [section.]key; a key without a section takes the section
+// of the only key of that name
+func splitINIKey(f *ini.File, name string) (string, string, error) {
+	if sectionName, key, ok := strings.Cut(name, "."); ok {
+		return sectionName, key, nil
+	}
+	key, err := lookupINIKey(f, name)
+	if err != nil {
+		return "", "", merry.Prepend(err, "name new keys as section.key")
+	}
+	return key.Section, key.Name, nil
+}
+
+// lookupINIKey returns the single key named [section.]key
+func lookupINIKey(f *ini.File, name string) (ini.Key, error) {
+	sectionName, key, _ := strings.Cut(name, ".")
+	if key == "" {
+		sectionName, key = "", name
+	}
+	keys := f.Lookup(sectionName, key)
+	switch len(keys) {
+	case 0:
+		return ini.Key{}, merry.Wrap(pkgerrors.ErrSectionNotFound, merry.WithMessagef("no INI key %q", name))
+	case 1:
+		return keys[0], nil
+	default:
+		return ini.Key{}, pkgerrors.InvalidParameterError("key",
+			fmt.Sprintf("%q is set %d times, name it as section.key", name, len(keys)))
+	}
+}
+
+// editINIText lets the user edit text in $EDITOR
+func editINIText(text []byte) ([]byte, error) {
+	tmp, err := os.CreateTemp("", "mlx5fw-*.ini")
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(text); err != nil {
+		tmp.Close()
+		return nil, merry.Wrap(err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+	// EDITOR may carry arguments, as in "code --wait"
+	fields := strings.Fields(editor)
+	editorCmd := exec.Command(fields[0], append(fields[1:], tmp.Name())...)
+	editorCmd.Stdin, editorCmd.Stdout, editorCmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := editorCmd.Run(); err != nil {
+		return nil, merry.Prepend(err, "editor "+editor+" failed")
+	}
+
+	edited, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+	// Editors that only add or drop the final newline leave the INI unchanged
+	if bytes.Equal(bytes.TrimRight(edited, "\n"), bytes.TrimRight(text, "\n")) {
+		return text, nil
+	}
+	// Reject text the INI parser cannot read back
+	if _, err := ini.Parse(edited); err != nil {
+		return nil, err
+	}
+	return edited, nil
+}
+
+// openDBGFwIni parses the firmware and returns its DBG_FW_INI section and the
+// decompressed INI
+func openDBGFwIni() (*cliutil.ParserContext, interfaces.CompleteSectionInterface, []byte, error) {
+	ctx, err := cliutil.InitializeFirmwareParser(firmwarePath, logger, parserOptions()...)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if ctx.Parser.IsEncrypted() {
+		ctx.Close()
+		return nil, nil, nil, pkgerrors.NotSupportedError("reading the INI of an encrypted image without --image-key")
+	}
+
+	iniSections := ctx.Parser.GetSections()[types.SectionTypeDbgFWINI]
+	if len(iniSections) == 0 {
+		ctx.Close()
+		return nil, nil, nil, pkgerrors.SectionNotFoundError("DBG_FW_INI", 0)
+	}
+	iniSection := iniSections[0]
+
+	compressed, err := ctx.Reader.ReadSection(int64(iniSection.Offset()), iniSection.Size())
+	if err != nil {
+		ctx.Close()
+		return nil, nil, nil, merry.Prepend(err, "failed to read DBG_FW_INI")
+	}
+	text, err := compressutil.DecompressZlib(compressed)
+	if err != nil {
+		ctx.Close()
+		return nil, nil, nil, merry.Prepend(err, "failed to decompress DBG_FW_INI")
+	}
+	return ctx, iniSection, text, nil
+}
+
+func runINIEditCommand(opts iniEditOptions, edit func(text []byte) ([]byte, error)) error {
+	outputFormat, err := imgfmt.OutputFormat(opts.outputFormat, opts.outputFile)
+	if err != nil {
+		return err
+	}
+
+	ctx, iniSection, text, err := openDBGFwIni()
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	// The replacer only knows the FS4/FS5 layout
+	fwParser, ok := ctx.Parser.(*fs4.Parser)
+	if !ok {
+		return pkgerrors.NotSupportedError("editing the INI of " + ctx.Parser.GetFormat().String() + " firmware")
+	}
+
+	newText, err := edit(text)
+	if err != nil {
+		return err
+	}
+	diff := diffutil.UnifiedTextDiff("DBG_FW_INI", "DBG_FW_INI (modified)", string(text), string(newText), 3)
+	if diff == "" {
+		logger.Info("INI unchanged, not writing an image")
+		return nil
+	}
+
+	// Recompress the way the section is parsed and pad to whole dwords
+	compressed, err := (&sections.DBGFwIniSection{IniData: newText}).Marshal()
+	if err != nil {
+		return merry.Wrap(err)
+	}
+	if pad := len(compressed) % section.DwordSize; pad != 0 {
+		compressed = append(compressed, make([]byte, section.DwordSize-pad)...)
+	}
+	// An in-section CRC takes the last dword, which must not hold the zlib stream
+	if iniSection.CRCType() == types.CRCInSection {
+		compressed = append(compressed, make([]byte, section.DwordSize)...)
+	}
+	logger.Info("Recompressed INI",
+		zap.Uint64("offset", iniSection.Offset()),
+		zap.Uint32("size", iniSection.Size()),
+		zap.Int("newSize", len(compressed)))
+
+	// The replacer works on its own copy of the image
+	data, err := ctx.Reader.Bytes()
+	if err != nil {
+		return merry.Wrap(err)
+	}
+	newData, err := section.NewReplacer(fwParser, data, logger).ReplaceSection(iniSection, compressed)
+	if err != nil {
+		return merry.Wrap(err)
+	}
+
+	if err := refreshImageHashes(newData, fwParser, opts.updateHashes); err != nil {
+		return err
+	}
+	warnSignedImage(fwParser)
+	if err := writeModifiedImage(ctx, newData, opts.outputFile, outputFormat); err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return cliutil.EncodeJSONIndent(os.Stdout, iniChange{
+			Offset:  iniSection.Offset(),
+			OldSize: iniSection.Size(),
+			NewSize: len(compressed),
+			Diff:    diff,
+		})
+	}
+	fmt.Print(diff)
+	return nil
+}
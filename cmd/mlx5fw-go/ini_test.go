@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/Civil/mlx5fw-go/pkg/parser"
+	"github.com/Civil/mlx5fw-go/pkg/parser/fs4"
+	"github.com/Civil/mlx5fw-go/pkg/section"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+	"github.com/Civil/mlx5fw-go/pkg/types/sections"
+)
+
+const (
+	testINIITOCAddr = 0x2000
+	testINIAddr     = 0x4000
+)
+
+// createINIImage builds a 32MB FS4 image whose ITOC lists one DBG_FW_INI
+// section at testINIAddr holding text, with its CRC in the section
+func createINIImage(t *testing.T, text []byte) []byte {
+	t.Helper()
+	crc := parser.NewCRCCalculator()
+	data := bytes.Repeat([]byte{0xFF}, section.FirmwareSize32MB)
+	binary.BigEndian.PutUint64(data, types.MagicPattern)
+	entry := data[types.HWPointersOffsetFromMagic+2*8:][:8]
+	binary.BigEndian.PutUint32(entry, testINIITOCAddr)
+	binary.BigEndian.PutUint16(entry[4:], 0)
+	binary.BigEndian.PutUint16(entry[6:], crc.CalculateHardwareCRC(entry[:6]))
+
+	header := data[testINIITOCAddr : testINIITOCAddr+types.ITOCHeaderSize]
+	clear(header)
+	binary.BigEndian.PutUint32(header, types.ITOCSignature)
+	fs4.UpdateITOCHeaderCRC(header, crc)
+
+	compressed, err := (&sections.DBGFwIniSection{IniData: text}).Marshal()
+	require.NoError(t, err)
+	size := uint32(len(compressed)+section.DwordSize-1)&^(section.DwordSize-1) + section.DwordSize
+	iniData := data[testINIAddr : testINIAddr+size]
+	clear(iniData)
+	copy(iniData, compressed)
+	binary.BigEndian.PutUint32(iniData[size-4:], uint32(crc.CalculateImageCRC(iniData, int(size/4)-1)))
+
+	itocEntry := &types.ITOCEntry{Type: uint8(types.SectionTypeDbgFWINI), FlashAddrDwords: testINIAddr, CRCField: uint8(types.CRCInSection)}
+	itocEntry.SetSize(size)
+	raw, err := itocEntry.Marshal()
+	require.NoError(t, err)
+	binary.BigEndian.PutUint16(raw[30:], crc.CalculateImageCRC(raw[:28], 7))
+	copy(data[testINIITOCAddr+types.ITOCHeaderSize:], raw)
+	return data
+}
+
+func TestRunINIEditCommand_InSectionCRC(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	dir := t.TempDir()
+	firmwarePath = filepath.Join(dir, "fw.bin")
+	t.Cleanup(func() { firmwarePath = "" })
+	require.NoError(t, os.WriteFile(firmwarePath, createINIImage(t, []byte("[HW]\nkey=1\n")), 0o600))
+
+	// Both a zlib stream ending on a dword boundary, which left no room for
+	// the CRC, and one that needs padding must survive the edit
+	found := map[bool][]byte{}
+	for i := 0; len(found) < 2; i++ {
+		text := []byte(fmt.Sprintf("[HW]\nkey=%d\n", i))
+		compressed, err := (&sections.DBGFwIniSection{IniData: text}).Marshal()
+		require.NoError(t, err)
+		aligned := len(compressed)%section.DwordSize == 0
+		if _, ok := found[aligned]; !ok {
+			found[aligned] = text
+		}
+	}
+
+	for aligned, text := range found {
+		t.Run(fmt.Sprintf("aligned=%v", aligned), func(t *testing.T) {
+			output := filepath.Join(dir, fmt.Sprintf("out-%v.bin", aligned))
+			err := runINIEditCommand(iniEditOptions{outputFile: output}, func([]byte) ([]byte, error) { return text, nil })
+			require.NoError(t, err)
+
+			saved := firmwarePath
+			firmwarePath = output
+			defer func() { firmwarePath = saved }()
+			ctx, iniSection, got, err := openDBGFwIni()
+			require.NoError(t, err)
+			defer ctx.Close()
+			assert.Equal(t, text, got)
+			status, err := ctx.Parser.VerifySectionNew(iniSection)
+			require.NoError(t, err)
+			assert.Equal(t, "OK", status)
+		})
+	}
+}
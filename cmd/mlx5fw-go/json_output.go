@@ -32,6 +32,9 @@ type QueryJSONOutput struct {
 	SecurityVer         int           `json:"security_version"`
 	ActivationMethod    string        `json:"activation_method,omitempty"`
 	DefaultUpdateMethod string        `json:"default_update_method"`
+	// Diagnostics are the parse diagnostics of image files (empty for devices),
+	// always present as in the sections output
+	Diagnostics types.Diagnostics `json:"diagnostics"`
 }
 
 // UIDInfo represents UID information in JSON
@@ -67,6 +70,7 @@ type SectionInfoJSON struct {
 // convertToQueryJSON converts FirmwareInfo to JSON output structure
 func convertToQueryJSON(info *interfaces.FirmwareInfo) *QueryJSONOutput {
 	output := &QueryJSONOutput{
+		Diagnostics:         types.Diagnostics{},
 		ImageType:           info.Format,
 		FWVersion:           info.FWVersion,
 		FWReleaseDate:       info.FWReleaseDate,
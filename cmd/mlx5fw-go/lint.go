@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ansel1/merry/v2"
+	"github.com/spf13/cobra"
+
+	cliutil "github.com/Civil/mlx5fw-go/pkg/cliutil"
+	"github.com/Civil/mlx5fw-go/pkg/lint"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+// CreateLintCommand creates the lint command
+func CreateLintCommand() *cobra.Command {
+	var disable []string
+	var failOn string
+	var listRules bool
+
+	cmd := &cobra.Command{
+		Use:   "lint",
+		Short: "Check an image layout for malformed or adversarial entries",
+		Long: `Check the layout of an FS4/FS5 image against a set of named rules.
+
+The parser recovers from what it can; lint instead reads the HW pointers and
+the ITOC/DTOC entries as stored and reports bad CRCs, unterminated TOCs,
+zero-size, out-of-bounds and wrapping entries, sections overlapping each
+other, the HW pointers or a TOC, and duplicate or unknown section types.
+Every rule has a severity; the command fails when a finding is at or above
+--fail-on. Use --list-rules to see the rules.
+
+Examples:
+  mlx5fw-go lint -f firmware.bin
+  mlx5fw-go lint -f firmware.bin --fail-on warning --json
+  mlx5fw-go lint -f firmware.bin --disable DUPLICATE_TYPE,UNKNOWN_TYPE
+  mlx5fw-go lint --list-rules`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if listRules {
+				return runLintListRules()
+			}
+			if err := cliutil.ValidateFirmwarePath(firmwarePath); err != nil {
+				return err
+			}
+			return runLintCommand(disable, failOn)
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&disable, "disable", nil, "Rules to skip (comma-separated or repeated)")
+	cmd.Flags().StringVar(&failOn, "fail-on", "error", "Lowest finding severity that fails the command: info, warning or error")
+	cmd.Flags().BoolVar(&listRules, "list-rules", false, "List the rules and exit")
+
+	return cmd
+}
+
+func runLintListRules() error {
+	rules := lint.Rules()
+	if jsonOutput {
+		return cliutil.EncodeJSONIndent(os.Stdout, rules)
+	}
+	for _, r := range rules {
+		fmt.Printf("%-18s %-8s %s\n", r.Name, r.Severity, r.Description)
+	}
+	return nil
+}
+
+func runLintCommand(disable []string, failOn string) error {
+	threshold, err := types.ParseSeverity(failOn)
+	if err != nil {
+		return merry.Wrap(err)
+	}
+
+	ctx, err := cliutil.InitializeFirmwareParser(firmwarePath, logger, parserOptions()...)
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	data, err := ctx.Reader.Bytes()
+	if err != nil {
+		return merry.Wrap(err)
+	}
+	report, err := lint.Lint(data, ctx.Parser, disable)
+	if err != nil {
+		return err
+	}
+	failing := report.Findings.AtLeast(threshold)
+
+	if jsonOutput {
+		if err := cliutil.EncodeJSONIndent(os.Stdout, report); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("%s image, %d rules checked", report.Format, len(report.Rules))
+		if report.Encrypted {
+			fmt.Print(" (encrypted, ITOC not checked)")
+		}
+		fmt.Print("\n\n")
+		for _, d := range report.Findings {
+			fmt.Printf("  %s\n", d)
+		}
+		if len(report.Findings) > 0 {
+			fmt.Println()
+		}
+		if len(failing) == 0 {
+			fmt.Printf("-I- Lint passed with %d finding(s).\n", len(report.Findings))
+		} else {
+			fmt.Printf("-E- Lint failed: %d finding(s) at %s or above.\n", len(failing), threshold)
+		}
+	}
+
+	if len(failing) > 0 {
+		return merry.New("lint failed")
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"go.uber.org/zap"
 
 	cliutil "github.com/Civil/mlx5fw-go/pkg/cliutil"
+	"github.com/Civil/mlx5fw-go/pkg/security"
 )
 
 var (
@@ -21,8 +22,26 @@ var (
 	quietLogging   bool
 	deviceBDF      string
 	mstPath        string
+	firmwarePSID   string
+	firmwareImage  string
+	strictMode     bool
+	imageKeyPath   string
+
+	// imageKey is the content of the --image-key file
+	imageKey []byte
 )
 
+// parserOptions returns the cliutil parser options selected by the global flags
+func parserOptions() []cliutil.ParserOption {
+	return []cliutil.ParserOption{cliutil.WithDecryptionKey(imageKey)}
+}
+
+// strictUsage is the help text of the --strict flag shared by query and sections
+const strictUsage = "Fail when parsing reports warnings (e.g. alternate ITOC location, missing BOOT2), for CI gating"
+
+// outputFormatUsage is the help text of the --output-format flag shared by image writers
+const outputFormatUsage = "Output image format: bin, ihex or srec (default: from the output file extension, else bin)"
+
 func main() {
 	// Parse command line args early to check for JSON/quiet flags
 	// We need to do this before logger initialization
@@ -54,19 +73,26 @@ This tool provides functionality to analyze Mellanox firmware files including:
 - Displaying section contents in human-readable format
 - Parsing ITOC (Image Table of Contents) and DTOC (Device Table of Contents)
 
+Images compressed with gzip, xz, zstd or bzip2 are decompressed transparently.
+
 Example usage:
   mlx5fw-go sections -f firmware.bin                # List all sections
   mlx5fw-go sections -f firmware.bin -c             # Show section contents
-  mlx5fw-go sections -f firmware.bin -v             # Enable verbose logging`,
+  mlx5fw-go sections -f firmware.bin -v             # Enable verbose logging
+  cat firmware.bin | mlx5fw-go query -f -           # Read the image from stdin
+  mlx5fw-go query -f firmware.bin.xz                # Query a compressed image
+  mlx5fw-go query -f fw.mfa2 --psid MT_0000000222   # Query one image of an MFA2 archive
+  mlx5fw-go query -f flash_dump.bin --image all     # Query both failsafe copies of a flash dump`,
 		SilenceUsage:  true,
 		SilenceErrors: true,
 	}
 
 	// Add global flags
 	rootCmd.PersistentFlags().BoolVarP(&verboseLogging, "verbose", "v", false, "Enable verbose logging")
-	rootCmd.PersistentFlags().StringVarP(&firmwarePath, "file", "f", "", "Firmware file path (when using file input)")
+	rootCmd.PersistentFlags().StringVarP(&firmwarePath, "file", "f", "", "Firmware file path (when using file input; use - for stdin)")
 	rootCmd.PersistentFlags().BoolVar(&jsonOutput, "json", false, "Output in JSON format")
 	rootCmd.PersistentFlags().BoolVarP(&quietLogging, "quiet", "q", false, "Quiet mode: errors only")
+	rootCmd.PersistentFlags().StringVar(&imageKeyPath, "image-key", "", "AES-XTS key file (raw or hex) to decrypt encrypted images and re-encrypt modified ones; the encryption layout is an undocumented assumption")
 	if DevSupported {
 		rootCmd.PersistentFlags().StringVarP(&deviceBDF, "device", "d", "", "PCI device BDF (e.g., 0000:07:00.0)")
 		rootCmd.PersistentFlags().StringVar(&mstPath, "mst-path", "", "Direct MST device path (e.g., /dev/mst/0000:07:00.0_pciconf0)")
@@ -88,6 +114,14 @@ Example usage:
 			logger = logger.With(zap.String("firmware", firmwarePath))
 		}
 
+		if imageKeyPath != "" {
+			key, err := security.LoadKeyFile(imageKeyPath)
+			if err != nil {
+				return err
+			}
+			imageKey = key
+		}
+
 		return nil
 	}
 
@@ -101,6 +135,9 @@ Example usage:
 	// Add flags
 	var showContent bool
 	sectionsCmd.Flags().BoolVarP(&showContent, "content", "c", false, "Show section content")
+	sectionsCmd.Flags().StringVar(&firmwarePSID, "psid", "", "Select the image by PSID when -f is an MFA2 archive")
+	sectionsCmd.Flags().StringVar(&firmwareImage, "image", "", "Failsafe image copy of a flash dump: primary, secondary or all (adds a validity report)")
+	sectionsCmd.Flags().BoolVar(&strictMode, "strict", false, strictUsage)
 
 	// Store the flag value for use in command
 	sectionsCmd.RunE = func(cmd *cobra.Command, args []string) error {
@@ -126,6 +163,9 @@ Example usage:
 	// Add flags
 	var fullOutput bool
 	queryCmd.Flags().BoolVar(&fullOutput, "full", false, "Show full query output")
+	queryCmd.Flags().StringVar(&firmwarePSID, "psid", "", "Select the image by PSID when -f is an MFA2 archive")
+	queryCmd.Flags().StringVar(&firmwareImage, "image", "", "Failsafe image copy of a flash dump: primary, secondary or all (adds a validity report)")
+	queryCmd.Flags().BoolVar(&strictMode, "strict", false, strictUsage)
 
 	// Store the flag value for use in command
 	queryCmd.RunE = func(cmd *cobra.Command, args []string) error {
@@ -169,6 +209,7 @@ Example usage:
 
 	// Add keep-binary flag
 	extractCmd.Flags().Bool("keep-binary", false, "Keep binary representation alongside JSON (by default, only JSON is saved for parsed sections)")
+	extractCmd.Flags().StringVar(&firmwarePSID, "psid", "", "Select the image by PSID when -f is an MFA2 archive")
 
 	extractCmd.RunE = func(cmd *cobra.Command, args []string) error {
 		if err := cliutil.ValidateFirmwarePath(firmwarePath); err != nil {
@@ -190,15 +231,21 @@ If the section size changes, the firmware structure will be adjusted accordingly
 
 Examples:
   mlx5fw-go replace-section -f firmware.bin DBG_FW_INI -r new_ini.txt -o modified.bin
-  mlx5fw-go replace-section -f firmware.bin ITOC:0 -r new_itoc.bin -o modified.bin`,
+  mlx5fw-go replace-section -f firmware.bin ITOC:0 -r new_itoc.bin -o modified.bin
+  mlx5fw-go replace-section -f firmware.hex DBG_FW_INI -r new_ini.txt -o modified.srec
+  mlx5fw-go replace-section -f firmware.bin DBG_FW_INI -r new_ini.txt -o modified.bin --update-hashes`,
 		Args: cobra.ExactArgs(1),
 	}
 
 	// Add flags
 	var replacementFile string
 	var outputFile string
+	var replaceOutputFormat string
+	var replaceUpdateHashes bool
 	replaceSectionCmd.Flags().StringVarP(&replacementFile, "replacement", "r", "", "File containing replacement data (required)")
 	replaceSectionCmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output firmware file (required)")
+	replaceSectionCmd.Flags().StringVar(&replaceOutputFormat, "output-format", "", outputFormatUsage)
+	replaceSectionCmd.Flags().BoolVar(&replaceUpdateHashes, "update-hashes", false, "Regenerate the HASHES_TABLE digests of the output image")
 	replaceSectionCmd.MarkFlagRequired("replacement")
 	replaceSectionCmd.MarkFlagRequired("output")
 
@@ -210,7 +257,7 @@ Examples:
 		if err != nil {
 			return err
 		}
-		return runReplaceSectionCommand(cmd, args, sectionName, sectionID, replacementFile, outputFile)
+		return runReplaceSectionCommand(cmd, args, sectionName, sectionID, replacementFile, outputFile, replaceOutputFormat, replaceUpdateHashes)
 	}
 
 	rootCmd.AddCommand(replaceSectionCmd)
@@ -227,15 +274,18 @@ and proper section placement according to the original firmware layout.
 
 Examples:
   mlx5fw-go reassemble -i extracted_fw -o reassembled.bin
-  mlx5fw-go reassemble -i extracted_fw -o reassembled.bin --verify-crc`,
+  mlx5fw-go reassemble -i extracted_fw -o reassembled.bin --verify-crc
+  mlx5fw-go reassemble -i extracted_fw -o reassembled.hex --output-format ihex`,
 	}
 
 	// Add flags
 	var reassembleInputDir string
 	var reassembleOutputFile string
 	var reassembleVerifyCRC bool
+	var reassembleOutputFormat string
 	reassembleCmd.Flags().StringVarP(&reassembleInputDir, "input", "i", "", "Input directory containing extracted sections (required)")
 	reassembleCmd.Flags().StringVarP(&reassembleOutputFile, "output", "o", "", "Output firmware file (required)")
+	reassembleCmd.Flags().StringVar(&reassembleOutputFormat, "output-format", "", outputFormatUsage)
 	reassembleCmd.Flags().BoolVar(&reassembleVerifyCRC, "verify-crc", false, "Verify CRC values during reassembly")
 	reassembleCmd.Flags().Bool("binary-only", false, "Force binary-only mode, ignore JSON files (by default, JSON is preferred)")
 	reassembleCmd.MarkFlagRequired("input")
@@ -244,10 +294,11 @@ Examples:
 	reassembleCmd.RunE = func(cmd *cobra.Command, args []string) error {
 		binaryOnly, _ := cmd.Flags().GetBool("binary-only")
 		opts := ReassembleOptions{
-			InputDir:   reassembleInputDir,
-			OutputFile: reassembleOutputFile,
-			VerifyCRC:  reassembleVerifyCRC,
-			BinaryOnly: binaryOnly,
+			InputDir:     reassembleInputDir,
+			OutputFile:   reassembleOutputFile,
+			VerifyCRC:    reassembleVerifyCRC,
+			BinaryOnly:   binaryOnly,
+			OutputFormat: reassembleOutputFormat,
 		}
 		return runReassembleCommand(cmd, args, opts)
 	}
@@ -257,6 +308,31 @@ Examples:
 	// Add diff command
 	rootCmd.AddCommand(CreateDiffFirmwareCommand())
 
+	// Add archive command
+	rootCmd.AddCommand(CreateArchiveCommand())
+
+	// Add verify command
+	rootCmd.AddCommand(CreateVerifyCommand())
+	rootCmd.AddCommand(CreateVerifySignatureCommand())
+	rootCmd.AddCommand(CreateVerifyHMACCommand())
+	rootCmd.AddCommand(CreateSignCommand())
+	rootCmd.AddCommand(CreateCertsCommand())
+	rootCmd.AddCommand(CreateSecurityReportCommand())
+	rootCmd.AddCommand(CreateCheckUpgradeCommand())
+	rootCmd.AddCommand(CreateLintCommand())
+
+	// Add GUID/MAC and VSD/PSID commands
+	rootCmd.AddCommand(CreateSetGUIDsCommand())
+	rootCmd.AddCommand(CreateSetMACsCommand())
+	rootCmd.AddCommand(CreateSetVSDCommand())
+	rootCmd.AddCommand(CreateSetPSIDCommand())
+
+	// Add expansion ROM commands
+	rootCmd.AddCommand(CreateROMCommand())
+
+	// Add INI commands
+	rootCmd.AddCommand(CreateINICommand())
+
 	// Add report commands
 	rootCmd.AddCommand(CreateReportCommand())
 	rootCmd.AddCommand(CreateSectionReportCommand())
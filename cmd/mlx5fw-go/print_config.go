@@ -16,7 +16,7 @@ func runPrintConfigCommand(cmd *cobra.Command, args []string) error {
 	logger.Info("Starting print-config command")
 
 	// Initialize firmware parser
-	ctx, err := cliutil.InitializeFirmwareParser(firmwarePath, logger)
+	ctx, err := cliutil.InitializeFirmwareParser(firmwarePath, logger, parserOptions()...)
 	if err != nil {
 		return err
 	}
@@ -8,6 +8,7 @@ import (
 
 	cliutil "github.com/Civil/mlx5fw-go/pkg/cliutil"
 	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/types"
 )
 
 func runQueryCommand(cmd *cobra.Command, args []string, fullOutput bool, jsonOutput bool) error {
@@ -18,8 +19,13 @@ func runQueryCommand(cmd *cobra.Command, args []string, fullOutput bool, jsonOut
 		return runQueryDeviceCommand(cmd, args, fullOutput, jsonOutput)
 	}
 
+	// Flash dumps may hold several failsafe image copies
+	if firmwareImage != "" {
+		return runQueryImagesCommand(fullOutput, jsonOutput)
+	}
+
 	// Initialize firmware parser
-	ctx, err := cliutil.InitializeFirmwareParser(firmwarePath, logger)
+	ctx, err := cliutil.InitializeFirmwareParserForPSID(firmwarePath, firmwarePSID, logger, parserOptions()...)
 	if err != nil {
 		return err
 	}
@@ -45,7 +51,7 @@ func runQueryCommand(cmd *cobra.Command, args []string, fullOutput bool, jsonOut
 	}
 
 	// Display query output
-	if err := displayQueryInfo(info, fullOutput, jsonOutput); err != nil {
+	if err := displayQueryInfo(info, fs4Parser.Diagnostics(), fullOutput, jsonOutput); err != nil {
 		return err
 	}
 
@@ -55,13 +61,14 @@ func runQueryCommand(cmd *cobra.Command, args []string, fullOutput bool, jsonOut
 		return fmt.Errorf("ITOC header is invalid")
 	}
 
-	return nil
+	return checkStrict(fs4Parser.Diagnostics())
 }
 
-func displayQueryInfo(info *interfaces.FirmwareInfo, fullOutput bool, jsonOutput bool) error {
+func displayQueryInfo(info *interfaces.FirmwareInfo, diags types.Diagnostics, fullOutput bool, jsonOutput bool) error {
 	if jsonOutput {
 		// Convert to JSON output format
 		jsonData := convertToQueryJSON(info)
+		jsonData.Diagnostics = jsonDiagnostics(diags)
 		return outputJSON(jsonData)
 	}
 
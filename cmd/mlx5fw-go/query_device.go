@@ -136,5 +136,5 @@ func runQueryDeviceCommand(cmd *cobra.Command, args []string, fullOutput bool, j
 	if jsonOutput {
 		return outputDeviceJSON(fwInfo, info)
 	}
-	return displayQueryInfo(fwInfo, fullOutput, jsonOutput)
+	return displayQueryInfo(fwInfo, nil, fullOutput, jsonOutput)
 }
@@ -3,6 +3,7 @@ package main
 import (
 	"github.com/spf13/cobra"
 
+	"github.com/Civil/mlx5fw-go/pkg/imgfmt"
 	"github.com/Civil/mlx5fw-go/pkg/reassemble"
 )
 
@@ -12,15 +13,24 @@ type ReassembleOptions struct {
 	OutputFile string
 	VerifyCRC  bool
 	BinaryOnly bool
+	// OutputFormat is the --output-format value (bin, ihex, srec; empty infers from the file extension)
+	OutputFormat string
 }
 
 func runReassembleCommand(cmd *cobra.Command, args []string, opts ReassembleOptions) error {
+	outputFormat, err := imgfmt.OutputFormat(opts.OutputFormat, opts.OutputFile)
+	if err != nil {
+		return err
+	}
+
 	// Create reassembler options
 	reassembleOpts := reassemble.Options{
-		InputDir:   opts.InputDir,
-		OutputFile: opts.OutputFile,
-		VerifyCRC:  opts.VerifyCRC,
-		BinaryOnly: opts.BinaryOnly,
+		InputDir:     opts.InputDir,
+		OutputFile:   opts.OutputFile,
+		VerifyCRC:    opts.VerifyCRC,
+		BinaryOnly:   opts.BinaryOnly,
+		OutputFormat: outputFormat,
+		Key:          imageKey,
 	}
 
 	// Create and run reassembler
@@ -6,10 +6,14 @@ import (
 	"strconv"
 	"strings"
 
+	cliutil "github.com/Civil/mlx5fw-go/pkg/cliutil"
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+	"github.com/Civil/mlx5fw-go/pkg/imgfmt"
 	"github.com/Civil/mlx5fw-go/pkg/interfaces"
 	"github.com/Civil/mlx5fw-go/pkg/parser"
 	"github.com/Civil/mlx5fw-go/pkg/parser/fs4"
 	"github.com/Civil/mlx5fw-go/pkg/section"
+	"github.com/Civil/mlx5fw-go/pkg/security"
 	"github.com/Civil/mlx5fw-go/pkg/types"
 	"github.com/ansel1/merry/v2"
 	"github.com/spf13/cobra"
@@ -37,19 +41,27 @@ func parseReplaceSectionArgs(args []string) (string, int, error) {
 	return sectionName, sectionID, nil
 }
 
-func runReplaceSectionCommand(cmd *cobra.Command, args []string, sectionName string, sectionID int, replacementFile string, outputFile string) error {
+func runReplaceSectionCommand(cmd *cobra.Command, args []string, sectionName string, sectionID int, replacementFile string, outputFile string, outputFormatFlag string, updateHashes bool) error {
 	logger.Debug("Starting replace-section command",
 		zap.String("firmware", firmwarePath),
 		zap.String("section", sectionName),
 		zap.Int("id", sectionID),
 		zap.String("replacement", replacementFile),
-		zap.String("output", outputFile))
+		zap.String("output", outputFile),
+		zap.String("outputFormat", outputFormatFlag),
+		zap.Bool("updateHashes", updateHashes))
+
+	outputFormat, err := imgfmt.OutputFormat(outputFormatFlag, outputFile)
+	if err != nil {
+		return err
+	}
 
-	// Read the entire firmware file
-	firmwareData, err := os.ReadFile(firmwarePath)
+	// Open the firmware ("-" reads from stdin, so the image is read only once)
+	reader, err := parser.NewFirmwareReader(firmwarePath, logger)
 	if err != nil {
 		return merry.Wrap(err)
 	}
+	defer reader.Close()
 
 	// Read the replacement data
 	replacementData, err := os.ReadFile(replacementFile)
@@ -57,15 +69,19 @@ func runReplaceSectionCommand(cmd *cobra.Command, args []string, sectionName str
 		return merry.Wrap(err)
 	}
 
-	// Parse the firmware using the existing parser
-	reader, err := parser.NewFirmwareReader(firmwarePath, logger)
+	// Parse the firmware; the replacer only knows the FS4/FS5 layout
+	ctx, err := cliutil.InitializeFirmwareParserFromReader(reader, logger, parserOptions()...)
 	if err != nil {
 		return merry.Wrap(err)
 	}
-	defer reader.Close()
+	fwParser, ok := ctx.Parser.(*fs4.Parser)
+	if !ok {
+		return pkgerrors.NotSupportedError("replace-section on " + ctx.Parser.GetFormat().String() + " firmware")
+	}
 
-	fwParser := fs4.NewParser(reader, logger)
-	err = fwParser.Parse()
+	// The replacer works on its own copy, so the image can be shared without copying.
+	// ctx.Reader holds the plaintext when the image was decrypted with --image-key.
+	firmwareData, err := ctx.Reader.Bytes()
 	if err != nil {
 		return merry.Wrap(err)
 	}
@@ -106,8 +122,17 @@ func runReplaceSectionCommand(cmd *cobra.Command, args []string, sectionName str
 		return merry.Wrap(err)
 	}
 
+	if err := refreshImageHashes(newFirmwareData, fwParser, updateHashes); err != nil {
+		return err
+	}
+	warnSignedImage(fwParser)
+
+	if err := ctx.EncryptImage(newFirmwareData); err != nil {
+		return merry.Prepend(err, "failed to encrypt modified firmware")
+	}
+
 	// Write the modified firmware
-	err = os.WriteFile(outputFile, newFirmwareData, 0644)
+	err = imgfmt.WriteFile(outputFile, newFirmwareData, outputFormat, 0644)
 	if err != nil {
 		return merry.Wrap(err)
 	}
@@ -117,3 +142,43 @@ func runReplaceSectionCommand(cmd *cobra.Command, args []string, sectionName str
 
 	return nil
 }
+
+// refreshImageHashes regenerates the HASHES_TABLE of a modified image when
+// update is set, and otherwise warns if the image has one
+func refreshImageHashes(data []byte, fwParser interfaces.FirmwareParser, update bool) error {
+	if update {
+		return updateImageHashes(data)
+	}
+	if _, err := security.FindHashesTable(fwParser); err == nil {
+		logger.Warn("HASHES_TABLE digests no longer match the modified image, use --update-hashes to regenerate them")
+	}
+	return nil
+}
+
+// warnSignedImage warns that the signatures of a modified image are stale
+func warnSignedImage(fwParser interfaces.FirmwareParser) {
+	sigs := fwParser.GetSections()
+	if len(sigs[types.SectionTypeImageSignature256])+len(sigs[types.SectionTypeImageSignature512]) > 0 {
+		logger.Warn("Image signatures no longer match the modified image, re-sign it with the sign command")
+	}
+}
+
+// updateImageHashes reparses a modified image and regenerates its HASHES_TABLE
+// digests in place, so the table covers the new section contents
+func updateImageHashes(data []byte) error {
+	ctx, err := cliutil.InitializeFirmwareParserFromReader(parser.NewFirmwareReaderFromBytes(data, logger), logger)
+	if err != nil {
+		return merry.Prepend(err, "failed to reparse modified firmware")
+	}
+	defer ctx.Close()
+
+	report, err := security.UpdateHashes(data, ctx.Parser)
+	if err != nil {
+		return merry.Prepend(err, "failed to update HASHES_TABLE")
+	}
+	logger.Info("Updated HASHES_TABLE",
+		zap.Uint64("offset", report.TableOffset),
+		zap.Int("entries", len(report.Entries)),
+		zap.Bool("ok", report.OK))
+	return nil
+}
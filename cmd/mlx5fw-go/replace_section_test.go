@@ -88,7 +88,7 @@ func TestReplaceSectionIntegration(t *testing.T) {
 
 	// Run replace-section command
 	firmwarePath = testFirmware
-	err = runReplaceSectionCommand(nil, []string{"DBG_FW_INI"}, "DBG_FW_INI", -1, replacementFile, outputFile)
+	err = runReplaceSectionCommand(nil, []string{"DBG_FW_INI"}, "DBG_FW_INI", -1, replacementFile, outputFile, "", false)
 
 	// Check if command succeeded (might fail if no DBG_FW_INI section)
 	if err != nil {
@@ -155,7 +155,7 @@ func TestReplaceSectionWithSizeChange(t *testing.T) {
 
 	// Run replace-section command
 	firmwarePath = testFirmware
-	err = runReplaceSectionCommand(nil, []string{"DBG_FW_INI"}, "DBG_FW_INI", -1, replacementFile, outputFile)
+	err = runReplaceSectionCommand(nil, []string{"DBG_FW_INI"}, "DBG_FW_INI", -1, replacementFile, outputFile, "", false)
 
 	// Check if command succeeded (might fail if no DBG_FW_INI section)
 	if err != nil {
@@ -221,7 +221,7 @@ func TestReplaceSectionCommandErrors(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			_, repl, out := tt.setupFiles()
-			err := runReplaceSectionCommand(nil, tt.args, tt.sectionName, tt.sectionID, repl, out)
+			err := runReplaceSectionCommand(nil, tt.args, tt.sectionName, tt.sectionID, repl, out, "", false)
 			assert.Error(t, err)
 			assert.Contains(t, err.Error(), tt.expectedError)
 		})
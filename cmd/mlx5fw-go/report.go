@@ -7,8 +7,6 @@ import (
 	"strings"
 
 	cliutil "github.com/Civil/mlx5fw-go/pkg/cliutil"
-	"github.com/Civil/mlx5fw-go/pkg/parser"
-	"github.com/Civil/mlx5fw-go/pkg/parser/fs4"
 	"github.com/Civil/mlx5fw-go/pkg/types"
 	"github.com/spf13/cobra"
 )
@@ -55,19 +53,13 @@ func runReport(cmd *cobra.Command, args []string) error {
 	encryptedOnly, _ := cmd.Flags().GetBool("encrypted")
 	deviceDataOnly, _ := cmd.Flags().GetBool("device-data")
 
-	// Create firmware reader
-	reader, err := parser.NewFirmwareReader(firmwarePath, logger)
+	// Open and parse firmware in whatever format it is
+	ctx, err := cliutil.InitializeFirmwareParser(firmwarePath, logger, parserOptions()...)
 	if err != nil {
-		return fmt.Errorf("failed to create firmware reader: %w", err)
-	}
-	defer reader.Close()
-
-	// Parse firmware
-	fwParser := fs4.NewParser(reader, logger)
-
-	if err := fwParser.Parse(); err != nil {
-		return fmt.Errorf("failed to parse firmware: %w", err)
+		return err
 	}
+	defer ctx.Close()
+	fwParser := ctx.Parser
 
 	// Get all sections
 	sectionsMap := fwParser.GetSections()
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ansel1/merry/v2"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	cliutil "github.com/Civil/mlx5fw-go/pkg/cliutil"
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+	"github.com/Civil/mlx5fw-go/pkg/imgfmt"
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/parser/fs4"
+	"github.com/Civil/mlx5fw-go/pkg/rom"
+	"github.com/Civil/mlx5fw-go/pkg/section"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+// romEditOptions holds the flags of the rom commands that modify the image
+type romEditOptions struct {
+	romType      string
+	cpu          string
+	updateHashes bool
+	outputFile   string
+	outputFormat string
+}
+
+// CreateROMCommand creates the rom command and its subcommands
+func CreateROMCommand() *cobra.Command {
+	romCmd := &cobra.Command{
+		Use:   "rom",
+		Short: "Edit the expansion ROMs in ROM_CODE",
+		Long: `Add, replace, remove or extract the PCI expansion ROMs (PXE, UEFI, ...)
+stored in the ROM_CODE section, like flint's brom, drom and rrom.
+
+ROM files must start with a 0x55AA ROM header pointing to a PCIR structure,
+and each ROM must carry an mlxsign record naming its type and CPU. When the
+ROM_CODE size changes, the sections after it are relocated as in
+replace-section.`,
+	}
+
+	romCmd.AddCommand(createROMExtractCommand())
+	romCmd.AddCommand(createROMEditCommand("add", "Append the ROMs of a file to ROM_CODE", `
+Examples:
+  mlx5fw-go rom add -f firmware.bin uefi.rom -o modified.bin`, rom.Add))
+	romCmd.AddCommand(createROMEditCommand("replace", "Replace the ROMs of the same type and CPU as those of a file", `
+Examples:
+  mlx5fw-go rom replace -f firmware.bin flexboot.rom -o modified.bin`, rom.Replace))
+	romCmd.AddCommand(createROMRemoveCommand())
+	return romCmd
+}
+
+// createROMExtractCommand creates rom extract
+func createROMExtractCommand() *cobra.Command {
+	var romType, cpu, outputFile string
+
+	cmd := &cobra.Command{
+		Use:   "extract",
+		Short: "Write the ROM chain, or the ROMs of one type, to a file",
+		Long: `Write the ROM chain of ROM_CODE, or with --type only the ROMs of that type,
+to a file. The last ROM written is flagged as the end of the chain.
+
+Examples:
+  mlx5fw-go rom extract -f firmware.bin -o roms.bin
+  mlx5fw-go rom extract -f firmware.bin --type UEFI --cpu AMD64 -o uefi.rom`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cliutil.ValidateFirmwarePath(firmwarePath); err != nil {
+				return err
+			}
+			ctx, romSection, chain, err := openROMCode()
+			if err != nil {
+				return err
+			}
+			defer ctx.Close()
+
+			extracted, err := rom.Extract(chain, romType, cpu)
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(outputFile, extracted, 0644); err != nil {
+				return merry.Wrap(err)
+			}
+			logger.Info("Wrote ROM",
+				zap.Uint64("romCode", romSection.Offset()),
+				zap.Int("size", len(extracted)),
+				zap.String("output", outputFile))
+			return printROMs(extracted)
+		},
+	}
+
+	cmd.Flags().StringVar(&romType, "type", "", "ROM type to extract (PXE, UEFI, ...; default: the whole chain)")
+	cmd.Flags().StringVar(&cpu, "cpu", "", "CPU of the ROM to extract (default: any)")
+	cmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output ROM file (required)")
+	cmd.MarkFlagRequired("output")
+	return cmd
+}
+
+// createROMEditCommand creates rom add or rom replace, which take a ROM file
+func createROMEditCommand(name, short, examples string, edit func(chain, file []byte) ([]byte, error)) *cobra.Command {
+	var opts romEditOptions
+
+	cmd := &cobra.Command{
+		Use:   name + " <rom-file>",
+		Short: short,
+		Args:  cobra.ExactArgs(1),
+		Long:  short + ". The file may hold several ROMs." + examples,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cliutil.ValidateFirmwarePath(firmwarePath); err != nil {
+				return err
+			}
+			file, err := os.ReadFile(args[0])
+			if err != nil {
+				return merry.Wrap(err)
+			}
+			return runROMEditCommand(opts, func(chain []byte) ([]byte, error) {
+				return edit(chain, file)
+			})
+		},
+	}
+
+	addROMEditFlags(cmd, &opts)
+	return cmd
+}
+
+// createROMRemoveCommand creates rom remove
+func createROMRemoveCommand() *cobra.Command {
+	var opts romEditOptions
+
+	cmd := &cobra.Command{
+		Use:   "remove",
+		Short: "Remove the ROMs of one type from ROM_CODE",
+		Long: `Remove the ROMs of the given type and, with --cpu, CPU from ROM_CODE. The
+ROM_CODE section itself is kept, so its last ROM cannot be removed.
+
+Examples:
+  mlx5fw-go rom remove -f firmware.bin --type UEFI -o modified.bin`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cliutil.ValidateFirmwarePath(firmwarePath); err != nil {
+				return err
+			}
+			return runROMEditCommand(opts, func(chain []byte) ([]byte, error) {
+				return rom.Remove(chain, opts.romType, opts.cpu)
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.romType, "type", "", "ROM type to remove (PXE, UEFI, ...) (required)")
+	cmd.Flags().StringVar(&opts.cpu, "cpu", "", "CPU of the ROM to remove (default: any)")
+	cmd.MarkFlagRequired("type")
+	addROMEditFlags(cmd, &opts)
+	return cmd
+}
+
+// addROMEditFlags adds the flags the modifying rom commands share
+func addROMEditFlags(cmd *cobra.Command, opts *romEditOptions) {
+	cmd.Flags().BoolVar(&opts.updateHashes, "update-hashes", false, "Regenerate the HASHES_TABLE digests of the modified image")
+	cmd.Flags().StringVarP(&opts.outputFile, "output", "o", "", "Output firmware file (required)")
+	cmd.Flags().StringVar(&opts.outputFormat, "output-format", "", outputFormatUsage)
+	cmd.MarkFlagRequired("output")
+}
+
+// openROMCode parses the firmware and returns its ROM_CODE section and the
+// section contents
+func openROMCode() (*cliutil.ParserContext, interfaces.CompleteSectionInterface, []byte, error) {
+	ctx, err := cliutil.InitializeFirmwareParser(firmwarePath, logger, parserOptions()...)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	romSections := ctx.Parser.GetSections()[types.SectionTypeROMCode]
+	if len(romSections) == 0 {
+		ctx.Close()
+		return nil, nil, nil, pkgerrors.SectionNotFoundError("ROM_CODE", 0)
+	}
+	romSection := romSections[0]
+
+	data, err := ctx.Reader.Bytes()
+	if err != nil {
+		ctx.Close()
+		return nil, nil, nil, merry.Wrap(err)
+	}
+	end := romSection.Offset() + uint64(romSection.Size())
+	if end > uint64(len(data)) {
+		ctx.Close()
+		return nil, nil, nil, pkgerrors.DataTooShortError(int(end), len(data), "ROM_CODE")
+	}
+	return ctx, romSection, data[romSection.Offset():end], nil
+}
+
+func runROMEditCommand(opts romEditOptions, edit func(chain []byte) ([]byte, error)) error {
+	outputFormat, err := imgfmt.OutputFormat(opts.outputFormat, opts.outputFile)
+	if err != nil {
+		return err
+	}
+
+	ctx, romSection, chain, err := openROMCode()
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	// The replacer only knows the FS4/FS5 layout
+	fwParser, ok := ctx.Parser.(*fs4.Parser)
+	if !ok {
+		return pkgerrors.NotSupportedError("editing ROMs of " + ctx.Parser.GetFormat().String() + " firmware")
+	}
+
+	newChain, err := edit(chain)
+	if err != nil {
+		return err
+	}
+	logger.Info("Rebuilt ROM chain",
+		zap.Uint64("offset", romSection.Offset()),
+		zap.Uint32("size", romSection.Size()),
+		zap.Int("newSize", len(newChain)))
+
+	// The replacer works on its own copy of the image
+	data, err := ctx.Reader.Bytes()
+	if err != nil {
+		return merry.Wrap(err)
+	}
+	newData, err := section.NewReplacer(fwParser, data, logger).ReplaceSection(romSection, newChain)
+	if err != nil {
+		return merry.Wrap(err)
+	}
+
+	if err := refreshImageHashes(newData, fwParser, opts.updateHashes); err != nil {
+		return err
+	}
+	warnSignedImage(fwParser)
+	if err := writeModifiedImage(ctx, newData, opts.outputFile, outputFormat); err != nil {
+		return err
+	}
+	return printROMs(newChain)
+}
+
+// printROMs prints the ROMs of a chain the way query lists them
+func printROMs(chain []byte) error {
+	roms, err := rom.Parse(chain)
+	if err != nil {
+		return err
+	}
+	if jsonOutput {
+		return cliutil.EncodeJSONIndent(os.Stdout, roms)
+	}
+	for _, r := range roms {
+		line := fmt.Sprintf("type=%s version=%s", r.Type, r.Version)
+		if r.CPU != "" {
+			line += " cpu=" + r.CPU
+		}
+		fmt.Printf("0x%06x  %s\n", r.Offset, line)
+	}
+	return nil
+}
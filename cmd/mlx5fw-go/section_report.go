@@ -7,8 +7,6 @@ import (
 
 	cliutil "github.com/Civil/mlx5fw-go/pkg/cliutil"
 	"github.com/Civil/mlx5fw-go/pkg/interfaces"
-	"github.com/Civil/mlx5fw-go/pkg/parser"
-	"github.com/Civil/mlx5fw-go/pkg/parser/fs4"
 	"github.com/Civil/mlx5fw-go/pkg/utils"
 	"github.com/spf13/cobra"
 )
@@ -38,19 +36,13 @@ func runSectionReport(cmd *cobra.Command, args []string) error {
 	outputFile, _ := cmd.Flags().GetString("output")
 	format, _ := cmd.Flags().GetString("format")
 
-	// Create firmware reader
-	reader, err := parser.NewFirmwareReader(firmwarePath, logger)
+	// Open and parse firmware in whatever format it is
+	ctx, err := cliutil.InitializeFirmwareParser(firmwarePath, logger, parserOptions()...)
 	if err != nil {
-		return fmt.Errorf("failed to create firmware reader: %w", err)
-	}
-	defer reader.Close()
-
-	// Parse firmware
-	fwParser := fs4.NewParser(reader, logger)
-
-	if err := fwParser.Parse(); err != nil {
-		return fmt.Errorf("failed to parse firmware: %w", err)
+		return err
 	}
+	defer ctx.Close()
+	fwParser := ctx.Parser
 
 	// Get all sections and convert to interfaces
 	sectionsMap := fwParser.GetSections()
@@ -11,7 +11,7 @@ import (
 
 	cliutil "github.com/Civil/mlx5fw-go/pkg/cliutil"
 	"github.com/Civil/mlx5fw-go/pkg/interfaces"
-	"github.com/Civil/mlx5fw-go/pkg/parser/fs4"
+	"github.com/Civil/mlx5fw-go/pkg/security"
 	"github.com/Civil/mlx5fw-go/pkg/types"
 )
 
@@ -30,8 +30,13 @@ type SectionDisplay struct {
 func runSectionsCommand(cmd *cobra.Command, args []string, showContent bool, outputFormat string) error {
 	logger.Debug("Starting sections command")
 
+	// Flash dumps may hold several failsafe image copies
+	if firmwareImage != "" {
+		return runSectionsImagesCommand(showContent, outputFormat)
+	}
+
 	// Initialize firmware parser
-	ctx, err := cliutil.InitializeFirmwareParser(firmwarePath, logger)
+	ctx, err := cliutil.InitializeFirmwareParserForPSID(firmwarePath, firmwarePSID, logger, parserOptions()...)
 	if err != nil {
 		return err
 	}
@@ -44,7 +49,10 @@ func runSectionsCommand(cmd *cobra.Command, args []string, showContent bool, out
 	format := fs4Parser.GetFormat()
 
 	// Display sections
-	return displaySections(firmwarePath, format, sections, fs4Parser, showContent, outputFormat)
+	if err := displaySections(firmwarePath, format, sections, fs4Parser, showContent, outputFormat); err != nil {
+		return err
+	}
+	return checkStrict(fs4Parser.Diagnostics())
 }
 
 // JSONSection represents a section for JSON output
@@ -58,89 +66,108 @@ type JSONSection struct {
 	IsEncrypted        bool   `json:"IsEncrypted"`
 	IsDeviceData       bool   `json:"IsDeviceData"`
 	VerificationStatus string `json:"VerificationStatus"`
+	// Certificates lists the X.509 certificates of certificate sections
+	Certificates []security.CertificateInfo `json:"Certificates,omitempty"`
 }
 
 // JSONOutput represents the complete JSON output structure
 type JSONOutput struct {
-	FirmwareFormat string        `json:"FirmwareFormat"`
-	OverallStatus  string        `json:"OverallStatus"`
-	IsBootable     bool          `json:"IsBootable"`
-	Sections       []JSONSection `json:"Sections"`
+	FirmwareFormat string            `json:"FirmwareFormat"`
+	OverallStatus  string            `json:"OverallStatus"`
+	IsBootable     bool              `json:"IsBootable"`
+	Sections       []JSONSection     `json:"Sections"`
+	Diagnostics    types.Diagnostics `json:"Diagnostics"`
 }
 
-func displaySections(filePath string, format types.FirmwareFormat, sections map[uint16][]interfaces.CompleteSectionInterface, parser *fs4.Parser, showContent bool, outputFormat string) error {
-	// If JSON output is requested, we'll collect all sections first
-	if outputFormat == "json" {
-		// Collect all sections and convert to JSON format
-		var jsonSections []JSONSection
-		overallBootable := true
-
-		for _, sectionList := range sections {
-			for _, section := range sectionList {
-				// Get section type name
-				typeName := section.TypeName()
-
-				// Convert CRC type to string
-				var crcTypeName string
-				switch section.CRCType() {
-				case types.CRCNone:
-					crcTypeName = "CRC_NONE"
-				case types.CRCInITOCEntry:
-					crcTypeName = "CRC_IN_ITOC_ENTRY"
-				case types.CRCInSection:
-					crcTypeName = "CRC_IN_SECTION"
-				default:
-					crcTypeName = fmt.Sprintf("UNKNOWN_%d", section.CRCType())
-				}
+// buildSectionsJSON verifies every section and builds the sections --json document
+func buildSectionsJSON(format types.FirmwareFormat, sections map[uint16][]interfaces.CompleteSectionInterface, parser interfaces.FirmwareParser) JSONOutput {
+	// Collect all sections and convert to JSON format
+	var jsonSections []JSONSection
+	overallBootable := true
 
-				// Verify section and get status
-				status, err := parser.VerifySectionNew(section)
-				if err != nil {
-					logger.Warn("Failed to verify section", zap.Error(err))
-					status = "ERROR"
-					overallBootable = false
-				}
+	for _, sectionList := range sections {
+		for _, section := range sectionList {
+			// Get section type name
+			typeName := section.TypeName()
+
+			// Convert CRC type to string
+			var crcTypeName string
+			switch section.CRCType() {
+			case types.CRCNone:
+				crcTypeName = "CRC_NONE"
+			case types.CRCInITOCEntry:
+				crcTypeName = "CRC_IN_ITOC_ENTRY"
+			case types.CRCInSection:
+				crcTypeName = "CRC_IN_SECTION"
+			default:
+				crcTypeName = fmt.Sprintf("UNKNOWN_%d", section.CRCType())
+			}
 
-				// Check if verification passed
-				// SIZE NOT ALIGNED is still considered OK for bootability
-				if status != "OK" && status != "CRC IGNORED" && status != "NO ENTRY" &&
-					status != "NOT FOUND" && status != "SIZE NOT ALIGNED" {
-					overallBootable = false
-				}
+			// Verify section and get status
+			status, err := parser.VerifySectionNew(section)
+			if err != nil {
+				logger.Warn("Failed to verify section", zap.Error(err))
+				status = "ERROR"
+				overallBootable = false
+			}
 
-				jsonSection := JSONSection{
-					Type:               typeName,
-					StartAddress:       section.Offset(),
-					EndAddress:         section.Offset() + uint64(section.Size()) - 1,
-					Size:               section.Size(),
-					CRCType:            crcTypeName,
-					CRC:                0, // CRC is not exposed in the interface
-					IsEncrypted:        section.IsEncrypted(),
-					IsDeviceData:       section.IsDeviceData(),
-					VerificationStatus: status,
-				}
+			// Check if verification passed
+			// SIZE NOT ALIGNED is still considered OK for bootability
+			if status != "OK" && status != "CRC IGNORED" && status != "NO ENTRY" &&
+				status != "NOT FOUND" && status != "SIZE NOT ALIGNED" {
+				overallBootable = false
+			}
 
-				jsonSections = append(jsonSections, jsonSection)
+			jsonSection := JSONSection{
+				Type:               typeName,
+				StartAddress:       section.Offset(),
+				EndAddress:         section.Offset() + uint64(section.Size()) - 1,
+				Size:               section.Size(),
+				CRCType:            crcTypeName,
+				CRC:                0, // CRC is not exposed in the interface
+				IsEncrypted:        section.IsEncrypted(),
+				IsDeviceData:       section.IsDeviceData(),
+				VerificationStatus: status,
+			}
+			if security.IsCertificateSection(section.Type()) {
+				certs, err := security.SectionCertificates(parser, section)
+				if err != nil {
+					logger.Warn("Failed to decode certificates", zap.String("section", typeName), zap.Error(err))
+				}
+				for _, c := range certs {
+					jsonSection.Certificates = append(jsonSection.Certificates, c.Info())
+				}
 			}
+
+			jsonSections = append(jsonSections, jsonSection)
 		}
+	}
 
-		// Sort by start address for consistent output
-		sort.Slice(jsonSections, func(i, j int) bool {
-			return jsonSections[i].StartAddress < jsonSections[j].StartAddress
-		})
+	// Sort by start address for consistent output
+	sort.Slice(jsonSections, func(i, j int) bool {
+		return jsonSections[i].StartAddress < jsonSections[j].StartAddress
+	})
 
-		// Create overall output structure
-		overallStatus := "FW image verification succeeded"
-		if !overallBootable {
-			overallStatus = "FW image verification failed"
-		}
+	// Create overall output structure
+	overallStatus := "FW image verification succeeded"
+	if !overallBootable {
+		overallStatus = "FW image verification failed"
+	}
 
-		output := JSONOutput{
-			FirmwareFormat: format.String(),
-			OverallStatus:  overallStatus,
-			IsBootable:     overallBootable,
-			Sections:       jsonSections,
-		}
+	output := JSONOutput{
+		FirmwareFormat: format.String(),
+		OverallStatus:  overallStatus,
+		IsBootable:     overallBootable,
+		Sections:       jsonSections,
+		Diagnostics:    jsonDiagnostics(parser.Diagnostics()),
+	}
+	return output
+}
+
+func displaySections(filePath string, format types.FirmwareFormat, sections map[uint16][]interfaces.CompleteSectionInterface, parser interfaces.FirmwareParser, showContent bool, outputFormat string) error {
+	// If JSON output is requested, we'll collect all sections first
+	if outputFormat == "json" {
+		output := buildSectionsJSON(format, sections, parser)
 
         // Output as JSON
         return cliutil.EncodeJSONIndent(os.Stdout, output)
@@ -152,8 +179,8 @@ func displaySections(filePath string, format types.FirmwareFormat, sections map[
 	// Prepare sections for display
 	var displaySections []SectionDisplay
 
-	// Add HW pointers (always at beginning)
-	for i := 0; i < 16; i++ {
+	// Add HW pointers (always at beginning, FS3 images have none)
+	for i := 0; i < 16 && format != types.FormatFS3; i++ {
 		offset := uint64(0x18 + i*8)
 		displaySections = append(displaySections, SectionDisplay{
 			StartAddr:   offset,
@@ -188,25 +215,26 @@ func displaySections(filePath string, format types.FirmwareFormat, sections map[
 		IsHeader:  true,
 	})
 
-	// Add DTOC header
-	dtocAddr := uint64(parser.GetDTOCAddress())
-	dtocStatus := "OK"
-	if !parser.IsDTOCHeaderValid() {
-		// For encrypted firmware, skip DTOC CRC verification as mstflint does
-		if parser.IsEncrypted() {
-			dtocStatus = "ENCRYPTED"
-		} else {
-			dtocStatus = "FAIL (Invalid CRC)"
+	// Add DTOC header (FS3 images have no DTOC)
+	if dtocAddr := uint64(parser.GetDTOCAddress()); dtocAddr != 0 {
+		dtocStatus := "OK"
+		if !parser.IsDTOCHeaderValid() {
+			// For encrypted firmware, skip DTOC CRC verification as mstflint does
+			if parser.IsEncrypted() {
+				dtocStatus = "ENCRYPTED"
+			} else {
+				dtocStatus = "FAIL (Invalid CRC)"
+			}
 		}
+		displaySections = append(displaySections, SectionDisplay{
+			StartAddr: dtocAddr,
+			EndAddr:   dtocAddr + 0x1F,
+			Size:      0x20,
+			Name:      "DTOC_HEADER",
+			Status:    dtocStatus,
+			IsHeader:  true,
+		})
 	}
-	displaySections = append(displaySections, SectionDisplay{
-		StartAddr: dtocAddr,
-		EndAddr:   dtocAddr + 0x1F,
-		Size:      0x20,
-		Name:      "DTOC_HEADER",
-		Status:    dtocStatus,
-		IsHeader:  true,
-	})
 
 	// Add parsed sections
 	for sectionType, sectionList := range sections {
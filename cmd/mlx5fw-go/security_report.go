@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ansel1/merry/v2"
+	"github.com/spf13/cobra"
+
+	cliutil "github.com/Civil/mlx5fw-go/pkg/cliutil"
+	"github.com/Civil/mlx5fw-go/pkg/security"
+)
+
+// CreateSecurityReportCommand creates the security-report command
+func CreateSecurityReportCommand() *cobra.Command {
+	var policySpecs []string
+	var policyFile string
+
+	cmd := &cobra.Command{
+		Use:   "security-report",
+		Short: "Report the security posture of a firmware image",
+		Long: `Report the security posture of a firmware image: the secure/signed/debug/dev
+FW and MCC bits, the token flags, the security version and FORBIDDEN_VERSIONS,
+the signature types and key sizes, the embedded public keys, the HASHES_TABLE
+state and whether the image is encrypted.
+
+Policy rules given with --policy or --policy-file (one rule per line) are
+evaluated against the report, and the command fails when any rule fails.
+Available rules: ` + strings.Join(security.PolicyRuleNames(), ", ") + `.
+
+Examples:
+  mlx5fw-go security-report -f firmware.bin
+  mlx5fw-go security-report -f firmware.bin --json
+  mlx5fw-go security-report -f firmware.bin --policy no-debug-fw,min-key-size=4096`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cliutil.ValidateFirmwarePath(firmwarePath); err != nil {
+				return err
+			}
+			rules, err := securityPolicy(policySpecs, policyFile)
+			if err != nil {
+				return err
+			}
+			return runSecurityReport(rules)
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&policySpecs, "policy", nil, "Policy rules to enforce, e.g. no-debug-fw,min-key-size=4096")
+	cmd.Flags().StringVar(&policyFile, "policy-file", "", "File with one policy rule per line")
+
+	return cmd
+}
+
+// securityPolicy parses the rules of --policy and --policy-file
+func securityPolicy(specs []string, path string) ([]security.PolicyRule, error) {
+	var rules []security.PolicyRule
+	if path != "" {
+		fileRules, err := security.LoadPolicyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, fileRules...)
+	}
+	for _, spec := range specs {
+		rule, err := security.ParsePolicyRule(spec)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func runSecurityReport(rules []security.PolicyRule) error {
+	ctx, err := cliutil.InitializeFirmwareParser(firmwarePath, logger, parserOptions()...)
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	data, err := ctx.Reader.Bytes()
+	if err != nil {
+		return merry.Wrap(err)
+	}
+	report, err := security.BuildSecurityReport(data, ctx.Parser)
+	if err != nil {
+		return merry.Prepend(err, "failed to build security report")
+	}
+	passed := report.ApplyPolicy(rules)
+
+	if jsonOutput {
+		if err := cliutil.EncodeJSONIndent(os.Stdout, report); err != nil {
+			return err
+		}
+	} else {
+		displaySecurityReport(report)
+	}
+
+	if !passed {
+		return merry.New("security policy check failed")
+	}
+	return nil
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "Yes"
+	}
+	return "No"
+}
+
+func displaySecurityReport(report *security.SecurityReport) {
+	fmt.Printf("Security Attributes:   %s\n", report.Attributes)
+	fmt.Printf("Security Mode:         0x%x\n", report.SecurityMode)
+	fmt.Printf("Secure FW:             %s\n", yesNo(report.Flags.SecureFW))
+	fmt.Printf("Signed FW:             %s\n", yesNo(report.Flags.SignedFW))
+	fmt.Printf("Debug FW:              %s\n", yesNo(report.Flags.DebugFW))
+	fmt.Printf("Dev FW:                %s\n", yesNo(report.Flags.DevFW))
+	fmt.Printf("MCC Enabled:           %s\n", yesNo(report.Flags.MCCEn))
+	fmt.Printf("CS Token:              %s\n", yesNo(report.Tokens.CSToken))
+	fmt.Printf("DBG Token:             %s\n", yesNo(report.Tokens.DbgToken))
+	fmt.Printf("Crypto To Comm.:       %s\n", yesNo(report.Tokens.CryptoToCommissioning))
+	if report.SecurityVersion != nil {
+		fmt.Printf("Security Version:      %d\n", *report.SecurityVersion)
+	} else {
+		fmt.Printf("Security Version:      N/A\n")
+	}
+	if len(report.ForbiddenVersions) > 0 {
+		versions := make([]string, len(report.ForbiddenVersions))
+		for i, v := range report.ForbiddenVersions {
+			versions[i] = fmt.Sprint(v)
+		}
+		fmt.Printf("Forbidden Versions:    %s\n", strings.Join(versions, ", "))
+		fmt.Printf("Version Forbidden:     %s\n", yesNo(report.SecurityVersionForbidden))
+	}
+	fmt.Printf("Encryption:            %s\n", report.Encryption)
+
+	hashes := "Not present"
+	if report.HashesTable.Present {
+		switch {
+		case report.HashesTable.Error != "":
+			hashes = "Error: " + report.HashesTable.Error
+		case report.HashesTable.Valid:
+			hashes = "Valid (" + report.HashesTable.Algorithm + ")"
+		default:
+			hashes = "Invalid (" + report.HashesTable.Algorithm + ")"
+		}
+	}
+	fmt.Printf("Hashes Table:          %s\n", hashes)
+
+	fmt.Println("\nSignatures:")
+	if len(report.Signatures) == 0 {
+		fmt.Println("  none")
+	}
+	for _, s := range report.Signatures {
+		state := "unsigned"
+		if s.Signed {
+			state = "signed"
+		}
+		fmt.Printf("  %-20s %s  RSA-%d  key pair %s  %s\n", s.Section, s.Algorithm, s.KeySize, s.KeypairUUID, state)
+	}
+
+	fmt.Println("\nPublic Keys:")
+	if len(report.PublicKeys) == 0 {
+		fmt.Println("  none")
+	}
+	for _, k := range report.PublicKeys {
+		fmt.Printf("  %-20s RSA-%d  %s", k.Source, k.KeySize, k.UUID)
+		if len(k.Auth) > 0 {
+			fmt.Printf("  [%s]", strings.Join(k.Auth, ", "))
+		}
+		fmt.Println()
+	}
+
+	if len(report.Policy) > 0 {
+		fmt.Println("\nPolicy:")
+		for _, p := range report.Policy {
+			if p.Passed {
+				fmt.Printf("  PASS  %s\n", p.Rule)
+			} else {
+				fmt.Printf("  FAIL  %s: %s\n", p.Rule, p.Message)
+			}
+		}
+	}
+}
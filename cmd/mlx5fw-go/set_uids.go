@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ansel1/merry/v2"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	cliutil "github.com/Civil/mlx5fw-go/pkg/cliutil"
+	"github.com/Civil/mlx5fw-go/pkg/imgfmt"
+	"github.com/Civil/mlx5fw-go/pkg/section"
+)
+
+// setUIDsOptions holds the set-guids and set-macs flags
+type setUIDsOptions struct {
+	base         string
+	count        uint8
+	step         uint8
+	mfg          bool
+	force        bool
+	outputFile   string
+	outputFormat string
+}
+
+// CreateSetGUIDsCommand creates the set-guids command
+func CreateSetGUIDsCommand() *cobra.Command {
+	return newSetUIDsCommand("guids", "guid", "GUID", `
+Examples:
+  mlx5fw-go set-guids -f firmware.bin --guid 0x0002c90300a1b2c0 --count 8 -o modified.bin
+  mlx5fw-go set-guids -f firmware.bin --guid 00:02:c9:03:00:a1:b2:c0 --count 8 --mfg -o modified.bin`)
+}
+
+// CreateSetMACsCommand creates the set-macs command
+func CreateSetMACsCommand() *cobra.Command {
+	return newSetUIDsCommand("macs", "mac", "MAC", `
+Examples:
+  mlx5fw-go set-macs -f firmware.bin --mac 00:02:c9:a1:b2:c0 --count 8 -o modified.bin
+  mlx5fw-go set-macs -f firmware.bin --mac 0x0002c9a1b2c0 --count 8 --mfg -o modified.bin`)
+}
+
+// newSetUIDsCommand creates set-guids or set-macs, which differ only in the
+// allocation they rewrite
+func newSetUIDsCommand(name, flag, kind, examples string) *cobra.Command {
+	var opts setUIDsOptions
+
+	cmd := &cobra.Command{
+		Use:   "set-" + name,
+		Short: "Set the base " + kind + " and " + kind + " count of an image",
+		Long: `Rewrite the ` + kind + ` allocation (base ` + kind + `, count and step) of every
+DEV_INFO copy (DEV_INFO, DEV_INFO1, DEV_INFO2), like flint's sg. With --mfg
+the MFG_INFO allocation is rewritten too, like flint's smg. The embedded
+DEV_INFO CRC and the CRC protecting each section are recomputed.
+
+Sections covered by the image signature are refused unless --force is given;
+the image must then be signed again.
+` + examples,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cliutil.ValidateFirmwarePath(firmwarePath); err != nil {
+				return err
+			}
+			return runSetUIDsCommand(kind, opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.base, flag, "", "Base "+kind+", hex with an optional 0x prefix or ':' separators (required)")
+	cmd.Flags().Uint8Var(&opts.count, "count", 0, "Number of "+kind+"s allocated from the base (required)")
+	cmd.Flags().Uint8Var(&opts.step, "step", 0, "Step between "+kind+"s (default: keep the stored one)")
+	cmd.Flags().BoolVar(&opts.mfg, "mfg", false, "Also rewrite the MFG_INFO allocation")
+	cmd.Flags().BoolVar(&opts.force, "force", false, "Rewrite sections covered by the image signature")
+	cmd.Flags().StringVarP(&opts.outputFile, "output", "o", "", "Output firmware file (required)")
+	cmd.Flags().StringVar(&opts.outputFormat, "output-format", "", outputFormatUsage)
+	cmd.MarkFlagRequired(flag)
+	cmd.MarkFlagRequired("count")
+	cmd.MarkFlagRequired("output")
+
+	return cmd
+}
+
+// parseUID parses a GUID or MAC given as hex digits, with an optional 0x
+// prefix and ':' or '-' separators
+func parseUID(s string) (uint64, error) {
+	digits := strings.NewReplacer(":", "", "-", "").Replace(strings.TrimPrefix(strings.ToLower(s), "0x"))
+	value, err := strconv.ParseUint(digits, 16, 64)
+	if err != nil {
+		return 0, merry.Wrap(err, merry.WithMessagef("invalid GUID/MAC %q", s))
+	}
+	return value, nil
+}
+
+func runSetUIDsCommand(kind string, opts setUIDsOptions) error {
+	outputFormat, err := imgfmt.OutputFormat(opts.outputFormat, opts.outputFile)
+	if err != nil {
+		return err
+	}
+	base, err := parseUID(opts.base)
+	if err != nil {
+		return err
+	}
+	allocation := &section.UIDAllocation{Base: base, Count: opts.count, Step: opts.step}
+	update := section.UIDUpdate{MFG: opts.mfg, Force: opts.force}
+	if kind == "MAC" {
+		update.MACs = allocation
+	} else {
+		update.GUIDs = allocation
+	}
+
+	ctx, err := cliutil.InitializeFirmwareParser(firmwarePath, logger, parserOptions()...)
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	// Reader bytes may be a read-only mapping, so modify a copy
+	original, err := ctx.Reader.Bytes()
+	if err != nil {
+		return merry.Wrap(err)
+	}
+	data := append([]byte(nil), original...)
+
+	updated, err := section.SetUIDs(data, ctx.Parser, update)
+	if err != nil {
+		return err
+	}
+	warnStaleSignatures(updated)
+	if err := writeModifiedImage(ctx, data, opts.outputFile, outputFormat); err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return cliutil.EncodeJSONIndent(os.Stdout, updated)
+	}
+	for _, u := range updated {
+		fmt.Printf("%-12s @ 0x%08x  %s 0x%x, %d allocated\n", u.Section, u.Offset, kind, base, opts.count)
+	}
+	return nil
+}
+
+// writeModifiedImage encrypts a modified image again when it was decrypted
+// with --image-key and writes it in the requested format
+func writeModifiedImage(ctx *cliutil.ParserContext, data []byte, outputFile string, format imgfmt.Format) error {
+	if err := ctx.EncryptImage(data); err != nil {
+		return merry.Prepend(err, "failed to encrypt modified firmware")
+	}
+	if err := imgfmt.WriteFile(outputFile, data, format, 0644); err != nil {
+		return merry.Wrap(err)
+	}
+	logger.Info("Wrote modified firmware", zap.String("output", outputFile))
+	return nil
+}
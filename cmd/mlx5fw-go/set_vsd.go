@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ansel1/merry/v2"
+	"github.com/spf13/cobra"
+
+	cliutil "github.com/Civil/mlx5fw-go/pkg/cliutil"
+	"github.com/Civil/mlx5fw-go/pkg/imgfmt"
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/section"
+)
+
+// setStringOptions holds the set-vsd and set-psid flags
+type setStringOptions struct {
+	value        string
+	mfg          bool
+	updateHashes bool
+	outputFile   string
+	outputFormat string
+}
+
+// CreateSetVSDCommand creates the set-vsd command
+func CreateSetVSDCommand() *cobra.Command {
+	var opts setStringOptions
+
+	cmd := &cobra.Command{
+		Use:   "set-vsd",
+		Short: "Set the vendor specific data string of an image",
+		Long: fmt.Sprintf(`Rewrite the VSD string of IMAGE_INFO, like flint's sv. The string may use
+the whole %d-byte field and is padded with NULs. The IMAGE_INFO CRC is
+recomputed.
+
+IMAGE_INFO is covered by the image signature and the HASHES_TABLE; the image
+must be signed again, and --update-hashes regenerates the HASHES_TABLE.
+
+Examples:
+  mlx5fw-go set-vsd -f firmware.bin --vsd "OEM build 7" -o modified.bin`, section.MaxVSDLength),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cliutil.ValidateFirmwarePath(firmwarePath); err != nil {
+				return err
+			}
+			return runSetStringCommand("VSD", opts, func(data []byte, fwParser interfaces.FirmwareParser) ([]section.UpdatedSection, error) {
+				return section.SetVSD(data, fwParser, opts.value)
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.value, "vsd", "", fmt.Sprintf("VSD string, up to %d bytes (required)", section.MaxVSDLength))
+	addSetStringFlags(cmd, &opts)
+	cmd.MarkFlagRequired("vsd")
+
+	return cmd
+}
+
+// CreateSetPSIDCommand creates the set-psid command
+func CreateSetPSIDCommand() *cobra.Command {
+	var opts setStringOptions
+
+	cmd := &cobra.Command{
+		Use:   "set-psid",
+		Short: "Set the PSID of an image",
+		Long: fmt.Sprintf(`Rewrite the PSID of IMAGE_INFO and, with --mfg, of MFG_INFO. The PSID may
+use the whole %d-byte field and is padded with NULs. The CRC protecting each
+section is recomputed.
+
+IMAGE_INFO is covered by the image signature and the HASHES_TABLE; the image
+must be signed again, and --update-hashes regenerates the HASHES_TABLE.
+
+Examples:
+  mlx5fw-go set-psid -f firmware.bin --psid MT_0000000911 -o modified.bin
+  mlx5fw-go set-psid -f firmware.bin --psid MT_0000000911 --mfg --update-hashes -o modified.bin`, section.MaxPSIDLength),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cliutil.ValidateFirmwarePath(firmwarePath); err != nil {
+				return err
+			}
+			return runSetStringCommand("PSID", opts, func(data []byte, fwParser interfaces.FirmwareParser) ([]section.UpdatedSection, error) {
+				return section.SetPSID(data, fwParser, opts.value, opts.mfg)
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.value, "psid", "", fmt.Sprintf("PSID, up to %d bytes (required)", section.MaxPSIDLength))
+	cmd.Flags().BoolVar(&opts.mfg, "mfg", false, "Also rewrite the MFG_INFO PSID")
+	addSetStringFlags(cmd, &opts)
+	cmd.MarkFlagRequired("psid")
+
+	return cmd
+}
+
+// addSetStringFlags adds the flags set-vsd and set-psid share
+func addSetStringFlags(cmd *cobra.Command, opts *setStringOptions) {
+	cmd.Flags().BoolVar(&opts.updateHashes, "update-hashes", false, "Regenerate the HASHES_TABLE digests of the modified image")
+	cmd.Flags().StringVarP(&opts.outputFile, "output", "o", "", "Output firmware file (required)")
+	cmd.Flags().StringVar(&opts.outputFormat, "output-format", "", outputFormatUsage)
+	cmd.MarkFlagRequired("output")
+}
+
+func runSetStringCommand(kind string, opts setStringOptions,
+	set func(data []byte, fwParser interfaces.FirmwareParser) ([]section.UpdatedSection, error)) error {
+	outputFormat, err := imgfmt.OutputFormat(opts.outputFormat, opts.outputFile)
+	if err != nil {
+		return err
+	}
+
+	ctx, err := cliutil.InitializeFirmwareParser(firmwarePath, logger, parserOptions()...)
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	// Reader bytes may be a read-only mapping, so modify a copy
+	original, err := ctx.Reader.Bytes()
+	if err != nil {
+		return merry.Wrap(err)
+	}
+	data := append([]byte(nil), original...)
+
+	updated, err := set(data, ctx.Parser)
+	if err != nil {
+		return err
+	}
+
+	// IMAGE_INFO is always rewritten, and the HASHES_TABLE covers it
+	if err := refreshImageHashes(data, ctx.Parser, opts.updateHashes); err != nil {
+		return err
+	}
+	warnStaleSignatures(updated)
+
+	if err := writeModifiedImage(ctx, data, opts.outputFile, outputFormat); err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return cliutil.EncodeJSONIndent(os.Stdout, updated)
+	}
+	for _, u := range updated {
+		fmt.Printf("%-12s @ 0x%08x  %s %q\n", u.Section, u.Offset, kind, opts.value)
+	}
+	return nil
+}
+
+// warnStaleSignatures warns when a rewritten section is covered by the image
+// signature
+func warnStaleSignatures(updated []section.UpdatedSection) {
+	for _, u := range updated {
+		if u.Signed {
+			logger.Warn("Image signatures no longer match the modified image, re-sign it with the sign command")
+			return
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"crypto"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ansel1/merry/v2"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	cliutil "github.com/Civil/mlx5fw-go/pkg/cliutil"
+	"github.com/Civil/mlx5fw-go/pkg/imgfmt"
+	"github.com/Civil/mlx5fw-go/pkg/security"
+)
+
+// signOptions holds the sign command flags
+type signOptions struct {
+	keyPath        string
+	keyUUID        string
+	signerCmd      string
+	pubKeyPath     string
+	writePublicKey bool
+	outputFile     string
+	outputFormat   string
+}
+
+// CreateSignCommand creates the sign command
+func CreateSignCommand() *cobra.Command {
+	var opts signOptions
+
+	cmd := &cobra.Command{
+		Use:   "sign",
+		Short: "Sign a firmware image with an RSA key",
+		Long: `Sign a firmware image with a local RSA 2048/4096 private key or an external signer.
+
+The key size selects IMAGE_SIGNATURE_256 (SHA-256) or IMAGE_SIGNATURE_512
+(SHA-512). The HASHES_TABLE is regenerated before signing, and the CRCs of
+every modified section are fixed. With --write-public-key the public key is
+stored in PUBLIC_KEYS_2048/4096 under the key pair UUID.
+
+--signer-cmd runs an external program instead of using --key, e.g. an HSM or
+PKCS#11 client. It receives the digest on stdin and ` + security.SignHashEnv + `
+(SHA256 or SHA512) in its environment, and must print the raw PKCS#1 v1.5
+signature on stdout. --pubkey names its public key.
+
+Examples:
+  mlx5fw-go sign -f modified.bin --key dev.pem --key-uuid 3a1f0c2e-8c4b-11ec-9b6f-0242ac120002 -o signed.bin
+  mlx5fw-go sign -f modified.bin --key dev.pem --key-uuid 3a1f0c2e-8c4b-11ec-9b6f-0242ac120002 --write-public-key -o signed.bin
+  mlx5fw-go sign -f modified.bin --signer-cmd "hsm-sign --slot 2" --pubkey hsm.pem --key-uuid 3a1f0c2e-8c4b-11ec-9b6f-0242ac120002 -o signed.bin`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cliutil.ValidateFirmwarePath(firmwarePath); err != nil {
+				return err
+			}
+			return runSignCommand(opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.keyPath, "key", "", "PEM file with the RSA private key (PKCS#1 or PKCS#8)")
+	cmd.Flags().StringVar(&opts.keyUUID, "key-uuid", "", "Key pair UUID stored with the signature (required)")
+	cmd.Flags().StringVar(&opts.signerCmd, "signer-cmd", "", "External signer command used instead of --key")
+	cmd.Flags().StringVar(&opts.pubKeyPath, "pubkey", "", "PEM file with the public key of --signer-cmd")
+	cmd.Flags().BoolVar(&opts.writePublicKey, "write-public-key", false, "Store the public key in the PUBLIC_KEYS section")
+	cmd.Flags().StringVarP(&opts.outputFile, "output", "o", "", "Output firmware file (required)")
+	cmd.Flags().StringVar(&opts.outputFormat, "output-format", "", outputFormatUsage)
+	cmd.MarkFlagRequired("key-uuid")
+	cmd.MarkFlagRequired("output")
+
+	return cmd
+}
+
+// newSigner returns the signer selected by the sign flags
+func newSigner(opts signOptions) (crypto.Signer, error) {
+	switch {
+	case opts.keyPath != "" && opts.signerCmd != "":
+		return nil, merry.New("--key and --signer-cmd are mutually exclusive")
+	case opts.keyPath != "":
+		return security.LoadPrivateKeyPEM(opts.keyPath)
+	case opts.signerCmd != "":
+		if opts.pubKeyPath == "" {
+			return nil, merry.New("--signer-cmd requires --pubkey")
+		}
+		pubKey, err := security.LoadPublicKeyPEM(opts.pubKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		return &security.CommandSigner{Command: strings.Fields(opts.signerCmd), PublicKey: pubKey}, nil
+	default:
+		return nil, merry.New("either --key or --signer-cmd is required")
+	}
+}
+
+func runSignCommand(opts signOptions) error {
+	outputFormat, err := imgfmt.OutputFormat(opts.outputFormat, opts.outputFile)
+	if err != nil {
+		return err
+	}
+	keyUUID, err := security.ParseUUID(opts.keyUUID)
+	if err != nil {
+		return err
+	}
+	signer, err := newSigner(opts)
+	if err != nil {
+		return err
+	}
+
+	ctx, err := cliutil.InitializeFirmwareParser(firmwarePath, logger, parserOptions()...)
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	// Reader bytes may be a read-only mapping, so sign a copy
+	original, err := ctx.Reader.Bytes()
+	if err != nil {
+		return merry.Wrap(err)
+	}
+	data := append([]byte(nil), original...)
+
+	report, err := security.SignImage(data, ctx.Parser, signer, security.SignOptions{
+		KeypairUUID:    keyUUID,
+		WritePublicKey: opts.writePublicKey,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := writeModifiedImage(ctx, data, opts.outputFile, outputFormat); err != nil {
+		return err
+	}
+	logger.Info("Signed firmware image", zap.Uint64("payloadSize", report.PayloadSize))
+
+	if jsonOutput {
+		return cliutil.EncodeJSONIndent(os.Stdout, report)
+	}
+	for _, s := range report.Signatures {
+		fmt.Printf("%-20s @ 0x%08x  %s  %s\n", s.Section, s.Offset, s.Algorithm, s.Status)
+	}
+	return nil
+}
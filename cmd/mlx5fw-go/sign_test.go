@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/Civil/mlx5fw-go/pkg/parser/fs4"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+const testEncSignatureAddr = 0x8000
+
+func TestRunSignCommand_EncryptedImage(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	dir := t.TempDir()
+	firmwarePath = filepath.Join(dir, "fw.bin")
+	t.Cleanup(func() { firmwarePath, imageKey = "", nil })
+	mainCode := bytes.Repeat([]byte{0x3c}, 0x1000)
+	require.NoError(t, os.WriteFile(firmwarePath, createEncryptedImage(t, []testSection{
+		{types.SectionTypeMainCode, testEncMainCodeAddr, mainCode},
+		{types.SectionTypeImageSignature256, testEncSignatureAddr, bytes.Repeat([]byte{0xFF}, 0x120)},
+	}), 0o600))
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	keyPath := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(keyPath,
+		pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0o600))
+
+	imageKey = testImageKey
+	output := filepath.Join(dir, "signed.bin")
+	require.NoError(t, runSignCommand(signOptions{
+		keyPath:    keyPath,
+		keyUUID:    "3a1f0c2e-8c4b-11ec-9b6f-0242ac120002",
+		outputFile: output,
+	}))
+
+	// The signed image must be encrypted again with the same key
+	signed, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.NotEqual(t, uint32(types.ITOCSignature), binary.BigEndian.Uint32(signed[testEncITOCAddr:]))
+	assert.False(t, bytes.Contains(signed, mainCode), "MAIN_CODE is stored in plaintext")
+
+	plain, _, err := fs4.DecryptImage(signed, testImageKey)
+	require.NoError(t, err)
+	assert.Equal(t, mainCode, plain[testEncMainCodeAddr:testEncMainCodeAddr+0x1000])
+	assert.NotEqual(t, bytes.Repeat([]byte{0xFF}, 0x100), plain[testEncSignatureAddr+0x20:testEncSignatureAddr+0x120],
+		"IMAGE_SIGNATURE_256 was not written")
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ansel1/merry/v2"
+	"github.com/spf13/cobra"
+
+	cliutil "github.com/Civil/mlx5fw-go/pkg/cliutil"
+	"github.com/Civil/mlx5fw-go/pkg/security"
+)
+
+// CreateVerifyCommand creates the verify command
+func CreateVerifyCommand() *cobra.Command {
+	var checkHashes bool
+
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify firmware integrity",
+		Long: `Verify the integrity of a firmware image, like flint verify.
+
+The checks cover the magic pattern, the HW pointer CRCs, the BOOT2 and
+TOOLS_AREA CRCs, the ITOC and DTOC header CRCs, the CRC of every section, the
+HASHES_TABLE digests (when the image has one) and the section layout: no
+section may overlap another or extend beyond the image. The command exits
+with an error when any check fails.
+
+Examples:
+  mlx5fw-go verify -f firmware.bin
+  mlx5fw-go verify -f firmware.bin --json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cliutil.ValidateFirmwarePath(firmwarePath); err != nil {
+				return err
+			}
+			return runVerifyCommand()
+		},
+	}
+
+	cmd.Flags().BoolVar(&checkHashes, "hashes", false, "Verify the HASHES_TABLE digests against the section contents")
+	cmd.Flags().MarkDeprecated("hashes", "the HASHES_TABLE is always verified when present")
+
+	return cmd
+}
+
+func runVerifyCommand() error {
+	ctx, err := cliutil.InitializeFirmwareParser(firmwarePath, logger, parserOptions()...)
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	data, err := ctx.Reader.Bytes()
+	if err != nil {
+		return merry.Wrap(err)
+	}
+	report := security.VerifyIntegrity(data, ctx.Parser)
+
+	if jsonOutput {
+		if err := cliutil.EncodeJSONIndent(os.Stdout, report); err != nil {
+			return err
+		}
+	} else {
+		displayVerify(report)
+	}
+
+	if !report.OK {
+		return merry.New("firmware verification failed")
+	}
+	return nil
+}
+
+func displayVerify(report *security.IntegrityReport) {
+	fmt.Printf("%s failsafe image\n\n", report.Format)
+	for _, c := range report.Checks {
+		displayIntegrityCheck(c, 1)
+	}
+
+	fmt.Println()
+	if report.OK {
+		fmt.Println("-I- FW image verification succeeded. Image is bootable.")
+	} else {
+		fmt.Println("-E- FW image verification failed. Image is not bootable.")
+	}
+}
+
+// displayIntegrityCheck prints a check in the flint verify layout, with its
+// children indented below it
+func displayIntegrityCheck(c security.IntegrityCheck, depth int) {
+	status := string(c.Status)
+	if c.Message != "" {
+		status += " (" + c.Message + ")"
+	}
+	indent := strings.Repeat("    ", depth)
+	if c.Size == 0 {
+		fmt.Printf("%s (%s) - %s\n", indent, c.Name, status)
+	} else {
+		fmt.Printf("%s /0x%08x-0x%08x (0x%06x)/ (%s) - %s\n",
+			indent, c.Offset, c.Offset+c.Size-1, c.Size, c.Name, status)
+	}
+	for _, child := range c.Children {
+		displayIntegrityCheck(child, depth+1)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ansel1/merry/v2"
+	"github.com/spf13/cobra"
+
+	cliutil "github.com/Civil/mlx5fw-go/pkg/cliutil"
+	"github.com/Civil/mlx5fw-go/pkg/security"
+)
+
+// CreateVerifyHMACCommand creates the verify-hmac command
+func CreateVerifyHMACCommand() *cobra.Command {
+	var keyPath string
+
+	cmd := &cobra.Command{
+		Use:   "verify-hmac",
+		Short: "Verify the HMAC_DIGEST of an image with a user-supplied key",
+		Long: `Verify the HMAC_DIGEST sections of a firmware image with an HMAC key.
+
+The covered data is, as in mstflint's FwSignWithHmac, the data of the
+critical ITOC sections (code, IMAGE_INFO and the HW/FW configuration) in ITOC
+order followed by the data of the other ITOC sections, leaving out the
+HMAC_DIGEST itself. The digest size stored in the section selects
+HMAC-SHA256, -SHA384 or -SHA512. The ITOC is needed to find the sections, so
+encrypted images are only checked when decrypted with --image-key.
+
+The key file holds the raw key or the key as hex text.
+
+Examples:
+  mlx5fw-go verify-hmac -f firmware.bin --hmac-key key.hex
+  mlx5fw-go verify-hmac -f firmware.bin --hmac-key key.bin --json
+  mlx5fw-go verify-hmac -f encrypted.bin --image-key image.key --hmac-key key.hex`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cliutil.ValidateFirmwarePath(firmwarePath); err != nil {
+				return err
+			}
+			return runVerifyHMACCommand(keyPath)
+		},
+	}
+
+	cmd.Flags().StringVar(&keyPath, "hmac-key", "", "File with the HMAC key, raw or hex (required)")
+	cmd.MarkFlagRequired("hmac-key")
+
+	return cmd
+}
+
+func runVerifyHMACCommand(keyPath string) error {
+	key, err := security.LoadKeyFile(keyPath)
+	if err != nil {
+		return err
+	}
+
+	ctx, err := cliutil.InitializeFirmwareParser(firmwarePath, logger, parserOptions()...)
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	data, err := ctx.Reader.Bytes()
+	if err != nil {
+		return merry.Wrap(err)
+	}
+	report, err := security.VerifyHMAC(data, ctx.Parser, key)
+	if err != nil {
+		return merry.Prepend(err, "failed to verify HMAC")
+	}
+
+	if jsonOutput {
+		if err := cliutil.EncodeJSONIndent(os.Stdout, report); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("Covered data: 0x%x critical and 0x%x non-critical bytes\n\n",
+			report.CriticalSize, report.NonCriticalSize)
+		for _, d := range report.Digests {
+			fmt.Printf("%-12s @ 0x%08x  %-12s %s\n", d.Section, d.Offset, d.Algorithm, d.Status)
+			if d.Status != security.HashMatch {
+				fmt.Printf("  Stored:     %s\n", d.Stored)
+				fmt.Printf("  Calculated: %s\n", d.Calculated)
+			}
+		}
+		fmt.Println()
+		if report.OK {
+			fmt.Println("-I- HMAC verification succeeded.")
+		} else {
+			fmt.Println("-E- HMAC verification failed.")
+		}
+	}
+
+	if !report.OK {
+		return merry.New("HMAC verification failed")
+	}
+	return nil
+}
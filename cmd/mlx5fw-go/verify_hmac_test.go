@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+	"github.com/Civil/mlx5fw-go/pkg/parser"
+	"github.com/Civil/mlx5fw-go/pkg/parser/fs4"
+	"github.com/Civil/mlx5fw-go/pkg/section"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+const (
+	testEncITOCAddr     = 0x5000
+	testEncMainCodeAddr = 0x6000
+	testEncHMACAddr     = 0x7000
+)
+
+var (
+	testImageKey = bytes.Repeat([]byte{0x5a}, 64)
+	testHMACKey  = []byte("0123456789abcdef0123456789abcdef")
+)
+
+// testSection is one ITOC section of a test image
+type testSection struct {
+	sectionType uint16
+	addr        uint32
+	data        []byte
+}
+
+// createEncryptedImage builds a 32MB FS4 image whose ITOC lists sections,
+// encrypted with testImageKey
+func createEncryptedImage(t *testing.T, sections []testSection) []byte {
+	t.Helper()
+	crc := parser.NewCRCCalculator()
+	data := bytes.Repeat([]byte{0xFF}, section.FirmwareSize32MB)
+	binary.BigEndian.PutUint64(data, types.MagicPattern)
+	entry := data[types.HWPointersOffsetFromMagic+2*8:][:8]
+	binary.BigEndian.PutUint32(entry, testEncITOCAddr)
+	binary.BigEndian.PutUint16(entry[4:], 0)
+	binary.BigEndian.PutUint16(entry[6:], crc.CalculateHardwareCRC(entry[:6]))
+
+	header := data[testEncITOCAddr : testEncITOCAddr+types.ITOCHeaderSize]
+	clear(header)
+	binary.BigEndian.PutUint32(header, types.ITOCSignature)
+	fs4.UpdateITOCHeaderCRC(header, crc)
+
+	for i, s := range sections {
+		copy(data[s.addr:], s.data)
+		itocEntry := &types.ITOCEntry{Type: uint8(s.sectionType), FlashAddrDwords: s.addr, CRCField: uint8(types.CRCNone)}
+		itocEntry.SetSize(uint32(len(s.data)))
+		raw, err := itocEntry.Marshal()
+		require.NoError(t, err)
+		binary.BigEndian.PutUint16(raw[30:], crc.CalculateImageCRC(raw[:28], 7))
+		copy(data[testEncITOCAddr+types.ITOCHeaderSize+i*types.ITOCEntrySize:], raw)
+	}
+
+	layout, err := fs4.EncryptionLayoutOf(data, testEncITOCAddr)
+	require.NoError(t, err)
+	require.NoError(t, fs4.EncryptImage(data, testImageKey, layout))
+	return data
+}
+
+// createEncryptedHMACImage builds an encrypted image with MAIN_CODE and an
+// HMAC-SHA512 HMAC_DIGEST over it made with testHMACKey
+func createEncryptedHMACImage(t *testing.T) []byte {
+	t.Helper()
+	mainCode := make([]byte, 0x1000)
+	for i := range mainCode {
+		mainCode[i] = byte(i)
+	}
+	// MAIN_CODE is the only section the HMAC covers
+	mac := hmac.New(sha512.New, testHMACKey)
+	mac.Write(mainCode)
+	digest := make([]byte, types.HMACDigestSize)
+	binary.BigEndian.PutUint32(digest, 1)
+	binary.BigEndian.PutUint32(digest[4:], sha512.Size)
+	copy(digest[8:], mac.Sum(nil))
+
+	return createEncryptedImage(t, []testSection{
+		{types.SectionTypeMainCode, testEncMainCodeAddr, mainCode},
+		{types.SectionTypeHMACDigest, testEncHMACAddr, digest},
+	})
+}
+
+func TestRunVerifyHMACCommand_EncryptedImage(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	dir := t.TempDir()
+	firmwarePath = filepath.Join(dir, "fw.bin")
+	t.Cleanup(func() { firmwarePath, imageKey = "", nil })
+	require.NoError(t, os.WriteFile(firmwarePath, createEncryptedHMACImage(t), 0o600))
+	keyPath := filepath.Join(dir, "hmac.hex")
+	require.NoError(t, os.WriteFile(keyPath, []byte(hex.EncodeToString(testHMACKey)), 0o600))
+
+	// The HMAC covers ITOC sections, so the image key is needed
+	err := runVerifyHMACCommand(keyPath)
+	require.Error(t, err)
+	require.True(t, errors.Is(err, pkgerrors.ErrNotSupported), "error = %v", err)
+
+	imageKey = testImageKey
+	require.NoError(t, runVerifyHMACCommand(keyPath))
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ansel1/merry/v2"
+	"github.com/spf13/cobra"
+
+	cliutil "github.com/Civil/mlx5fw-go/pkg/cliutil"
+	"github.com/Civil/mlx5fw-go/pkg/security"
+)
+
+// CreateVerifySignatureCommand creates the verify-signature command
+func CreateVerifySignatureCommand() *cobra.Command {
+	var pubKeyPath string
+	var keyUUID string
+
+	cmd := &cobra.Command{
+		Use:   "verify-signature",
+		Short: "Verify the RSA image signatures offline",
+		Long: `Verify the IMAGE_SIGNATURE_256/512 sections of a firmware image without a device.
+
+The signed payload is rebuilt the way mstflint does: the image from its magic
+pattern to the end of the last ITOC section, with the signature sections and
+device data masked. Each signature is checked with the key whose UUID matches
+its key pair UUID, taken from the PUBLIC_KEYS_2048/4096 sections of the image
+or, with --pubkey, from a PEM file.
+
+The command fails when the image is unsigned or any signature does not verify.
+
+Examples:
+  mlx5fw-go verify-signature -f firmware.bin
+  mlx5fw-go verify-signature -f firmware.bin --pubkey release.pem
+  mlx5fw-go verify-signature -f firmware.bin --pubkey release.pem --key-uuid 3a1f0c2e-8c4b-11ec-9b6f-0242ac120002 --json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cliutil.ValidateFirmwarePath(firmwarePath); err != nil {
+				return err
+			}
+			return runVerifySignatureCommand(pubKeyPath, keyUUID)
+		},
+	}
+
+	cmd.Flags().StringVar(&pubKeyPath, "pubkey", "", "PEM file with the RSA public key (public key, certificate or private key) to verify with instead of the embedded keys")
+	cmd.Flags().StringVar(&keyUUID, "key-uuid", "", "Only use --pubkey for signatures made with this key pair UUID")
+
+	return cmd
+}
+
+// signatureKeys returns the keys to verify with: the PEM key when given, else
+// the keys embedded in the image
+func signatureKeys(ctx *cliutil.ParserContext, data []byte, pubKeyPath, keyUUID string) ([]security.PublicKey, error) {
+	if pubKeyPath == "" {
+		if keyUUID != "" {
+			return nil, merry.New("--key-uuid requires --pubkey")
+		}
+		return security.EmbeddedPublicKeys(data, ctx.Parser)
+	}
+
+	key, err := security.LoadPublicKeyPEM(pubKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	pubKey := security.PublicKey{Key: key, Source: pubKeyPath}
+	if keyUUID != "" {
+		if pubKey.UUID, err = security.ParseUUID(keyUUID); err != nil {
+			return nil, err
+		}
+	}
+	return []security.PublicKey{pubKey}, nil
+}
+
+func runVerifySignatureCommand(pubKeyPath, keyUUID string) error {
+	ctx, err := cliutil.InitializeFirmwareParser(firmwarePath, logger, parserOptions()...)
+	if err != nil {
+		return err
+	}
+	defer ctx.Close()
+
+	data, err := ctx.Reader.Bytes()
+	if err != nil {
+		return merry.Wrap(err)
+	}
+	keys, err := signatureKeys(ctx, data, pubKeyPath, keyUUID)
+	if err != nil {
+		return err
+	}
+
+	report, err := security.VerifySignatures(data, ctx.Parser, keys)
+	if err != nil {
+		return merry.Prepend(err, "failed to verify signatures")
+	}
+
+	if jsonOutput {
+		if err := cliutil.EncodeJSONIndent(os.Stdout, report); err != nil {
+			return err
+		}
+	} else {
+		displaySignatureReport(report)
+	}
+
+	if len(report.Signatures) == 0 {
+		return merry.New("image is not signed")
+	}
+	if !report.OK {
+		return merry.New("signature verification failed")
+	}
+	return nil
+}
+
+func displaySignatureReport(report *security.SignatureReport) {
+	fmt.Printf("Signed payload: 0x%08x-0x%08x (0x%x bytes)\n\n",
+		report.PayloadOffset, report.PayloadOffset+report.PayloadSize-1, report.PayloadSize)
+	if len(report.Signatures) == 0 {
+		fmt.Println("No IMAGE_SIGNATURE sections found")
+	}
+	for _, s := range report.Signatures {
+		fmt.Printf("%-20s @ 0x%08x  %s\n", s.Section, s.Offset, s.Algorithm)
+		fmt.Printf("  Key pair UUID:  %s\n", s.KeypairUUID)
+		fmt.Printf("  Signature UUID: %s\n", s.SignatureUUID)
+		if s.KeySource != "" {
+			fmt.Printf("  Key:            %s\n", s.KeySource)
+		}
+		fmt.Printf("  Status:         %s\n", s.Status)
+	}
+
+	fmt.Println()
+	if report.OK {
+		fmt.Println("-I- Image signature verification succeeded.")
+	} else {
+		fmt.Println("-E- Image signature verification failed.")
+	}
+}
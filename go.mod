@@ -4,8 +4,10 @@ go 1.22
 
 require (
 	github.com/ansel1/merry/v2 v2.1.1
+	github.com/klauspost/compress v1.18.0
 	github.com/spf13/cobra v1.8.0
 	github.com/stretchr/testify v1.8.1
+	github.com/ulikunitz/xz v0.5.15
 	go.uber.org/zap v1.27.0
 )
 
@@ -0,0 +1,66 @@
+package mlx5fw_test
+
+import (
+	"io"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/Civil/mlx5fw-go/mlx5fw"
+)
+
+// This file pins the public API of package mlx5fw. It fails to compile when
+// an exported function, method, type or field is removed or changes its
+// signature. Such changes must bump APIVersion; additions need no change here.
+
+var (
+	_ func(string, *mlx5fw.Options) (*mlx5fw.Firmware, error)    = mlx5fw.Open
+	_ func(io.Reader, *mlx5fw.Options) (*mlx5fw.Firmware, error) = mlx5fw.OpenReader
+	_ func(string, string, *mlx5fw.ReassembleOptions) error      = mlx5fw.Reassemble
+	_ func(a, b *mlx5fw.Firmware) (*mlx5fw.DiffResult, error)    = mlx5fw.Diff
+
+	_ func(*mlx5fw.Firmware) error                                 = (*mlx5fw.Firmware).Close
+	_ func(*mlx5fw.Firmware) string                                = (*mlx5fw.Firmware).Format
+	_ func(*mlx5fw.Firmware) int64                                 = (*mlx5fw.Firmware).Size
+	_ func(*mlx5fw.Firmware) ([]byte, error)                       = (*mlx5fw.Firmware).Bytes
+	_ func(*mlx5fw.Firmware) (*mlx5fw.Info, error)                 = (*mlx5fw.Firmware).Query
+	_ func(*mlx5fw.Firmware) []mlx5fw.Section                      = (*mlx5fw.Firmware).Sections
+	_ func(*mlx5fw.Firmware) (*mlx5fw.VerifyResult, error)         = (*mlx5fw.Firmware).Verify
+	_ func(*mlx5fw.Firmware, string, *mlx5fw.ExtractOptions) error = (*mlx5fw.Firmware).Extract
+	_ func(*mlx5fw.Firmware, string, int, []byte) ([]byte, error)  = (*mlx5fw.Firmware).ReplaceSection
+	_ func(*mlx5fw.Firmware) []mlx5fw.Diagnostic                   = (*mlx5fw.Firmware).Diagnostics
+
+	_ error = mlx5fw.ErrUnknownFormat
+	_ error = mlx5fw.ErrNotSupported
+	_ error = mlx5fw.ErrSectionNotFound
+	_ error = mlx5fw.ErrPSIDNotFound
+
+	_ = mlx5fw.Options{PSID: "", Logger: (*zap.Logger)(nil)}
+	_ = mlx5fw.ExtractOptions{KeepBinary: false}
+	_ = mlx5fw.ReassembleOptions{VerifyCRC: false, BinaryOnly: false, OutputFormat: "", Logger: (*zap.Logger)(nil)}
+
+	_ = mlx5fw.Info{
+		Format: "", FWVersion: "", FWReleaseDate: "", ProductVersion: "",
+		PartNumber: "", Description: "", PSID: "", PRSName: "", ImageVSD: "",
+		SecurityAttrs: "", Encrypted: false, ROMs: []mlx5fw.ROM{{Type: "", Version: "", CPU: ""}},
+		BaseGUID: uint64(0), NumGUIDs: int(0), BaseMAC: uint64(0), NumMACs: int(0),
+		DeviceID: uint16(0), VendorID: uint16(0), ImageSize: uint64(0), SecurityVer: int(0),
+	}
+	_ = mlx5fw.Section{
+		Type: uint16(0), Name: "", Offset: uint64(0), Size: uint32(0),
+		CRCType: "", Encrypted: false, DeviceData: false,
+	}
+	_ = mlx5fw.Diagnostic{Severity: "", Code: "", Offset: uint64(0), Section: "", Message: ""}
+	_ = mlx5fw.VerifyResult{OK: false, Sections: []mlx5fw.SectionStatus{{Section: mlx5fw.Section{}, Status: "", OK: false}}}
+	_ = mlx5fw.DiffResult{Identical: false, Sections: []mlx5fw.SectionDiff{{
+		Name: "", Type: uint16(0), OffsetA: uint64(0), OffsetB: uint64(0),
+		SizeA: uint32(0), SizeB: uint32(0), Identical: false, FirstDiff: int64(0), MissingIn: "",
+	}}}
+)
+
+func TestAPIVersion(t *testing.T) {
+	// Bump together with the assertions above when the API breaks
+	if mlx5fw.APIVersion != 1 {
+		t.Errorf("APIVersion = %d, update the API assertions in api_test.go", mlx5fw.APIVersion)
+	}
+}
@@ -0,0 +1,113 @@
+package mlx5fw
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/Civil/mlx5fw-go/pkg/cliutil"
+)
+
+// DiffResult is the result of Diff
+type DiffResult struct {
+	// Identical is true when both images are byte-for-byte equal
+	Identical bool
+	// Sections pairs the sections of both images by type and position
+	Sections []SectionDiff
+}
+
+// SectionDiff compares one section of image A with its counterpart in image B
+type SectionDiff struct {
+	Name    string
+	Type    uint16
+	OffsetA uint64
+	OffsetB uint64
+	SizeA   uint32
+	SizeB   uint32
+	// Identical is true when both sections have the same contents
+	Identical bool
+	// FirstDiff is the offset of the first differing byte within the section, or -1
+	FirstDiff int64
+	// MissingIn is "A" or "B" when the section exists in one image only
+	MissingIn string
+}
+
+// Diff compares two images byte-wise and section by section. Sections are
+// matched by type and, within a type, by their order in the image, like the
+// diff command does.
+func Diff(a, b *Firmware) (*DiffResult, error) {
+	dataA, err := a.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	dataB, err := b.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DiffResult{Identical: bytes.Equal(dataA, dataB)}
+
+	ctxA := &cliutil.ParserContext{Logger: a.logger, Reader: a.reader, Parser: a.parser}
+	ctxB := &cliutil.ParserContext{Logger: b.logger, Reader: b.reader, Parser: b.parser}
+	groupsA := cliutil.CollectSectionsByType(ctxA)
+	groupsB := cliutil.CollectSectionsByType(ctxB)
+
+	seen := make(map[uint16]struct{})
+	for t := range groupsA {
+		seen[t] = struct{}{}
+	}
+	for t := range groupsB {
+		seen[t] = struct{}{}
+	}
+	sectionTypes := make([]uint16, 0, len(seen))
+	for t := range seen {
+		sectionTypes = append(sectionTypes, t)
+	}
+	sort.Slice(sectionTypes, func(i, j int) bool { return sectionTypes[i] < sectionTypes[j] })
+
+	for _, t := range sectionTypes {
+		listA, listB := groupsA[t], groupsB[t]
+		for i := 0; i < max(len(listA), len(listB)); i++ {
+			d := SectionDiff{Type: t, FirstDiff: -1}
+			switch {
+			case i >= len(listA):
+				sb := listB[i]
+				d.Name, d.OffsetB, d.SizeB, d.MissingIn = sb.Name, sb.Offset, sb.Size, "A"
+			case i >= len(listB):
+				sa := listA[i]
+				d.Name, d.OffsetA, d.SizeA, d.MissingIn = sa.Name, sa.Offset, sa.Size, "B"
+			default:
+				sa, sb := listA[i], listB[i]
+				d.Name = sa.Name
+				d.OffsetA, d.OffsetB = sa.Offset, sb.Offset
+				d.SizeA, d.SizeB = sa.Size, sb.Size
+
+				bytesA, err := cliutil.ReadSectionBytes(ctxA, sa)
+				if err != nil {
+					return nil, err
+				}
+				bytesB, err := cliutil.ReadSectionBytes(ctxB, sb)
+				if err != nil {
+					return nil, err
+				}
+				d.Identical = bytes.Equal(bytesA, bytesB)
+				if !d.Identical {
+					d.FirstDiff = int64(firstDifference(bytesA, bytesB))
+				}
+			}
+			result.Sections = append(result.Sections, d)
+		}
+	}
+	return result, nil
+}
+
+// firstDifference returns the index of the first differing byte of a and b;
+// when one is a prefix of the other it is the length of the shorter one
+func firstDifference(a, b []byte) int {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
@@ -0,0 +1,125 @@
+package mlx5fw_test
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/Civil/mlx5fw-go/mlx5fw"
+)
+
+func ExampleOpen() {
+	fw, err := mlx5fw.Open("fw-ConnectX6Dx.bin", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer fw.Close()
+
+	info, err := fw.Query()
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%s %s (%s)\n", info.PSID, info.FWVersion, fw.Format())
+}
+
+func ExampleOpen_archive() {
+	// MFA2 archives hold one image per PSID
+	fw, err := mlx5fw.Open("fw-ConnectX6Dx.mfa2", &mlx5fw.Options{PSID: "MT_0000000359"})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer fw.Close()
+	fmt.Println(fw.Format())
+}
+
+func ExampleFirmware_Sections() {
+	fw, err := mlx5fw.Open("fw-ConnectX6Dx.bin", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer fw.Close()
+
+	for _, s := range fw.Sections() {
+		fmt.Printf("0x%08x %-20s 0x%x\n", s.Offset, s.Name, s.Size)
+	}
+}
+
+func ExampleFirmware_Verify() {
+	fw, err := mlx5fw.Open("fw-ConnectX6Dx.bin", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer fw.Close()
+
+	result, err := fw.Verify()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, s := range result.Sections {
+		if !s.OK {
+			fmt.Printf("%s at 0x%x: %s\n", s.Name, s.Offset, s.Status)
+		}
+	}
+	if !result.OK {
+		os.Exit(1)
+	}
+}
+
+func ExampleReassemble() {
+	fw, err := mlx5fw.Open("fw-ConnectX6Dx.bin", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer fw.Close()
+
+	if err := fw.Extract("extracted", nil); err != nil {
+		log.Fatal(err)
+	}
+	// Edit extracted/*.json here, then rebuild the image
+	if err := mlx5fw.Reassemble("extracted", "fw-rebuilt.bin", &mlx5fw.ReassembleOptions{VerifyCRC: true}); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func ExampleFirmware_ReplaceSection() {
+	fw, err := mlx5fw.Open("fw-ConnectX6Dx.bin", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer fw.Close()
+
+	ini, err := os.ReadFile("DBG_FW_INI.bin")
+	if err != nil {
+		log.Fatal(err)
+	}
+	image, err := fw.ReplaceSection("DBG_FW_INI", -1, ini)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("fw-new.bin", image, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func ExampleDiff() {
+	a, err := mlx5fw.Open("fw-old.bin", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer a.Close()
+	b, err := mlx5fw.Open("fw-new.bin", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer b.Close()
+
+	result, err := mlx5fw.Diff(a, b)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, s := range result.Sections {
+		if !s.Identical {
+			fmt.Printf("%s differs (missing in: %q)\n", s.Name, s.MissingIn)
+		}
+	}
+}
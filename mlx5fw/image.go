@@ -0,0 +1,116 @@
+package mlx5fw
+
+import (
+	"sort"
+
+	"github.com/ansel1/merry/v2"
+	"go.uber.org/zap"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+	"github.com/Civil/mlx5fw-go/pkg/extract"
+	"github.com/Civil/mlx5fw-go/pkg/imgfmt"
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/parser/fs4"
+	"github.com/Civil/mlx5fw-go/pkg/reassemble"
+	"github.com/Civil/mlx5fw-go/pkg/section"
+)
+
+// ExtractOptions configures Extract. The zero value is usable.
+type ExtractOptions struct {
+	// KeepBinary writes the raw .bin file next to the JSON of every parsed section
+	KeepBinary bool
+}
+
+// Extract writes every section, the gaps between them and the metadata needed
+// by Reassemble into dir. opts may be nil.
+func (f *Firmware) Extract(dir string, opts *ExtractOptions) error {
+	fwParser, ok := f.parser.(extract.FirmwareParser)
+	if !ok {
+		return pkgerrors.NotSupportedError("extract of " + f.Format() + " firmware")
+	}
+
+	extractOpts := extract.Options{
+		OutputDir:       dir,
+		IncludeMetadata: true,
+		RemoveCRC:       true,
+	}
+	if opts != nil {
+		extractOpts.KeepBinary = opts.KeepBinary
+	}
+	return extract.New(fwParser, f.logger, extractOpts).Extract()
+}
+
+// ReassembleOptions configures Reassemble. The zero value is usable.
+type ReassembleOptions struct {
+	// VerifyCRC verifies the CRCs of the rebuilt image
+	VerifyCRC bool
+	// BinaryOnly ignores the JSON files and uses the extracted .bin files only
+	BinaryOnly bool
+	// OutputFormat is "bin", "ihex" or "srec"; empty infers it from the output extension
+	OutputFormat string
+	// Logger receives debug output; nil disables logging
+	Logger *zap.Logger
+}
+
+// Reassemble rebuilds a firmware image from a directory written by Extract
+// and stores it in output. opts may be nil.
+func Reassemble(dir, output string, opts *ReassembleOptions) error {
+	if opts == nil {
+		opts = &ReassembleOptions{}
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	outputFormat, err := imgfmt.OutputFormat(opts.OutputFormat, output)
+	if err != nil {
+		return err
+	}
+	return reassemble.New(logger, reassemble.Options{
+		InputDir:     dir,
+		OutputFile:   output,
+		VerifyCRC:    opts.VerifyCRC,
+		BinaryOnly:   opts.BinaryOnly,
+		OutputFormat: outputFormat,
+	}).Reassemble()
+}
+
+// ReplaceSection returns a copy of the image with the index-th section named
+// name (as listed by Sections) replaced by data; index -1 selects the first one.
+// CRCs are updated; following sections are relocated when the size changes.
+// The image itself is left untouched. Only FS4 and FS5 images are supported.
+func (f *Firmware) ReplaceSection(name string, index int, data []byte) ([]byte, error) {
+	fwParser, ok := f.parser.(*fs4.Parser)
+	if !ok {
+		return nil, pkgerrors.NotSupportedError("replace-section on " + f.Format() + " firmware")
+	}
+
+	// Number the sections named name in the order Sections lists them, so the
+	// index does not depend on map iteration
+	var matches []interfaces.CompleteSectionInterface
+	for _, list := range fwParser.GetSections() {
+		for _, s := range list {
+			if s.TypeName() == name {
+				matches = append(matches, s)
+			}
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return lessSection(newSection(matches[i]), newSection(matches[j]))
+	})
+	i := index
+	if i == -1 {
+		i = 0
+	}
+	if i < 0 || i >= len(matches) {
+		return nil, merry.Wrap(pkgerrors.ErrSectionNotFound, merry.WithMessagef("section %s with index %d not found", name, index))
+	}
+	target := matches[i]
+
+	image, err := f.reader.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return section.NewReplacer(fwParser, image, f.logger).ReplaceSection(target, data)
+}
@@ -0,0 +1,118 @@
+// Package mlx5fw is the stable library API of mlx5fw-go.
+//
+// It wraps the format parsers, extractor, reassembler and section replacer
+// behind a small set of functions and plain result types that do not change
+// shape when the packages under pkg/ are refactored. Code outside this
+// repository should prefer it over importing pkg/parser/fs4, pkg/extract or
+// pkg/types/sections directly.
+//
+// Compatibility: exported identifiers of this package are only ever added to.
+// A change that breaks existing callers increments APIVersion.
+package mlx5fw
+
+import (
+	"io"
+
+	"go.uber.org/zap"
+
+	"github.com/Civil/mlx5fw-go/pkg/cliutil"
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/parser"
+)
+
+// APIVersion is the major version of this package's API
+const APIVersion = 1
+
+// Errors returned by this package; test for them with errors.Is
+var (
+	// ErrUnknownFormat is returned by Open when no firmware format matches the image
+	ErrUnknownFormat = pkgerrors.ErrInvalidMagic
+	// ErrNotSupported is returned when an operation is not available for the image format
+	ErrNotSupported = pkgerrors.ErrNotSupported
+	// ErrSectionNotFound is returned by ReplaceSection when no section matches
+	ErrSectionNotFound = pkgerrors.ErrSectionNotFound
+	// ErrPSIDNotFound is returned by Open when an MFA2 archive has no image for the PSID
+	ErrPSIDNotFound = pkgerrors.ErrPSIDNotFound
+)
+
+// Options configures Open and OpenReader. The zero value is usable.
+type Options struct {
+	// PSID selects the image when the input is an MFA2 archive
+	PSID string
+	// Logger receives debug output; nil disables logging
+	Logger *zap.Logger
+}
+
+func (o *Options) logger() *zap.Logger {
+	if o == nil || o.Logger == nil {
+		return zap.NewNop()
+	}
+	return o.Logger
+}
+
+func (o *Options) psid() string {
+	if o == nil {
+		return ""
+	}
+	return o.PSID
+}
+
+// Firmware is an opened and parsed firmware image. It must be closed after use.
+type Firmware struct {
+	reader *parser.FirmwareReader
+	parser interfaces.FirmwareParser
+	logger *zap.Logger
+}
+
+// Open opens and parses a firmware image. The path may name a raw, compressed,
+// Intel HEX or S-record image, an MFA2 archive (with Options.PSID), or be "-"
+// for standard input. opts may be nil.
+func Open(path string, opts *Options) (*Firmware, error) {
+	logger := opts.logger()
+	reader, err := cliutil.OpenFirmwareImage(path, opts.psid(), logger)
+	if err != nil {
+		return nil, err
+	}
+	return open(reader, logger)
+}
+
+// OpenReader is Open for an image read from r. r is read to the end.
+// MFA2 archives are not detected; use Open for those. opts may be nil.
+func OpenReader(r io.Reader, opts *Options) (*Firmware, error) {
+	logger := opts.logger()
+	reader, err := parser.NewFirmwareReaderFromStream(r, logger)
+	if err != nil {
+		return nil, err
+	}
+	return open(reader, logger)
+}
+
+func open(reader *parser.FirmwareReader, logger *zap.Logger) (*Firmware, error) {
+	fwParser, err := parser.Open(reader, logger)
+	if err != nil {
+		_ = reader.Close()
+		return nil, err
+	}
+	return &Firmware{reader: reader, parser: fwParser, logger: logger}, nil
+}
+
+// Close releases the resources held by the image
+func (f *Firmware) Close() error {
+	return f.reader.Close()
+}
+
+// Format returns the firmware format name ("FS3", "FS4" or "FS5")
+func (f *Firmware) Format() string {
+	return f.parser.GetFormat().String()
+}
+
+// Size returns the image size in bytes
+func (f *Firmware) Size() int64 {
+	return f.reader.Size()
+}
+
+// Bytes returns the complete image. The result must not be modified.
+func (f *Firmware) Bytes() ([]byte, error) {
+	return f.reader.Bytes()
+}
@@ -0,0 +1,298 @@
+package mlx5fw_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+
+	"github.com/Civil/mlx5fw-go/mlx5fw"
+	"github.com/Civil/mlx5fw-go/pkg/parser"
+	"github.com/Civil/mlx5fw-go/pkg/parser/fs4"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+const (
+	testITOCAddr      = 0x1000
+	testImageInfoAddr = 0x2000
+	testDevInfoAddr   = 0x3000
+	testPSID          = "MT_2190110032"
+)
+
+// createTestImage builds a minimal FS3 image: magic pattern and FS3 boot version, BOOT2 at 0x38 and
+// an ITOC listing IMAGE_INFO and DEV_INFO
+func createTestImage(t *testing.T) []byte {
+	t.Helper()
+	crc := parser.NewCRCCalculator()
+
+	data := bytes.Repeat([]byte{0xFF}, 0x4000)
+	binary.BigEndian.PutUint64(data[0:], types.MagicPattern)
+	binary.BigEndian.PutUint64(data[8:], 0xFADE12345678DEAD)
+	data[types.BootVersionOffset] = types.ImageFormatVersionFS3
+
+	boot2 := make([]byte, 0x30)
+	binary.BigEndian.PutUint32(boot2[4:], 8)
+	binary.BigEndian.PutUint32(boot2[0x2C:], uint32(crc.CalculateImageCRC(boot2[:0x2C], 11)))
+	copy(data[types.FS3Boot2Offset:], boot2)
+
+	imageInfo := types.ImageInfo{FWVerMajor: 2, FWVerMinor: 42, FWVerSubminor: 5000, Year: 2024, Month: 6, Day: 27}
+	copy(imageInfo.PSID[:], testPSID)
+	copy(imageInfo.Name[:], "MCX456A-ECAT")
+	copy(imageInfo.Description[:], "ConnectX-4 VPI adapter card")
+	imageInfoData, err := imageInfo.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	devInfo := types.DevInfo{Guids: types.UidEntry{NumAllocated: 8, Step: 1, UID: 0x0002c90300a1b2c0}}
+	devInfoData, err := devInfo.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := make([]byte, types.ITOCHeaderSize)
+	for i, word := range []uint32{types.ITOCSignature, 0x04081516, 0x2342CAFA, 0xBACAFE00} {
+		binary.BigEndian.PutUint32(header[i*4:], word)
+	}
+	binary.BigEndian.PutUint16(header[30:], crc.CalculateImageCRC(header[:28], 7))
+	copy(data[testITOCAddr:], header)
+
+	entries := []types.FS3ITOCEntry{
+		{
+			Type:            uint8(types.SectionTypeImageInfo),
+			SizeDwords:      uint32(len(imageInfoData) / 4),
+			FlashAddrDwords: testImageInfoAddr / 4,
+			SectionCRC:      crc.CalculateImageCRC(imageInfoData, len(imageInfoData)/4),
+		},
+		{
+			Type:            uint8(types.SectionTypeDevInfo),
+			SizeDwords:      uint32(len(devInfoData) / 4),
+			FlashAddrDwords: testDevInfoAddr / 4,
+			NoCRC:           true,
+			DeviceData:      true,
+		},
+		{Type: 0xFF},
+	}
+	for i, entry := range entries {
+		raw, err := entry.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		binary.BigEndian.PutUint16(raw[30:], crc.CalculateImageCRC(raw[:28], 7))
+		copy(data[testITOCAddr+types.ITOCHeaderSize+i*types.ITOCEntrySize:], raw)
+	}
+	copy(data[testImageInfoAddr:], imageInfoData)
+	copy(data[testDevInfoAddr:], devInfoData)
+	return data
+}
+
+func openTestImage(t *testing.T, data []byte) *mlx5fw.Firmware {
+	t.Helper()
+	fw, err := mlx5fw.OpenReader(bytes.NewReader(data), &mlx5fw.Options{Logger: zaptest.NewLogger(t)})
+	if err != nil {
+		t.Fatalf("OpenReader() error = %v", err)
+	}
+	t.Cleanup(func() { fw.Close() })
+	return fw
+}
+
+func TestOpen_UnknownFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "garbage.bin")
+	if err := os.WriteFile(path, make([]byte, 0x1000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := mlx5fw.Open(path, nil)
+	if !errors.Is(err, mlx5fw.ErrUnknownFormat) {
+		t.Errorf("Open() error = %v, want ErrUnknownFormat", err)
+	}
+}
+
+func TestFirmware_QueryAndSections(t *testing.T) {
+	fw := openTestImage(t, createTestImage(t))
+
+	if fw.Format() != "FS3" {
+		t.Errorf("Format() = %q, want FS3", fw.Format())
+	}
+
+	info, err := fw.Query()
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if info.PSID != testPSID || info.FWVersion != "2.42.5000" {
+		t.Errorf("Query() PSID/FWVersion = %q/%q", info.PSID, info.FWVersion)
+	}
+	if info.BaseGUID != 0x0002c90300a1b2c0 || info.NumGUIDs != 8 {
+		t.Errorf("Query() GUID = 0x%x/%d", info.BaseGUID, info.NumGUIDs)
+	}
+
+	var names []string
+	for _, s := range fw.Sections() {
+		names = append(names, s.Name)
+	}
+	want := []string{"BOOT2", "IMAGE_INFO", "DEV_INFO"}
+	if len(names) != len(want) {
+		t.Fatalf("Sections() = %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("Sections() = %v, want %v", names, want)
+		}
+	}
+}
+
+func TestFirmware_Verify(t *testing.T) {
+	data := createTestImage(t)
+	result, err := openTestImage(t, data).Verify()
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if !result.OK {
+		t.Errorf("Verify() of a valid image failed: %+v", result.Sections)
+	}
+
+	data[testImageInfoAddr+0x20] ^= 0xFF
+	result, err = openTestImage(t, data).Verify()
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if result.OK {
+		t.Error("Verify() of a corrupted image passed")
+	}
+	if diags := openTestImage(t, data).Diagnostics(); len(diags) != 0 {
+		t.Errorf("Diagnostics() = %+v, section CRC errors are not parse diagnostics", diags)
+	}
+	for _, s := range result.Sections {
+		if s.OK != (s.Name != "IMAGE_INFO") {
+			t.Errorf("%s: OK = %v, status %q", s.Name, s.OK, s.Status)
+		}
+	}
+}
+
+func TestFirmware_ExtractReassemble(t *testing.T) {
+	original := createTestImage(t)
+	fw := openTestImage(t, original)
+
+	dir := t.TempDir()
+	extractDir := filepath.Join(dir, "extracted")
+	if err := fw.Extract(extractDir, nil); err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	output := filepath.Join(dir, "rebuilt.bin")
+	if err := mlx5fw.Reassemble(extractDir, output, nil); err != nil {
+		t.Fatalf("Reassemble() error = %v", err)
+	}
+
+	rebuilt, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(rebuilt, original) {
+		for i := range original {
+			if i >= len(rebuilt) || rebuilt[i] != original[i] {
+				t.Fatalf("reassembled image differs at 0x%x", i)
+			}
+		}
+		t.Fatalf("reassembled image size %d, want %d", len(rebuilt), len(original))
+	}
+}
+
+func TestFirmware_ReplaceSectionNotSupported(t *testing.T) {
+	fw := openTestImage(t, createTestImage(t))
+	_, err := fw.ReplaceSection("IMAGE_INFO", -1, make([]byte, 0x400))
+	if !errors.Is(err, mlx5fw.ErrNotSupported) {
+		t.Errorf("ReplaceSection() error = %v, want ErrNotSupported", err)
+	}
+}
+
+// createFS4Image builds a 32MB FS4 image whose ITOC lists two PCI_CODE
+// sections, the one at the higher offset first
+func createFS4Image(t *testing.T) []byte {
+	t.Helper()
+	const itocAddr = 0x2000
+	crc := parser.NewCRCCalculator()
+	data := bytes.Repeat([]byte{0xFF}, 32*1024*1024)
+	binary.BigEndian.PutUint64(data, types.MagicPattern)
+	data[types.BootVersionOffset] = types.ImageFormatVersionFS4
+	entry := data[types.HWPointersOffsetFromMagic+2*8:][:8]
+	binary.BigEndian.PutUint32(entry, itocAddr)
+	binary.BigEndian.PutUint16(entry[4:], 0)
+	binary.BigEndian.PutUint16(entry[6:], crc.CalculateHardwareCRC(entry[:6]))
+
+	header := data[itocAddr : itocAddr+types.ITOCHeaderSize]
+	clear(header)
+	binary.BigEndian.PutUint32(header, types.ITOCSignature)
+	fs4.UpdateITOCHeaderCRC(header, crc)
+
+	for i, addr := range []uint32{0x6000, 0x4000} {
+		section := data[addr : addr+0x400]
+		clear(section)
+		section[0] = byte(i)
+		itocEntry := &types.ITOCEntry{Type: uint8(types.SectionTypePCICode), FlashAddrDwords: addr, CRCField: uint8(types.CRCInITOCEntry)}
+		itocEntry.SetSize(0x400)
+		itocEntry.SectionCRC = crc.CalculateImageCRC(section, 0x100)
+		raw, err := itocEntry.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		binary.BigEndian.PutUint16(raw[30:], crc.CalculateImageCRC(raw[:28], 7))
+		copy(data[itocAddr+types.ITOCHeaderSize+i*types.ITOCEntrySize:], raw)
+	}
+	return data
+}
+
+func TestFirmware_ReplaceSectionIndex(t *testing.T) {
+	// Indexes follow the offset order of Sections, not the ITOC order
+	for _, tt := range []struct {
+		index int
+		addr  int
+	}{{-1, 0x4000}, {0, 0x4000}, {1, 0x6000}} {
+		fw := openTestImage(t, createFS4Image(t))
+		replacement := bytes.Repeat([]byte{0xA5}, 0x400)
+		image, err := fw.ReplaceSection("PCI_CODE", tt.index, replacement)
+		if err != nil {
+			t.Fatalf("ReplaceSection(%d) error = %v", tt.index, err)
+		}
+		if !bytes.Equal(image[tt.addr:tt.addr+0x400], replacement) {
+			t.Errorf("ReplaceSection(%d) did not replace the section at 0x%x", tt.index, tt.addr)
+		}
+	}
+
+	fw := openTestImage(t, createFS4Image(t))
+	if _, err := fw.ReplaceSection("PCI_CODE", 2, nil); !errors.Is(err, mlx5fw.ErrSectionNotFound) {
+		t.Errorf("ReplaceSection(2) error = %v, want ErrSectionNotFound", err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := createTestImage(t)
+	b := createTestImage(t)
+	b[testImageInfoAddr+0x10] ^= 0x01
+
+	result, err := mlx5fw.Diff(openTestImage(t, a), openTestImage(t, a))
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if !result.Identical {
+		t.Error("Diff() of identical images reported a difference")
+	}
+
+	result, err = mlx5fw.Diff(openTestImage(t, a), openTestImage(t, b))
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if result.Identical {
+		t.Error("Diff() of different images reported them identical")
+	}
+	for _, s := range result.Sections {
+		changed := s.Name == "IMAGE_INFO"
+		if s.Identical == changed {
+			t.Errorf("%s: Identical = %v", s.Name, s.Identical)
+		}
+		if changed && s.FirstDiff != 0x10 {
+			t.Errorf("%s: FirstDiff = 0x%x, want 0x10", s.Name, s.FirstDiff)
+		}
+	}
+}
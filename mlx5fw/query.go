@@ -0,0 +1,197 @@
+package mlx5fw
+
+import (
+	"sort"
+
+	"go.uber.org/zap"
+
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+)
+
+// Info is the image information reported by Query, mirroring mstflint query
+type Info struct {
+	Format         string
+	FWVersion      string
+	FWReleaseDate  string
+	ProductVersion string
+	PartNumber     string
+	Description    string
+	PSID           string
+	PRSName        string
+	ImageVSD       string
+	SecurityAttrs  string
+	Encrypted      bool
+	ROMs           []ROM
+
+	BaseGUID    uint64
+	NumGUIDs    int
+	BaseMAC     uint64
+	NumMACs     int
+	DeviceID    uint16
+	VendorID    uint16
+	ImageSize   uint64
+	SecurityVer int
+}
+
+// ROM describes an expansion ROM (PXE, UEFI, ...) embedded in the image
+type ROM struct {
+	Type    string
+	Version string
+	CPU     string
+}
+
+// Query returns the image information
+func (f *Firmware) Query() (*Info, error) {
+	fi, err := f.parser.Query()
+	if err != nil {
+		return nil, err
+	}
+
+	info := &Info{
+		Format:         fi.Format,
+		FWVersion:      fi.FWVersion,
+		FWReleaseDate:  fi.FWReleaseDate,
+		ProductVersion: fi.ProductVersion,
+		PartNumber:     fi.PartNumber,
+		Description:    fi.Description,
+		PSID:           fi.PSID,
+		PRSName:        fi.PRSName,
+		ImageVSD:       fi.ImageVSD,
+		SecurityAttrs:  fi.SecurityAttrs,
+		Encrypted:      fi.IsEncrypted,
+		BaseGUID:       fi.BaseGUID,
+		NumGUIDs:       fi.BaseGUIDNum,
+		BaseMAC:        fi.BaseMAC,
+		NumMACs:        fi.BaseMACNum,
+		DeviceID:       fi.DeviceID,
+		VendorID:       fi.VendorID,
+		ImageSize:      fi.ImageSize,
+		SecurityVer:    fi.SecurityVer,
+	}
+	for _, rom := range fi.RomInfo {
+		info.ROMs = append(info.ROMs, ROM{Type: rom.Type, Version: rom.Version, CPU: rom.CPU})
+	}
+	return info, nil
+}
+
+// Section describes one firmware section
+type Section struct {
+	Type       uint16
+	Name       string
+	Offset     uint64
+	Size       uint32
+	CRCType    string // NONE, IN_ITOC_ENTRY or IN_SECTION
+	Encrypted  bool
+	DeviceData bool
+}
+
+func newSection(s interfaces.CompleteSectionInterface) Section {
+	return Section{
+		Type:       s.Type(),
+		Name:       s.TypeName(),
+		Offset:     s.Offset(),
+		Size:       s.Size(),
+		CRCType:    s.CRCType().String(),
+		Encrypted:  s.IsEncrypted(),
+		DeviceData: s.IsDeviceData(),
+	}
+}
+
+// lessSection orders sections by offset, then by type
+func lessSection(a, b Section) bool {
+	if a.Offset != b.Offset {
+		return a.Offset < b.Offset
+	}
+	return a.Type < b.Type
+}
+
+// Sections returns all sections of the image ordered by offset
+func (f *Firmware) Sections() []Section {
+	var result []Section
+	for _, list := range f.parser.GetSections() {
+		for _, s := range list {
+			result = append(result, newSection(s))
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return lessSection(result[i], result[j]) })
+	return result
+}
+
+// SectionStatus is the verification result of one section
+type SectionStatus struct {
+	Section
+	// Status is the mstflint-style status: OK, CRC IGNORED, FAIL (...), ERROR, ...
+	Status string
+	// OK is false when the status makes the image unbootable
+	OK bool
+}
+
+// VerifyResult is the result of Verify
+type VerifyResult struct {
+	// OK is true when every section verified and the TOC headers are valid
+	OK       bool
+	Sections []SectionStatus
+}
+
+// Verify checks the TOC headers and the CRC of every section
+func (f *Firmware) Verify() (*VerifyResult, error) {
+	result := &VerifyResult{OK: f.parser.IsITOCValid() && f.parser.IsDTOCHeaderValid()}
+
+	for _, list := range f.parser.GetSections() {
+		for _, s := range list {
+			status, err := f.parser.VerifySectionNew(s)
+			if err != nil {
+				f.logger.Warn("Failed to verify section", zap.String("type", s.TypeName()), zap.Error(err))
+				status = "ERROR"
+			}
+			ok := statusOK(status)
+			result.Sections = append(result.Sections, SectionStatus{Section: newSection(s), Status: status, OK: ok})
+			if !ok {
+				result.OK = false
+			}
+		}
+	}
+	sort.SliceStable(result.Sections, func(i, j int) bool {
+		return lessSection(result.Sections[i].Section, result.Sections[j].Section)
+	})
+	return result, nil
+}
+
+// statusOK mirrors the bootability rule of the sections command:
+// SIZE NOT ALIGNED and missing optional sections do not fail an image
+func statusOK(status string) bool {
+	switch status {
+	case "OK", "CRC IGNORED", "NO ENTRY", "NOT FOUND", "SIZE NOT ALIGNED":
+		return true
+	default:
+		return false
+	}
+}
+
+// Diagnostic is a condition found while parsing the image, such as an ITOC
+// found at its alternate location or a missing BOOT2
+type Diagnostic struct {
+	// Severity is "info", "warning" or "error"
+	Severity string
+	// Code is a stable identifier such as ITOC_ALTERNATE_LOCATION
+	Code    string
+	Offset  uint64
+	Section string
+	Message string
+}
+
+// Diagnostics returns the parse diagnostics of the image in the order they
+// were found
+func (f *Firmware) Diagnostics() []Diagnostic {
+	var result []Diagnostic
+	for _, d := range f.parser.Diagnostics() {
+		result = append(result, Diagnostic{
+			Severity: d.Severity.String(),
+			Code:     string(d.Code),
+			Offset:   d.Offset,
+			Section:  d.Section,
+			Message:  d.Message,
+		})
+	}
+	return result
+}
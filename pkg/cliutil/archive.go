@@ -0,0 +1,54 @@
+package cliutil
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/Civil/mlx5fw-go/pkg/mfa2"
+	"github.com/Civil/mlx5fw-go/pkg/parser"
+)
+
+// OpenFirmwareImage opens a firmware image for parsing. Plain (optionally compressed)
+// images are returned as is. For MFA2 archives the image matching psid is extracted;
+// psid may be empty when the archive holds a single image.
+func OpenFirmwareImage(firmwarePath, psid string, logger *zap.Logger) (*parser.FirmwareReader, error) {
+	reader, err := parser.NewFirmwareReader(firmwarePath, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open firmware: %w", err)
+	}
+
+	header := make([]byte, len(mfa2.Fingerprint))
+	n, _ := reader.ReadAt(header, 0)
+	if !mfa2.IsArchive(header[:n]) && !mfa2.IsLegacyArchive(header[:n]) {
+		if psid != "" {
+			reader.Close()
+			return nil, fmt.Errorf("--psid requires an MFA2 archive, %s is a firmware image", firmwarePath)
+		}
+		return reader, nil
+	}
+	defer reader.Close()
+
+	archive, err := mfa2.Parse(reader, reader.Size(), logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse firmware archive: %w", err)
+	}
+
+	if psid == "" {
+		if len(archive.Devices) != 1 {
+			return nil, fmt.Errorf("%s is an MFA2 archive with %d images, select one with --psid (available: %s)",
+				firmwarePath, len(archive.Devices), strings.Join(archive.PSIDs(), ", "))
+		}
+		psid = archive.Devices[0].PSID
+	}
+
+	image, err := archive.ImageForPSID(psid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract image from archive: %w", err)
+	}
+
+	imageReader := parser.NewFirmwareReaderFromBytes(image, logger)
+	imageReader.SetName(fmt.Sprintf("%s[%s]", reader.Name(), psid))
+	return imageReader, nil
+}
@@ -0,0 +1,275 @@
+package cliutil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/Civil/mlx5fw-go/pkg/parser"
+)
+
+// Image selectors accepted by --image
+const (
+	ImagePrimary   = "primary"
+	ImageSecondary = "secondary"
+	ImageAll       = "all"
+)
+
+// ImageContext is one failsafe image copy found in a flash dump
+type ImageContext struct {
+	Index  int
+	Role   string
+	Offset uint32
+	Ctx    *ParserContext // nil when the copy failed to parse
+	Err    error
+}
+
+// ImageSet holds every image copy parsed from a single firmware file
+type ImageSet struct {
+	Reader *parser.FirmwareReader
+	Images []*ImageContext
+}
+
+// Close releases the underlying reader shared by all image copies
+func (s *ImageSet) Close() {
+	if s.Reader != nil {
+		_ = s.Reader.Close()
+	}
+}
+
+// Select returns the image copies matching an --image selector
+func (s *ImageSet) Select(selector string) ([]*ImageContext, error) {
+	switch selector {
+	case ImageAll:
+		return s.Images, nil
+	case "", ImagePrimary:
+		return s.Images[:1], nil
+	case ImageSecondary:
+		if len(s.Images) < 2 {
+			return nil, fmt.Errorf("no secondary image found (single magic pattern at 0x%08x)", s.Images[0].Offset)
+		}
+		return s.Images[1:2], nil
+	default:
+		return nil, fmt.Errorf("invalid --image %q: must be %s, %s or %s", selector, ImagePrimary, ImageSecondary, ImageAll)
+	}
+}
+
+// InitializeImageParsers finds every magic pattern in the image and parses each
+// failsafe copy independently. A copy that fails to parse is kept with its error
+// so callers can report it; only a file without any magic pattern is an error.
+func InitializeImageParsers(firmwarePath, psid string, logger *zap.Logger, opts ...ParserOption) (*ImageSet, error) {
+	reader, err := OpenFirmwareImage(firmwarePath, psid, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	offsets, err := reader.FindAllMagicPatterns()
+	if err != nil {
+		reader.Close()
+		return nil, fmt.Errorf("failed to find firmware image: %w", err)
+	}
+
+	set := &ImageSet{Reader: reader}
+	for i, offset := range offsets {
+		img := &ImageContext{Index: i, Role: imageRole(i), Offset: offset}
+
+		// The primary copy is parsed exactly as a single-image file would be
+		imageReader := reader
+		if i > 0 {
+			imageReader = reader.ImageView(offsets[0], offset)
+		}
+
+		img.Ctx, img.Err = InitializeFirmwareParserFromReader(imageReader, logger, opts...)
+		if img.Err != nil {
+			logger.Warn("Failed to parse image copy",
+				zap.String("role", img.Role),
+				zap.Uint32("offset", offset),
+				zap.Error(img.Err))
+		} else if psid == "" {
+			img.Ctx.FirmwarePath = firmwarePath
+		}
+		set.Images = append(set.Images, img)
+	}
+
+	return set, nil
+}
+
+func imageRole(index int) string {
+	switch index {
+	case 0:
+		return ImagePrimary
+	case 1:
+		return ImageSecondary
+	default:
+		return fmt.Sprintf("image%d", index)
+	}
+}
+
+// ImageStatus summarizes the health and version of one image copy
+type ImageStatus struct {
+	Index       int      `json:"index"`
+	Role        string   `json:"role"`
+	Offset      uint32   `json:"offset"`
+	Valid       bool     `json:"valid"`
+	Newest      bool     `json:"newest"`
+	FWVersion   string   `json:"fw_version,omitempty"`
+	ReleaseDate string   `json:"release_date,omitempty"`
+	Problems    []string `json:"problems,omitempty"`
+}
+
+// AssessImages checks every copy (ITOC header, image section CRCs) and marks the
+// valid copy with the highest firmware version and release date as newest.
+// When the valid copies carry the same version none of them is marked.
+func AssessImages(set *ImageSet) []ImageStatus {
+	statuses := make([]ImageStatus, 0, len(set.Images))
+	for _, img := range set.Images {
+		statuses = append(statuses, assessImage(img))
+	}
+
+	newest := -1
+	tie := false
+	for i := range statuses {
+		if !statuses[i].Valid {
+			continue
+		}
+		if newest < 0 {
+			newest = i
+			continue
+		}
+		switch c := compareImageVersions(statuses[i], statuses[newest]); {
+		case c > 0:
+			newest, tie = i, false
+		case c == 0:
+			tie = true
+		}
+	}
+	if newest >= 0 && !tie {
+		statuses[newest].Newest = true
+	}
+	return statuses
+}
+
+func assessImage(img *ImageContext) ImageStatus {
+	status := ImageStatus{Index: img.Index, Role: img.Role, Offset: img.Offset}
+	if img.Err != nil {
+		status.Problems = append(status.Problems, img.Err.Error())
+		return status
+	}
+
+	p := img.Ctx.Parser
+	if !p.IsITOCHeaderValid() && !p.IsEncrypted() {
+		status.Problems = append(status.Problems, "ITOC header CRC mismatch")
+	}
+
+	for _, list := range p.GetSections() {
+		for _, section := range list {
+			// Device data is shared between copies and does not decide which copy boots
+			if section.IsDeviceData() {
+				continue
+			}
+			result, err := p.VerifySectionNew(section)
+			if err != nil || strings.HasPrefix(result, "FAIL") || result == "ERROR" {
+				status.Problems = append(status.Problems,
+					fmt.Sprintf("%s @0x%08x: %s", section.TypeName(), section.Offset(), result))
+			}
+		}
+	}
+
+	if info, err := p.Query(); err == nil {
+		status.FWVersion = info.FWVersion
+		status.ReleaseDate = info.FWReleaseDate
+	} else {
+		status.Problems = append(status.Problems, fmt.Sprintf("query failed: %v", err))
+	}
+
+	status.Valid = len(status.Problems) == 0
+	return status
+}
+
+// compareImageVersions orders two copies by FW version, then release date
+func compareImageVersions(a, b ImageStatus) int {
+	if c := compareDotted(a.FWVersion, b.FWVersion, false); c != 0 {
+		return c
+	}
+	return compareDotted(a.ReleaseDate, b.ReleaseDate, true)
+}
+
+// compareDotted compares dot-separated numeric strings field by field.
+// Dates are printed day first (dd.mm.yyyy), so reverse compares them year first.
+func compareDotted(a, b string, reverse bool) int {
+	pa, pb := strings.Split(a, "."), strings.Split(b, ".")
+	if reverse {
+		pa, pb = reversed(pa), reversed(pb)
+	}
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		na, errA := strconv.Atoi(pa[i])
+		nb, errB := strconv.Atoi(pb[i])
+		if errA != nil || errB != nil {
+			return strings.Compare(pa[i], pb[i])
+		}
+		if na != nb {
+			if na > nb {
+				return 1
+			}
+			return -1
+		}
+	}
+	return len(pa) - len(pb)
+}
+
+func reversed(s []string) []string {
+	out := make([]string, len(s))
+	for i, v := range s {
+		out[len(s)-1-i] = v
+	}
+	return out
+}
+
+// FormatImageReport renders image statuses as text
+func FormatImageReport(statuses []ImageStatus) string {
+	var b strings.Builder
+	b.WriteString("Image copies:\n")
+	for _, st := range statuses {
+		state := "VALID"
+		if !st.Valid {
+			state = "INVALID"
+		}
+		fmt.Fprintf(&b, "  %-9s @0x%08x  %-7s", st.Role, st.Offset, state)
+		if st.FWVersion != "" {
+			fmt.Fprintf(&b, "  FW %s", st.FWVersion)
+		}
+		if st.ReleaseDate != "" {
+			fmt.Fprintf(&b, " (%s)", st.ReleaseDate)
+		}
+		if st.Newest {
+			b.WriteString("  [newest]")
+		}
+		b.WriteString("\n")
+		for _, problem := range st.Problems {
+			fmt.Fprintf(&b, "    - %s\n", problem)
+		}
+	}
+	if len(statuses) > 1 {
+		var valid int
+		for _, st := range statuses {
+			if st.Valid {
+				valid++
+			}
+		}
+		if valid > 1 && !anyNewest(statuses) {
+			b.WriteString("  Valid copies carry the same firmware version\n")
+		}
+	}
+	return b.String()
+}
+
+func anyNewest(statuses []ImageStatus) bool {
+	for _, st := range statuses {
+		if st.Newest {
+			return true
+		}
+	}
+	return false
+}
@@ -1,14 +1,17 @@
 package cliutil
 
 import (
-    "encoding/binary"
-    "fmt"
-
     "go.uber.org/zap"
 
+    "github.com/ansel1/merry/v2"
+
+    "github.com/Civil/mlx5fw-go/pkg/interfaces"
     "github.com/Civil/mlx5fw-go/pkg/parser"
     "github.com/Civil/mlx5fw-go/pkg/parser/fs4"
     "github.com/Civil/mlx5fw-go/pkg/types"
+
+    // Register the built-in firmware formats with parser.Open
+    _ "github.com/Civil/mlx5fw-go/pkg/parser/fs3"
 )
 
 // ParserContext provides shared resources for commands needing a parsed firmware
@@ -16,44 +19,123 @@ type ParserContext struct {
 	Logger       *zap.Logger
 	FirmwarePath string
 	Reader       *parser.FirmwareReader
-	Parser       *fs4.Parser
+	Parser       interfaces.FirmwareParser
+	// Encryption is the encrypted area when the image was decrypted with the
+	// key given by WithDecryptionKey; Reader then holds the plaintext image
+	Encryption *types.EncryptionLayout
+
+	key             []byte                 // image key of a decrypted Reader
+	encryptedReader *parser.FirmwareReader // original image of a decrypted Reader
+}
+
+// ParserOption configures the InitializeFirmwareParser helpers
+type ParserOption func(*parserOptions)
+
+type parserOptions struct {
+	key []byte
+}
+
+// WithDecryptionKey makes the parser helpers decrypt encrypted FS4/FS5 images
+// with key before parsing them (see fs4.DecryptImage). A nil key disables it.
+func WithDecryptionKey(key []byte) ParserOption {
+	return func(o *parserOptions) {
+		o.key = key
+	}
 }
 
 // InitializeFirmwareParser creates and initializes a firmware parser
 // This consolidates the common pattern used across commands
-func InitializeFirmwareParser(firmwarePath string, logger *zap.Logger) (*ParserContext, error) {
-	// Open firmware file
-	reader, err := parser.NewFirmwareReader(firmwarePath, logger)
+func InitializeFirmwareParser(firmwarePath string, logger *zap.Logger, opts ...ParserOption) (*ParserContext, error) {
+	return InitializeFirmwareParserForPSID(firmwarePath, "", logger, opts...)
+}
+
+// InitializeFirmwareParserForPSID is InitializeFirmwareParser for paths that may point to
+// an MFA2 archive; psid selects the image to parse (see OpenFirmwareImage)
+func InitializeFirmwareParserForPSID(firmwarePath, psid string, logger *zap.Logger, opts ...ParserOption) (*ParserContext, error) {
+	// Open firmware file ("-" reads from stdin)
+	reader, err := OpenFirmwareImage(firmwarePath, psid, logger)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open firmware: %w", err)
+		return nil, err
 	}
 
-	// Detect firmware format by reading hardware pointers
-	format, err := detectFirmwareFormat(reader, logger)
+	ctx, err := InitializeFirmwareParserFromReader(reader, logger, opts...)
 	if err != nil {
 		reader.Close()
-		return nil, fmt.Errorf("failed to detect firmware format: %w", err)
+		return nil, err
+	}
+	if psid == "" {
+		ctx.FirmwarePath = firmwarePath
 	}
+	return ctx, nil
+}
 
-    // Use FS4 parser but mark FS3/FS5 format when detected
-    fs4Parser := fs4.NewParser(reader, logger)
-    if format == types.FormatFS5 {
-        fs4Parser.SetFormat(types.FormatFS5)
-    } else if format == types.FormatFS3 {
-        fs4Parser.SetFormat(types.FormatFS3)
-    }
+// InitializeFirmwareParserFromReader detects the format and parses an already opened image.
+// The returned context takes ownership of the reader; on error the caller must close it.
+func InitializeFirmwareParserFromReader(reader *parser.FirmwareReader, logger *zap.Logger, opts ...ParserOption) (*ParserContext, error) {
+	var options parserOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
 
-	if err := fs4Parser.Parse(); err != nil {
-		reader.Close()
-		return nil, fmt.Errorf("failed to parse firmware: %w", err)
+	fwParser, err := parser.Open(reader, logger)
+	if err != nil {
+		return nil, err
 	}
 
-	return &ParserContext{
+	ctx := &ParserContext{
 		Logger:       logger,
-		FirmwarePath: firmwarePath,
+		FirmwarePath: reader.Name(),
 		Reader:       reader,
-		Parser:       fs4Parser,
-	}, nil
+		Parser:       fwParser,
+	}
+	if options.key != nil && fwParser.IsEncrypted() {
+		if err := ctx.decrypt(options.key); err != nil {
+			return nil, err
+		}
+	}
+	return ctx, nil
+}
+
+// decrypt replaces the parsed encrypted image with its decryption under key
+// and parses the plaintext image
+func (ctx *ParserContext) decrypt(key []byte) error {
+	data, err := ctx.Reader.Bytes()
+	if err != nil {
+		return merry.Wrap(err)
+	}
+	plain, layout, err := fs4.DecryptImage(data, key)
+	if err != nil {
+		return merry.Prependf(err, "failed to decrypt %s", ctx.Reader.Name())
+	}
+
+	plainReader := parser.NewFirmwareReaderFromBytes(plain, ctx.Logger)
+	plainReader.SetName(ctx.Reader.Name())
+	fwParser, err := parser.Open(plainReader, ctx.Logger)
+	if err != nil {
+		return merry.Prepend(err, "failed to parse decrypted image")
+	}
+
+	ctx.encryptedReader = ctx.Reader
+	ctx.Reader = plainReader
+	ctx.Parser = fwParser
+	ctx.Encryption = layout
+	ctx.key = key
+	return nil
+}
+
+// EncryptImage encrypts data, a modified copy of the decrypted image, in
+// place with the key it was decrypted with. The encrypted area is recomputed
+// from data, as edits may have relocated sections. Images that were not
+// decrypted are left unchanged.
+func (ctx *ParserContext) EncryptImage(data []byte) error {
+	if ctx.Encryption == nil {
+		return nil
+	}
+	layout, err := fs4.EncryptionLayoutOf(data, ctx.Encryption.ITOCAddr)
+	if err != nil {
+		return merry.Prepend(err, "failed to compute the encrypted area of the modified image")
+	}
+	return fs4.EncryptImage(data, ctx.key, layout)
 }
 
 // Close releases resources held by the context
@@ -61,50 +143,7 @@ func (ctx *ParserContext) Close() {
 	if ctx.Reader != nil {
 		_ = ctx.Reader.Close()
 	}
-}
-
-// detectFirmwareFormat detects whether the firmware is FS4 or FS5 based on boot version
-// Mirrors mstflint logic at a high level
-func detectFirmwareFormat(reader *parser.FirmwareReader, logger *zap.Logger) (types.FirmwareFormat, error) {
-    // First, attempt FS4/FS5 magic-based detection
-    magicOffset, err := reader.FindMagicPattern()
-    if err == nil {
-        // Read boot version structure at offset 0x10 from magic pattern
-        bootVersionOffset := int64(magicOffset + types.BootVersionOffset)
-        bootVersionData, err := reader.ReadSection(bootVersionOffset, 4) // Boot version is 4 bytes
-        if err != nil {
-            return types.FormatUnknown, fmt.Errorf("failed to read boot version: %w", err)
-        }
-
-        // Parse boot version structure
-        var bootVersion types.FirmwareBootVersion
-        if err := bootVersion.Unmarshal(bootVersionData); err != nil {
-            return types.FormatUnknown, fmt.Errorf("failed to parse boot version: %w", err)
-        }
-
-        switch bootVersion.ImageFormatVersion {
-        case types.ImageFormatVersionFS4:
-            logger.Debug("Detected FS4 format from boot version")
-            return types.FormatFS4, nil
-        case types.ImageFormatVersionFS5:
-            logger.Debug("Detected FS5 format from boot version")
-            return types.FormatFS5, nil
-        default:
-            logger.Warn("Unknown image format version", zap.Uint8("version", bootVersion.ImageFormatVersion))
-            // Fall back to FS4 for compatibility
-            return types.FormatFS4, nil
-        }
-    }
-
-    // If FS4/FS5 magic not found, attempt FS3 detection: check for "MTFW" at file start
-    hdr, err2 := reader.ReadSection(0, 4)
-    if err2 == nil {
-        if binary.BigEndian.Uint32(hdr) == types.FS3Magic {
-            logger.Debug("Detected FS3 format from FS3 magic at offset 0x0")
-            return types.FormatFS3, nil
-        }
-    }
-
-    // No known magic found
-    return types.FormatUnknown, err
+	if ctx.encryptedReader != nil {
+		_ = ctx.encryptedReader.Close()
+	}
 }
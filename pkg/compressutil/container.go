@@ -0,0 +1,107 @@
+package compressutil
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+
+	"github.com/ansel1/merry/v2"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+)
+
+// Format identifies a whole-file compression container
+type Format string
+
+const (
+	FormatNone  Format = ""
+	FormatGzip  Format = "gzip"
+	FormatXZ    Format = "xz"
+	FormatZstd  Format = "zstd"
+	FormatBzip2 Format = "bzip2"
+)
+
+// MagicPeekSize is the number of leading bytes DetectFormat needs to see
+const MagicPeekSize = 6
+
+var (
+	magicGzip  = []byte{0x1f, 0x8b}
+	magicXZ    = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	magicZstd  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	magicBzip2 = []byte{'B', 'Z', 'h'}
+)
+
+// DetectFormat identifies the compression container from the leading bytes of a file.
+// Raw firmware images never start with any of these signatures (they start with the
+// FS4 magic or 0xFF padding), so a match is unambiguous.
+func DetectFormat(header []byte) Format {
+	switch {
+	case bytes.HasPrefix(header, magicXZ):
+		return FormatXZ
+	case bytes.HasPrefix(header, magicZstd):
+		return FormatZstd
+	case bytes.HasPrefix(header, magicGzip):
+		return FormatGzip
+	case len(header) >= 4 && bytes.HasPrefix(header, magicBzip2) && header[3] >= '1' && header[3] <= '9':
+		return FormatBzip2
+	}
+	return FormatNone
+}
+
+// NewReader wraps r with a decompressor for the given format
+func NewReader(format Format, r io.Reader) (io.ReadCloser, error) {
+	switch format {
+	case FormatGzip:
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, merry.Wrap(err)
+		}
+		return zr, nil
+	case FormatXZ:
+		xr, err := xz.NewReader(r)
+		if err != nil {
+			return nil, merry.Wrap(err)
+		}
+		return io.NopCloser(xr), nil
+	case FormatZstd:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, merry.Wrap(err)
+		}
+		return zr.IOReadCloser(), nil
+	case FormatBzip2:
+		return io.NopCloser(bzip2.NewReader(r)), nil
+	case FormatNone:
+		return io.NopCloser(r), nil
+	}
+	return nil, merry.Wrap(pkgerrors.ErrNotSupported, merry.WithMessagef("unsupported compression format %q", format))
+}
+
+// DecompressLimited decompresses r according to format and returns the result.
+// Output larger than limit bytes is rejected with ErrFileTooLarge without being
+// fully buffered, which protects against decompression bombs.
+func DecompressLimited(format Format, r io.Reader, limit int64) ([]byte, error) {
+	dr, err := NewReader(format, r)
+	if err != nil {
+		return nil, err
+	}
+	defer dr.Close()
+
+	return ReadAllLimited(dr, limit)
+}
+
+// ReadAllLimited reads r to EOF, failing with ErrFileTooLarge once more than limit bytes are seen
+func ReadAllLimited(r io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+	if int64(len(data)) > limit {
+		return nil, merry.Wrap(pkgerrors.ErrFileTooLarge,
+			merry.WithMessagef("data exceeds limit of %d bytes", limit))
+	}
+	return data, nil
+}
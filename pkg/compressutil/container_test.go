@@ -0,0 +1,94 @@
+package compressutil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ulikunitz/xz"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+)
+
+func compress(t *testing.T, format Format, data []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	var err error
+	switch format {
+	case FormatGzip:
+		w = gzip.NewWriter(&buf)
+	case FormatXZ:
+		w, err = xz.NewWriter(&buf)
+	case FormatZstd:
+		w, err = zstd.NewWriter(&buf)
+	default:
+		t.Fatalf("no encoder for %q", format)
+	}
+	require.NoError(t, err)
+
+	_, err = w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []byte
+		want   Format
+	}{
+		{"gzip", []byte{0x1f, 0x8b, 0x08, 0x00}, FormatGzip},
+		{"xz", []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}, FormatXZ},
+		{"zstd", []byte{0x28, 0xb5, 0x2f, 0xfd, 0x00}, FormatZstd},
+		{"bzip2", []byte("BZh91AY"), FormatBzip2},
+		{"bzip2 bad level", []byte("BZhx"), FormatNone},
+		{"fs4 magic", []byte{0x4d, 0x54, 0x46, 0x57, 0xab, 0xcd}, FormatNone},
+		{"erased flash", []byte{0xff, 0xff, 0xff, 0xff}, FormatNone},
+		{"short", []byte{0x1f}, FormatNone},
+		{"empty", nil, FormatNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, DetectFormat(tt.header))
+		})
+	}
+}
+
+func TestDecompressLimited(t *testing.T) {
+	payload := bytes.Repeat([]byte{0xff, 0x00, 0x4d, 0x54}, 4096)
+
+	for _, format := range []Format{FormatGzip, FormatXZ, FormatZstd} {
+		t.Run(string(format), func(t *testing.T) {
+			packed := compress(t, format, payload)
+			require.Equal(t, format, DetectFormat(packed))
+
+			out, err := DecompressLimited(format, bytes.NewReader(packed), int64(len(payload)))
+			require.NoError(t, err)
+			assert.Equal(t, payload, out)
+
+			_, err = DecompressLimited(format, bytes.NewReader(packed), int64(len(payload)-1))
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, pkgerrors.ErrFileTooLarge))
+		})
+	}
+
+	t.Run("none", func(t *testing.T) {
+		out, err := DecompressLimited(FormatNone, bytes.NewReader(payload), int64(len(payload)))
+		require.NoError(t, err)
+		assert.Equal(t, payload, out)
+	})
+
+	t.Run("corrupt", func(t *testing.T) {
+		_, err := DecompressLimited(FormatGzip, bytes.NewReader([]byte{0x1f, 0x8b, 0x00}), 1024)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,67 @@
+package diffutil
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UnifiedTextDiff returns a unified diff of two texts with context lines
+// around each change, or an empty string when they are equal.
+func UnifiedTextDiff(aName, bName, aText, bText string, context int) string {
+	ops := lcsLines(strings.Split(aText, "\n"), strings.Split(bText, "\n"))
+
+	// Group the changes into hunks, merging those whose context overlaps
+	type hunk struct{ start, end int }
+	var hunks []hunk
+	for i, op := range ops {
+		if op.kind == opEq {
+			continue
+		}
+		start, end := max(i-context, 0), min(i+context+1, len(ops))
+		if n := len(hunks); n > 0 && start <= hunks[n-1].end {
+			hunks[n-1].end = end
+		} else {
+			hunks = append(hunks, hunk{start, end})
+		}
+	}
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", aName, bName)
+	aLine, bLine, pos := 1, 1, 0
+	for _, h := range hunks {
+		// Count the lines of both texts before the hunk
+		for ; pos < h.start; pos++ {
+			aLine, bLine = advance(ops[pos], aLine, bLine)
+		}
+		aCount, bCount := 0, 0
+		for _, op := range ops[h.start:h.end] {
+			aCount, bCount = advance(op, aCount, bCount)
+		}
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", aLine, aCount, bLine, bCount)
+		for _, op := range ops[h.start:h.end] {
+			switch op.kind {
+			case opEq:
+				sb.WriteString(" " + op.a + "\n")
+			case opDel:
+				sb.WriteString("-" + op.a + "\n")
+			case opIns:
+				sb.WriteString("+" + op.b + "\n")
+			}
+		}
+	}
+	return sb.String()
+}
+
+// advance moves the line numbers of both texts past op
+func advance(op diffOp, aLine, bLine int) (int, int) {
+	if op.kind != opIns {
+		aLine++
+	}
+	if op.kind != opDel {
+		bLine++
+	}
+	return aLine, bLine
+}
@@ -39,6 +39,9 @@ var (
 
 	// ErrInvalidParameter indicates invalid function parameter
 	ErrInvalidParameter = merry.New("invalid parameter")
+
+	// ErrPSIDNotFound indicates a firmware archive has no image for the requested PSID
+	ErrPSIDNotFound = merry.New("PSID not found")
 )
 
 // DataTooShortError creates an error for insufficient data with detailed context
@@ -13,7 +13,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/Civil/mlx5fw-go/pkg/interfaces"
-	"github.com/Civil/mlx5fw-go/pkg/parser/fs4"
+	"github.com/Civil/mlx5fw-go/pkg/parser"
 	"github.com/Civil/mlx5fw-go/pkg/types"
 	"github.com/Civil/mlx5fw-go/pkg/types/extracted"
 	types_sections "github.com/Civil/mlx5fw-go/pkg/types/sections"
@@ -26,17 +26,31 @@ type Options struct {
 	IncludeMetadata bool
 	RemoveCRC       bool
 	KeepBinary      bool // Keep binary representation alongside JSON
+	// Encryption is the encrypted area of an image that was decrypted before
+	// parsing; it is recorded in the metadata so reassemble can re-encrypt it
+	Encryption *types.EncryptionLayout
+}
+
+// FirmwareParser is the parser functionality needed for extraction
+type FirmwareParser interface {
+	interfaces.FirmwareParser
+	GetReader() *parser.FirmwareReader
+}
+
+// hwPointersSource is implemented by parsers of formats with HW pointers (FS4/FS5)
+type hwPointersSource interface {
+	GetHWPointersRaw() ([]byte, *types.FS4HWPointers, error)
 }
 
 // Extractor handles firmware extraction
 type Extractor struct {
-	parser  *fs4.Parser
+	parser  FirmwareParser
 	logger  *zap.Logger
 	options Options
 }
 
 // New creates a new Extractor
-func New(parser *fs4.Parser, logger *zap.Logger, opts Options) *Extractor {
+func New(parser FirmwareParser, logger *zap.Logger, opts Options) *Extractor {
 	return &Extractor{
 		parser:  parser,
 		logger:  logger,
@@ -451,10 +465,13 @@ func (e *Extractor) extractMetadataWithFilenames(sectionFileMap map[uint64]strin
 	// Get magic pattern info
 	magicOffset := e.parser.GetMagicOffset()
 
-	// Get hardware pointers
-	_, fs4HwPointers, err := e.parser.GetHWPointersRaw()
-	if err != nil {
-		e.logger.Warn("Failed to get HW pointers", zap.Error(err))
+	// Get hardware pointers (FS3 images have none)
+	var fs4HwPointers *types.FS4HWPointers
+	if hw, ok := e.parser.(hwPointersSource); ok {
+		_, fs4HwPointers, err = hw.GetHWPointersRaw()
+		if err != nil {
+			e.logger.Warn("Failed to get HW pointers", zap.Error(err))
+		}
 	}
 
 	// Get ITOC/DTOC raw data
@@ -493,6 +510,7 @@ func (e *Extractor) extractMetadataWithFilenames(sectionFileMap map[uint64]strin
 			RawHeader:   base64.StdEncoding.EncodeToString(dtocRawData),
 		},
 		IsEncrypted: e.parser.IsEncrypted(),
+		Encryption:  e.options.Encryption,
     }
 
     // Set HW pointers based on type
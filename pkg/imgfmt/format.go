@@ -0,0 +1,171 @@
+// Package imgfmt converts firmware images between raw binary and the text
+// formats used by external SPI programmers: Intel HEX and Motorola S-record.
+package imgfmt
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ansel1/merry/v2"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+)
+
+// Format identifies an on-disk image encoding
+type Format string
+
+const (
+	FormatBinary Format = "bin"
+	FormatIHex   Format = "ihex"
+	FormatSRec   Format = "srec"
+)
+
+// GapFill is the value of flash bytes not covered by any record (erased flash)
+const GapFill = 0xFF
+
+// PeekSize is the number of leading bytes Detect needs to see
+const PeekSize = 10
+
+// bytesPerRecord is the data payload of each emitted HEX/S-record line
+const bytesPerRecord = 16
+
+// Formats lists the accepted --output-format values
+var Formats = []Format{FormatBinary, FormatIHex, FormatSRec}
+
+// ParseFormat validates an --output-format value. "hex" is accepted as an
+// alias of "ihex", and "s19"/"mot" as aliases of "srec".
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "", "bin", "binary", "raw":
+		return FormatBinary, nil
+	case "ihex", "hex":
+		return FormatIHex, nil
+	case "srec", "s19", "s28", "s37", "mot":
+		return FormatSRec, nil
+	}
+	return "", pkgerrors.InvalidParameterError("output-format", "must be one of bin, ihex or srec")
+}
+
+// FormatFromPath guesses the format from a file extension, defaulting to binary
+func FormatFromPath(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".hex", ".ihex", ".ihx":
+		return FormatIHex
+	case ".srec", ".s19", ".s28", ".s37", ".mot":
+		return FormatSRec
+	}
+	return FormatBinary
+}
+
+// Detect identifies a text image from its leading bytes. Raw firmware starts with
+// the FS4 magic or 0xFF padding, so a well-formed first record is unambiguous.
+func Detect(header []byte) Format {
+	switch {
+	case len(header) >= 9 && header[0] == ':' && isHex(header[1:9]):
+		return FormatIHex
+	case len(header) >= 4 && header[0] == 'S' && header[1] >= '0' && header[1] <= '9' && isHex(header[2:4]):
+		return FormatSRec
+	}
+	return FormatBinary
+}
+
+func isHex(b []byte) bool {
+	for _, c := range b {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// Decode reads a text image and returns the flash contents starting at address 0.
+// Gaps between records are filled with GapFill; images reaching past limit bytes
+// are rejected with ErrFileTooLarge.
+func Decode(format Format, r io.Reader, limit int64) ([]byte, error) {
+	img := &image{limit: limit}
+	var err error
+	switch format {
+	case FormatIHex:
+		err = decodeIHex(r, img)
+	case FormatSRec:
+		err = decodeSRec(r, img)
+	case FormatBinary:
+		return nil, merry.Wrap(pkgerrors.ErrInvalidParameter, merry.WithMessage("binary images need no decoding"))
+	default:
+		return nil, pkgerrors.NotSupportedError("image format " + string(format))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return img.data, nil
+}
+
+// OutputFormat resolves an --output-format flag value; an empty value picks the
+// format from the output file extension
+func OutputFormat(flag, path string) (Format, error) {
+	if flag == "" {
+		return FormatFromPath(path), nil
+	}
+	return ParseFormat(flag)
+}
+
+// Encode writes data, placed at flash address 0, in the given format
+func Encode(format Format, w io.Writer, data []byte) error {
+	return EncodeAt(format, w, 0, data)
+}
+
+// EncodeAt writes data placed at flash address base in the given format.
+// Binary output carries no addresses, so base only affects text formats.
+func EncodeAt(format Format, w io.Writer, base uint32, data []byte) error {
+	if uint64(base)+uint64(len(data)) > 1<<32 {
+		return pkgerrors.InvalidParameterError("base", "image does not fit in a 32-bit address space")
+	}
+	switch format {
+	case FormatBinary, "":
+		_, err := w.Write(data)
+		return merry.Wrap(err)
+	case FormatIHex:
+		return encodeIHex(w, base, data)
+	case FormatSRec:
+		return encodeSRec(w, base, data)
+	}
+	return pkgerrors.NotSupportedError("image format " + string(format))
+}
+
+// WriteFile writes data to path in the given format
+func WriteFile(path string, data []byte, format Format, perm os.FileMode) error {
+	if format == FormatBinary || format == "" {
+		return merry.Wrap(os.WriteFile(path, data, perm))
+	}
+	var buf bytes.Buffer
+	if err := Encode(format, &buf, data); err != nil {
+		return err
+	}
+	return merry.Wrap(os.WriteFile(path, buf.Bytes(), perm))
+}
+
+// image accumulates records into a contiguous buffer
+type image struct {
+	data  []byte
+	limit int64
+}
+
+func (img *image) write(addr uint64, payload []byte) error {
+	end := addr + uint64(len(payload))
+	if int64(end) > img.limit || end < addr {
+		return pkgerrors.FileTooLargeError(int64(end), img.limit)
+	}
+	if gap := int(end) - len(img.data); gap > 0 {
+		img.data = append(img.data, bytes.Repeat([]byte{GapFill}, gap)...)
+	}
+	copy(img.data[addr:], payload)
+	return nil
+}
+
+// recordError reports a malformed line of a text image
+func recordError(line int, format string, args ...interface{}) error {
+	return merry.Wrap(pkgerrors.ErrInvalidData, merry.WithMessagef("line %d: "+format, append([]interface{}{line}, args...)...))
+}
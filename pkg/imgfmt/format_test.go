@@ -0,0 +1,143 @@
+package imgfmt
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+)
+
+const testLimit = 1 << 20
+
+func testImage(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	return data
+}
+
+func TestRoundTrip(t *testing.T) {
+	// Crosses a 64KB boundary and ends with a partial record
+	data := testImage(0x10000 + 37)
+
+	for _, format := range []Format{FormatIHex, FormatSRec} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, Encode(format, &buf, data))
+			assert.Equal(t, format, Detect(buf.Bytes()))
+
+			decoded, err := Decode(format, &buf, testLimit)
+			require.NoError(t, err)
+			assert.Equal(t, data, decoded)
+		})
+	}
+}
+
+func TestEncodeAtFillsLeadingGap(t *testing.T) {
+	data := testImage(40)
+
+	for _, format := range []Format{FormatIHex, FormatSRec} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, EncodeAt(format, &buf, 0xfff8, data))
+
+			decoded, err := Decode(format, &buf, testLimit)
+			require.NoError(t, err)
+			require.Len(t, decoded, 0xfff8+len(data))
+			assert.Equal(t, bytes.Repeat([]byte{GapFill}, 0xfff8), decoded[:0xfff8])
+			assert.Equal(t, data, decoded[0xfff8:])
+		})
+	}
+}
+
+func TestDecodeIHex(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []byte
+		wantErr error
+	}{
+		{
+			name:  "gap between records is filled",
+			input: ":020000001122CB\n:02000400AABB95\n:00000001FF\n",
+			want:  []byte{0x11, 0x22, 0xFF, 0xFF, 0xAA, 0xBB},
+		},
+		{
+			name:  "extended linear address",
+			input: ":020000040000FA\r\n:010002000AF3\r\n:00000001FF\r\n",
+			want:  []byte{0xFF, 0xFF, 0x0A},
+		},
+		{
+			name:    "bad checksum",
+			input:   ":020000001122CC\n:00000001FF\n",
+			wantErr: pkgerrors.ErrInvalidData,
+		},
+		{
+			name:    "missing end of file",
+			input:   ":020000001122CB\n",
+			wantErr: pkgerrors.ErrInvalidData,
+		},
+		{
+			name:    "beyond limit",
+			input:   ":020000040010EA\n:0100000000FF\n:00000001FF\n",
+			wantErr: pkgerrors.ErrFileTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decode(FormatIHex, strings.NewReader(tt.input), testLimit)
+			if tt.wantErr != nil {
+				assert.True(t, errors.Is(err, tt.wantErr), "got %v", err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDecodeSRec(t *testing.T) {
+	// S1 record at 0x0002 and S2 record at 0x000005, terminated by S9
+	input := "S00600004844521B\nS1050002AABB93\nS2060000050102F1\nS9030000FC\n"
+	got, err := Decode(FormatSRec, strings.NewReader(input), testLimit)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0xFF, 0xFF, 0xAA, 0xBB, 0xFF, 0x01, 0x02}, got)
+
+	_, err = Decode(FormatSRec, strings.NewReader("S1050002AABB94\nS9030000FC\n"), testLimit)
+	assert.True(t, errors.Is(err, pkgerrors.ErrInvalidData), "got %v", err)
+}
+
+func TestDetect(t *testing.T) {
+	assert.Equal(t, FormatIHex, Detect([]byte(":020000040000FA")))
+	assert.Equal(t, FormatSRec, Detect([]byte("S00600004844521B")))
+	assert.Equal(t, FormatBinary, Detect([]byte{0x4D, 0x54, 0x46, 0x57, 0xAB, 0xCD, 0xEF, 0x00, 0xFA, 0xDE}))
+	assert.Equal(t, FormatBinary, Detect(bytes.Repeat([]byte{0xFF}, PeekSize)))
+}
+
+func TestOutputFormat(t *testing.T) {
+	tests := []struct {
+		flag, path string
+		want       Format
+	}{
+		{"", "out.bin", FormatBinary},
+		{"", "out.hex", FormatIHex},
+		{"", "out.S19", FormatSRec},
+		{"srec", "out.bin", FormatSRec},
+		{"hex", "out.bin", FormatIHex},
+	}
+	for _, tt := range tests {
+		got, err := OutputFormat(tt.flag, tt.path)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got, "flag=%q path=%q", tt.flag, tt.path)
+	}
+
+	_, err := OutputFormat("elf", "out.bin")
+	assert.True(t, errors.Is(err, pkgerrors.ErrInvalidParameter))
+}
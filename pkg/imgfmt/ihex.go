@@ -0,0 +1,113 @@
+package imgfmt
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ansel1/merry/v2"
+)
+
+// Intel HEX record types
+const (
+	ihexData                   = 0x00
+	ihexEndOfFile              = 0x01
+	ihexExtendedSegmentAddress = 0x02
+	ihexStartSegmentAddress    = 0x03
+	ihexExtendedLinearAddress  = 0x04
+	ihexStartLinearAddress     = 0x05
+)
+
+func decodeIHex(r io.Reader, img *image) error {
+	scanner := bufio.NewScanner(r)
+	var base uint64
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if text[0] != ':' {
+			return recordError(line, "missing ':' record mark")
+		}
+		rec, err := hex.DecodeString(text[1:])
+		if err != nil {
+			return recordError(line, "invalid hex digits: %v", err)
+		}
+		if len(rec) < 5 || len(rec) != int(rec[0])+5 {
+			return recordError(line, "record length mismatch")
+		}
+		var sum byte
+		for _, b := range rec {
+			sum += b
+		}
+		if sum != 0 {
+			return recordError(line, "checksum mismatch")
+		}
+
+		payload := rec[4 : len(rec)-1]
+		addr := uint64(rec[1])<<8 | uint64(rec[2])
+		switch rec[3] {
+		case ihexData:
+			if err := img.write(base+addr, payload); err != nil {
+				return err
+			}
+		case ihexEndOfFile:
+			return nil
+		case ihexExtendedSegmentAddress:
+			if len(payload) != 2 {
+				return recordError(line, "extended segment address needs 2 bytes")
+			}
+			base = (uint64(payload[0])<<8 | uint64(payload[1])) << 4
+		case ihexExtendedLinearAddress:
+			if len(payload) != 2 {
+				return recordError(line, "extended linear address needs 2 bytes")
+			}
+			base = (uint64(payload[0])<<8 | uint64(payload[1])) << 16
+		case ihexStartSegmentAddress, ihexStartLinearAddress:
+			// Execution start addresses are meaningless for flash contents
+		default:
+			return recordError(line, "unknown record type 0x%02X", rec[3])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return merry.Wrap(err)
+	}
+	return recordError(line, "missing end-of-file record")
+}
+
+func encodeIHex(w io.Writer, base uint32, data []byte) error {
+	bw := bufio.NewWriter(w)
+	upper := int64(-1)
+	for off := 0; off < len(data); {
+		addr := uint64(base) + uint64(off)
+		// Split records so none crosses a 64KB boundary of the linear address
+		n := bytesPerRecord - int(addr%bytesPerRecord)
+		if off+n > len(data) {
+			n = len(data) - off
+		}
+		if hi := int64(addr >> 16); hi != upper {
+			writeIHexRecord(bw, 0, ihexExtendedLinearAddress, []byte{byte(hi >> 8), byte(hi)})
+			upper = hi
+		}
+		writeIHexRecord(bw, uint16(addr), ihexData, data[off:off+n])
+		off += n
+	}
+	writeIHexRecord(bw, 0, ihexEndOfFile, nil)
+	return merry.Wrap(bw.Flush())
+}
+
+func writeIHexRecord(w *bufio.Writer, addr uint16, recType byte, payload []byte) {
+	rec := make([]byte, 0, len(payload)+5)
+	rec = append(rec, byte(len(payload)), byte(addr>>8), byte(addr), recType)
+	rec = append(rec, payload...)
+	var sum byte
+	for _, b := range rec {
+		sum += b
+	}
+	rec = append(rec, -sum)
+	fmt.Fprintf(w, ":%X\n", rec)
+}
@@ -0,0 +1,108 @@
+package imgfmt
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ansel1/merry/v2"
+)
+
+// srecHeader is the S0 payload written in front of exported images
+const srecHeader = "mlx5fw-go"
+
+// srecAddressSize returns the address width of an S-record type, 0 if unknown
+func srecAddressSize(recType byte) int {
+	switch recType {
+	case '0', '1', '5', '9':
+		return 2
+	case '2', '6', '8':
+		return 3
+	case '3', '7':
+		return 4
+	}
+	return 0
+}
+
+func decodeSRec(r io.Reader, img *image) error {
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if len(text) < 4 || text[0] != 'S' {
+			return recordError(line, "missing 'S' record mark")
+		}
+		recType := text[1]
+		addrSize := srecAddressSize(recType)
+		if addrSize == 0 {
+			return recordError(line, "unknown record type S%c", recType)
+		}
+		rec, err := hex.DecodeString(text[2:])
+		if err != nil {
+			return recordError(line, "invalid hex digits: %v", err)
+		}
+		if len(rec) != int(rec[0])+1 || int(rec[0]) < addrSize+1 {
+			return recordError(line, "record length mismatch")
+		}
+		var sum byte
+		for _, b := range rec[:len(rec)-1] {
+			sum += b
+		}
+		if ^sum != rec[len(rec)-1] {
+			return recordError(line, "checksum mismatch")
+		}
+
+		var addr uint64
+		for _, b := range rec[1 : 1+addrSize] {
+			addr = addr<<8 | uint64(b)
+		}
+		switch recType {
+		case '1', '2', '3':
+			if err := img.write(addr, rec[1+addrSize:len(rec)-1]); err != nil {
+				return err
+			}
+		case '7', '8', '9':
+			return nil
+		}
+		// S0 header and S5/S6 record counts carry no flash data
+	}
+	if err := scanner.Err(); err != nil {
+		return merry.Wrap(err)
+	}
+	return recordError(line, "missing termination record")
+}
+
+func encodeSRec(w io.Writer, base uint32, data []byte) error {
+	bw := bufio.NewWriter(w)
+	writeSRecRecord(bw, '0', 0, 2, []byte(srecHeader))
+	for off := 0; off < len(data); off += bytesPerRecord {
+		end := off + bytesPerRecord
+		if end > len(data) {
+			end = len(data)
+		}
+		writeSRecRecord(bw, '3', base+uint32(off), 4, data[off:end])
+	}
+	writeSRecRecord(bw, '7', 0, 4, nil)
+	return merry.Wrap(bw.Flush())
+}
+
+func writeSRecRecord(w *bufio.Writer, recType byte, addr uint32, addrSize int, payload []byte) {
+	rec := make([]byte, 0, 1+addrSize+len(payload)+1)
+	rec = append(rec, byte(addrSize+len(payload)+1))
+	for i := addrSize - 1; i >= 0; i-- {
+		rec = append(rec, byte(addr>>(8*i)))
+	}
+	rec = append(rec, payload...)
+	var sum byte
+	for _, b := range rec {
+		sum += b
+	}
+	rec = append(rec, ^sum)
+	fmt.Fprintf(w, "S%c%X\n", recType, rec)
+}
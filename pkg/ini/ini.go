@@ -0,0 +1,172 @@
+// Package ini reads and edits the INI text stored, zlib compressed, in the
+// DBG_FW_INI section. Edits rewrite only the lines they change; comments,
+// blank lines and spacing elsewhere are kept byte for byte.
+package ini
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ansel1/merry/v2"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+)
+
+// Key is one key = value line; keys before the first section header have an
+// empty Section
+type Key struct {
+	Section string `json:"section"`
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+}
+
+// line is one line of the text and what it holds
+type line struct {
+	text    string
+	section string
+	// name is set on key lines; valueStart is where the value begins in text
+	name       string
+	valueStart int
+	header     bool
+}
+
+// File is a parsed INI text
+type File struct {
+	lines []line
+	// separator is used for added keys, following the style of the text
+	separator string
+}
+
+// Parse splits text into section headers, key lines and everything else
+// (comments, blank lines). A '[' line that is not closed is an error.
+func Parse(text []byte) (*File, error) {
+	f := &File{separator: " = "}
+	section := ""
+	sawKey := false
+	for i, raw := range strings.Split(string(text), "\n") {
+		l := line{text: raw, section: section}
+		trimmed := strings.TrimSpace(raw)
+		switch {
+		case trimmed == "" || trimmed[0] == ';' || trimmed[0] == '#':
+		case trimmed[0] == '[':
+			if !strings.HasSuffix(trimmed, "]") {
+				return nil, merry.Wrap(pkgerrors.ErrInvalidData,
+					merry.WithMessagef("line %d: unterminated section header %q", i+1, trimmed))
+			}
+			section = strings.TrimSpace(trimmed[1 : len(trimmed)-1])
+			l.section, l.header = section, true
+		default:
+			eq := strings.IndexByte(raw, '=')
+			if eq < 0 {
+				break
+			}
+			l.name = strings.TrimSpace(raw[:eq])
+			l.valueStart = eq + 1
+			for l.valueStart < len(raw) && (raw[l.valueStart] == ' ' || raw[l.valueStart] == '\t') {
+				l.valueStart++
+			}
+			if !sawKey && !strings.HasSuffix(raw[:eq], " ") {
+				f.separator = "="
+			}
+			sawKey = true
+		}
+		f.lines = append(f.lines, l)
+	}
+	return f, nil
+}
+
+// Bytes returns the text with the edits applied
+func (f *File) Bytes() []byte {
+	texts := make([]string, len(f.lines))
+	for i, l := range f.lines {
+		texts[i] = l.text
+	}
+	return []byte(strings.Join(texts, "\n"))
+}
+
+// Keys returns every key in text order
+func (f *File) Keys() []Key {
+	var keys []Key
+	for _, l := range f.lines {
+		if l.name != "" {
+			keys = append(keys, Key{Section: l.section, Name: l.name, Value: l.value()})
+		}
+	}
+	return keys
+}
+
+// value returns the value of a key line
+func (l *line) value() string {
+	return strings.TrimSpace(l.text[l.valueStart:])
+}
+
+// Lookup returns the keys named name in section; an empty section matches
+// the key in any section. Names compare case-insensitively.
+func (f *File) Lookup(section, name string) []Key {
+	var keys []Key
+	for _, k := range f.Keys() {
+		if strings.EqualFold(k.Name, name) && (section == "" || strings.EqualFold(k.Section, section)) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// Set changes the value of name in section, keeping the rest of its line. A
+// missing key is added after the last line of the section and a missing
+// section is added at the end of the text.
+func (f *File) Set(section, name, value string) error {
+	if name == "" || strings.ContainsAny(name, "=[\n") {
+		return pkgerrors.InvalidParameterError("key", fmt.Sprintf("invalid key name %q", name))
+	}
+	if strings.ContainsAny(section, "]\n") {
+		return pkgerrors.InvalidParameterError("section", fmt.Sprintf("invalid section name %q", section))
+	}
+	if strings.Contains(value, "\n") {
+		return pkgerrors.InvalidParameterError("value", "must be a single line")
+	}
+
+	last := -1
+	for i := range f.lines {
+		l := &f.lines[i]
+		if !strings.EqualFold(l.section, section) {
+			continue
+		}
+		if strings.EqualFold(l.name, name) {
+			// Keep the carriage return of CRLF text
+			cr := ""
+			if strings.HasSuffix(l.text, "\r") {
+				cr = "\r"
+			}
+			l.text = l.text[:l.valueStart] + value + cr
+			return nil
+		}
+		if l.header || l.name != "" {
+			last = i
+		}
+	}
+
+	added := line{section: section, name: name, valueStart: len(name) + len(f.separator)}
+	added.text = name + f.separator + value
+	if last < 0 {
+		if section == "" {
+			// Keys outside any section go before the first header
+			f.lines = append([]line{added}, f.lines...)
+			return nil
+		}
+		// Keep a blank line between the old text and the new section
+		end := len(f.lines)
+		for end > 0 && strings.TrimSpace(f.lines[end-1].text) == "" {
+			end--
+		}
+		var tail []line
+		if end > 0 {
+			tail = append(tail, line{section: f.lines[end-1].section})
+		}
+		tail = append(tail, line{text: "[" + section + "]", section: section, header: true}, added)
+		f.lines = append(append(f.lines[:end:end], tail...), f.lines[end:]...)
+		return nil
+	}
+	f.lines = append(f.lines[:last+1], append([]line{added}, f.lines[last+1:]...)...)
+	return nil
+}
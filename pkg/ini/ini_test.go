@@ -0,0 +1,113 @@
+package ini
+
+import (
+	"errors"
+	"testing"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+)
+
+const testINI = `; generated
+[IMAGE_INFO]
+name = CX5
+psid=MT_0000000001
+
+[HCA]
+; link settings
+num_pfs = 1
+`
+
+func TestParse(t *testing.T) {
+	f, err := Parse([]byte(testINI))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	want := []Key{
+		{Section: "IMAGE_INFO", Name: "name", Value: "CX5"},
+		{Section: "IMAGE_INFO", Name: "psid", Value: "MT_0000000001"},
+		{Section: "HCA", Name: "num_pfs", Value: "1"},
+	}
+	keys := f.Keys()
+	if len(keys) != len(want) {
+		t.Fatalf("Keys() = %v, want %v", keys, want)
+	}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Errorf("Keys()[%d] = %v, want %v", i, keys[i], want[i])
+		}
+	}
+	if got := string(f.Bytes()); got != testINI {
+		t.Errorf("Bytes() of an unedited file = %q", got)
+	}
+	if got := f.Lookup("", "NUM_PFS"); len(got) != 1 || got[0].Section != "HCA" {
+		t.Errorf("Lookup(NUM_PFS) = %v", got)
+	}
+
+	if _, err := Parse([]byte("[HCA\nkey = 1\n")); !errors.Is(err, pkgerrors.ErrInvalidData) {
+		t.Errorf("Parse() of an unterminated header error = %v, want ErrInvalidData", err)
+	}
+}
+
+func TestSet(t *testing.T) {
+	tests := []struct {
+		name                string
+		section, key, value string
+		want                string
+	}{
+		{"existing key keeps its spacing", "hca", "num_pfs", "2",
+			"; generated\n[IMAGE_INFO]\nname = CX5\npsid=MT_0000000001\n\n[HCA]\n; link settings\nnum_pfs = 2\n"},
+		{"new key follows the section", "IMAGE_INFO", "vsd", "OEM",
+			"; generated\n[IMAGE_INFO]\nname = CX5\npsid=MT_0000000001\nvsd = OEM\n\n[HCA]\n; link settings\nnum_pfs = 1\n"},
+		{"new section goes last", "PORT", "speed", "100G",
+			"; generated\n[IMAGE_INFO]\nname = CX5\npsid=MT_0000000001\n\n[HCA]\n; link settings\nnum_pfs = 1\n\n[PORT]\nspeed = 100G\n"},
+		{"key outside sections goes first", "", "version", "3",
+			"version = 3\n; generated\n[IMAGE_INFO]\nname = CX5\npsid=MT_0000000001\n\n[HCA]\n; link settings\nnum_pfs = 1\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := Parse([]byte(testINI))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if err := f.Set(tt.section, tt.key, tt.value); err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+			if got := string(f.Bytes()); got != tt.want {
+				t.Errorf("Bytes() = %q, want %q", got, tt.want)
+			}
+			if got := f.Lookup(tt.section, tt.key); len(got) != 1 || got[0].Value != tt.value {
+				t.Errorf("Lookup() after Set() = %v", got)
+			}
+		})
+	}
+}
+
+func TestSet_CRLF(t *testing.T) {
+	f, err := Parse([]byte("[HCA]\r\nnum_pfs = 1\r\n"))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if err := f.Set("HCA", "num_pfs", "4"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if got := string(f.Bytes()); got != "[HCA]\r\nnum_pfs = 4\r\n" {
+		t.Errorf("Bytes() = %q", got)
+	}
+}
+
+func TestSet_Errors(t *testing.T) {
+	f, err := Parse([]byte(testINI))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	for _, args := range [][3]string{
+		{"HCA", "", "1"},
+		{"HCA", "a=b", "1"},
+		{"HC]A", "key", "1"},
+		{"HCA", "key", "1\n[other]"},
+	} {
+		if err := f.Set(args[0], args[1], args[2]); !errors.Is(err, pkgerrors.ErrInvalidParameter) {
+			t.Errorf("Set(%q) error = %v, want ErrInvalidParameter", args, err)
+		}
+	}
+}
@@ -1,27 +1,53 @@
 package interfaces
 
 import (
-	"io"
-
 	"github.com/Civil/mlx5fw-go/pkg/types"
 )
 
-// FirmwareParser is the main interface for parsing firmware files
+// FirmwareParser is implemented by every format-specific firmware parser
+// (pkg/parser/fs3, pkg/parser/fs4) and is what commands work with
 type FirmwareParser interface {
-	// Parse reads and parses the firmware from the provided reader
-	Parse(reader io.ReaderAt) error
+	// Parse reads the image and discovers its sections
+	Parse() error
 
 	// Query returns firmware information similar to mstflint query output
 	Query() (*FirmwareInfo, error)
 
-	// GetFormat returns the firmware format (FS4 or FS5)
-	GetFormat() int
+	// GetFormat returns the firmware format
+	GetFormat() types.FirmwareFormat
+
+	// GetSections returns all parsed sections grouped by type
+	GetSections() map[uint16][]CompleteSectionInterface
+
+	// VerifySectionNew verifies a section and returns an mstflint-style status
+	VerifySectionNew(section SectionVerifier) (string, error)
+
+	// ReadSectionData reads raw section bytes from the image
+	ReadSectionData(sectionType uint16, offset uint64, size uint32) ([]byte, error)
+
+	// IsEncrypted returns true if the image is encrypted
+	IsEncrypted() bool
+
+	// GetMagicOffset returns the offset where the image starts
+	GetMagicOffset() uint32
+
+	// GetITOCAddress and GetDTOCAddress return the TOC addresses (0 when absent)
+	GetITOCAddress() uint32
+	GetDTOCAddress() uint32
+
+	// IsITOCHeaderValid and IsDTOCHeaderValid report the TOC header CRC status
+	IsITOCHeaderValid() bool
+	IsDTOCHeaderValid() bool
+
+	// IsITOCValid returns true if the ITOC can be trusted
+	IsITOCValid() bool
 
-	// GetSections returns all parsed sections
-	GetSections() map[uint16]*Section
+	// GetITOCRawData and GetDTOCRawData return the raw TOC headers
+	GetITOCRawData() ([]byte, error)
+	GetDTOCRawData() ([]byte, error)
 
-	// GetSection returns a specific section by type
-	GetSection(sectionType uint16) (*Section, error)
+	// Diagnostics returns the recoverable conditions found by Parse
+	Diagnostics() types.Diagnostics
 }
 
 // FirmwareInfo represents the query output information
@@ -0,0 +1,160 @@
+package lint
+
+import (
+	"encoding/binary"
+
+	"github.com/ansel1/merry/v2"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/parser"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+// hwPointer is one used HW pointer entry
+type hwPointer struct {
+	index  int
+	offset uint64
+	entry  types.HWPointerEntry
+}
+
+// tocEntry is one ITOC/DTOC entry as stored in the image
+type tocEntry struct {
+	toc    *toc
+	index  int
+	offset uint64
+	raw    []byte
+	entry  types.ITOCEntry
+}
+
+// sectionType returns the section type of the entry, with DTOC types in the
+// 0xE0xx range as the TOC reader maps them
+func (e *tocEntry) sectionType() uint16 {
+	t := e.entry.GetType()
+	if e.toc.name == "DTOC" && t < 0x20 {
+		t |= 0xE000
+	}
+	return t
+}
+
+// name returns the section name of the entry
+func (e *tocEntry) name() string {
+	return types.GetSectionTypeName(e.sectionType())
+}
+
+// start and end return the byte range of the section the entry describes;
+// end is computed in 64 bits so that it cannot wrap
+func (e *tocEntry) start() uint64 { return uint64(e.entry.GetFlashAddr()) }
+func (e *tocEntry) end() uint64   { return e.start() + uint64(e.entry.GetSize()) }
+
+// toc is an ITOC or DTOC with the entries up to its end entry
+type toc struct {
+	name      string
+	addr      uint64
+	header    []byte
+	signature bool
+	entries   []*tocEntry
+	// terminated is set when the walk reached the end entry; areaEnd is the
+	// end of the entry array including that entry
+	terminated bool
+	areaEnd    uint64
+}
+
+// image is the raw layout the rules check
+type image struct {
+	data        []byte
+	magicOffset uint64
+	hwPointers  []hwPointer
+	// hwPointerNames are the HW pointer names of the image format
+	hwPointerNames []string
+	tocs           []*toc
+	crc            *parser.CRCCalculator
+}
+
+// newImage reads the HW pointers and the TOCs of the image in data
+func newImage(data []byte, fwParser interfaces.FirmwareParser) (*image, error) {
+	img := &image{
+		data:           data,
+		magicOffset:    uint64(fwParser.GetMagicOffset()),
+		hwPointerNames: types.HWPointerNames(fwParser.GetFormat()),
+		crc:            parser.NewCRCCalculator(),
+	}
+
+	hwOffset := img.magicOffset + types.HWPointersOffsetFromMagic
+	if hwOffset+types.HWPointersSize > uint64(len(data)) {
+		return nil, pkgerrors.DataTooShortError(int(hwOffset+types.HWPointersSize), len(data), "HW pointers")
+	}
+	for i := 0; i < types.HWPointersSize/8; i++ {
+		offset := hwOffset + uint64(i*8)
+		p := hwPointer{index: i, offset: offset}
+		if err := p.entry.UnmarshalWithReserved(data[offset : offset+8]); err != nil {
+			return nil, merry.Wrap(err)
+		}
+		if p.entry.Ptr != 0 && p.entry.Ptr != 0xFFFFFFFF {
+			img.hwPointers = append(img.hwPointers, p)
+		}
+	}
+
+	// The ITOC of an encrypted image cannot be read
+	if !fwParser.IsEncrypted() {
+		img.tocs = append(img.tocs, readTOC(data, "ITOC", uint64(fwParser.GetITOCAddress()), types.ITOCSignature))
+	}
+	if addr := fwParser.GetDTOCAddress(); addr != 0 {
+		img.tocs = append(img.tocs, readTOC(data, "DTOC", uint64(addr), types.DTOCSignature))
+	}
+	return img, nil
+}
+
+// readTOC walks the TOC at addr. Like the parser, it reads no entries when
+// the header signature is missing.
+func readTOC(data []byte, name string, addr uint64, signature uint32) *toc {
+	t := &toc{name: name, addr: addr, areaEnd: addr}
+	if addr+types.ITOCHeaderSize > uint64(len(data)) {
+		return t
+	}
+	t.header = data[addr : addr+types.ITOCHeaderSize]
+	t.signature = binary.BigEndian.Uint32(t.header) == signature
+	t.areaEnd = addr + types.ITOCHeaderSize
+	if !t.signature {
+		return t
+	}
+
+	for i := 0; i < maxTOCEntries; i++ {
+		offset := addr + types.ITOCHeaderSize + uint64(i*types.ITOCEntrySize)
+		if offset+types.ITOCEntrySize > uint64(len(data)) {
+			break
+		}
+		e := &tocEntry{toc: t, index: i, offset: offset, raw: data[offset : offset+types.ITOCEntrySize]}
+		if err := e.entry.Unmarshal(e.raw); err != nil {
+			break
+		}
+		t.areaEnd = offset + types.ITOCEntrySize
+		if e.entry.GetType() == 0xFF {
+			t.terminated = true
+			break
+		}
+		t.entries = append(t.entries, e)
+	}
+	return t
+}
+
+// entries returns the entries of all TOCs
+func (img *image) entries() []*tocEntry {
+	var all []*tocEntry
+	for _, t := range img.tocs {
+		all = append(all, t.entries...)
+	}
+	return all
+}
+
+// placedEntries returns the entries that describe a non-empty section inside
+// the image, which the overlap rules compare
+func (img *image) placedEntries() []*tocEntry {
+	var placed []*tocEntry
+	for _, e := range img.entries() {
+		if e.entry.GetSize() != 0 && e.end() <= uint64(len(img.data)) {
+			placed = append(placed, e)
+		}
+	}
+	return placed
+}
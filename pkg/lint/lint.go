@@ -0,0 +1,103 @@
+// Package lint checks the layout of FS4/FS5 images against a set of named
+// rules. Unlike the parser, which recovers from what it can, the rules read
+// the HW pointers and TOC entries as stored and flag anything a malformed or
+// adversarial image could use to confuse a consumer.
+package lint
+
+import (
+	"fmt"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+// maxTOCEntries bounds the TOC walk, as in the TOC reader
+const maxTOCEntries = 256
+
+// reportFunc records a finding of the rule being run
+type reportFunc func(offset uint64, section string, format string, args ...any)
+
+// Rule is a named layout check
+type Rule struct {
+	Name        types.DiagnosticCode `json:"name"`
+	Severity    types.Severity       `json:"severity"`
+	Description string               `json:"description"`
+	check       func(img *image, report reportFunc)
+}
+
+// Report is the result of Lint
+type Report struct {
+	Format    string            `json:"format"`
+	Encrypted bool              `json:"encrypted"`
+	Rules     []string          `json:"rules"`
+	Findings  types.Diagnostics `json:"findings"`
+	// OK is true when no finding has error severity
+	OK bool `json:"ok"`
+}
+
+// Rules returns all rules in the order they run
+func Rules() []Rule {
+	return []Rule{
+		{"HW_POINTER_CRC", types.SeverityError, "A used HW pointer entry has a bad CRC", checkHWPointerCRC},
+		{"HW_POINTER_RANGE", types.SeverityError, "A used HW pointer points beyond the image", checkHWPointerRange},
+		{"TOC_SIGNATURE", types.SeverityError, "No ITOC/DTOC signature at the TOC address", checkTOCSignature},
+		{"TOC_HEADER_CRC", types.SeverityError, "The ITOC/DTOC header CRC does not match", checkTOCHeaderCRC},
+		{"TOC_NO_END", types.SeverityWarning, "The TOC has no end entry before its size limit or the image end", checkTOCNoEnd},
+		{"TOC_ENTRY_CRC", types.SeverityError, "A TOC entry CRC does not match", checkTOCEntryCRC},
+		{"ZERO_SIZE", types.SeverityWarning, "A TOC entry describes an empty section", checkZeroSize},
+		{"ADDRESS_WRAP", types.SeverityError, "A section end wraps around the 32-bit flash address space", checkAddressWrap},
+		{"OUT_OF_BOUNDS", types.SeverityError, "A section extends beyond the image", checkOutOfBounds},
+		{"HW_POINTER_AREA", types.SeverityError, "A section overlaps the magic pattern or the HW pointers", checkHWPointerArea},
+		{"TOC_AREA", types.SeverityError, "A section overlaps an ITOC/DTOC header or entry array", checkTOCArea},
+		{"SECTION_OVERLAP", types.SeverityError, "Two sections overlap", checkSectionOverlap},
+		{"DUPLICATE_TYPE", types.SeverityWarning, "A section type appears more than once in a TOC", checkDuplicateType},
+		{"UNKNOWN_TYPE", types.SeverityInfo, "A TOC entry has a section type with no known name", checkUnknownType},
+	}
+}
+
+// Lint runs every rule not listed in disable on the image in data. fwParser
+// supplies the image start and the TOC addresses. The ITOC rules are skipped
+// for encrypted images.
+func Lint(data []byte, fwParser interfaces.FirmwareParser, disable []string) (*Report, error) {
+	format := fwParser.GetFormat()
+	if format == types.FormatFS3 {
+		return nil, pkgerrors.NotSupportedError("linting " + format.String() + " images")
+	}
+
+	rules := Rules()
+	disabled := make(map[types.DiagnosticCode]bool)
+	for _, name := range disable {
+		found := false
+		for _, r := range rules {
+			if string(r.Name) == name {
+				found = true
+			}
+		}
+		if !found {
+			return nil, pkgerrors.InvalidParameterError("rule", fmt.Sprintf("unknown lint rule %q", name))
+		}
+		disabled[types.DiagnosticCode(name)] = true
+	}
+
+	img, err := newImage(data, fwParser)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{
+		Format:    format.String(),
+		Encrypted: fwParser.IsEncrypted(),
+		Findings:  types.Diagnostics{},
+	}
+	for _, r := range rules {
+		if disabled[r.Name] {
+			continue
+		}
+		report.Rules = append(report.Rules, string(r.Name))
+		r.check(img, func(offset uint64, section string, format string, args ...any) {
+			report.Findings.Add(r.Severity, r.Name, offset, section, format, args...)
+		})
+	}
+	report.OK = len(report.Findings.AtLeast(types.SeverityError)) == 0
+	return report, nil
+}
@@ -0,0 +1,194 @@
+package lint
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/parser"
+	"github.com/Civil/mlx5fw-go/pkg/parser/fs4"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+const (
+	testITOCAddr = 0x2000
+	testDTOCAddr = 0xF000
+	testSize     = 0x10000
+)
+
+// stubParser supplies the image start and TOC addresses; the remaining
+// methods are unused
+type stubParser struct {
+	interfaces.FirmwareParser
+	format    types.FirmwareFormat
+	encrypted bool
+}
+
+func (p *stubParser) GetFormat() types.FirmwareFormat { return p.format }
+func (p *stubParser) GetMagicOffset() uint32          { return 0 }
+func (p *stubParser) GetITOCAddress() uint32          { return testITOCAddr }
+func (p *stubParser) GetDTOCAddress() uint32          { return testDTOCAddr }
+func (p *stubParser) IsEncrypted() bool               { return p.encrypted }
+
+// putEntry writes TOC entry i with a valid entry CRC
+func putEntry(t *testing.T, data []byte, tocAddr uint32, i int, sectionType uint8, addr, size uint32) {
+	t.Helper()
+	entry := &types.ITOCEntry{Type: sectionType, SizeDwords: size / 4, FlashAddrDwords: addr}
+	raw, err := entry.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	offset := tocAddr + types.ITOCHeaderSize + uint32(i)*types.ITOCEntrySize
+	copy(data[offset:], raw)
+	binary.BigEndian.PutUint16(data[offset+30:], parser.NewCRCCalculator().CalculateImageCRC(raw[:28], 7))
+}
+
+// putHWPointer writes HW pointer i with a valid CRC
+func putHWPointer(data []byte, i int, ptr uint32) {
+	entry := data[types.HWPointersOffsetFromMagic+i*8:]
+	binary.BigEndian.PutUint32(entry, ptr)
+	binary.BigEndian.PutUint16(entry[4:], 0)
+	binary.BigEndian.PutUint16(entry[6:], parser.NewCRCCalculator().CalculateHardwareCRC(entry[:6]))
+}
+
+// createLintImage builds a clean image: BOOT2 and TOC HW pointers, an ITOC
+// with MAIN_CODE and IMAGE_INFO, and an empty DTOC
+func createLintImage(t *testing.T) []byte {
+	t.Helper()
+	data := bytes.Repeat([]byte{0xFF}, testSize)
+	binary.BigEndian.PutUint64(data, types.MagicPattern)
+	putHWPointer(data, 1, 0x1000)
+	putHWPointer(data, 2, testITOCAddr)
+
+	crc := parser.NewCRCCalculator()
+	for _, toc := range []struct {
+		addr      uint32
+		signature uint32
+	}{{testITOCAddr, types.ITOCSignature}, {testDTOCAddr, types.DTOCSignature}} {
+		header := data[toc.addr : toc.addr+types.ITOCHeaderSize]
+		for i := range header {
+			header[i] = 0
+		}
+		binary.BigEndian.PutUint32(header, toc.signature)
+		fs4.UpdateITOCHeaderCRC(header, crc)
+	}
+	putEntry(t, data, testITOCAddr, 0, uint8(types.SectionTypeMainCode), 0x4000, 0x1000)
+	putEntry(t, data, testITOCAddr, 1, uint8(types.SectionTypeImageInfo), 0x6000, 0x400)
+	return data
+}
+
+func TestLint_CleanImage(t *testing.T) {
+	report, err := Lint(createLintImage(t), &stubParser{format: types.FormatFS4}, nil)
+	if err != nil {
+		t.Fatalf("Lint() error = %v", err)
+	}
+	if !report.OK || len(report.Findings) != 0 || len(report.Rules) != len(Rules()) {
+		t.Errorf("Lint() of a clean image = %+v", report)
+	}
+}
+
+func TestLint_Rules(t *testing.T) {
+	tests := []struct {
+		rule   types.DiagnosticCode
+		modify func(t *testing.T, data []byte)
+	}{
+		{"HW_POINTER_CRC", func(t *testing.T, data []byte) { data[0x20+7] ^= 1 }},
+		{"HW_POINTER_RANGE", func(t *testing.T, data []byte) { putHWPointer(data, 10, 0x20000) }},
+		{"TOC_SIGNATURE", func(t *testing.T, data []byte) { data[testDTOCAddr] = 0 }},
+		{"TOC_HEADER_CRC", func(t *testing.T, data []byte) { data[testITOCAddr+0x10] = 1 }},
+		{"TOC_NO_END", func(t *testing.T, data []byte) {
+			for i := 0; i < maxTOCEntries; i++ {
+				putEntry(t, data, testITOCAddr, i, uint8(types.SectionTypeMainCode), 0x4000, 0x10)
+			}
+		}},
+		{"TOC_ENTRY_CRC", func(t *testing.T, data []byte) { data[testITOCAddr+types.ITOCHeaderSize+31] ^= 1 }},
+		{"ZERO_SIZE", func(t *testing.T, data []byte) { putEntry(t, data, testITOCAddr, 1, 0x10, 0x6000, 0) }},
+		{"ADDRESS_WRAP", func(t *testing.T, data []byte) { putEntry(t, data, testITOCAddr, 1, 0x10, 0xFFFFF000, 0x2000) }},
+		{"OUT_OF_BOUNDS", func(t *testing.T, data []byte) { putEntry(t, data, testITOCAddr, 1, 0x10, 0xF800, 0x1000) }},
+		{"HW_POINTER_AREA", func(t *testing.T, data []byte) { putEntry(t, data, testITOCAddr, 1, 0x10, 0, 0x100) }},
+		{"TOC_AREA", func(t *testing.T, data []byte) { putEntry(t, data, testITOCAddr, 1, 0x10, testDTOCAddr, 0x100) }},
+		{"SECTION_OVERLAP", func(t *testing.T, data []byte) { putEntry(t, data, testITOCAddr, 1, 0x10, 0x4800, 0x400) }},
+		{"DUPLICATE_TYPE", func(t *testing.T, data []byte) {
+			putEntry(t, data, testITOCAddr, 1, uint8(types.SectionTypeMainCode), 0x6000, 0x400)
+		}},
+		{"UNKNOWN_TYPE", func(t *testing.T, data []byte) { putEntry(t, data, testITOCAddr, 1, 0x7F, 0x6000, 0x400) }},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.rule), func(t *testing.T) {
+			data := createLintImage(t)
+			tt.modify(t, data)
+
+			report, err := Lint(data, &stubParser{format: types.FormatFS4}, nil)
+			if err != nil {
+				t.Fatalf("Lint() error = %v", err)
+			}
+			found := false
+			for _, d := range report.Findings {
+				found = found || d.Code == tt.rule
+			}
+			if !found {
+				t.Errorf("Lint() findings %v do not include %s", report.Findings, tt.rule)
+			}
+		})
+	}
+}
+
+func TestLint_HWPointerNames(t *testing.T) {
+	tests := []struct {
+		format types.FirmwareFormat
+		want   string
+	}{
+		{types.FormatFS4, "FW_SECURITY_VERSION_PTR"},
+		{types.FormatFS5, "RESERVED_PTR13"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format.String(), func(t *testing.T) {
+			data := createLintImage(t)
+			putHWPointer(data, 13, 0x20000)
+
+			report, err := Lint(data, &stubParser{format: tt.format}, nil)
+			if err != nil {
+				t.Fatalf("Lint() error = %v", err)
+			}
+			found := false
+			for _, d := range report.Findings {
+				found = found || d.Code == "HW_POINTER_RANGE" && d.Section == tt.want
+			}
+			if !found {
+				t.Errorf("Lint() findings %v do not include HW_POINTER_RANGE for %s", report.Findings, tt.want)
+			}
+		})
+	}
+}
+
+func TestLint_Options(t *testing.T) {
+	data := createLintImage(t)
+	data[testITOCAddr+types.ITOCHeaderSize+31] ^= 1
+
+	report, err := Lint(data, &stubParser{format: types.FormatFS4}, []string{"TOC_ENTRY_CRC"})
+	if err != nil {
+		t.Fatalf("Lint() error = %v", err)
+	}
+	if len(report.Findings) != 0 || len(report.Rules) != len(Rules())-1 {
+		t.Errorf("Lint() with TOC_ENTRY_CRC disabled = %+v", report)
+	}
+
+	// The ITOC of an encrypted image is not read
+	report, err = Lint(data, &stubParser{format: types.FormatFS4, encrypted: true}, nil)
+	if err != nil {
+		t.Fatalf("Lint() error = %v", err)
+	}
+	if len(report.Findings) != 0 {
+		t.Errorf("Lint() of an encrypted image = %v", report.Findings)
+	}
+
+	if _, err := Lint(data, &stubParser{format: types.FormatFS4}, []string{"NO_SUCH_RULE"}); err == nil {
+		t.Error("Lint() accepted an unknown rule")
+	}
+	if _, err := Lint(data, &stubParser{format: types.FormatFS3}, nil); !errors.Is(err, pkgerrors.ErrNotSupported) {
+		t.Errorf("Lint() of an FS3 image error = %v, want ErrNotSupported", err)
+	}
+}
@@ -0,0 +1,171 @@
+package lint
+
+import (
+	"encoding/binary"
+	"sort"
+	"strings"
+
+	"github.com/Civil/mlx5fw-go/pkg/parser/fs4"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+// checkHWPointerCRC checks the CRC over the first 6 bytes of each used HW
+// pointer entry, as mstflint does when it reads the pointers
+func checkHWPointerCRC(img *image, report reportFunc) {
+	for _, p := range img.hwPointers {
+		raw := img.data[p.offset : p.offset+8]
+		if calculated := img.crc.CalculateHardwareCRC(raw[:6]); calculated != p.entry.CRC {
+			report(p.offset, img.hwPointerNames[p.index],
+				"CRC mismatch: calculated 0x%04x, stored 0x%04x", calculated, p.entry.CRC)
+		}
+	}
+}
+
+// checkHWPointerRange flags used HW pointers beyond the end of the image
+func checkHWPointerRange(img *image, report reportFunc) {
+	for _, p := range img.hwPointers {
+		if uint64(p.entry.Ptr) >= uint64(len(img.data)) {
+			report(p.offset, img.hwPointerNames[p.index],
+				"points to 0x%x, beyond the image size 0x%x", p.entry.Ptr, len(img.data))
+		}
+	}
+}
+
+// checkTOCSignature flags TOCs whose header is missing or has no signature
+func checkTOCSignature(img *image, report reportFunc) {
+	for _, t := range img.tocs {
+		switch {
+		case t.header == nil:
+			report(t.addr, t.name, "header at 0x%x is beyond the image size 0x%x", t.addr, len(img.data))
+		case !t.signature:
+			report(t.addr, t.name, "no %s signature, found 0x%08x", t.name, binary.BigEndian.Uint32(t.header))
+		}
+	}
+}
+
+// checkTOCHeaderCRC checks the CRC of every TOC header with a signature
+func checkTOCHeaderCRC(img *image, report reportFunc) {
+	for _, t := range img.tocs {
+		if !t.signature {
+			continue
+		}
+		stored := uint16(binary.BigEndian.Uint32(t.header[28:]))
+		if calculated := fs4.CalculateITOCHeaderCRC(t.header, img.crc); calculated != stored {
+			report(t.addr, t.name, "header CRC mismatch: calculated 0x%04x, stored 0x%04x", calculated, stored)
+		}
+	}
+}
+
+// checkTOCNoEnd flags TOCs whose entry walk did not reach an end entry
+func checkTOCNoEnd(img *image, report reportFunc) {
+	for _, t := range img.tocs {
+		if t.signature && !t.terminated {
+			report(t.addr, t.name, "no end entry after %d entries", len(t.entries))
+		}
+	}
+}
+
+// checkTOCEntryCRC checks the CRC over the first 7 dwords of every entry
+func checkTOCEntryCRC(img *image, report reportFunc) {
+	for _, e := range img.entries() {
+		calculated := img.crc.CalculateImageCRC(e.raw[:types.ITOCEntrySize-4], types.ITOCEntrySize/4-1)
+		if calculated != e.entry.ITOCEntryCRC {
+			report(e.offset, e.name(), "%s entry %d CRC mismatch: calculated 0x%04x, stored 0x%04x",
+				e.toc.name, e.index, calculated, e.entry.ITOCEntryCRC)
+		}
+	}
+}
+
+// checkZeroSize flags entries with a zero section size
+func checkZeroSize(img *image, report reportFunc) {
+	for _, e := range img.entries() {
+		if e.entry.GetSize() == 0 {
+			report(e.offset, e.name(), "%s entry %d has size 0 at 0x%x", e.toc.name, e.index, e.start())
+		}
+	}
+}
+
+// checkAddressWrap flags sections whose 32-bit end address wraps to the
+// start of the flash, which bypasses naive end > size bound checks
+func checkAddressWrap(img *image, report reportFunc) {
+	for _, e := range img.entries() {
+		if e.end() > 1<<32 {
+			report(e.start(), e.name(), "section 0x%x+0x%x wraps around the 32-bit address space",
+				e.start(), e.entry.GetSize())
+		}
+	}
+}
+
+// checkOutOfBounds flags sections that do not fit in the image
+func checkOutOfBounds(img *image, report reportFunc) {
+	for _, e := range img.entries() {
+		if e.end() > uint64(len(img.data)) {
+			report(e.start(), e.name(), "section ends at 0x%x, beyond the image size 0x%x", e.end(), len(img.data))
+		}
+	}
+}
+
+// checkHWPointerArea flags sections that overlap the magic pattern or the
+// HW pointers, which would let a section rewrite the image entry points
+func checkHWPointerArea(img *image, report reportFunc) {
+	areaEnd := img.magicOffset + types.HWPointersOffsetFromMagic + types.HWPointersSize
+	for _, e := range img.placedEntries() {
+		if e.start() < areaEnd && e.end() > img.magicOffset {
+			report(e.start(), e.name(), "section 0x%x-0x%x overlaps the HW pointer area 0x%x-0x%x",
+				e.start(), e.end()-1, img.magicOffset, areaEnd-1)
+		}
+	}
+}
+
+// checkTOCArea flags sections that overlap a TOC header or entry array
+func checkTOCArea(img *image, report reportFunc) {
+	for _, e := range img.placedEntries() {
+		for _, t := range img.tocs {
+			if t.header != nil && e.start() < t.areaEnd && e.end() > t.addr {
+				report(e.start(), e.name(), "section 0x%x-0x%x overlaps the %s at 0x%x-0x%x",
+					e.start(), e.end()-1, t.name, t.addr, t.areaEnd-1)
+			}
+		}
+	}
+}
+
+// checkSectionOverlap flags every section that starts before the end of a
+// section placed below it
+func checkSectionOverlap(img *image, report reportFunc) {
+	placed := img.placedEntries()
+	sort.SliceStable(placed, func(i, j int) bool { return placed[i].start() < placed[j].start() })
+
+	var prev *tocEntry
+	for _, e := range placed {
+		if prev != nil && e.start() < prev.end() {
+			report(e.start(), e.name(), "section 0x%x-0x%x overlaps %s at 0x%x-0x%x",
+				e.start(), e.end()-1, prev.name(), prev.start(), prev.end()-1)
+		}
+		if prev == nil || e.end() > prev.end() {
+			prev = e
+		}
+	}
+}
+
+// checkDuplicateType flags section types listed more than once in a TOC
+func checkDuplicateType(img *image, report reportFunc) {
+	for _, t := range img.tocs {
+		first := make(map[uint16]*tocEntry)
+		for _, e := range t.entries {
+			if f, ok := first[e.sectionType()]; ok {
+				report(e.offset, e.name(), "%s entry %d repeats the type of entry %d", t.name, e.index, f.index)
+				continue
+			}
+			first[e.sectionType()] = e
+		}
+	}
+}
+
+// checkUnknownType flags entries whose type has no known name
+func checkUnknownType(img *image, report reportFunc) {
+	for _, e := range img.entries() {
+		if strings.HasPrefix(e.name(), "UNKNOWN") {
+			report(e.offset, e.name(), "%s entry %d has unknown type 0x%02x", e.toc.name, e.index, e.entry.GetType())
+		}
+	}
+}
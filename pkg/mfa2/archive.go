@@ -0,0 +1,375 @@
+// Package mfa2 parses MFA2 firmware bundles (.mfa2) that carry per-PSID firmware
+// images as xz-compressed components.
+package mfa2
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ansel1/merry/v2"
+	"go.uber.org/zap"
+
+	"github.com/Civil/mlx5fw-go/pkg/compressutil"
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+// Device is a device descriptor: a PSID and the components that apply to it
+type Device struct {
+	PSID       string             `json:"psid"`
+	Components []ComponentPointer `json:"components"`
+}
+
+// Component is a component descriptor together with its index in the archive
+type Component struct {
+	Index      int                 `json:"index"`
+	Descriptor ComponentDescriptor `json:"descriptor"`
+}
+
+// Archive is a parsed MFA2 bundle. Component payloads stay compressed until requested.
+type Archive struct {
+	Package    PackageDescriptor `json:"package"`
+	Devices    []Device          `json:"devices"`
+	Components []Component       `json:"components"`
+
+	src    io.ReaderAt
+	size   int64
+	closer io.Closer
+	logger *zap.Logger
+}
+
+// IsArchive reports whether header starts with the MFA2 fingerprint
+func IsArchive(header []byte) bool {
+	return bytes.HasPrefix(header, []byte(Fingerprint))
+}
+
+// IsLegacyArchive reports whether header starts with the MFA (v1) signature
+func IsLegacyArchive(header []byte) bool {
+	return bytes.HasPrefix(header, []byte(MFA1Signature))
+}
+
+// Open opens and parses an MFA2 archive from a file path
+func Open(path string, logger *zap.Logger) (*Archive, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, merry.Wrap(err)
+	}
+
+	archive, err := Parse(file, stat.Size(), logger)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	archive.closer = file
+	return archive, nil
+}
+
+// Parse parses the descriptors of an MFA2 archive read from src
+func Parse(src io.ReaderAt, size int64, logger *zap.Logger) (*Archive, error) {
+	header := make([]byte, len(Fingerprint))
+	if _, err := src.ReadAt(header, 0); err != nil {
+		if err == io.EOF {
+			return nil, merry.Wrap(pkgerrors.ErrInvalidMagic, merry.WithMessage("file too short for MFA2 fingerprint"))
+		}
+		return nil, merry.Wrap(err)
+	}
+	if IsLegacyArchive(header) {
+		return nil, pkgerrors.NotSupportedError("legacy MFA (v1) archives")
+	}
+	if !IsArchive(header) {
+		return nil, merry.Wrap(pkgerrors.ErrInvalidMagic, merry.WithMessagef("missing MFA2 fingerprint %q", Fingerprint))
+	}
+
+	a := &Archive{
+		src:    src,
+		size:   size,
+		logger: logger,
+	}
+
+	// The descriptor area ends where the component block begins, but its location is only
+	// known once the package descriptor is read. Read the first multi-part TLV on its own,
+	// sized by its header so that extension TLVs after the descriptor are included.
+	firstOffset := alignTLV(len(Fingerprint))
+	prefix, err := a.readMultiPart(firstOffset)
+	if err != nil {
+		return nil, merry.Prepend(err, "failed to read package descriptor")
+	}
+	first, err := readTLV(prefix, firstOffset)
+	if err != nil {
+		return nil, merry.Prepend(err, "failed to read package descriptor")
+	}
+	if err := a.parsePackageDescriptor(first); err != nil {
+		return nil, err
+	}
+
+	cbOffset := int64(alignTLV(int(a.Package.CBOffset)))
+	if cbOffset > size || cbOffset+int64(a.Package.CBArchiveSize) > size {
+		return nil, merry.Wrap(pkgerrors.ErrInvalidOffset,
+			merry.WithMessagef("component block [0x%x, +0x%x) exceeds archive size 0x%x", cbOffset, a.Package.CBArchiveSize, size))
+	}
+
+	descriptors, err := a.readRange(0, int(cbOffset))
+	if err != nil {
+		return nil, merry.Prepend(err, "failed to read descriptors")
+	}
+	first, err = readTLV(descriptors, firstOffset)
+	if err != nil {
+		return nil, err
+	}
+
+	next := first.next
+	for i := 0; i < int(a.Package.NumDevices); i++ {
+		t, err := readTLV(descriptors, next)
+		if err != nil {
+			return nil, merry.Prependf(err, "device descriptor %d", i)
+		}
+		dev, err := a.parseDevice(t)
+		if err != nil {
+			return nil, merry.Prependf(err, "device descriptor %d", i)
+		}
+		a.Devices = append(a.Devices, *dev)
+		next = t.next
+	}
+
+	for i := 0; i < int(a.Package.NumComponents); i++ {
+		t, err := readTLV(descriptors, next)
+		if err != nil {
+			return nil, merry.Prependf(err, "component descriptor %d", i)
+		}
+		comp, err := a.parseComponent(t)
+		if err != nil {
+			return nil, merry.Prependf(err, "component descriptor %d", i)
+		}
+		comp.Index = i
+		a.Components = append(a.Components, *comp)
+		next = t.next
+	}
+
+	for _, dev := range a.Devices {
+		for _, ptr := range dev.Components {
+			if int(ptr.ComponentIndex) >= len(a.Components) {
+				return nil, merry.Wrap(pkgerrors.ErrInvalidData,
+					merry.WithMessagef("device %s references component %d, archive has %d", dev.PSID, ptr.ComponentIndex, len(a.Components)))
+			}
+		}
+	}
+
+	logger.Debug("Parsed MFA2 archive",
+		zap.Int("devices", len(a.Devices)),
+		zap.Int("components", len(a.Components)),
+		zap.Uint32("cb_offset", a.Package.CBOffset),
+		zap.Uint32("cb_archive_size", a.Package.CBArchiveSize))
+
+	return a, nil
+}
+
+// Close releases the archive file if it was opened by Open
+func (a *Archive) Close() error {
+	if a.closer != nil {
+		err := a.closer.Close()
+		a.closer = nil
+		return err
+	}
+	return nil
+}
+
+// PSIDs returns the PSIDs of all devices in archive order
+func (a *Archive) PSIDs() []string {
+	psids := make([]string, 0, len(a.Devices))
+	for _, dev := range a.Devices {
+		psids = append(psids, dev.PSID)
+	}
+	return psids
+}
+
+// FindDevice returns the device descriptor for a PSID (case-insensitive)
+func (a *Archive) FindDevice(psid string) (*Device, error) {
+	for i := range a.Devices {
+		if strings.EqualFold(a.Devices[i].PSID, psid) {
+			return &a.Devices[i], nil
+		}
+	}
+	return nil, merry.Wrap(pkgerrors.ErrPSIDNotFound,
+		merry.WithMessagef("PSID %s not in archive (available: %s)", psid, strings.Join(a.PSIDs(), ", ")))
+}
+
+// FirmwareComponent returns the firmware image component of a device.
+// Devices normally reference exactly one; the first FW component wins.
+func (a *Archive) FirmwareComponent(dev *Device) (*Component, error) {
+	for _, ptr := range dev.Components {
+		comp := &a.Components[ptr.ComponentIndex]
+		if comp.Descriptor.Identifier == ComponentIDFirmware {
+			return comp, nil
+		}
+	}
+	return nil, merry.Wrap(pkgerrors.ErrSectionNotFound,
+		merry.WithMessagef("no firmware component for PSID %s", dev.PSID))
+}
+
+// ComponentData decompresses the payload of a component, without the component magic
+func (a *Archive) ComponentData(comp *Component) ([]byte, error) {
+	desc := comp.Descriptor
+	if int64(desc.Size) > types.MaxFirmwareSize {
+		return nil, pkgerrors.FileTooLargeError(int64(desc.Size), types.MaxFirmwareSize)
+	}
+	if desc.CBOffset()+uint64(len(ComponentMagic))+uint64(desc.Size) > a.Package.CBSize() {
+		return nil, merry.Wrap(pkgerrors.ErrInvalidOffset,
+			merry.WithMessagef("component %d [0x%x, +0x%x) exceeds component block size 0x%x",
+				comp.Index, desc.CBOffset(), desc.Size, a.Package.CBSize()))
+	}
+
+	cb := io.NewSectionReader(a.src, int64(alignTLV(int(a.Package.CBOffset))), int64(a.Package.CBArchiveSize))
+	r, err := compressutil.NewReader(compressutil.FormatXZ, cb)
+	if err != nil {
+		return nil, merry.Prepend(err, "failed to open component block")
+	}
+	defer r.Close()
+
+	// xz streams are not seekable: skip everything before the component
+	if _, err := io.CopyN(io.Discard, r, int64(desc.CBOffset())); err != nil {
+		return nil, merry.Prependf(err, "failed to seek to component %d", comp.Index)
+	}
+
+	buf := make([]byte, len(ComponentMagic)+int(desc.Size))
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, merry.Prependf(err, "failed to read component %d", comp.Index)
+	}
+	if string(buf[:len(ComponentMagic)]) != ComponentMagic {
+		return nil, merry.Wrap(pkgerrors.ErrInvalidMagic,
+			merry.WithMessagef("component %d has wrong magic %q", comp.Index, buf[:len(ComponentMagic)]))
+	}
+
+	return buf[len(ComponentMagic):], nil
+}
+
+// ImageForPSID decompresses the firmware image that applies to the given PSID
+func (a *Archive) ImageForPSID(psid string) ([]byte, error) {
+	dev, err := a.FindDevice(psid)
+	if err != nil {
+		return nil, err
+	}
+	comp, err := a.FirmwareComponent(dev)
+	if err != nil {
+		return nil, err
+	}
+	a.logger.Debug("Extracting firmware image from MFA2 archive",
+		zap.String("psid", dev.PSID),
+		zap.Int("component", comp.Index),
+		zap.Uint32("size", comp.Descriptor.Size))
+	return a.ComponentData(comp)
+}
+
+func (a *Archive) readRange(offset int64, size int) ([]byte, error) {
+	if offset+int64(size) > a.size {
+		return nil, pkgerrors.DataTooShortError(int(offset)+size, int(a.size), "MFA2 archive")
+	}
+	buf := make([]byte, size)
+	if _, err := a.src.ReadAt(buf, offset); err != nil {
+		return nil, merry.Wrap(err)
+	}
+	return buf, nil
+}
+
+// readMultiPart reads the archive up to the end of the multi-part TLV at offset,
+// whose extent is only known from its header
+func (a *Archive) readMultiPart(offset int) ([]byte, error) {
+	header, err := a.readRange(0, offset+TLVHeaderSize+MultiPartHeaderSize)
+	if err != nil {
+		return nil, err
+	}
+	var th TLVHeader
+	if err := th.Unmarshal(header[offset:]); err != nil {
+		return nil, merry.Prepend(err, "failed to unmarshal TLV header")
+	}
+	if th.Type != TLVTypeMultiPart || int(th.Len) < MultiPartHeaderSize {
+		return nil, merry.Wrap(pkgerrors.ErrInvalidData, merry.WithMessage("first TLV is not a multi-part package descriptor"))
+	}
+	var multi MultiPartHeader
+	if err := multi.Unmarshal(header[offset+TLVHeaderSize:]); err != nil {
+		return nil, merry.Prepend(err, "failed to unmarshal multi-part header")
+	}
+	return a.readRange(0, offset+alignTLV(TLVHeaderSize+int(th.Len))+int(multi.TotalLen))
+}
+
+func (a *Archive) parsePackageDescriptor(t *tlv) error {
+	if t.header.Type != TLVTypeMultiPart || len(t.children) == 0 {
+		return merry.Wrap(pkgerrors.ErrInvalidData, merry.WithMessage("first TLV is not a multi-part package descriptor"))
+	}
+	pd := t.children[0]
+	if pd.header.Type != TLVTypePackageDescriptor {
+		return merry.Wrap(pkgerrors.ErrInvalidData,
+			merry.WithMessagef("expected %s TLV, got %s (0x%02x)", GetTLVTypeName(TLVTypePackageDescriptor),
+				GetTLVTypeName(pd.header.Type), pd.header.Type))
+	}
+	if len(pd.data) < PackageDescriptorSize {
+		return pkgerrors.DataTooShortError(PackageDescriptorSize, len(pd.data), "package descriptor")
+	}
+	if err := a.Package.Unmarshal(pd.data); err != nil {
+		return merry.Prepend(err, "failed to unmarshal package descriptor")
+	}
+	for _, ext := range t.children[1:] {
+		a.logger.Debug("Skipping package descriptor extension", zap.Uint8("type", ext.header.Type))
+	}
+	return nil
+}
+
+func (a *Archive) parseDevice(t *tlv) (*Device, error) {
+	if t.header.Type != TLVTypeMultiPart {
+		return nil, merry.Wrap(pkgerrors.ErrInvalidData,
+			merry.WithMessagef("expected multi-part TLV, got %s (0x%02x)", GetTLVTypeName(t.header.Type), t.header.Type))
+	}
+
+	dev := &Device{}
+	for _, child := range t.children {
+		switch child.header.Type {
+		case TLVTypePSID:
+			dev.PSID = strings.TrimRight(string(child.data), "\x00 ")
+		case TLVTypeComponentPointer:
+			var ptr ComponentPointer
+			if len(child.data) < ComponentPointerSize {
+				return nil, pkgerrors.DataTooShortError(ComponentPointerSize, len(child.data), "component pointer")
+			}
+			if err := ptr.Unmarshal(child.data); err != nil {
+				return nil, merry.Prepend(err, "failed to unmarshal component pointer")
+			}
+			dev.Components = append(dev.Components, ptr)
+		default:
+			a.logger.Debug("Skipping device descriptor extension", zap.Uint8("type", child.header.Type))
+		}
+	}
+	if dev.PSID == "" {
+		return nil, merry.Wrap(pkgerrors.ErrInvalidData, merry.WithMessage("device descriptor has no PSID"))
+	}
+	return dev, nil
+}
+
+func (a *Archive) parseComponent(t *tlv) (*Component, error) {
+	if t.header.Type != TLVTypeMultiPart {
+		return nil, merry.Wrap(pkgerrors.ErrInvalidData,
+			merry.WithMessagef("expected multi-part TLV, got %s (0x%02x)", GetTLVTypeName(t.header.Type), t.header.Type))
+	}
+
+	for _, child := range t.children {
+		if child.header.Type != TLVTypeComponentDescriptor {
+			a.logger.Debug("Skipping component descriptor extension", zap.Uint8("type", child.header.Type))
+			continue
+		}
+		if len(child.data) < ComponentDescriptorSize {
+			return nil, pkgerrors.DataTooShortError(ComponentDescriptorSize, len(child.data), "component descriptor")
+		}
+		comp := &Component{}
+		if err := comp.Descriptor.Unmarshal(child.data); err != nil {
+			return nil, merry.Prepend(err, "failed to unmarshal component descriptor")
+		}
+		return comp, nil
+	}
+	return nil, merry.Wrap(pkgerrors.ErrInvalidData, merry.WithMessage("multi-part TLV has no component descriptor"))
+}
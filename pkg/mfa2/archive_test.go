@@ -0,0 +1,177 @@
+package mfa2
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ulikunitz/xz"
+	"go.uber.org/zap/zaptest"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+)
+
+type testImage struct {
+	psid string
+	data []byte
+}
+
+func marshal(t *testing.T, m interface{ Marshal() ([]byte, error) }) []byte {
+	t.Helper()
+	out, err := m.Marshal()
+	require.NoError(t, err)
+	return out
+}
+
+func encodeTLV(t *testing.T, tlvType uint8, payload []byte) []byte {
+	t.Helper()
+	hdr := marshal(t, &TLVHeader{Type: tlvType, Len: uint16(len(payload))})
+	out := append(hdr, payload...)
+	return append(out, make([]byte, alignTLV(len(out))-len(out))...)
+}
+
+func encodeMulti(t *testing.T, children ...[]byte) []byte {
+	t.Helper()
+	var body []byte
+	for _, c := range children {
+		body = append(body, c...)
+	}
+	multi := marshal(t, &MultiPartHeader{NumExtensions: uint16(len(children) - 1), TotalLen: uint16(len(body))})
+	return append(encodeTLV(t, TLVTypeMultiPart, multi), body...)
+}
+
+// buildArchive assembles an MFA2 archive with one FW component per image;
+// pdExtensions are TLVs appended to the package descriptor multi-part TLV
+func buildArchive(t *testing.T, images []testImage, pdExtensions ...[]byte) []byte {
+	t.Helper()
+
+	var block []byte
+	var components [][]byte
+	var devices [][]byte
+	for i, img := range images {
+		desc := &ComponentDescriptor{
+			Identifier:  ComponentIDFirmware,
+			CBOffsetLow: uint32(len(block)),
+			Size:        uint32(len(img.data)),
+		}
+		block = append(block, ComponentMagic...)
+		block = append(block, img.data...)
+		components = append(components, encodeMulti(t, encodeTLV(t, TLVTypeComponentDescriptor, marshal(t, desc))))
+
+		ptr := &ComponentPointer{ComponentIndex: uint16(i)}
+		devices = append(devices, encodeMulti(t,
+			encodeTLV(t, TLVTypePSID, []byte(img.psid)),
+			encodeTLV(t, TLVTypeComponentPointer, marshal(t, ptr))))
+	}
+
+	var xzBuf bytes.Buffer
+	w, err := xz.NewWriter(&xzBuf)
+	require.NoError(t, err)
+	_, err = w.Write(block)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	packageMulti := func(pd []byte) []byte {
+		return encodeMulti(t, append([][]byte{encodeTLV(t, TLVTypePackageDescriptor, pd)}, pdExtensions...)...)
+	}
+	pdSize := len(packageMulti(make([]byte, PackageDescriptorSize)))
+	cbOffset := len(Fingerprint) + pdSize
+	for _, d := range append(devices, components...) {
+		cbOffset += len(d)
+	}
+
+	pd := &PackageDescriptor{
+		NumComponents: uint16(len(components)),
+		NumDevices:    uint16(len(devices)),
+		CBOffset:      uint32(cbOffset),
+		CBArchiveSize: uint32(xzBuf.Len()),
+		CBSizeLow:     uint32(len(block)),
+	}
+
+	out := []byte(Fingerprint)
+	out = append(out, packageMulti(marshal(t, pd))...)
+	for _, d := range append(devices, components...) {
+		out = append(out, d...)
+	}
+	require.Equal(t, cbOffset, len(out))
+	return append(out, xzBuf.Bytes()...)
+}
+
+func TestParse(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	images := []testImage{
+		{psid: "MT_0000000222", data: bytes.Repeat([]byte{0xAA}, 1000)},
+		{psid: "MT_0000000008", data: bytes.Repeat([]byte{0x55, 0x66}, 777)},
+	}
+	data := buildArchive(t, images)
+
+	archive, err := Parse(bytes.NewReader(data), int64(len(data)), logger)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"MT_0000000222", "MT_0000000008"}, archive.PSIDs())
+	require.Len(t, archive.Components, 2)
+	assert.Equal(t, uint32(1000), archive.Components[0].Descriptor.Size)
+
+	for _, img := range images {
+		got, err := archive.ImageForPSID(img.psid)
+		require.NoError(t, err)
+		assert.Equal(t, img.data, got)
+	}
+
+	got, err := archive.ImageForPSID("mt_0000000008")
+	require.NoError(t, err)
+	assert.Equal(t, images[1].data, got)
+
+	_, err = archive.ImageForPSID("MT_DOES_NOT_EXIST")
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, pkgerrors.ErrPSIDNotFound))
+}
+
+func TestParse_PackageDescriptorExtensions(t *testing.T) {
+	images := []testImage{{psid: "MT_0000000222", data: []byte("firmware")}}
+	// An unknown extension TLV longer than the fixed descriptor prefix
+	data := buildArchive(t, images, encodeTLV(t, 0x7E, bytes.Repeat([]byte{0xEE}, 100)))
+
+	archive, err := Parse(bytes.NewReader(data), int64(len(data)), zaptest.NewLogger(t))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"MT_0000000222"}, archive.PSIDs())
+
+	got, err := archive.ImageForPSID("MT_0000000222")
+	require.NoError(t, err)
+	assert.Equal(t, images[0].data, got)
+}
+
+func TestParseErrors(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	t.Run("not an archive", func(t *testing.T) {
+		data := bytes.Repeat([]byte{0xFF}, 64)
+		_, err := Parse(bytes.NewReader(data), int64(len(data)), logger)
+		assert.True(t, errors.Is(err, pkgerrors.ErrInvalidMagic))
+	})
+
+	t.Run("legacy MFA", func(t *testing.T) {
+		data := append([]byte(MFA1Signature), make([]byte, 60)...)
+		_, err := Parse(bytes.NewReader(data), int64(len(data)), logger)
+		assert.True(t, errors.Is(err, pkgerrors.ErrNotSupported))
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		data := buildArchive(t, []testImage{{psid: "MT_1", data: []byte("firmware")}})
+		data = data[:len(data)-8]
+		_, err := Parse(bytes.NewReader(data), int64(len(data)), logger)
+		assert.True(t, errors.Is(err, pkgerrors.ErrInvalidOffset))
+	})
+
+	t.Run("bad component magic", func(t *testing.T) {
+		data := buildArchive(t, []testImage{{psid: "MT_1", data: []byte("firmware")}})
+		archive, err := Parse(bytes.NewReader(data), int64(len(data)), logger)
+		require.NoError(t, err)
+		archive.Components[0].Descriptor.CBOffsetLow = 1
+		archive.Components[0].Descriptor.Size = 7
+		_, err = archive.ImageForPSID("MT_1")
+		assert.True(t, errors.Is(err, pkgerrors.ErrInvalidMagic))
+	})
+}
@@ -0,0 +1,179 @@
+package mfa2
+
+import (
+	"github.com/Civil/mlx5fw-go/pkg/annotations"
+)
+
+// MFA2 on-disk layout.
+// Based on mlxfw_mfa2_format.h / mlxfw_mfa2.c from the Linux kernel (drivers/net/ethernet/mellanox/mlxfw)
+// and mstflint mlxfwupdate/mfa2.
+//
+//	fingerprint "MLNX.MFA2.XZ.00!"
+//	MULTI_PART { PACKAGE_DESCRIPTOR, ... }
+//	MULTI_PART { PSID, COMPONENT_PTR... }        x num_devices
+//	MULTI_PART { COMPONENT_DESCRIPTOR, ... }     x num_components
+//	xz stream (component block) at cb_offset
+//
+// Each component inside the decompressed component block starts with ComponentMagic
+// followed by the component payload (a raw firmware image for FW components).
+
+const (
+	// Fingerprint opens every MFA2 archive
+	Fingerprint = "MLNX.MFA2.XZ.00!"
+
+	// MFA1Signature opens legacy MFA (v1) archives
+	MFA1Signature = "MFAR"
+
+	// ComponentMagic precedes every component payload in the component block
+	ComponentMagic = "#BIN.COMPONENT!#"
+
+	// tlvAlignment is the alignment of every TLV (NLA_ALIGNTO)
+	tlvAlignment = 4
+)
+
+// TLV types (mlxfw_mfa2_tlv_type)
+const (
+	TLVTypeMultiPart           uint8 = 0x01
+	TLVTypePackageDescriptor   uint8 = 0x02
+	TLVTypeComponentDescriptor uint8 = 0x04
+	TLVTypeComponentPointer    uint8 = 0x22
+	TLVTypePSID                uint8 = 0x2A
+)
+
+// Component identifiers (MCC component index)
+const (
+	ComponentIDFirmware uint16 = 0x1 // Boot image, i.e. the full flash image
+)
+
+// Structure sizes in bytes
+const (
+	TLVHeaderSize           = 4
+	MultiPartHeaderSize     = 4
+	PackageDescriptorSize   = 28
+	ComponentDescriptorSize = 16
+	ComponentPointerSize    = 8
+)
+
+// GetTLVTypeName returns a human-readable name for a TLV type
+func GetTLVTypeName(tlvType uint8) string {
+	switch tlvType {
+	case TLVTypeMultiPart:
+		return "MULTI_PART"
+	case TLVTypePackageDescriptor:
+		return "PACKAGE_DESCRIPTOR"
+	case TLVTypeComponentDescriptor:
+		return "COMPONENT_DESCRIPTOR"
+	case TLVTypeComponentPointer:
+		return "COMPONENT_PTR"
+	case TLVTypePSID:
+		return "PSID"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// TLVHeader precedes every element of the archive (mlxfw_mfa2_tlv)
+type TLVHeader struct {
+	Version uint8  `offset:"byte:0" json:"version"`
+	Type    uint8  `offset:"byte:1" json:"type"`
+	Len     uint16 `offset:"byte:2,endian:be" json:"len"`
+}
+
+// MultiPartHeader is the payload of a MULTI_PART TLV (mlxfw_mfa2_tlv_multi).
+// The nested TLVs follow it; NumExtensions is the number of children minus one.
+type MultiPartHeader struct {
+	NumExtensions uint16 `offset:"byte:0,endian:be" json:"num_extensions"`
+	TotalLen      uint16 `offset:"byte:2,endian:be" json:"total_len"`
+}
+
+// PackageDescriptor describes the archive as a whole (mlxfw_mfa2_tlv_package_descriptor)
+type PackageDescriptor struct {
+	NumComponents  uint16 `offset:"byte:0,endian:be" json:"num_components"`
+	NumDevices     uint16 `offset:"byte:2,endian:be" json:"num_devices"`
+	CBOffset       uint32 `offset:"byte:4,endian:be" json:"cb_offset"`
+	CBArchiveSize  uint32 `offset:"byte:8,endian:be" json:"cb_archive_size"`
+	CBSizeHigh     uint32 `offset:"byte:12,endian:be" json:"cb_size_h"`
+	CBSizeLow      uint32 `offset:"byte:16,endian:be" json:"cb_size_l"`
+	Compression    uint8  `offset:"byte:23" json:"cv_compression"`
+	UserDataOffset uint32 `offset:"byte:24,endian:be" json:"user_data_offset"`
+}
+
+// CBSize returns the uncompressed size of the component block
+func (p *PackageDescriptor) CBSize() uint64 {
+	return uint64(p.CBSizeHigh)<<32 | uint64(p.CBSizeLow)
+}
+
+// ComponentDescriptor locates a component in the decompressed component block
+// (mlxfw_mfa2_tlv_component_descriptor)
+type ComponentDescriptor struct {
+	PLDMClassification uint16 `offset:"byte:0,endian:be" json:"pldm_classification"`
+	Identifier         uint16 `offset:"byte:2,endian:be" json:"identifier"`
+	CBOffsetHigh       uint32 `offset:"byte:4,endian:be" json:"cb_offset_h"`
+	CBOffsetLow        uint32 `offset:"byte:8,endian:be" json:"cb_offset_l"`
+	Size               uint32 `offset:"byte:12,endian:be" json:"size"`
+}
+
+// CBOffset returns the offset of the component (including its magic) in the component block
+func (c *ComponentDescriptor) CBOffset() uint64 {
+	return uint64(c.CBOffsetHigh)<<32 | uint64(c.CBOffsetLow)
+}
+
+// ComponentPointer links a device descriptor to a component descriptor
+// (mlxfw_mfa2_tlv_component_ptr)
+type ComponentPointer struct {
+	StorageID      uint16 `offset:"byte:0,endian:be" json:"storage_id"`
+	ComponentIndex uint16 `offset:"byte:2,endian:be" json:"component_index"`
+	StorageAddress uint32 `offset:"byte:4,endian:be" json:"storage_address"`
+}
+
+// Unmarshal methods using annotations
+
+// Unmarshal unmarshals binary data into TLVHeader
+func (h *TLVHeader) Unmarshal(data []byte) error {
+	return annotations.UnmarshalStruct(data, h)
+}
+
+// Marshal marshals TLVHeader into binary data
+func (h *TLVHeader) Marshal() ([]byte, error) {
+	return annotations.MarshalStruct(h)
+}
+
+// Unmarshal unmarshals binary data into MultiPartHeader
+func (h *MultiPartHeader) Unmarshal(data []byte) error {
+	return annotations.UnmarshalStruct(data, h)
+}
+
+// Marshal marshals MultiPartHeader into binary data
+func (h *MultiPartHeader) Marshal() ([]byte, error) {
+	return annotations.MarshalStruct(h)
+}
+
+// Unmarshal unmarshals binary data into PackageDescriptor
+func (p *PackageDescriptor) Unmarshal(data []byte) error {
+	return annotations.UnmarshalStruct(data, p)
+}
+
+// Marshal marshals PackageDescriptor into binary data
+func (p *PackageDescriptor) Marshal() ([]byte, error) {
+	return annotations.MarshalStruct(p)
+}
+
+// Unmarshal unmarshals binary data into ComponentDescriptor
+func (c *ComponentDescriptor) Unmarshal(data []byte) error {
+	return annotations.UnmarshalStruct(data, c)
+}
+
+// Marshal marshals ComponentDescriptor into binary data
+func (c *ComponentDescriptor) Marshal() ([]byte, error) {
+	return annotations.MarshalStruct(c)
+}
+
+// Unmarshal unmarshals binary data into ComponentPointer
+func (c *ComponentPointer) Unmarshal(data []byte) error {
+	return annotations.UnmarshalStruct(data, c)
+}
+
+// Marshal marshals ComponentPointer into binary data
+func (c *ComponentPointer) Marshal() ([]byte, error) {
+	return annotations.MarshalStruct(c)
+}
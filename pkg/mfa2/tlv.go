@@ -0,0 +1,76 @@
+package mfa2
+
+import (
+	"github.com/ansel1/merry/v2"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+)
+
+// tlv is a decoded TLV element; multi-part TLVs carry their nested children
+type tlv struct {
+	header   TLVHeader
+	offset   int
+	data     []byte // payload, header.Len bytes
+	children []*tlv
+	next     int // offset of the following sibling
+}
+
+func alignTLV(n int) int {
+	return (n + tlvAlignment - 1) &^ (tlvAlignment - 1)
+}
+
+// readTLV decodes the TLV at offset in buf.
+// Mirrors mlxfw_mfa2_tlv_next: a plain TLV spans align(4+len); a multi-part TLV
+// additionally spans align(total_len) of nested children.
+func readTLV(buf []byte, offset int) (*tlv, error) {
+	if offset < 0 || offset+TLVHeaderSize > len(buf) {
+		return nil, merry.Wrap(pkgerrors.ErrInvalidOffset,
+			merry.WithMessagef("TLV header at 0x%x exceeds descriptor area of 0x%x bytes", offset, len(buf)))
+	}
+
+	t := &tlv{offset: offset}
+	if err := t.header.Unmarshal(buf[offset : offset+TLVHeaderSize]); err != nil {
+		return nil, merry.Prepend(err, "failed to unmarshal TLV header")
+	}
+
+	dataStart := offset + TLVHeaderSize
+	dataEnd := dataStart + int(t.header.Len)
+	if dataEnd > len(buf) {
+		return nil, merry.Wrap(pkgerrors.ErrInvalidSize,
+			merry.WithMessagef("%s TLV at 0x%x with length 0x%x exceeds descriptor area", GetTLVTypeName(t.header.Type), offset, t.header.Len))
+	}
+	t.data = buf[dataStart:dataEnd]
+	t.next = offset + alignTLV(TLVHeaderSize+int(t.header.Len))
+
+	if t.header.Type != TLVTypeMultiPart {
+		return t, nil
+	}
+
+	var multi MultiPartHeader
+	if len(t.data) < MultiPartHeaderSize {
+		return nil, pkgerrors.DataTooShortError(MultiPartHeaderSize, len(t.data), "multi-part TLV")
+	}
+	if err := multi.Unmarshal(t.data); err != nil {
+		return nil, merry.Prepend(err, "failed to unmarshal multi-part header")
+	}
+
+	childrenStart := t.next
+	childrenEnd := childrenStart + int(multi.TotalLen)
+	if childrenEnd > len(buf) {
+		return nil, merry.Wrap(pkgerrors.ErrInvalidSize,
+			merry.WithMessagef("multi-part TLV at 0x%x with total length 0x%x exceeds descriptor area", offset, multi.TotalLen))
+	}
+
+	childOffset := childrenStart
+	for i := 0; i <= int(multi.NumExtensions); i++ {
+		child, err := readTLV(buf[:childrenEnd], childOffset)
+		if err != nil {
+			return nil, merry.Prependf(err, "multi-part TLV at 0x%x, child %d", offset, i)
+		}
+		t.children = append(t.children, child)
+		childOffset = child.next
+	}
+	t.next = alignTLV(childrenEnd)
+
+	return t, nil
+}
@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"bufio"
+	"bytes"
 	"crypto/sha256"
 	"encoding/binary"
 	"fmt"
@@ -10,77 +12,327 @@ import (
 	"github.com/ansel1/merry/v2"
 	"go.uber.org/zap"
 
+	"github.com/Civil/mlx5fw-go/pkg/compressutil"
 	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+	"github.com/Civil/mlx5fw-go/pkg/imgfmt"
 	"github.com/Civil/mlx5fw-go/pkg/types"
 )
 
-// FirmwareReader provides low-level firmware file reading operations
+// StdinPath is the firmware path that selects standard input instead of a file
+const StdinPath = "-"
+
+// NoMmapEnv disables memory-mapped reading of firmware files when set to a non-empty value
+const NoMmapEnv = "MLX5FW_NO_MMAP"
+
+// ImageSource is a random-access view of a firmware image with a known size.
+// *bytes.Reader and *io.SectionReader satisfy it directly.
+type ImageSource interface {
+	io.ReaderAt
+	Size() int64
+}
+
+// FirmwareReader provides low-level firmware image reading operations.
+// Files and streams compressed with gzip, xz, zstd or bzip2 are detected by
+// their magic bytes and decompressed into memory; Intel HEX and Motorola
+// S-record images are converted to a flat image the same way. Offsets and
+// Size always refer to the raw flash image.
+//
+// On Linux raw image files are memory-mapped. Mapped and converted images are
+// held by the reader as a single buffer, and ReadSection returns zero-copy
+// slices of it; other sources fall back to ReadAt into fresh buffers.
 type FirmwareReader struct {
-	file     *os.File
-	filePath string
-	size     int64
-	logger   *zap.Logger
+	src         io.ReaderAt
+	closer      io.Closer
+	data        []byte       // whole image when mapped or owned in memory, nil otherwise
+	unmap       func() error // releases the mapping of data, nil when not mapped
+	fileInfo    *FileInfo    // cached result of GetFileInfo
+	name        string
+	size        int64
+	compression compressutil.Format
+	imageFormat imgfmt.Format
+	logger      *zap.Logger
 }
 
-// NewFirmwareReader creates a new firmware reader
+// NewFirmwareReader creates a new firmware reader for a file path.
+// The special path "-" reads the whole image from standard input.
 func NewFirmwareReader(filePath string, logger *zap.Logger) (*FirmwareReader, error) {
+	if filePath == StdinPath {
+		reader, err := NewFirmwareReaderFromStream(os.Stdin, logger)
+		if err != nil {
+			return nil, err
+		}
+		reader.name = "<stdin>"
+		return reader, nil
+	}
+
 	file, err := os.Open(filePath)
 	if err != nil {
 		return nil, merry.Wrap(err)
 	}
 
-	stat, err := file.Stat()
+	reader, err := NewFirmwareReaderFromFile(file, logger)
 	if err != nil {
 		file.Close()
+		return nil, err
+	}
+	reader.closer = file
+	return reader, nil
+}
+
+// NewFirmwareReaderFromFile creates a firmware reader on top of an already open file.
+// The caller keeps ownership of the file; Close on the reader releases the memory
+// mapping, if any, but does not close the file.
+func NewFirmwareReaderFromFile(file *os.File, logger *zap.Logger) (*FirmwareReader, error) {
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+
+	header := make([]byte, headerPeekSize)
+	n, err := file.ReadAt(header, 0)
+	if err != nil && err != io.EOF {
 		return nil, merry.Wrap(err)
 	}
 
+	format := compressutil.DetectFormat(header[:n])
+	if format != compressutil.FormatNone || imgfmt.Detect(header[:n]) != imgfmt.FormatBinary {
+		reader, err := newDecompressedReader(format, io.NewSectionReader(file, 0, stat.Size()), logger)
+		if err != nil {
+			return nil, merry.Prepend(err, file.Name())
+		}
+		reader.name = file.Name()
+		return reader, nil
+	}
+
+	reader := &FirmwareReader{
+		src:         file,
+		name:        file.Name(),
+		size:        stat.Size(),
+		imageFormat: imgfmt.FormatBinary,
+		logger:      logger,
+	}
+	reader.tryMap(file)
+	return reader, nil
+}
+
+// tryMap switches the reader to a memory mapping of file, keeping the read path
+// when mapping is disabled, unsupported or fails (pipes, special files, etc.)
+func (r *FirmwareReader) tryMap(file *os.File) {
+	if os.Getenv(NoMmapEnv) != "" || r.size == 0 {
+		return
+	}
+	data, unmap, err := mapFile(file, r.size)
+	if err != nil {
+		r.logger.Debug("Memory mapping unavailable, using read path",
+			zap.String("file", r.name),
+			zap.Error(err))
+		return
+	}
+	r.data = data
+	r.unmap = unmap
+	r.src = bytes.NewReader(data)
+}
+
+// NewFirmwareReaderFromBytes creates a firmware reader over an in-memory image.
+// The data is used as is; pass compressed buffers through NewFirmwareReaderFromStream.
+func NewFirmwareReaderFromBytes(data []byte, logger *zap.Logger) *FirmwareReader {
 	return &FirmwareReader{
-		file:     file,
-		filePath: filePath,
-		size:     stat.Size(),
-		logger:   logger,
-	}, nil
+		src:         bytes.NewReader(data),
+		name:        "<memory>",
+		size:        int64(len(data)),
+		imageFormat: imgfmt.FormatBinary,
+		logger:      logger,
+	}
+}
+
+// NewFirmwareReaderFromSource creates a firmware reader over any random-access source
+func NewFirmwareReaderFromSource(src ImageSource, logger *zap.Logger) *FirmwareReader {
+	return &FirmwareReader{
+		src:         src,
+		name:        "<source>",
+		size:        src.Size(),
+		imageFormat: imgfmt.FormatBinary,
+		logger:      logger,
+	}
+}
+
+// NewFirmwareReaderFromStream buffers a sequential stream (pipe, HTTP body, etc.)
+// into memory and creates a firmware reader over it. Compressed streams are
+// decompressed on the fly and text images (Intel HEX, S-record) are converted.
+func NewFirmwareReaderFromStream(r io.Reader, logger *zap.Logger) (*FirmwareReader, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(headerPeekSize)
+	if err != nil && err != io.EOF {
+		return nil, merry.Wrap(err)
+	}
+
+	reader, err := newDecompressedReader(compressutil.DetectFormat(header), br, logger)
+	if err != nil {
+		return nil, err
+	}
+	reader.name = "<stream>"
+	return reader, nil
+}
+
+// headerPeekSize covers the signatures of both compression containers and text images
+const headerPeekSize = max(compressutil.MagicPeekSize, imgfmt.PeekSize)
+
+// newDecompressedReader buffers r into memory, decompressing it according to format
+// and converting Intel HEX / S-record contents to a flat image.
+// The resulting image must not exceed types.MaxFirmwareSize.
+func newDecompressedReader(format compressutil.Format, r io.Reader, logger *zap.Logger) (*FirmwareReader, error) {
+	dr, err := compressutil.NewReader(format, r)
+	if err != nil {
+		return nil, err
+	}
+	defer dr.Close()
+
+	br := bufio.NewReader(dr)
+	header, err := br.Peek(headerPeekSize)
+	if err != nil && err != io.EOF {
+		return nil, decompressError(format, merry.Wrap(err))
+	}
+
+	imageFormat := imgfmt.Detect(header)
+	var data []byte
+	if imageFormat == imgfmt.FormatBinary {
+		data, err = compressutil.ReadAllLimited(br, types.MaxFirmwareSize)
+	} else {
+		data, err = imgfmt.Decode(imageFormat, br, types.MaxFirmwareSize)
+		if err != nil {
+			err = merry.Prependf(err, "failed to decode %s firmware", imageFormat)
+		}
+	}
+	if err != nil {
+		return nil, decompressError(format, err)
+	}
+
+	if format != compressutil.FormatNone || imageFormat != imgfmt.FormatBinary {
+		logger.Debug("Converted firmware image",
+			zap.String("compression", string(format)),
+			zap.String("imageFormat", string(imageFormat)),
+			zap.Int("size", len(data)))
+	}
+
+	reader := NewFirmwareReaderFromBytes(data, logger)
+	// The buffer is private to the reader, so sections may alias it
+	reader.data = data
+	reader.compression = format
+	reader.imageFormat = imageFormat
+	return reader, nil
+}
+
+func decompressError(format compressutil.Format, err error) error {
+	if format != compressutil.FormatNone {
+		return merry.Prependf(err, "failed to decompress %s firmware", format)
+	}
+	return err
 }
 
-// Close closes the firmware file
+// Close releases the memory mapping and the underlying file if the reader owns them.
+// Slices returned by ReadSection and Bytes must not be used after Close.
 func (r *FirmwareReader) Close() error {
-	if r.file != nil {
-		err := r.file.Close()
-		r.file = nil
-		return err
+	var err error
+	if r.unmap != nil {
+		err = r.unmap()
+		r.unmap = nil
+		r.data = nil
+		r.src = errReaderAt{}
+	}
+	if r.closer != nil {
+		if cerr := r.closer.Close(); err == nil {
+			err = cerr
+		}
+		r.closer = nil
 	}
-	return nil
+	return err
+}
+
+// errReaderAt fails every read; it replaces the source of a reader whose mapping was released
+type errReaderAt struct{}
+
+func (errReaderAt) ReadAt([]byte, int64) (int, error) {
+	return 0, os.ErrClosed
+}
+
+// IsMapped reports whether the image is read through a memory mapping
+func (r *FirmwareReader) IsMapped() bool {
+	return r.unmap != nil
+}
+
+// Name returns a human-readable name of the image source (file path or placeholder)
+func (r *FirmwareReader) Name() string {
+	return r.name
+}
+
+// SetName overrides the name reported for the image source
+func (r *FirmwareReader) SetName(name string) {
+	r.name = name
+}
+
+// Compression returns the container format the image was decompressed from,
+// or compressutil.FormatNone for raw images
+func (r *FirmwareReader) Compression() compressutil.Format {
+	return r.compression
+}
+
+// ImageFormat returns the encoding the image was loaded from: imgfmt.FormatBinary
+// for raw images, or the text format (Intel HEX, S-record) it was converted from
+func (r *FirmwareReader) ImageFormat() imgfmt.Format {
+	return r.imageFormat
 }
 
-// Size returns the size of the firmware file
+// Size returns the size of the firmware image
 func (r *FirmwareReader) Size() int64 {
 	return r.size
 }
 
 // ReadAt implements io.ReaderAt interface
 func (r *FirmwareReader) ReadAt(p []byte, off int64) (n int, err error) {
-	return r.file.ReadAt(p, off)
+	return r.src.ReadAt(p, off)
+}
+
+// Bytes returns the complete firmware image, without copying when the reader holds
+// it in memory (mapped or decompressed). The result must be treated as read-only;
+// use ReadAll for a copy that can be modified.
+func (r *FirmwareReader) Bytes() ([]byte, error) {
+	if r.data != nil {
+		return r.data, nil
+	}
+	return r.ReadAll()
+}
+
+// ReadAll returns a copy of the complete firmware image
+func (r *FirmwareReader) ReadAll() ([]byte, error) {
+	data := make([]byte, r.size)
+	if _, err := r.ReadAt(data, 0); err != nil && err != io.EOF {
+		return nil, merry.Wrap(err)
+	}
+	return data, nil
+}
+
+// ReadBootVersion reads the boot version dword that follows the magic pattern
+// found at magicOffset
+func (r *FirmwareReader) ReadBootVersion(magicOffset uint32) (*types.FirmwareBootVersion, error) {
+	data, err := r.ReadSection(int64(magicOffset+types.BootVersionOffset), 4)
+	if err != nil {
+		return nil, merry.Prepend(err, "failed to read boot version")
+	}
+	bootVersion := &types.FirmwareBootVersion{}
+	if err := bootVersion.Unmarshal(data); err != nil {
+		return nil, merry.Prepend(err, "failed to parse boot version")
+	}
+	return bootVersion, nil
 }
 
 // FindMagicPattern searches for the firmware magic pattern at standard offsets
 func (r *FirmwareReader) FindMagicPattern() (uint32, error) {
-	buf := make([]byte, 8)
 	for _, offset := range types.MagicSearchOffsets {
-		if int64(offset) >= r.size {
-			continue
-		}
-
-		_, err := r.ReadAt(buf, int64(offset))
+		found, err := r.hasMagicAt(offset)
 		if err != nil {
-			if err == io.EOF {
-				continue
-			}
 			return 0, err
 		}
-
-		magic := binary.BigEndian.Uint64(buf)
-		if magic == types.MagicPattern {
+		if found {
 			r.logger.Debug("Found magic pattern", zap.Uint32("offset", offset))
 			return offset, nil
 		}
@@ -88,7 +340,44 @@ func (r *FirmwareReader) FindMagicPattern() (uint32, error) {
 	return 0, pkgerrors.ErrInvalidMagic
 }
 
-// ReadSection reads a section of data from the firmware
+// FindAllMagicPatterns returns every standard offset that holds the magic pattern.
+// A full flash dump has one hit per failsafe image copy (primary first).
+func (r *FirmwareReader) FindAllMagicPatterns() ([]uint32, error) {
+	var offsets []uint32
+	for _, offset := range types.MagicSearchOffsets {
+		found, err := r.hasMagicAt(offset)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			offsets = append(offsets, offset)
+		}
+	}
+	if len(offsets) == 0 {
+		return nil, pkgerrors.ErrInvalidMagic
+	}
+	r.logger.Debug("Found magic patterns", zap.Uint32s("offsets", offsets))
+	return offsets, nil
+}
+
+func (r *FirmwareReader) hasMagicAt(offset uint32) (bool, error) {
+	if int64(offset) >= r.size {
+		return false, nil
+	}
+
+	buf := make([]byte, 8)
+	_, err := r.ReadAt(buf, int64(offset))
+	if err != nil {
+		if err == io.EOF {
+			return false, nil
+		}
+		return false, err
+	}
+	return binary.BigEndian.Uint64(buf) == types.MagicPattern, nil
+}
+
+// ReadSection reads a section of data from the firmware. When the image is held in
+// memory the result is a zero-copy slice of it; callers must not modify it.
 func (r *FirmwareReader) ReadSection(offset int64, size uint32) ([]byte, error) {
 	if offset < 0 || offset >= r.size {
 		return nil, merry.Wrap(pkgerrors.ErrInvalidOffset, merry.WithMessagef("offset %d is out of range [0, %d)", offset, r.size))
@@ -98,6 +387,12 @@ func (r *FirmwareReader) ReadSection(offset int64, size uint32) ([]byte, error)
 		return nil, merry.Wrap(pkgerrors.ErrInvalidSize, merry.WithMessagef("section at offset %d with size %d extends beyond file size %d", offset, size, r.size))
 	}
 
+	if r.data != nil {
+		// Cap the capacity so appending to the result never writes into the image
+		end := offset + int64(size)
+		return r.data[offset:end:end], nil
+	}
+
 	buf := make([]byte, size)
 	_, err := r.ReadAt(buf, offset)
 	if err != nil {
@@ -112,21 +407,27 @@ type FileInfo struct {
 	SHA256 string
 }
 
-// GetFileInfo returns information about the firmware file
+// GetFileInfo returns information about the firmware image.
+// The image is immutable, so the hash is computed once and cached.
 func (r *FirmwareReader) GetFileInfo() (*FileInfo, error) {
-	// Calculate SHA256 hash
-	hasher := sha256.New()
-	_, err := r.file.Seek(0, 0)
-	if err != nil {
-		return nil, merry.Wrap(err)
-	}
-
-	if _, err := io.Copy(hasher, r.file); err != nil {
-		return nil, merry.Wrap(err)
+	if r.fileInfo == nil {
+		var sum []byte
+		if r.data != nil {
+			digest := sha256.Sum256(r.data)
+			sum = digest[:]
+		} else {
+			hasher := sha256.New()
+			if _, err := io.Copy(hasher, io.NewSectionReader(r.src, 0, r.size)); err != nil {
+				return nil, merry.Wrap(err)
+			}
+			sum = hasher.Sum(nil)
+		}
+		r.fileInfo = &FileInfo{
+			Size:   r.size,
+			SHA256: fmt.Sprintf("%x", sum),
+		}
 	}
 
-	return &FileInfo{
-		Size:   r.size,
-		SHA256: fmt.Sprintf("%x", hasher.Sum(nil)),
-	}, nil
+	info := *r.fileInfo
+	return &info, nil
 }
@@ -0,0 +1,125 @@
+package parser
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+// benchImageSize matches the flash size of current adapters
+const benchImageSize = 64 * 1024 * 1024
+
+// writeSyntheticImage writes a flash dump with two failsafe copies and
+// non-uniform section-like content so nothing is trivially compressible
+func writeSyntheticImage(b *testing.B, size int) string {
+	b.Helper()
+
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = 0xFF
+	}
+	for off := 0x1000; off+0x10000 <= size; off += 0x40000 {
+		for i := 0; i < 0x10000; i++ {
+			data[off+i] = byte((off + i) * 31)
+		}
+	}
+	binary.BigEndian.PutUint64(data[0:], types.MagicPattern)
+	binary.BigEndian.PutUint64(data[size/2:], types.MagicPattern)
+
+	path := filepath.Join(b.TempDir(), "synthetic.bin")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		b.Fatal(err)
+	}
+	return path
+}
+
+// benchModes runs fn once with memory mapping and once with the read fallback
+func benchModes(b *testing.B, fn func(b *testing.B, path string)) {
+	path := writeSyntheticImage(b, benchImageSize)
+
+	b.Run("mmap", func(b *testing.B) {
+		fn(b, path)
+	})
+	b.Run("read", func(b *testing.B) {
+		b.Setenv(NoMmapEnv, "1")
+		fn(b, path)
+	})
+}
+
+func BenchmarkFirmwareReader_Open(b *testing.B) {
+	benchModes(b, func(b *testing.B, path string) {
+		logger := zap.NewNop()
+		for i := 0; i < b.N; i++ {
+			reader, err := NewFirmwareReader(path, logger)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := reader.FindAllMagicPatterns(); err != nil {
+				b.Fatal(err)
+			}
+			reader.Close()
+		}
+	})
+}
+
+func BenchmarkFirmwareReader_ReadSections(b *testing.B) {
+	benchModes(b, func(b *testing.B, path string) {
+		reader, err := NewFirmwareReader(path, zap.NewNop())
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer reader.Close()
+
+		// Walk the image in 64KB sections the way the parser loads section data
+		const sectionSize = 0x10000
+		b.SetBytes(sectionSize)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			offset := int64(i*sectionSize) % (benchImageSize - sectionSize)
+			if _, err := reader.ReadSection(offset, sectionSize); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkFirmwareReader_GetFileInfo(b *testing.B) {
+	benchModes(b, func(b *testing.B, path string) {
+		logger := zap.NewNop()
+		b.SetBytes(benchImageSize)
+		for i := 0; i < b.N; i++ {
+			// A fresh reader per iteration, as in batch jobs over many dumps
+			reader, err := NewFirmwareReader(path, logger)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := reader.GetFileInfo(); err != nil {
+				b.Fatal(err)
+			}
+			reader.Close()
+		}
+	})
+}
+
+func BenchmarkFirmwareReader_Bytes(b *testing.B) {
+	benchModes(b, func(b *testing.B, path string) {
+		reader, err := NewFirmwareReader(path, zap.NewNop())
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer reader.Close()
+
+		b.SetBytes(benchImageSize)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := reader.Bytes(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
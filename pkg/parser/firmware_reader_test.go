@@ -2,15 +2,21 @@ package parser
 
 import (
 	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
 	"encoding/binary"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zaptest"
 
+	"github.com/Civil/mlx5fw-go/pkg/compressutil"
+	"github.com/Civil/mlx5fw-go/pkg/imgfmt"
 	"github.com/Civil/mlx5fw-go/pkg/types"
 )
 
@@ -209,6 +215,66 @@ func TestFirmwareReader_FindMagicPattern(t *testing.T) {
 	}
 }
 
+func TestFirmwareReader_FindAllMagicPatterns(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	buf := make([]byte, 0x110000)
+	binary.BigEndian.PutUint64(buf[0:], types.MagicPattern)
+	binary.BigEndian.PutUint64(buf[0x100000:], types.MagicPattern)
+
+	offsets, err := NewFirmwareReaderFromBytes(buf, logger).FindAllMagicPatterns()
+	if err != nil {
+		t.Fatalf("FindAllMagicPatterns() error = %v", err)
+	}
+	if len(offsets) != 2 || offsets[0] != 0 || offsets[1] != 0x100000 {
+		t.Errorf("FindAllMagicPatterns() = %#x, want [0x0 0x100000]", offsets)
+	}
+
+	if _, err := NewFirmwareReaderFromBytes(make([]byte, 0x2000), logger).FindAllMagicPatterns(); err == nil {
+		t.Error("FindAllMagicPatterns() expected error but got none")
+	}
+}
+
+func TestFirmwareReader_ImageView(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	// Two 0x100 byte image copies followed by shared device data
+	content := make([]byte, 0x300)
+	for i := range content {
+		content[i] = byte(i / 0x100)
+	}
+	reader := NewFirmwareReaderFromBytes(content, logger)
+	view := reader.ImageView(0, 0x100)
+
+	if view.Size() != reader.Size() {
+		t.Errorf("Size() = %#x, want %#x", view.Size(), reader.Size())
+	}
+
+	tests := []struct {
+		name   string
+		offset uint32
+		size   uint32
+		want   []byte
+	}{
+		{"image area is shifted", 0x10, 4, []byte{1, 1, 1, 1}},
+		{"device data passes through", 0x210, 4, []byte{2, 2, 2, 2}},
+		{"read crossing the window", 0xfe, 4, []byte{1, 1, 1, 1}},
+		{"read crossing into device data", 0x1fe, 4, []byte{1, 1, 2, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := view.ReadSection(int64(tt.offset), tt.size)
+			if err != nil {
+				t.Fatalf("ReadSection() error = %v", err)
+			}
+			if !bytes.Equal(data, tt.want) {
+				t.Errorf("ReadSection(%#x) = %v, want %v", tt.offset, data, tt.want)
+			}
+		})
+	}
+}
+
 func TestFirmwareReader_ReadSection(t *testing.T) {
 	logger := zaptest.NewLogger(t)
 
@@ -314,6 +380,149 @@ func TestFirmwareReader_Close(t *testing.T) {
 		t.Errorf("Second Close() error = %v", err)
 	}
 }
+func TestFirmwareReader_InMemorySources(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	content := []byte("0123456789ABCDEF")
+
+	filename := createTestFile(t, content)
+	defer os.Remove(filename)
+	fileReader, err := NewFirmwareReader(filename, logger)
+	if err != nil {
+		t.Fatalf("NewFirmwareReader() error = %v", err)
+	}
+	defer fileReader.Close()
+	want, err := fileReader.GetFileInfo()
+	if err != nil {
+		t.Fatalf("GetFileInfo() error = %v", err)
+	}
+
+	streamReader, err := NewFirmwareReaderFromStream(bytes.NewBuffer(content), logger)
+	if err != nil {
+		t.Fatalf("NewFirmwareReaderFromStream() error = %v", err)
+	}
+
+	readers := map[string]*FirmwareReader{
+		"bytes":  NewFirmwareReaderFromBytes(content, logger),
+		"source": NewFirmwareReaderFromSource(io.NewSectionReader(bytes.NewReader(content), 0, int64(len(content))), logger),
+		"stream": streamReader,
+	}
+
+	for name, reader := range readers {
+		t.Run(name, func(t *testing.T) {
+			if reader.Size() != int64(len(content)) {
+				t.Errorf("Size() = %v, want %v", reader.Size(), len(content))
+			}
+
+			data, err := reader.ReadSection(4, 4)
+			if err != nil {
+				t.Fatalf("ReadSection() error = %v", err)
+			}
+			if !bytes.Equal(data, content[4:8]) {
+				t.Errorf("ReadSection() = %q, want %q", data, content[4:8])
+			}
+
+			all, err := reader.ReadAll()
+			if err != nil {
+				t.Fatalf("ReadAll() error = %v", err)
+			}
+			if !bytes.Equal(all, content) {
+				t.Errorf("ReadAll() = %q, want %q", all, content)
+			}
+
+			info, err := reader.GetFileInfo()
+			if err != nil {
+				t.Fatalf("GetFileInfo() error = %v", err)
+			}
+			if *info != *want {
+				t.Errorf("GetFileInfo() = %+v, want %+v", info, want)
+			}
+
+			if err := reader.Close(); err != nil {
+				t.Errorf("Close() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestNewFirmwareReaderFromFile_CallerOwnsFile(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	filename := createTestFile(t, []byte("test"))
+	defer os.Remove(filename)
+
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	reader, err := NewFirmwareReaderFromFile(file, logger)
+	if err != nil {
+		t.Fatalf("NewFirmwareReaderFromFile() error = %v", err)
+	}
+	if err := reader.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// The file must still be usable after the reader is closed
+	buf := make([]byte, 4)
+	if _, err := file.ReadAt(buf, 0); err != nil {
+		t.Errorf("file closed by reader: %v", err)
+	}
+}
+
+func TestFirmwareReader_Compressed(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	content := bytes.Repeat([]byte("0123456789ABCDEF"), 256)
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	filename := createTestFile(t, buf.Bytes())
+	defer os.Remove(filename)
+
+	fileReader, err := NewFirmwareReader(filename, logger)
+	if err != nil {
+		t.Fatalf("NewFirmwareReader() error = %v", err)
+	}
+	defer fileReader.Close()
+
+	streamReader, err := NewFirmwareReaderFromStream(bytes.NewReader(buf.Bytes()), logger)
+	if err != nil {
+		t.Fatalf("NewFirmwareReaderFromStream() error = %v", err)
+	}
+
+	for name, reader := range map[string]*FirmwareReader{"file": fileReader, "stream": streamReader} {
+		t.Run(name, func(t *testing.T) {
+			if reader.Compression() != compressutil.FormatGzip {
+				t.Errorf("Compression() = %q, want %q", reader.Compression(), compressutil.FormatGzip)
+			}
+			if reader.Size() != int64(len(content)) {
+				t.Errorf("Size() = %v, want %v", reader.Size(), len(content))
+			}
+			all, err := reader.ReadAll()
+			if err != nil {
+				t.Fatalf("ReadAll() error = %v", err)
+			}
+			if !bytes.Equal(all, content) {
+				t.Error("ReadAll() does not match the uncompressed content")
+			}
+		})
+	}
+
+	t.Run("Raw image", func(t *testing.T) {
+		reader := NewFirmwareReaderFromBytes(content, logger)
+		if reader.Compression() != compressutil.FormatNone {
+			t.Errorf("Compression() = %q, want none", reader.Compression())
+		}
+	})
+}
+
 func BenchmarkFirmwareReader_ReadSection(b *testing.B) {
 	logger := zap.NewNop()
 
@@ -351,6 +560,109 @@ func BenchmarkFirmwareReader_ReadSection(b *testing.B) {
 	}
 }
 
+func TestFirmwareReader_TextImages(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	content := make([]byte, 0x100)
+	binary.BigEndian.PutUint64(content[0:], types.MagicPattern)
+
+	for _, format := range []imgfmt.Format{imgfmt.FormatIHex, imgfmt.FormatSRec} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := imgfmt.Encode(format, &buf, content); err != nil {
+				t.Fatal(err)
+			}
+
+			// A gzip-compressed text image exercises both conversions at once
+			var gz bytes.Buffer
+			zw := gzip.NewWriter(&gz)
+			zw.Write(buf.Bytes())
+			zw.Close()
+
+			for name, data := range map[string][]byte{"plain": buf.Bytes(), "gzip": gz.Bytes()} {
+				filename := createTestFile(t, data)
+				defer os.Remove(filename)
+
+				reader, err := NewFirmwareReader(filename, logger)
+				if err != nil {
+					t.Fatalf("%s: NewFirmwareReader() error = %v", name, err)
+				}
+				defer reader.Close()
+
+				if reader.ImageFormat() != format {
+					t.Errorf("%s: ImageFormat() = %q, want %q", name, reader.ImageFormat(), format)
+				}
+				all, err := reader.ReadAll()
+				if err != nil {
+					t.Fatalf("%s: ReadAll() error = %v", name, err)
+				}
+				if !bytes.Equal(all, content) {
+					t.Errorf("%s: ReadAll() does not match the binary content", name)
+				}
+			}
+		})
+	}
+}
+
+func TestFirmwareReader_Mapped(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("memory mapping is only used on Linux")
+	}
+	logger := zaptest.NewLogger(t)
+	content := bytes.Repeat([]byte("0123456789ABCDEF"), 256)
+
+	filename := createTestFile(t, content)
+	defer os.Remove(filename)
+
+	reader, err := NewFirmwareReader(filename, logger)
+	if err != nil {
+		t.Fatalf("NewFirmwareReader() error = %v", err)
+	}
+	if !reader.IsMapped() {
+		t.Fatal("IsMapped() = false, want true")
+	}
+
+	section, err := reader.ReadSection(16, 4)
+	if err != nil {
+		t.Fatalf("ReadSection() error = %v", err)
+	}
+	if !bytes.Equal(section, content[16:20]) {
+		t.Errorf("ReadSection() = %q, want %q", section, content[16:20])
+	}
+
+	// Appending to a zero-copy section must not overwrite the following bytes
+	_ = append(section, 'X')
+	if next, _ := reader.ReadSection(20, 1); next[0] != content[20] {
+		t.Errorf("append to ReadSection() result modified the image")
+	}
+
+	info, err := reader.GetFileInfo()
+	if err != nil {
+		t.Fatalf("GetFileInfo() error = %v", err)
+	}
+	if want := fmt.Sprintf("%x", sha256.Sum256(content)); info.SHA256 != want {
+		t.Errorf("GetFileInfo().SHA256 = %s, want %s", info.SHA256, want)
+	}
+
+	if err := reader.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, err := reader.ReadSection(0, 4); err == nil {
+		t.Error("ReadSection() after Close() expected error but got none")
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		t.Setenv(NoMmapEnv, "1")
+		reader, err := NewFirmwareReader(filename, logger)
+		if err != nil {
+			t.Fatalf("NewFirmwareReader() error = %v", err)
+		}
+		defer reader.Close()
+		if reader.IsMapped() {
+			t.Error("IsMapped() = true with mapping disabled")
+		}
+	})
+}
+
 func TestFirmwareReader_Integration(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping integration test in short mode")
@@ -0,0 +1,29 @@
+package fs3
+
+import (
+	"go.uber.org/zap"
+
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/parser"
+)
+
+// FS3 images start with the same magic pattern as FS4/FS5 ones; the image
+// format version after it tells them apart (see FindImageStart), so the
+// probing order does not matter.
+func init() {
+	parser.RegisterFormat(parser.Format{
+		Name:     "FS3",
+		Priority: 10,
+		Probe: func(reader *parser.FirmwareReader, logger *zap.Logger) bool {
+			offset, err := FindImageStart(reader)
+			if err != nil {
+				return false
+			}
+			logger.Debug("Detected FS3 format from image format version", zap.Uint32("offset", offset))
+			return true
+		},
+		New: func(reader *parser.FirmwareReader, logger *zap.Logger) (interfaces.FirmwareParser, error) {
+			return NewParser(reader, logger), nil
+		},
+	})
+}
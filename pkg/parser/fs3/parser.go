@@ -0,0 +1,398 @@
+// Package fs3 parses FS3 (CIB) firmware images used by Connect-IB, ConnectX-4
+// and ConnectX-4 Lx adapters. ConnectX-3 and ConnectX-3 Pro use FS2 images,
+// which this package does not parse.
+//
+// An FS3 image starts with the magic pattern and a boot version holding an FS3
+// image format version, followed by the boot header and BOOT2 at 0x38. Sections are described by a single ITOC aligned to a flash sector;
+// there are no HW pointers and no DTOC, device data sections (MFG_INFO, DEV_INFO,
+// VPD) are listed in the ITOC with the device_data flag set.
+package fs3
+
+import (
+	"encoding/binary"
+
+	"github.com/ansel1/merry/v2"
+	"go.uber.org/zap"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/parser"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+	"github.com/Civil/mlx5fw-go/pkg/types/sections"
+)
+
+// ITOC signature words following "ITOC"
+// Based on mstflint's fs3_ops.h ITOC_ASCII/TOC_RAND1..3
+const (
+	itocSignature1 = 0x04081516
+	itocSignature2 = 0x2342CAFA
+	itocSignature3 = 0xBACAFE00
+
+	// sectionTypeEnd terminates the ITOC entry list
+	sectionTypeEnd = 0xFF
+
+	// maxBoot2SizeDwords bounds the BOOT2 size field, as mstflint's CheckBoot2 does
+	maxBoot2SizeDwords = 0x40000
+)
+
+// Parser implements FS3 firmware parsing
+type Parser struct {
+	reader         *parser.FirmwareReader
+	logger         *zap.Logger
+	crc            *parser.CRCCalculator
+	sectionFactory interfaces.SectionFactory
+
+	// Parsed data
+	imageStart  uint32
+	bootVersion *types.FirmwareBootVersion
+	itocHeader  *types.FS3ITOCHeader
+	sections    map[uint16][]interfaces.CompleteSectionInterface
+
+	// Addresses
+	itocAddr uint32
+
+	// Validation status
+	itocHeaderValid  bool
+	itocEntriesValid bool
+
+	// Conditions found while parsing
+	diagnostics types.Diagnostics
+}
+
+var _ interfaces.FirmwareParser = (*Parser)(nil)
+
+// NewParser creates a new FS3 parser
+func NewParser(reader *parser.FirmwareReader, logger *zap.Logger) *Parser {
+	return &Parser{
+		reader:           reader,
+		logger:           logger,
+		crc:              parser.NewCRCCalculator(),
+		sectionFactory:   sections.NewDefaultSectionFactory(),
+		sections:         make(map[uint16][]interfaces.CompleteSectionInterface),
+		itocEntriesValid: true,
+	}
+}
+
+// FindImageStart returns the offset of the FS3 image in the reader: the magic
+// pattern at one of the standard search offsets, followed by a boot version
+// whose image format version is that of FS3
+func FindImageStart(reader *parser.FirmwareReader) (uint32, error) {
+	offset, err := reader.FindMagicPattern()
+	if err != nil {
+		return 0, err
+	}
+	bootVersion, err := reader.ReadBootVersion(offset)
+	if err != nil {
+		return 0, err
+	}
+	if !bootVersion.IsFS3() {
+		return 0, merry.Wrap(pkgerrors.ErrInvalidMagic,
+			merry.WithMessagef("image format version %d at 0x%x is not FS3", bootVersion.ImageFormatVersion, offset))
+	}
+	return offset, nil
+}
+
+// Parse parses the FS3 firmware
+func (p *Parser) Parse() error {
+	p.logger.Info("Parsing FS3 firmware")
+	p.diagnostics = nil
+
+	var err error
+	p.imageStart, err = FindImageStart(p.reader)
+	if err != nil {
+		return merry.Wrap(err)
+	}
+
+	// FindImageStart has read the boot version already
+	p.bootVersion, err = p.reader.ReadBootVersion(p.imageStart)
+	if err != nil {
+		return err
+	}
+
+	if err := p.parseBoot2(); err != nil {
+		// Log but don't fail - the ITOC is still usable without BOOT2
+		p.logger.Warn("Failed to parse FS3 BOOT2", zap.Error(err))
+		p.diagnostics.Add(types.SeverityWarning, types.DiagBoot2Missing, uint64(p.imageStart+types.FS3Boot2Offset), "BOOT2",
+			"failed to parse BOOT2: %v", err)
+	}
+
+	if err := p.findITOC(); err != nil {
+		return merry.Wrap(err)
+	}
+
+	return p.parseITOC()
+}
+
+// parseBoot2 adds the BOOT2 section that follows the FS3 boot header
+// Based on mstflint's FwOperations::CheckBoot2
+func (p *Parser) parseBoot2() error {
+	boot2Addr := p.imageStart + types.FS3Boot2Offset
+
+	headerData, err := p.reader.ReadSection(int64(boot2Addr), 16)
+	if err != nil {
+		return merry.Wrap(err)
+	}
+
+	// Boot2 size is at offset 4, the section spans (size + 4) dwords including the CRC
+	size := binary.BigEndian.Uint32(headerData[4:8])
+	if size < 4 || size > maxBoot2SizeDwords {
+		return merry.Wrap(pkgerrors.ErrInvalidSize, merry.WithMessagef("invalid BOOT2 size: %d dwords", size))
+	}
+	boot2SizeBytes := (size + 4) * 4
+
+	// Read the trailing dword as well, like the FS4 parser does, so the
+	// extractor/reassembler treat BOOT2 the same way for both formats
+	boot2Data, err := p.reader.ReadSection(int64(boot2Addr), boot2SizeBytes+4)
+	if err != nil {
+		return merry.Wrap(err)
+	}
+
+	section, err := p.sectionFactory.CreateSectionFromData(
+		types.SectionTypeBoot2,
+		uint64(boot2Addr),
+		boot2SizeBytes,
+		types.CRCInSection,
+		0,     // CRC is stored in the last dword of BOOT2
+		false, // isEncrypted
+		false, // isDeviceData
+		nil,   // entry
+		false, // isFromHWPointer
+		boot2Data,
+	)
+	if err != nil {
+		return merry.Wrap(err)
+	}
+	p.addSection(section)
+
+	p.logger.Debug("Found FS3 BOOT2 section",
+		zap.Uint32("offset", boot2Addr),
+		zap.Uint32("size", boot2SizeBytes))
+	return nil
+}
+
+// findITOC scans flash sectors after the boot area for the ITOC signature
+func (p *Parser) findITOC() error {
+	size := p.reader.Size()
+	for off := int64(p.imageStart) + types.FS3SectorSize; off+types.ITOCHeaderSize <= size; off += types.FS3SectorSize {
+		headerData, err := p.reader.ReadSection(off, types.ITOCHeaderSize)
+		if err != nil {
+			return merry.Wrap(err)
+		}
+
+		header := &types.FS3ITOCHeader{}
+		if err := header.Unmarshal(headerData); err != nil {
+			return merry.Wrap(err)
+		}
+		if header.Signature0 != types.ITOCSignature || header.Signature1 != itocSignature1 ||
+			header.Signature2 != itocSignature2 || header.Signature3 != itocSignature3 {
+			continue
+		}
+
+		p.itocAddr = uint32(off)
+		p.itocHeader = header
+
+		calculated := p.crc.CalculateImageCRC(headerData[:types.ITOCHeaderSize-4], types.ITOCHeaderSize/4-1)
+		p.itocHeaderValid = calculated == header.ITOCEntryCRC
+		if !p.itocHeaderValid {
+			p.logger.Warn("FS3 ITOC header CRC mismatch",
+				zap.Uint16("calculated", calculated),
+				zap.Uint16("stored", header.ITOCEntryCRC))
+			p.diagnostics.Add(types.SeverityWarning, types.DiagITOCHeaderCRC, uint64(off), "ITOC",
+				"ITOC header CRC mismatch: calculated 0x%04x, stored 0x%04x", calculated, header.ITOCEntryCRC)
+		}
+
+		p.logger.Debug("Found FS3 ITOC",
+			zap.Uint32("address", p.itocAddr),
+			zap.Bool("header_valid", p.itocHeaderValid))
+		return nil
+	}
+
+	return merry.Wrap(pkgerrors.ErrSectionNotFound, merry.WithMessage("no valid FS3 ITOC found"))
+}
+
+// parseITOC walks the ITOC entries until the END entry
+func (p *Parser) parseITOC() error {
+	size := p.reader.Size()
+	for idx := 0; ; idx++ {
+		entryOff := int64(p.itocAddr) + types.ITOCHeaderSize + int64(idx)*types.ITOCEntrySize
+		if entryOff+types.ITOCEntrySize > size {
+			p.logger.Warn("FS3 ITOC has no END entry", zap.Int("entries", idx))
+			p.diagnostics.Add(types.SeverityWarning, types.DiagITOCNoEnd, uint64(p.itocAddr), "ITOC",
+				"ITOC has no END entry after %d entries", idx)
+			break
+		}
+		entryData, err := p.reader.ReadSection(entryOff, types.ITOCEntrySize)
+		if err != nil {
+			return merry.Wrap(err)
+		}
+
+		entry := &types.FS3ITOCEntry{}
+		if err := entry.Unmarshal(entryData); err != nil {
+			return merry.Wrap(err)
+		}
+		if entry.Type == sectionTypeEnd {
+			break
+		}
+
+		calculated := p.crc.CalculateImageCRC(entryData[:types.ITOCEntrySize-4], types.ITOCEntrySize/4-1)
+		if calculated != entry.ITOCEntryCRC {
+			p.itocEntriesValid = false
+			p.logger.Warn("FS3 ITOC entry CRC mismatch",
+				zap.Int("index", idx),
+				zap.String("type", types.GetSectionTypeName(uint16(entry.Type))),
+				zap.Uint16("calculated", calculated),
+				zap.Uint16("stored", entry.ITOCEntryCRC))
+			p.diagnostics.Add(types.SeverityError, types.DiagITOCEntryCRC, uint64(entryOff), types.GetSectionTypeName(uint16(entry.Type)),
+				"ITOC entry %d CRC mismatch: calculated 0x%04x, stored 0x%04x", idx, calculated, entry.ITOCEntryCRC)
+		}
+
+		if err := p.addEntrySection(entry); err != nil {
+			p.logger.Warn("Failed to add FS3 section",
+				zap.String("type", types.GetSectionTypeName(uint16(entry.Type))),
+				zap.Error(err))
+			p.diagnostics.Add(types.SeverityWarning, types.DiagSectionInvalid, uint64(entry.FlashAddrDwords)*4, types.GetSectionTypeName(uint16(entry.Type)),
+				"failed to add section: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// addEntrySection creates the section described by an ITOC entry
+func (p *Parser) addEntrySection(entry *types.FS3ITOCEntry) error {
+	addr := uint64(entry.FlashAddrDwords) << 2
+	if entry.RelativeAddr {
+		addr += uint64(p.imageStart)
+	}
+	size := entry.SizeDwords << 2
+
+	crcType := types.CRCInITOCEntry
+	if entry.NoCRC {
+		crcType = types.CRCNone
+	}
+
+	// Downstream consumers (verification, extract metadata) work with FS4-style entries
+	itocEntry := &types.ITOCEntry{
+		Type:            entry.Type,
+		SizeDwords:      entry.SizeDwords,
+		Param1:          entry.Param1,
+		FlashAddrDwords: uint32(addr),
+		CRCField:        uint8(crcType),
+		SectionCRC:      entry.SectionCRC,
+		ITOCEntryCRC:    entry.ITOCEntryCRC,
+	}
+	itocEntry.SetParam0(entry.Param0)
+	if entry.DeviceData {
+		itocEntry.CRCField |= 0x2
+	}
+
+	var section interfaces.CompleteSectionInterface
+	var err error
+	if size == 0 {
+		// Zero-length sections (e.g. VPD_R0) are still listed by mstflint
+		section, err = p.sectionFactory.CreateSection(uint16(entry.Type), addr, size, crcType,
+			uint32(entry.SectionCRC), false, entry.DeviceData, itocEntry, false)
+	} else {
+		data, rerr := p.reader.ReadSection(int64(addr), size)
+		if rerr != nil {
+			return rerr
+		}
+		section, err = p.sectionFactory.CreateSectionFromData(uint16(entry.Type), addr, size, crcType,
+			uint32(entry.SectionCRC), false, entry.DeviceData, itocEntry, false, data)
+	}
+	if err != nil {
+		return err
+	}
+
+	p.logger.Debug("Found FS3 ITOC section",
+		zap.String("name", section.TypeName()),
+		zap.Uint64("offset", addr),
+		zap.Uint32("size", size),
+		zap.Bool("device_data", entry.DeviceData))
+
+	p.addSection(section)
+	return nil
+}
+
+// addSection adds a section to the sections map
+func (p *Parser) addSection(section interfaces.CompleteSectionInterface) {
+	p.sections[section.Type()] = append(p.sections[section.Type()], section)
+}
+
+// GetSections returns all parsed sections
+func (p *Parser) GetSections() map[uint16][]interfaces.CompleteSectionInterface {
+	return p.sections
+}
+
+// GetFormat returns the firmware format
+func (p *Parser) GetFormat() types.FirmwareFormat {
+	return types.FormatFS3
+}
+
+// GetBootVersion returns the boot version following the magic pattern, if present
+func (p *Parser) GetBootVersion() *types.FirmwareBootVersion {
+	return p.bootVersion
+}
+
+// GetMagicOffset returns the offset of the image start (the magic pattern)
+func (p *Parser) GetMagicOffset() uint32 {
+	return p.imageStart
+}
+
+// GetReader returns the firmware reader
+func (p *Parser) GetReader() *parser.FirmwareReader {
+	return p.reader
+}
+
+// GetITOCAddress returns the ITOC address
+func (p *Parser) GetITOCAddress() uint32 {
+	return p.itocAddr
+}
+
+// GetDTOCAddress returns 0, FS3 images have no DTOC
+func (p *Parser) GetDTOCAddress() uint32 {
+	return 0
+}
+
+// IsITOCHeaderValid returns true if the ITOC header CRC is valid
+func (p *Parser) IsITOCHeaderValid() bool {
+	return p.itocHeaderValid
+}
+
+// IsDTOCHeaderValid returns true, there is no DTOC that could be invalid
+func (p *Parser) IsDTOCHeaderValid() bool {
+	return true
+}
+
+// IsITOCValid returns true if the ITOC header and all entry CRCs are valid
+func (p *Parser) IsITOCValid() bool {
+	return p.itocHeaderValid && p.itocEntriesValid
+}
+
+// IsEncrypted returns false, FS3 images are never encrypted
+func (p *Parser) IsEncrypted() bool {
+	return false
+}
+
+// Diagnostics returns the conditions found by Parse
+func (p *Parser) Diagnostics() types.Diagnostics {
+	return p.diagnostics
+}
+
+// GetITOCRawData returns the raw ITOC header data
+func (p *Parser) GetITOCRawData() ([]byte, error) {
+	if p.itocHeader == nil {
+		return nil, merry.New("ITOC address not found")
+	}
+	return p.reader.ReadSection(int64(p.itocAddr), types.ITOCHeaderSize)
+}
+
+// GetDTOCRawData returns no data, FS3 images have no DTOC
+func (p *Parser) GetDTOCRawData() ([]byte, error) {
+	return nil, nil
+}
+
+// ReadSectionData reads section data from the firmware
+func (p *Parser) ReadSectionData(sectionType uint16, offset uint64, size uint32) ([]byte, error) {
+	return p.reader.ReadSection(int64(offset), size)
+}
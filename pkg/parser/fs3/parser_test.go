@@ -0,0 +1,393 @@
+package fs3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+
+	"github.com/Civil/mlx5fw-go/pkg/extract"
+	"github.com/Civil/mlx5fw-go/pkg/parser"
+	"github.com/Civil/mlx5fw-go/pkg/reassemble"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+const (
+	testImageSize     = 0x10000
+	testITOCAddr      = 0x1000
+	testImageInfoAddr = 0x2000
+	testROMCodeAddr   = 0x3000
+	testDevInfoAddr   = 0xF000
+	testBaseGUID      = 0x0002c90300a1b2c0
+	testBaseMAC       = 0x0002c9a1b2c0
+)
+
+// testSection describes one ITOC entry of the synthetic image
+type testSection struct {
+	sectionType uint8
+	addr        uint32
+	data        []byte
+	noCRC       bool
+	deviceData  bool
+}
+
+// createMockFS3Firmware builds a small FS3 image: magic pattern and FS3 boot version, BOOT2 at 0x38,
+// an ITOC at 0x1000 and IMAGE_INFO, ROM_CODE and DEV_INFO sections
+func createMockFS3Firmware(t *testing.T) []byte {
+	t.Helper()
+	crc := parser.NewCRCCalculator()
+
+	data := bytes.Repeat([]byte{0xFF}, testImageSize)
+	binary.BigEndian.PutUint64(data[0:], types.MagicPattern)
+	binary.BigEndian.PutUint64(data[8:], 0xFADE12345678DEAD)
+	copy(data[types.BootVersionOffset:], []byte{types.ImageFormatVersionFS2, 0, 1, 0})
+
+	// BOOT2: magic, size in dwords, reserved, code, CRC over all but the last dword
+	const boot2Dwords = 8
+	boot2 := make([]byte, (boot2Dwords+4)*4)
+	binary.BigEndian.PutUint32(boot2[0:], 0x20400040)
+	binary.BigEndian.PutUint32(boot2[4:], boot2Dwords)
+	for i := 16; i < len(boot2)-4; i++ {
+		boot2[i] = byte(i)
+	}
+	binary.BigEndian.PutUint32(boot2[len(boot2)-4:], uint32(crc.CalculateImageCRC(boot2[:len(boot2)-4], boot2Dwords+3)))
+	copy(data[types.FS3Boot2Offset:], boot2)
+
+	imageInfo := types.ImageInfo{
+		FWVerMajor:    2,
+		FWVerMinor:    42,
+		FWVerSubminor: 5000,
+		Year:          2024,
+		Month:         6,
+		Day:           27,
+	}
+	copy(imageInfo.PSID[:], "MT_2190110032")
+	copy(imageInfo.Name[:], "MCX456A-ECAT")
+	copy(imageInfo.Description[:], "ConnectX-4 VPI adapter card")
+	imageInfoData, err := imageInfo.Marshal()
+	if err != nil {
+		t.Fatalf("failed to marshal IMAGE_INFO: %v", err)
+	}
+
+	romCode := make([]byte, 0x100)
+	copy(romCode[0x20:], "mlxsign:")
+	// PXE 3.4.752
+	binary.LittleEndian.PutUint32(romCode[0x28:], 0x00100003)
+	binary.LittleEndian.PutUint32(romCode[0x2C:], 0x000402F0)
+
+	devInfo := types.DevInfo{
+		Guids: types.UidEntry{NumAllocated: 8, Step: 1, UID: testBaseGUID},
+		Macs:  types.UidEntry{NumAllocated: 4, Step: 1, UID: testBaseMAC},
+	}
+	devInfoData, err := devInfo.Marshal()
+	if err != nil {
+		t.Fatalf("failed to marshal DEV_INFO: %v", err)
+	}
+
+	sections := []testSection{
+		{sectionType: types.SectionTypeImageInfo, addr: testImageInfoAddr, data: imageInfoData},
+		{sectionType: types.SectionTypeROMCode, addr: testROMCodeAddr, data: romCode},
+		{sectionType: types.SectionTypeDevInfo, addr: testDevInfoAddr, data: devInfoData, noCRC: true, deviceData: true},
+	}
+
+	header := make([]byte, types.ITOCHeaderSize)
+	binary.BigEndian.PutUint32(header[0:], types.ITOCSignature)
+	binary.BigEndian.PutUint32(header[4:], itocSignature1)
+	binary.BigEndian.PutUint32(header[8:], itocSignature2)
+	binary.BigEndian.PutUint32(header[12:], itocSignature3)
+	binary.BigEndian.PutUint16(header[30:], crc.CalculateImageCRC(header[:28], 7))
+	copy(data[testITOCAddr:], header)
+
+	entryOff := testITOCAddr + types.ITOCHeaderSize
+	for _, s := range sections {
+		copy(data[s.addr:], s.data)
+		entry := types.FS3ITOCEntry{
+			Type:            s.sectionType,
+			SizeDwords:      uint32(len(s.data) / 4),
+			FlashAddrDwords: s.addr / 4,
+			NoCRC:           s.noCRC,
+			DeviceData:      s.deviceData,
+		}
+		if !s.noCRC {
+			entry.SectionCRC = crc.CalculateImageCRC(s.data, len(s.data)/4)
+		}
+		copy(data[entryOff:], marshalTestEntry(t, &entry))
+		entryOff += types.ITOCEntrySize
+	}
+	copy(data[entryOff:], marshalTestEntry(t, &types.FS3ITOCEntry{Type: sectionTypeEnd}))
+
+	return data
+}
+
+// marshalTestEntry packs an ITOC entry and fills in its entry CRC
+func marshalTestEntry(t *testing.T, entry *types.FS3ITOCEntry) []byte {
+	t.Helper()
+	raw, err := entry.Marshal()
+	if err != nil {
+		t.Fatalf("failed to marshal ITOC entry: %v", err)
+	}
+	binary.BigEndian.PutUint16(raw[30:], parser.NewCRCCalculator().CalculateImageCRC(raw[:28], 7))
+	return raw
+}
+
+func parseTestImage(t *testing.T, data []byte) *Parser {
+	t.Helper()
+	logger := zaptest.NewLogger(t)
+	p := NewParser(parser.NewFirmwareReaderFromBytes(data, logger), logger)
+	if err := p.Parse(); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	return p
+}
+
+func TestFindImageStart(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	tests := []struct {
+		name    string
+		data    func() []byte
+		want    uint32
+		wantErr bool
+	}{
+		{
+			name: "magic at 0",
+			data: func() []byte { return createMockFS3Firmware(t) },
+			want: 0,
+		},
+		{
+			name: "magic at 0x10000",
+			data: func() []byte {
+				data := bytes.Repeat([]byte{0xFF}, 0x20000)
+				binary.BigEndian.PutUint64(data[0x10000:], types.MagicPattern)
+				data[0x10000+types.BootVersionOffset] = types.ImageFormatVersionFS3
+				return data
+			},
+			want: 0x10000,
+		},
+		{
+			name: "MTFW only at 0",
+			data: func() []byte {
+				data := make([]byte, 0x100)
+				binary.BigEndian.PutUint32(data, types.FS3Magic)
+				return data
+			},
+			wantErr: true,
+		},
+		{
+			name: "FS4 image format version",
+			data: func() []byte {
+				data := createMockFS3Firmware(t)
+				data[types.BootVersionOffset] = types.ImageFormatVersionFS4
+				return data
+			},
+			wantErr: true,
+		},
+		{
+			name: "FS5 image format version",
+			data: func() []byte {
+				data := createMockFS3Firmware(t)
+				data[types.BootVersionOffset] = types.ImageFormatVersionFS5
+				return data
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FindImageStart(parser.NewFirmwareReaderFromBytes(tt.data(), logger))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FindImageStart() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("FindImageStart() = 0x%x, want 0x%x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParser_Parse(t *testing.T) {
+	p := parseTestImage(t, createMockFS3Firmware(t))
+
+	if p.GetFormat() != types.FormatFS3 {
+		t.Errorf("GetFormat() = %v, want FS3", p.GetFormat())
+	}
+	if p.GetITOCAddress() != testITOCAddr {
+		t.Errorf("GetITOCAddress() = 0x%x, want 0x%x", p.GetITOCAddress(), testITOCAddr)
+	}
+	if p.GetDTOCAddress() != 0 {
+		t.Errorf("GetDTOCAddress() = 0x%x, want 0", p.GetDTOCAddress())
+	}
+	if !p.IsITOCValid() {
+		t.Error("IsITOCValid() = false, want true")
+	}
+	if v := p.GetBootVersion(); v == nil || v.MajorVersion != 1 {
+		t.Errorf("GetBootVersion() = %+v, want major version 1", v)
+	}
+
+	want := map[uint16]uint64{
+		types.SectionTypeBoot2:     types.FS3Boot2Offset,
+		types.SectionTypeImageInfo: testImageInfoAddr,
+		types.SectionTypeROMCode:   testROMCodeAddr,
+		types.SectionTypeDevInfo:   testDevInfoAddr,
+	}
+	sections := p.GetSections()
+	for sectionType, offset := range want {
+		list := sections[sectionType]
+		if len(list) != 1 {
+			t.Errorf("%s: got %d sections, want 1", types.GetSectionTypeName(sectionType), len(list))
+			continue
+		}
+		if list[0].Offset() != offset {
+			t.Errorf("%s: offset = 0x%x, want 0x%x", list[0].TypeName(), list[0].Offset(), offset)
+		}
+	}
+	if dev := sections[types.SectionTypeDevInfo]; len(dev) == 1 && (!dev[0].IsDeviceData() || dev[0].CRCType() != types.CRCNone) {
+		t.Errorf("DEV_INFO: device data = %v, CRC type = %v", dev[0].IsDeviceData(), dev[0].CRCType())
+	}
+}
+
+func TestParser_Diagnostics(t *testing.T) {
+	if diags := parseTestImage(t, createMockFS3Firmware(t)).Diagnostics(); len(diags) != 0 {
+		t.Errorf("Diagnostics() of a valid image = %v, want none", diags)
+	}
+
+	// Corrupt the second ITOC entry (ROM_CODE) and BOOT2's size
+	data := createMockFS3Firmware(t)
+	romEntry := testITOCAddr + types.ITOCHeaderSize + types.ITOCEntrySize
+	data[romEntry+31] ^= 0xFF
+	binary.BigEndian.PutUint32(data[types.FS3Boot2Offset+4:], 0)
+
+	diags := parseTestImage(t, data).Diagnostics()
+	want := []types.Diagnostic{
+		{Severity: types.SeverityWarning, Code: types.DiagBoot2Missing, Offset: types.FS3Boot2Offset, Section: "BOOT2"},
+		{Severity: types.SeverityError, Code: types.DiagITOCEntryCRC, Offset: uint64(romEntry), Section: "ROM_CODE"},
+	}
+	if len(diags) != len(want) {
+		t.Fatalf("Diagnostics() = %v, want %d entries", diags, len(want))
+	}
+	for i, d := range diags {
+		d.Message = ""
+		if d != want[i] {
+			t.Errorf("Diagnostics()[%d] = %+v, want %+v", i, d, want[i])
+		}
+	}
+	if got := diags.AtLeast(types.SeverityError); len(got) != 1 || got[0].Code != types.DiagITOCEntryCRC {
+		t.Errorf("AtLeast(error) = %v", got)
+	}
+}
+
+func TestParser_Query(t *testing.T) {
+	p := parseTestImage(t, createMockFS3Firmware(t))
+
+	info, err := p.Query()
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+
+	if info.Format != "FS3" {
+		t.Errorf("Format = %q, want FS3", info.Format)
+	}
+	if info.FWVersion != "2.42.5000" {
+		t.Errorf("FWVersion = %q, want 2.42.5000", info.FWVersion)
+	}
+	if info.FWReleaseDate != "27.6.2024" {
+		t.Errorf("FWReleaseDate = %q, want 27.6.2024", info.FWReleaseDate)
+	}
+	if info.PSID != "MT_2190110032" {
+		t.Errorf("PSID = %q, want MT_2190110032", info.PSID)
+	}
+	if info.BaseGUID != testBaseGUID || info.BaseGUIDNum != 8 {
+		t.Errorf("Base GUID = 0x%x/%d, want 0x%x/8", info.BaseGUID, info.BaseGUIDNum, uint64(testBaseGUID))
+	}
+	if info.BaseMAC != testBaseMAC || info.BaseMACNum != 4 {
+		t.Errorf("Base MAC = 0x%x/%d, want 0x%x/4", info.BaseMAC, info.BaseMACNum, uint64(testBaseMAC))
+	}
+	if len(info.RomInfo) != 1 || info.RomInfo[0].Type != "PXE" || info.RomInfo[0].Version != "3.4.752" {
+		t.Errorf("RomInfo = %+v, want PXE 3.4.752", info.RomInfo)
+	}
+}
+
+func TestParser_VerifySection(t *testing.T) {
+	t.Run("valid image", func(t *testing.T) {
+		p := parseTestImage(t, createMockFS3Firmware(t))
+		for _, list := range p.GetSections() {
+			for _, section := range list {
+				status, err := p.VerifySectionNew(section)
+				if err != nil {
+					t.Fatalf("%s: VerifySectionNew() error = %v", section.TypeName(), err)
+				}
+				want := "OK"
+				if !section.HasCRC() {
+					want = "CRC IGNORED"
+				}
+				if status != want {
+					t.Errorf("%s: status = %q, want %q", section.TypeName(), status, want)
+				}
+			}
+		}
+	})
+
+	t.Run("corrupted section", func(t *testing.T) {
+		data := createMockFS3Firmware(t)
+		data[testImageInfoAddr+0x100] ^= 0xFF
+		data[types.FS3Boot2Offset+0x14] ^= 0xFF
+		p := parseTestImage(t, data)
+
+		for _, sectionType := range []uint16{types.SectionTypeImageInfo, types.SectionTypeBoot2} {
+			section := p.GetSections()[sectionType][0]
+			status, err := p.VerifySectionNew(section)
+			if err != nil {
+				t.Fatalf("%s: VerifySectionNew() error = %v", section.TypeName(), err)
+			}
+			if len(status) < 4 || status[:4] != "FAIL" {
+				t.Errorf("%s: status = %q, want FAIL", section.TypeName(), status)
+			}
+		}
+	})
+
+	t.Run("corrupted ITOC entry", func(t *testing.T) {
+		data := createMockFS3Firmware(t)
+		data[testITOCAddr+types.ITOCHeaderSize+8] ^= 0x01
+		p := parseTestImage(t, data)
+		if !p.IsITOCHeaderValid() {
+			t.Error("IsITOCHeaderValid() = false, want true")
+		}
+		if p.IsITOCValid() {
+			t.Error("IsITOCValid() = true, want false")
+		}
+	})
+}
+
+func TestParser_ExtractReassembleRoundTrip(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	original := createMockFS3Firmware(t)
+	p := parseTestImage(t, original)
+
+	dir := t.TempDir()
+	extractDir := filepath.Join(dir, "extracted")
+	if err := extract.New(p, logger, extract.Options{OutputDir: extractDir, RemoveCRC: true}).Extract(); err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+
+	output := filepath.Join(dir, "reassembled.bin")
+	if err := reassemble.New(logger, reassemble.Options{InputDir: extractDir, OutputFile: output}).Reassemble(); err != nil {
+		t.Fatalf("Reassemble() error = %v", err)
+	}
+
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, original) {
+		for i := range original {
+			if i >= len(got) || got[i] != original[i] {
+				t.Fatalf("reassembled image differs at 0x%x (size %d, want %d)", i, len(got), len(original))
+			}
+		}
+		t.Fatalf("reassembled image size %d, want %d", len(got), len(original))
+	}
+}
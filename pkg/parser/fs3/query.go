@@ -0,0 +1,109 @@
+package fs3
+
+import (
+	"go.uber.org/zap"
+
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/parser"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+// Query returns firmware information similar to mstflint query output
+// Based on mstflint's Fs3Operations::GetImageInfo and FsIntQueryAux
+func (p *Parser) Query() (*interfaces.FirmwareInfo, error) {
+	info := &interfaces.FirmwareInfo{
+		Format:        p.GetFormat().String(),
+		FormatVersion: 3,
+		ImageSize:     uint64(p.reader.Size()),
+		// FS3 images have no security attributes
+		SecurityAttrs: "N/A",
+	}
+
+	if data := p.firstSectionData(types.SectionTypeImageInfo); data != nil {
+		var imageInfo types.ImageInfo
+		if err := imageInfo.Unmarshal(data); err != nil {
+			p.logger.Warn("Failed to parse IMAGE_INFO", zap.Error(err))
+		} else {
+			info.FWVersion = imageInfo.GetFWVersionString()
+			info.FWReleaseDate = imageInfo.GetFWReleaseDateString()
+			// Same MIC version mstflint reports for FS4 images
+			info.MICVersion = "2.0.0"
+			info.PRSName = imageInfo.GetPRSNameString()
+			info.PartNumber = imageInfo.GetPartNumberString()
+			info.Description = imageInfo.GetDescriptionString()
+			info.PSID = imageInfo.GetPSIDString()
+			info.ImageVSD = imageInfo.GetVSDString()
+			info.ProductVersion = imageInfo.GetProductVerString()
+			info.DeviceID = imageInfo.PCIDeviceID
+			info.VendorID = imageInfo.PCIVendorID
+		}
+	}
+
+	// GUIDs and MACs come from DEV_INFO, falling back to MFG_INFO when it is blank
+	if data := p.firstSectionData(types.SectionTypeDevInfo); len(data) >= 0x40 {
+		var devInfo types.DevInfo
+		if err := devInfo.Unmarshal(data); err != nil {
+			p.logger.Warn("Failed to parse DEV_INFO", zap.Error(err))
+		} else {
+			setUIDs(info, devInfo.Guids, devInfo.Macs)
+		}
+	}
+	if info.BaseGUID == 0 && info.BaseMAC == 0 {
+		if data := p.firstSectionData(types.SectionTypeMfgInfo); len(data) >= 0x40 {
+			var mfgInfo types.MfgInfo
+			if err := mfgInfo.Unmarshal(data); err != nil {
+				p.logger.Warn("Failed to parse MFG_INFO", zap.Error(err))
+			} else {
+				setUIDs(info, mfgInfo.Guids, mfgInfo.Macs)
+			}
+		}
+	}
+
+	if data := p.firstSectionData(types.SectionTypeROMCode); data != nil {
+		if romInfo := parser.ParseRomInfo(data, p.logger); len(romInfo) > 0 {
+			info.RomInfo = romInfo
+		}
+	}
+
+	for _, sections := range p.sections {
+		for _, section := range sections {
+			info.Sections = append(info.Sections, interfaces.SectionInfo{
+				Type:         section.Type(),
+				TypeName:     section.TypeName(),
+				Offset:       section.Offset(),
+				Size:         section.Size(),
+				CRCType:      section.CRCType(),
+				IsEncrypted:  section.IsEncrypted(),
+				IsDeviceData: section.IsDeviceData(),
+			})
+		}
+	}
+
+	return info, nil
+}
+
+// firstSectionData returns the data of the first section of the given type
+func (p *Parser) firstSectionData(sectionType uint16) []byte {
+	sections := p.sections[sectionType]
+	if len(sections) == 0 {
+		return nil
+	}
+	section := sections[0]
+	if err := p.LoadSectionData(section); err != nil {
+		p.logger.Warn("Failed to read section",
+			zap.String("type", section.TypeName()),
+			zap.Error(err))
+		return nil
+	}
+	return section.GetRawData()
+}
+
+// setUIDs fills the base GUID/MAC information
+func setUIDs(info *interfaces.FirmwareInfo, guids, macs types.UidEntry) {
+	info.BaseGUID = guids.GetUID()
+	info.BaseGUIDNum = guids.GetNumAllocated()
+	info.GUIDStep = guids.Step
+	info.BaseMAC = macs.GetUID()
+	info.BaseMACNum = macs.GetNumAllocated()
+	info.MACStep = macs.Step
+}
@@ -0,0 +1,51 @@
+package fs3
+
+import (
+	"errors"
+	"fmt"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+)
+
+// VerifySectionNew verifies a section CRC and returns an mstflint-style status.
+// FS3 sections carry their CRC in the ITOC entry; BOOT2 keeps it in its last dword.
+func (p *Parser) VerifySectionNew(section interfaces.SectionVerifier) (string, error) {
+	if !section.HasCRC() {
+		return "CRC IGNORED", nil
+	}
+
+	if err := p.LoadSectionData(section); err != nil {
+		return "ERROR", err
+	}
+
+	if err := section.VerifyCRC(); err != nil {
+		if errors.Is(err, pkgerrors.ErrCRCMismatch) {
+			if crcData, ok := pkgerrors.GetCRCMismatchData(err); ok {
+				return fmt.Sprintf("FAIL (0x%04X != 0x%04X)", crcData.Actual, crcData.Expected), nil
+			}
+			return "FAIL", nil
+		}
+		return "ERROR", err
+	}
+	return "OK", nil
+}
+
+// LoadSectionData reads and parses the section data if it is not loaded yet
+func (p *Parser) LoadSectionData(section interfaces.SectionParser) error {
+	if len(section.GetRawData()) > 0 {
+		return nil
+	}
+	if section.Size() == 0 {
+		return section.Parse([]byte{})
+	}
+
+	data, err := p.reader.ReadSection(int64(section.Offset()), section.Size())
+	if err != nil {
+		return fmt.Errorf("failed to read section data: %w", err)
+	}
+	if err := section.Parse(data); err != nil {
+		return fmt.Errorf("failed to parse section data: %w", err)
+	}
+	return nil
+}
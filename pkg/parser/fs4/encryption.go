@@ -0,0 +1,284 @@
+package fs4
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ansel1/merry/v2"
+	"go.uber.org/zap"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+	"github.com/Civil/mlx5fw-go/pkg/parser"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+// Image encryption handled by DecryptImage and EncryptImage.
+//
+// The layout below is an assumption: neither mstflint nor the PRM document
+// how images are encrypted, and it has not been checked against a real
+// encrypted image. It only relies on what the parser observes, an ITOC that
+// cannot be read while BOOT2, TOOLS_AREA and IMAGE_INFO can.
+//
+// The encrypted area is assumed to start at the sector holding the ITOC and
+// to end with the last sector of the last non-device-data ITOC section, and
+// to be encrypted with AES-XTS (IEEE 1619) in 4KB data units, each unit using
+// its flash sector number (address / EncryptionSectorSize) as the tweak.
+// Sectors holding BOOT2, TOOLS_AREA and IMAGE_INFO are assumed to stay in
+// plaintext, since the device and the tools read them through the HW
+// pointers.
+const (
+	EncryptionScheme     = "AES-XTS"
+	EncryptionSectorSize = 0x1000
+)
+
+// maxITOCEntries bounds the ITOC walk, as in the TOC reader
+const maxITOCEntries = 256
+
+// xtsCipher implements AES-XTS for data units that are a multiple of the AES
+// block size, so no ciphertext stealing is needed
+type xtsCipher struct {
+	data, tweak cipher.Block
+}
+
+// newXTSCipher creates an AES-XTS cipher from a 32-byte (AES-128) or 64-byte
+// (AES-256) key holding the data key followed by the tweak key
+func newXTSCipher(key []byte) (*xtsCipher, error) {
+	if len(key) != 32 && len(key) != 64 {
+		return nil, pkgerrors.InvalidParameterError("key",
+			fmt.Sprintf("AES-XTS key must be 32 or 64 bytes, got %d", len(key)))
+	}
+	half := len(key) / 2
+	data, err := aes.NewCipher(key[:half])
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+	tweak, err := aes.NewCipher(key[half:])
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+	return &xtsCipher{data: data, tweak: tweak}, nil
+}
+
+// crypt encrypts or decrypts one data unit from src into dst
+func (c *xtsCipher) crypt(dst, src []byte, unit uint64, decrypt bool) {
+	var t [aes.BlockSize]byte
+	binary.LittleEndian.PutUint64(t[:8], unit)
+	c.tweak.Encrypt(t[:], t[:])
+
+	var block [aes.BlockSize]byte
+	for i := 0; i+aes.BlockSize <= len(src); i += aes.BlockSize {
+		for j := range block {
+			block[j] = src[i+j] ^ t[j]
+		}
+		if decrypt {
+			c.data.Decrypt(block[:], block[:])
+		} else {
+			c.data.Encrypt(block[:], block[:])
+		}
+		for j := range block {
+			dst[i+j] = block[j] ^ t[j]
+		}
+
+		// Multiply the tweak by x in GF(2^128), little-endian
+		carry := t[aes.BlockSize-1] >> 7
+		for j := aes.BlockSize - 1; j > 0; j-- {
+			t[j] = t[j]<<1 | t[j-1]>>7
+		}
+		t[0] = t[0]<<1 ^ carry*0x87
+	}
+}
+
+// cryptRegions encrypts or decrypts the regions of data in place
+func (c *xtsCipher) cryptRegions(data []byte, regions []types.EncryptedRegion, decrypt bool) error {
+	for _, r := range regions {
+		end := uint64(r.Offset) + uint64(r.Size)
+		if r.Offset%EncryptionSectorSize != 0 || r.Size%EncryptionSectorSize != 0 || end > uint64(len(data)) {
+			return merry.Wrap(pkgerrors.ErrInvalidOffset,
+				merry.WithMessagef("encrypted region 0x%x+0x%x is not sector aligned or exceeds the image", r.Offset, r.Size))
+		}
+		for addr := uint64(r.Offset); addr < end; addr += EncryptionSectorSize {
+			sector := data[addr : addr+EncryptionSectorSize]
+			c.crypt(sector, sector, addr/EncryptionSectorSize, decrypt)
+		}
+	}
+	return nil
+}
+
+// DecryptImage decrypts the encrypted area of an FS4/FS5 image with key and
+// returns the plaintext image together with the layout that EncryptImage
+// needs to restore it. The input is not modified. A key that does not turn
+// the ITOC into a valid one is rejected.
+func DecryptImage(data, key []byte) ([]byte, *types.EncryptionLayout, error) {
+	c, err := newXTSCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	hwPointers, err := readHWPointers(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	plain := make([]byte, len(data))
+	copy(plain, data)
+	decrypted := make(map[uint32]bool)
+	decryptRange := func(offset, size uint32) error {
+		for s := offset &^ (EncryptionSectorSize - 1); s < offset+size; s += EncryptionSectorSize {
+			if decrypted[s] {
+				continue
+			}
+			if uint64(s)+EncryptionSectorSize > uint64(len(plain)) {
+				return pkgerrors.DataTooShortError(int(s)+EncryptionSectorSize, len(plain), "encrypted sector")
+			}
+			c.crypt(plain[s:s+EncryptionSectorSize], data[s:s+EncryptionSectorSize], uint64(s/EncryptionSectorSize), true)
+			decrypted[s] = true
+		}
+		return nil
+	}
+
+	// Like the parser, look for the ITOC at the HW pointer and one sector after it
+	standardAddr := itocAddress(hwPointers)
+	itocAddr := uint32(0)
+	for _, addr := range []uint32{standardAddr, standardAddr + EncryptionSectorSize} {
+		if uint64(addr)+EncryptionSectorSize > uint64(len(data)) {
+			continue
+		}
+		if binary.BigEndian.Uint32(data[addr:]) == types.ITOCSignature {
+			return nil, nil, pkgerrors.NotSupportedError(fmt.Sprintf("decrypting an image with a readable ITOC at 0x%x", addr))
+		}
+		if err := decryptRange(addr, types.ITOCHeaderSize); err != nil {
+			return nil, nil, err
+		}
+		if binary.BigEndian.Uint32(plain[addr:]) == types.ITOCSignature {
+			itocAddr = addr
+			break
+		}
+	}
+	if itocAddr == 0 {
+		return nil, nil, merry.Wrap(pkgerrors.ErrInvalidData,
+			merry.WithMessagef("key does not decrypt an ITOC at 0x%x or 0x%x", standardAddr, standardAddr+EncryptionSectorSize))
+	}
+
+	// Decrypt the ITOC entries, which EncryptionLayoutOf walks to find the area
+	for i := uint32(0); i < maxITOCEntries; i++ {
+		entryOffset := itocAddr + types.ITOCHeaderSize + i*types.ITOCEntrySize
+		if err := decryptRange(entryOffset, types.ITOCEntrySize); err != nil {
+			return nil, nil, err
+		}
+		if plain[entryOffset] == 0xFF {
+			break
+		}
+	}
+
+	layout, err := EncryptionLayoutOf(plain, itocAddr)
+	if err != nil {
+		return nil, nil, err
+	}
+	// A sector tried at the standard location is outside the area when the ITOC is at the next one
+	copy(plain, data)
+	clear(decrypted)
+	for _, r := range layout.Regions {
+		if err := decryptRange(r.Offset, r.Size); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return plain, layout, nil
+}
+
+// EncryptionLayoutOf returns the encrypted area of the plaintext image in
+// data with its ITOC at itocAddr under the assumed layout described above:
+// the sectors from the ITOC to the end of the last non-device-data ITOC
+// section, except those holding BOOT2, TOOLS_AREA and IMAGE_INFO. Images
+// modified after DecryptImage must be encrypted with the layout of the new
+// image, since their sections may have moved.
+func EncryptionLayoutOf(data []byte, itocAddr uint32) (*types.EncryptionLayout, error) {
+	hwPointers, err := readHWPointers(data)
+	if err != nil {
+		return nil, err
+	}
+
+	areaEnd := uint64(itocAddr) + types.ITOCHeaderSize
+	itocEnd := areaEnd
+	for i := uint32(0); i < maxITOCEntries; i++ {
+		entryOffset := uint64(itocAddr) + types.ITOCHeaderSize + uint64(i)*types.ITOCEntrySize
+		if entryOffset+types.ITOCEntrySize > uint64(len(data)) {
+			return nil, pkgerrors.DataTooShortError(int(entryOffset)+types.ITOCEntrySize, len(data), "ITOC entry")
+		}
+		itocEnd = entryOffset + types.ITOCEntrySize
+		areaEnd = max(areaEnd, itocEnd)
+
+		entry := &types.ITOCEntry{}
+		if err := entry.Unmarshal(data[entryOffset:itocEnd]); err != nil {
+			return nil, merry.Wrap(err)
+		}
+		if entry.GetType() == 0xFF {
+			break
+		}
+		if entry.GetDeviceData() || entry.GetSize() == 0 {
+			continue
+		}
+		areaEnd = max(areaEnd, uint64(entry.GetFlashAddr())+uint64(entry.GetSize()))
+	}
+	areaEnd = (areaEnd + EncryptionSectorSize - 1) &^ (EncryptionSectorSize - 1)
+	if areaEnd > uint64(len(data)) {
+		return nil, pkgerrors.DataTooShortError(int(areaEnd), len(data), "encrypted area")
+	}
+
+	// The sectors holding the ITOC are encrypted even when a HW pointer targets them
+	areaStart := itocAddr &^ (EncryptionSectorSize - 1)
+	plaintext := make(map[uint32]bool)
+	for _, ptr := range []uint32{hwPointers.Boot2Ptr.Ptr, hwPointers.ToolsPtr.Ptr, hwPointers.ImageInfoSectionPtr.Ptr} {
+		if sector := ptr &^ (EncryptionSectorSize - 1); ptr != 0 && ptr != 0xffffffff && (sector < areaStart || uint64(sector) >= itocEnd) {
+			plaintext[sector] = true
+		}
+	}
+
+	layout := &types.EncryptionLayout{
+		Scheme:     EncryptionScheme,
+		SectorSize: EncryptionSectorSize,
+		ITOCAddr:   itocAddr,
+	}
+	for s := areaStart; uint64(s) < areaEnd; s += EncryptionSectorSize {
+		if plaintext[s] {
+			continue
+		}
+		if n := len(layout.Regions); n > 0 && layout.Regions[n-1].Offset+layout.Regions[n-1].Size == s {
+			layout.Regions[n-1].Size += EncryptionSectorSize
+		} else {
+			layout.Regions = append(layout.Regions, types.EncryptedRegion{Offset: s, Size: EncryptionSectorSize})
+		}
+	}
+	return layout, nil
+}
+
+// readHWPointers reads the FS4 HW pointers of the image in data
+func readHWPointers(data []byte) (*types.FS4HWPointers, error) {
+	magicOffset, err := parser.NewFirmwareReaderFromBytes(data, zap.NewNop()).FindMagicPattern()
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+	hwOffset := uint64(magicOffset) + types.HWPointersOffsetFromMagic
+	if hwOffset+types.HWPointersSize > uint64(len(data)) {
+		return nil, pkgerrors.DataTooShortError(int(hwOffset+types.HWPointersSize), len(data), "HW pointers")
+	}
+	hwPointers := &types.FS4HWPointers{}
+	if err := hwPointers.Unmarshal(data[hwOffset : hwOffset+types.HWPointersSize]); err != nil {
+		return nil, merry.Wrap(err)
+	}
+	return hwPointers, nil
+}
+
+// EncryptImage encrypts the regions of layout in data in place with key,
+// reversing DecryptImage
+func EncryptImage(data, key []byte, layout *types.EncryptionLayout) error {
+	if layout.Scheme != EncryptionScheme || layout.SectorSize != EncryptionSectorSize {
+		return pkgerrors.NotSupportedError(fmt.Sprintf("%s encryption with 0x%x-byte sectors", layout.Scheme, layout.SectorSize))
+	}
+	c, err := newXTSCipher(key)
+	if err != nil {
+		return err
+	}
+	return c.cryptRegions(data, layout.Regions, false)
+}
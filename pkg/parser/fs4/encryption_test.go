@@ -0,0 +1,193 @@
+package fs4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+func TestXTSCipher(t *testing.T) {
+	// IEEE 1619-2007 Annex B XTS-AES test vectors 1, 2 and 10 (the first two
+	// blocks of its 512-byte data unit; each block only depends on its own
+	// plaintext and position)
+	tests := []struct {
+		name            string
+		key, plain, out string
+		unit            uint64
+	}{
+		{
+			name:  "vector 1",
+			key:   strings.Repeat("00", 32),
+			plain: strings.Repeat("00", 32),
+			out:   "917cf69ebd68b2ec9b9fe9a3eadda692cd43d2f59598ed858c02c2652fbf922e",
+		},
+		{
+			name:  "vector 2",
+			key:   strings.Repeat("11", 16) + strings.Repeat("22", 16),
+			plain: strings.Repeat("44", 32),
+			out:   "c454185e6a16936e39334038acef838bfb186fff7480adc4289382ecd6d394f0",
+			unit:  0x3333333333,
+		},
+		{
+			name: "vector 10",
+			key: "2718281828459045235360287471352662497757247093699959574966967627" +
+				"3141592653589793238462643383279502884197169399375105820974944592",
+			plain: "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f",
+			out:   "1c3b3a102f770386e4836c99e370cf9bea00803f5e482357a4ae12d414a3e63b",
+			unit:  0xff,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, _ := hex.DecodeString(tt.key)
+			plain, _ := hex.DecodeString(tt.plain)
+			want, _ := hex.DecodeString(tt.out)
+			c, err := newXTSCipher(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make([]byte, len(plain))
+			c.crypt(got, plain, tt.unit, false)
+			if !bytes.Equal(got, want) {
+				t.Fatalf("crypt() = %x, want %x", got, want)
+			}
+			c.crypt(got, got, tt.unit, true)
+			if !bytes.Equal(got, plain) {
+				t.Errorf("decrypt(encrypt(x)) = %x, want %x", got, plain)
+			}
+		})
+	}
+
+	if _, err := newXTSCipher(make([]byte, 16)); err == nil {
+		t.Error("newXTSCipher() accepted a 16-byte key")
+	}
+}
+
+// createEncryptableFS4Firmware extends the mock image with a section after
+// the ITOC and an IMAGE_INFO pointer into the area that must stay plaintext
+func createEncryptableFS4Firmware() []byte {
+	data := createMockFS4Firmware()
+	entry := &types.ITOCEntry{Type: 0x10, SizeDwords: 0x1800 / 4, FlashAddrDwords: 0x16000, CRCField: 1}
+	entryData, _ := entry.Marshal()
+	copy(data[0x15000+types.ITOCHeaderSize+types.ITOCEntrySize:], entryData)
+	copy(data[0x15000+types.ITOCHeaderSize+2*types.ITOCEntrySize:], createITOCEntry(0xff, 0, 0, 0, false))
+	for i := 0x16000; i < 0x17800; i++ {
+		data[i] = byte(i)
+	}
+	binary.BigEndian.PutUint32(data[0x10018+0x50:], 0x17000) // ImageInfoSectionPtr
+	return data
+}
+
+func TestDecryptImage(t *testing.T) {
+	key := bytes.Repeat([]byte{0x5a}, 64)
+	plain := createEncryptableFS4Firmware()
+	layout := &types.EncryptionLayout{
+		Scheme:     EncryptionScheme,
+		SectorSize: EncryptionSectorSize,
+		ITOCAddr:   0x15000,
+		Regions:    []types.EncryptedRegion{{Offset: 0x15000, Size: 0x2000}},
+	}
+
+	encrypted := bytes.Clone(plain)
+	if err := EncryptImage(encrypted, key, layout); err != nil {
+		t.Fatalf("EncryptImage() error = %v", err)
+	}
+	if binary.BigEndian.Uint32(encrypted[0x15000:]) == types.ITOCSignature {
+		t.Fatal("ITOC still readable after EncryptImage()")
+	}
+	if !bytes.Equal(encrypted[0x17000:0x18000], plain[0x17000:0x18000]) {
+		t.Error("EncryptImage() modified data outside the regions")
+	}
+
+	p := NewParser(newMockFirmwareReader(encrypted), zaptest.NewLogger(t))
+	if err := p.Parse(); err != nil {
+		t.Fatalf("Parse() of encrypted image error = %v", err)
+	}
+	if !p.IsEncrypted() {
+		t.Fatal("encrypted image not detected as encrypted")
+	}
+
+	decrypted, got, err := DecryptImage(encrypted, key)
+	if err != nil {
+		t.Fatalf("DecryptImage() error = %v", err)
+	}
+	if !bytes.Equal(decrypted, plain) {
+		t.Error("DecryptImage() did not restore the plaintext image")
+	}
+	if got.ITOCAddr != layout.ITOCAddr || len(got.Regions) != 1 || got.Regions[0] != layout.Regions[0] {
+		t.Errorf("DecryptImage() layout = %+v, want %+v", got, layout)
+	}
+
+	p = NewParser(newMockFirmwareReader(decrypted), zaptest.NewLogger(t))
+	if err := p.Parse(); err != nil {
+		t.Fatalf("Parse() of decrypted image error = %v", err)
+	}
+	if p.IsEncrypted() || len(p.GetSections()[0x10]) != 1 {
+		t.Errorf("decrypted image: encrypted = %v, sections = %v", p.IsEncrypted(), p.GetSections())
+	}
+}
+
+func TestDecryptImage_Errors(t *testing.T) {
+	key := bytes.Repeat([]byte{0x5a}, 64)
+	plain := createEncryptableFS4Firmware()
+
+	if _, _, err := DecryptImage(plain, key); !errors.Is(err, pkgerrors.ErrNotSupported) {
+		t.Errorf("DecryptImage() of a plaintext image error = %v, want ErrNotSupported", err)
+	}
+
+	encrypted := bytes.Clone(plain)
+	layout := &types.EncryptionLayout{
+		Scheme:     EncryptionScheme,
+		SectorSize: EncryptionSectorSize,
+		Regions:    []types.EncryptedRegion{{Offset: 0x15000, Size: 0x2000}},
+	}
+	if err := EncryptImage(encrypted, key, layout); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := DecryptImage(encrypted, bytes.Repeat([]byte{0xa5}, 64)); !errors.Is(err, pkgerrors.ErrInvalidData) {
+		t.Errorf("DecryptImage() with a wrong key error = %v, want ErrInvalidData", err)
+	}
+
+	layout.Regions[0].Offset = 0x15010
+	if err := EncryptImage(encrypted, key, layout); !errors.Is(err, pkgerrors.ErrInvalidOffset) {
+		t.Errorf("EncryptImage() of an unaligned region error = %v, want ErrInvalidOffset", err)
+	}
+}
+
+func TestEncryptionLayoutOf(t *testing.T) {
+	plain := createEncryptableFS4Firmware()
+	layout, err := EncryptionLayoutOf(plain, 0x15000)
+	if err != nil {
+		t.Fatalf("EncryptionLayoutOf() error = %v", err)
+	}
+	if want := []types.EncryptedRegion{{Offset: 0x15000, Size: 0x2000}}; !slices.Equal(layout.Regions, want) {
+		t.Errorf("Regions = %+v, want %+v", layout.Regions, want)
+	}
+
+	// Relocating the section past IMAGE_INFO extends the area around its sector
+	moved := append(bytes.Clone(plain), make([]byte, 0x2000)...)
+	entry := &types.ITOCEntry{Type: 0x10, SizeDwords: 0x1800 / 4, FlashAddrDwords: 0x18000, CRCField: 1}
+	entryData, _ := entry.Marshal()
+	copy(moved[0x15000+types.ITOCHeaderSize+types.ITOCEntrySize:], entryData)
+	layout, err = EncryptionLayoutOf(moved, 0x15000)
+	if err != nil {
+		t.Fatalf("EncryptionLayoutOf() of the relocated image error = %v", err)
+	}
+	want := []types.EncryptedRegion{{Offset: 0x15000, Size: 0x2000}, {Offset: 0x18000, Size: 0x2000}}
+	if !slices.Equal(layout.Regions, want) {
+		t.Errorf("Regions after relocation = %+v, want %+v", layout.Regions, want)
+	}
+
+	if _, err := EncryptionLayoutOf(plain[:0x17000], 0x15000); !errors.Is(err, pkgerrors.ErrDataTooShort) {
+		t.Errorf("EncryptionLayoutOf() of a truncated image error = %v, want ErrDataTooShort", err)
+	}
+}
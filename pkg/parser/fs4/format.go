@@ -0,0 +1,64 @@
+package fs4
+
+import (
+	"github.com/ansel1/merry/v2"
+	"go.uber.org/zap"
+
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/parser"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+// FS4 and FS5 share the image layout and are both handled by this package;
+// they are told apart by the image format version in the boot header.
+func init() {
+	parser.RegisterFormat(parser.Format{
+		Name:     "FS4",
+		Priority: 0,
+		Probe: func(reader *parser.FirmwareReader, logger *zap.Logger) bool {
+			magicOffset, err := reader.FindMagicPattern()
+			if err != nil {
+				return false
+			}
+			bootVersion, err := reader.ReadBootVersion(magicOffset)
+			return err == nil && !bootVersion.IsFS3()
+		},
+		New: func(reader *parser.FirmwareReader, logger *zap.Logger) (interfaces.FirmwareParser, error) {
+			format, err := DetectFormat(reader, logger)
+			if err != nil {
+				return nil, err
+			}
+			p := NewParser(reader, logger)
+			p.SetFormat(format)
+			return p, nil
+		},
+	})
+}
+
+// DetectFormat detects whether the firmware is FS4 or FS5 based on boot version
+// Mirrors mstflint logic at a high level
+func DetectFormat(reader *parser.FirmwareReader, logger *zap.Logger) (types.FirmwareFormat, error) {
+	magicOffset, err := reader.FindMagicPattern()
+	if err != nil {
+		return types.FormatUnknown, merry.Wrap(err)
+	}
+
+	// Read boot version structure at offset 0x10 from magic pattern
+	bootVersion, err := reader.ReadBootVersion(magicOffset)
+	if err != nil {
+		return types.FormatUnknown, err
+	}
+
+	switch bootVersion.ImageFormatVersion {
+	case types.ImageFormatVersionFS4:
+		logger.Debug("Detected FS4 format from boot version")
+		return types.FormatFS4, nil
+	case types.ImageFormatVersionFS5:
+		logger.Debug("Detected FS5 format from boot version")
+		return types.FormatFS5, nil
+	default:
+		logger.Warn("Unknown image format version", zap.Uint8("version", bootVersion.ImageFormatVersion))
+		// Fall back to FS4 for compatibility
+		return types.FormatFS4, nil
+	}
+}
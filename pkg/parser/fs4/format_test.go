@@ -0,0 +1,87 @@
+package fs4
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+
+	"github.com/Civil/mlx5fw-go/pkg/parser"
+	_ "github.com/Civil/mlx5fw-go/pkg/parser/fs3"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+func TestDetectFormat(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	tests := []struct {
+		name          string
+		formatVersion uint8
+		want          types.FirmwareFormat
+	}{
+		{name: "FS4", formatVersion: types.ImageFormatVersionFS4, want: types.FormatFS4},
+		{name: "FS5", formatVersion: types.ImageFormatVersionFS5, want: types.FormatFS5},
+		{name: "unknown falls back to FS4", formatVersion: 0x7F, want: types.FormatFS4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := createMockFS4Firmware()
+			data[0x10000+types.BootVersionOffset] = tt.formatVersion
+
+			got, err := DetectFormat(newMockFirmwareReader(data), logger)
+			if err != nil {
+				t.Fatalf("DetectFormat() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("DetectFormat() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpen(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	data := createMockFS4Firmware()
+	data[0x10000+types.BootVersionOffset] = types.ImageFormatVersionFS5
+
+	fwParser, err := parser.Open(newMockFirmwareReader(data), logger)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if _, ok := fwParser.(*Parser); !ok {
+		t.Fatalf("Open() returned %T, want *fs4.Parser", fwParser)
+	}
+	if fwParser.GetFormat() != types.FormatFS5 {
+		t.Errorf("GetFormat() = %v, want %v", fwParser.GetFormat(), types.FormatFS5)
+	}
+	if len(fwParser.GetSections()) == 0 {
+		t.Error("Open() returned a parser without sections")
+	}
+}
+
+func TestDetectFormat_FS3ImageFormatVersion(t *testing.T) {
+	// FS3 and FS4/FS5 images share the magic pattern; the boot version decides
+	tests := []struct {
+		formatVersion uint8
+		want          string
+	}{
+		{types.ImageFormatVersionFS4, "FS4"},
+		{types.ImageFormatVersionFS5, "FS4"},
+		{types.ImageFormatVersionFS3, "FS3"},
+		{types.ImageFormatVersionFS2, "FS3"},
+	}
+	for _, tt := range tests {
+		data := make([]byte, 0x100)
+		binary.BigEndian.PutUint64(data, types.MagicPattern)
+		data[types.BootVersionOffset] = tt.formatVersion
+
+		format, err := parser.DetectFormat(newMockFirmwareReader(data), zaptest.NewLogger(t))
+		if err != nil {
+			t.Fatalf("DetectFormat() of format version %d error = %v", tt.formatVersion, err)
+		}
+		if format.Name != tt.want {
+			t.Errorf("DetectFormat() of format version %d = %s, want %s", tt.formatVersion, format.Name, tt.want)
+		}
+	}
+}
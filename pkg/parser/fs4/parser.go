@@ -43,8 +43,13 @@ type Parser struct {
 
 	// Format type
 	format types.FirmwareFormat
+
+	// Conditions found while parsing
+	diagnostics types.Diagnostics
 }
 
+var _ interfaces.FirmwareParser = (*Parser)(nil)
+
 // NewParser creates a new FS4 parser
 func NewParser(reader *parser.FirmwareReader, logger *zap.Logger) *Parser {
 	return &Parser{
@@ -61,32 +66,22 @@ func NewParser(reader *parser.FirmwareReader, logger *zap.Logger) *Parser {
 
 // Parse parses the FS4 firmware
 func (p *Parser) Parse() error {
-    // If FS3 format, use dedicated parsing path
-    if p.format == types.FormatFS3 {
-        if err := p.parseFS3(); err != nil {
-            return merry.Wrap(err)
-        }
-        // Build metadata and return
-        p.buildMetadata()
-        return nil
-    }
-
-    // FS4/FS5 path: Find magic pattern
-    var err error
-    p.magicOffset, err = p.reader.FindMagicPattern()
-    if err != nil {
-        return merry.Wrap(err)
-    }
+	p.diagnostics = nil
+
+	// Find magic pattern
+	var err error
+	p.magicOffset, err = p.reader.FindMagicPattern()
+	if err != nil {
+		return merry.Wrap(err)
+	}
 
 	// Read and parse hardware pointers
 	if err := p.parseHWPointers(); err != nil {
 		return merry.Wrap(err)
 	}
 
-	// Skip boot2 verification for now
-	// if err := p.verifyBoot2(); err != nil {
-	// 	return merry.Wrap(err)
-	// }
+	// BOOT2 is checked with the other sections by VerifySectionNew and the
+	// verify command, so a bad CRC does not prevent parsing
 
 	// Check if firmware is encrypted
 	p.isEncrypted = false
@@ -96,34 +91,53 @@ func (p *Parser) Parse() error {
 		p.logger.Debug("ITOC parsing failed at standard location, trying alternate location", zap.Error(err))
 
 		// Try alternate location (standard + 0x1000) before assuming encryption
+		standardAddr := p.itocAddr
 		if err := p.tryAlternateITOCLocation(); err != nil {
 			// If both locations fail, then it might be encrypted
 			p.logger.Debug("No valid ITOC found at standard or alternate locations, checking for encrypted firmware")
 			p.isEncrypted = true
+			p.diagnostics.Add(types.SeverityInfo, types.DiagEncryptedImage, uint64(standardAddr), "ITOC",
+				"no readable ITOC at 0x%x or 0x%x, treating image as encrypted", standardAddr, standardAddr+0x1000)
 			// Try parsing as encrypted firmware
 			if err := p.parseEncryptedFirmware(); err != nil {
 				return merry.Wrap(err)
 			}
+		} else {
+			p.diagnostics.Add(types.SeverityWarning, types.DiagITOCAlternateLocation, uint64(p.itocAddr), "ITOC",
+				"ITOC not valid at HW pointer 0x%x, using alternate location 0x%x", standardAddr, p.itocAddr)
 		}
 	}
+	if !p.isEncrypted && !p.itocHeaderValid {
+		p.diagnostics.Add(types.SeverityWarning, types.DiagITOCHeaderCRC, uint64(p.itocAddr), "ITOC",
+			"ITOC header CRC mismatch")
+	}
 
 	// Always try to parse DTOC, even for encrypted firmware
 	// ConnectX-8 might have valid DTOC even without valid ITOC
 	if err := p.parseDTOC(); err != nil {
 		// Log but don't fail - DTOC might not be present
 		p.logger.Warn("Failed to parse DTOC", zap.Error(err))
+		p.diagnostics.Add(types.SeverityWarning, types.DiagDTOCParseFailed, uint64(p.dtocAddr), "DTOC",
+			"failed to parse DTOC: %v", err)
+	} else if !p.dtocHeaderValid {
+		p.diagnostics.Add(types.SeverityWarning, types.DiagDTOCHeaderCRC, uint64(p.dtocAddr), "DTOC",
+			"DTOC header CRC mismatch")
 	}
 
 	// Parse BOOT2 section (even for encrypted firmware)
 	if err := p.parseBoot2(); err != nil {
 		// Log but don't fail - BOOT2 might not be present
 		p.logger.Debug("No BOOT2 found", zap.Error(err))
+		p.diagnostics.Add(types.SeverityWarning, types.DiagBoot2Missing, uint64(p.boot2Addr), "BOOT2",
+			"no BOOT2 found: %v", err)
 	}
 
 	// Parse TOOLS_AREA section (even for encrypted firmware)
 	if err := p.parseToolsArea(); err != nil {
 		// Log but don't fail - TOOLS_AREA might not be present
 		p.logger.Debug("No TOOLS_AREA found", zap.Error(err))
+		p.diagnostics.Add(types.SeverityWarning, types.DiagToolsAreaMissing, uint64(p.hwPointers.ToolsPtr.Ptr), "TOOLS_AREA",
+			"no TOOLS_AREA found: %v", err)
 	}
 
 	if !p.isEncrypted {
@@ -131,6 +145,11 @@ func (p *Parser) Parse() error {
 		if err := p.parseHashesTable(); err != nil {
 			// Log but don't fail - HASHES_TABLE might not be present
 			p.logger.Debug("No HASHES_TABLE found", zap.Error(err))
+			// A missing pointer is normal for unsigned images; a bad table is not
+			if ptr := p.hwPointers.HashesTablePtr.Ptr; ptr != 0 && ptr != 0xffffffff {
+				p.diagnostics.Add(types.SeverityWarning, types.DiagHashesTableInvalid, uint64(ptr), "HASHES_TABLE",
+					"failed to parse HASHES_TABLE: %v", err)
+			}
 		}
 	}
 
@@ -168,17 +187,7 @@ func (p *Parser) parseHWPointers() error {
 	// For ConnectX-7: in TOCPtr (second pointer)
 	p.boot2Addr = p.hwPointers.Boot2Ptr.Ptr
 
-	// Check which pointer contains the ITOC
-	// Try TOCPtr first (ConnectX-7 style)
-	if p.hwPointers.TOCPtr.Ptr != 0 && p.hwPointers.TOCPtr.Ptr != 0x1000 {
-		p.itocAddr = p.hwPointers.TOCPtr.Ptr
-	} else if p.hwPointers.ToolsPtr.Ptr != 0 {
-		// Fall back to ToolsPtr (ConnectX-5/6 style)
-		p.itocAddr = p.hwPointers.ToolsPtr.Ptr
-	} else {
-		// Default to 0x5000 if no valid pointer found
-		p.itocAddr = 0x5000
-	}
+	p.itocAddr = itocAddress(p.hwPointers)
 
 	// Calculate DTOC address
 	// For ConnectX-7/8 with specific sizes, DTOC is at fixed locations
@@ -208,41 +217,19 @@ func (p *Parser) parseHWPointers() error {
 	return nil
 }
 
-// verifyBoot2 verifies the boot2 section
-func (p *Parser) verifyBoot2() error {
-	if p.boot2Addr == 0 {
-		return nil // No boot2
-	}
-
-	// Read boot2 header to get size
-	headerData, err := p.reader.ReadSection(int64(p.boot2Addr), 16)
-	if err != nil {
-		return err
-	}
-
-	// Boot2 size is at offset 4
-	size := binary.BigEndian.Uint32(headerData[4:8])
-
-	// Read full boot2
-	boot2Data, err := p.reader.ReadSection(int64(p.boot2Addr), size)
-	if err != nil {
-		return err
+// itocAddress returns the ITOC address the HW pointers point to.
+// The ITOC address can be in different pointers depending on firmware version.
+func itocAddress(hwPointers *types.FS4HWPointers) uint32 {
+	// Try TOCPtr first (ConnectX-7 style)
+	if hwPointers.TOCPtr.Ptr != 0 && hwPointers.TOCPtr.Ptr != 0x1000 {
+		return hwPointers.TOCPtr.Ptr
 	}
-
-	// Calculate CRC (excluding last 4 bytes which contain the CRC)
-	crcData := boot2Data[:len(boot2Data)-4]
-	expectedCRC := binary.BigEndian.Uint32(boot2Data[len(boot2Data)-4:])
-
-	// Boot2 uses hardware CRC
-	calculatedCRC := p.crc.CalculateHardwareCRC(crcData)
-
-	if uint32(calculatedCRC) != expectedCRC {
-		p.logger.Warn("Boot2 CRC mismatch",
-			zap.Uint32("expected", expectedCRC),
-			zap.Uint16("calculated", calculatedCRC))
+	if hwPointers.ToolsPtr.Ptr != 0 {
+		// Fall back to ToolsPtr (ConnectX-5/6 style)
+		return hwPointers.ToolsPtr.Ptr
 	}
-
-	return nil
+	// Default to 0x5000 if no valid pointer found
+	return 0x5000
 }
 
 // parseITOC parses the Image Table of Contents
@@ -1123,6 +1110,11 @@ func (p *Parser) IsEncrypted() bool {
 	return p.isEncrypted
 }
 
+// Diagnostics returns the conditions found by Parse
+func (p *Parser) Diagnostics() types.Diagnostics {
+	return p.diagnostics
+}
+
 // GetMagicOffset returns the offset of the magic pattern
 func (p *Parser) GetMagicOffset() uint32 {
 	return p.magicOffset
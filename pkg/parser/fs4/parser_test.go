@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"encoding/binary"
 	"os"
+	"path/filepath"
 	"testing"
 
+	"go.uber.org/zap"
 	"go.uber.org/zap/zaptest"
 
 	"github.com/Civil/mlx5fw-go/pkg/interfaces"
@@ -19,26 +21,7 @@ type mockFirmwareReader struct {
 }
 
 func newMockFirmwareReader(data []byte) *parser.FirmwareReader {
-	// Create a temporary file with the data
-	tmpfile, err := os.CreateTemp("", "mock_firmware_*.bin")
-	if err != nil {
-		panic(err)
-	}
-
-	if _, err := tmpfile.Write(data); err != nil {
-		tmpfile.Close()
-		os.Remove(tmpfile.Name())
-		panic(err)
-	}
-	tmpfile.Close()
-
-	reader, err := parser.NewFirmwareReader(tmpfile.Name(), zaptest.NewLogger(&testing.T{}))
-	if err != nil {
-		os.Remove(tmpfile.Name())
-		panic(err)
-	}
-
-	return reader
+	return parser.NewFirmwareReaderFromBytes(data, zaptest.NewLogger(&testing.T{}))
 }
 
 // createMockFS4Firmware creates a mock FS4 firmware structure
@@ -460,6 +443,45 @@ func TestParser_ParseEncryptedFirmware(t *testing.T) {
     // It is sufficient that parsing completes and alternate ITOC was handled.
 }
 
+func TestParser_Diagnostics(t *testing.T) {
+	reader := newMockFirmwareReader(createMockFS4Firmware())
+	defer reader.Close()
+
+	parser := NewParser(reader, zaptest.NewLogger(t))
+	if err := parser.Parse(); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	// The mock has no TOC header CRCs and an empty BOOT2
+	want := map[types.DiagnosticCode]uint64{
+		types.DiagITOCHeaderCRC: 0x15000,
+		types.DiagDTOCHeaderCRC: 0xff000,
+		types.DiagBoot2Missing:  0x12000,
+	}
+	diags := parser.Diagnostics()
+	if len(diags) != len(want) {
+		t.Fatalf("Diagnostics() = %v, want codes %v", diags, want)
+	}
+	for _, d := range diags {
+		offset, ok := want[d.Code]
+		if !ok {
+			t.Errorf("unexpected diagnostic %s", d)
+			continue
+		}
+		if d.Offset != offset || d.Severity != types.SeverityWarning {
+			t.Errorf("%s: offset 0x%x severity %s, want 0x%x warning", d.Code, d.Offset, d.Severity, offset)
+		}
+	}
+
+	// Parsing again must not accumulate diagnostics
+	if err := parser.Parse(); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(parser.Diagnostics()) != len(want) {
+		t.Errorf("Diagnostics() after second Parse() = %d entries, want %d", len(parser.Diagnostics()), len(want))
+	}
+}
+
 func TestParser_SpecificFileSizes(t *testing.T) {
 	logger := zaptest.NewLogger(t)
 
@@ -540,3 +562,30 @@ func BenchmarkParser_Parse(b *testing.B) {
 		os.Remove("mock_firmware_*.bin")
 	}
 }
+
+func BenchmarkParser_ParseFile(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "mock_firmware.bin")
+	if err := os.WriteFile(path, createMockFS4Firmware(), 0644); err != nil {
+		b.Fatal(err)
+	}
+
+	run := func(b *testing.B) {
+		logger := zap.NewNop()
+		for i := 0; i < b.N; i++ {
+			reader, err := parser.NewFirmwareReader(path, logger)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if err := NewParser(reader, logger).Parse(); err != nil {
+				b.Fatal(err)
+			}
+			reader.Close()
+		}
+	}
+
+	b.Run("mmap", run)
+	b.Run("read", func(b *testing.B) {
+		b.Setenv(parser.NoMmapEnv, "1")
+		run(b)
+	})
+}
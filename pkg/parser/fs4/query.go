@@ -9,6 +9,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/parser"
 	"github.com/Civil/mlx5fw-go/pkg/types"
 )
 
@@ -100,6 +101,7 @@ func (p *Parser) Query() (*interfaces.FirmwareInfo, error) {
 				info.PartNumber = imageInfo.GetPartNumberString()
 				info.Description = imageInfo.GetDescriptionString()
 				info.PSID = imageInfo.GetPSIDString()
+				info.ImageVSD = imageInfo.GetVSDString()
 
 				// Get security attributes using the parsed fields
 				attrs := p.parseSecurityAttributesFromImageInfo(&imageInfo)
@@ -208,7 +210,7 @@ func (p *Parser) Query() (*interfaces.FirmwareInfo, error) {
 			// Parse ROM info from ROM_CODE section
 			p.logger.Debug("Parsing ROM info",
 				zap.Int("data_len", len(section.GetRawData())))
-			romInfo := p.parseRomInfo(section.GetRawData())
+			romInfo := parser.ParseRomInfo(section.GetRawData(), p.logger)
 			p.logger.Debug("Parsed ROM info entries",
 				zap.Int("count", len(romInfo)))
 			if len(romInfo) > 0 {
@@ -428,157 +430,6 @@ func (p *Parser) parseSecurityAttributesFromImageInfo(imageInfo *types.ImageInfo
 	return strings.Join(attrs, ", ")
 }
 
-// parseRomInfo parses ROM information from ROM_CODE section data
-// Based on mstflint's FwOperations::RomInfo::GetExpRomVersion() in fw_ops.cpp:1894
-func (p *Parser) parseRomInfo(data []byte) []interfaces.RomInfo {
-	var romInfoList []interfaces.RomInfo
-
-	// mstflint searches for the magic string "mlxsign:" in the ROM data
-	// Reference: fw_ops.cpp:1896-1904
-	magicString := "mlxsign:"
-	magicLen := len(magicString)
-
-	p.logger.Debug("Searching for ROM signatures",
-		zap.String("magic", magicString),
-		zap.Int("data_len", len(data)))
-
-	// Search for magic string in ROM data
-	// Reference: fw_ops.cpp:1928-1942
-	for i := 0; i <= len(data)-magicLen; i++ {
-		if i+magicLen > len(data) {
-			break
-		}
-
-		// Check if we found the magic string
-		found := true
-		for j := 0; j < magicLen; j++ {
-			if data[i+j] != magicString[j] {
-				found = false
-				break
-			}
-		}
-
-		if found {
-			p.logger.Debug("Found ROM signature",
-				zap.Int("offset", i))
-			// Parse ROM info after mlxsign:
-			// Reference: fw_ops.cpp:1960-1961 - calls GetExpRomVerForOneRom
-			verOffset := i + magicLen
-			if romInfo := p.parseOneRomInfo(data, verOffset); romInfo != nil {
-				p.logger.Debug("Parsed ROM entry",
-					zap.String("type", romInfo.Type),
-					zap.String("version", romInfo.Version),
-					zap.String("cpu", romInfo.CPU))
-				romInfoList = append(romInfoList, *romInfo)
-			}
-
-			// Skip past this ROM info (ROM_INFO_SIZE = 12)
-			// Reference: fw_ops.cpp:1989 and mlxfwops_com.h:151
-			// Note: we add 11 because the for loop will increment i by 1
-			i += 11
-		}
-	}
-
-	p.logger.Debug("Finished parsing ROM info",
-		zap.Int("entries_found", len(romInfoList)))
-
-	return romInfoList
-}
-
-// parseOneRomInfo parses a single ROM entry after mlxsign:
-// Based on mstflint's FwOperations::RomInfo::GetExpRomVerForOneRom() in fw_ops.cpp:2044
-func (p *Parser) parseOneRomInfo(data []byte, verOffset int) *interfaces.RomInfo {
-	if verOffset+12 > len(data) {
-		return nil
-	}
-
-	// Get expansion ROM product ID and version info
-	// Reference: fw_ops.cpp:2065-2066
-	tmp := binary.LittleEndian.Uint32(data[verOffset:])
-	offs4 := binary.LittleEndian.Uint32(data[verOffset+4:])
-	offs8 := binary.LittleEndian.Uint32(data[verOffset+8:])
-
-	productID := uint16(tmp >> 16)
-	p.logger.Debug("ROM product ID",
-		zap.Uint16("product_id", productID),
-		zap.String("hex", fmt.Sprintf("0x%x", productID)))
-
-	// Parse ROM type from product ID
-	// Reference: fw_ops.cpp:2357 - expRomType2Str
-	romType := ""
-	switch productID {
-	case 0x10:
-		romType = "PXE"
-	case 0x11:
-		romType = "UEFI"
-	case 0x12:
-		romType = "CLP"
-	case 0x13:
-		romType = "NVMe"
-	case 0x14:
-		romType = "UEFI Virtio net"
-	case 0x15:
-		romType = "UEFI Virtio blk"
-	case 0xf:
-		romType = "CLP"
-	default:
-		// Unknown type, skip
-		return nil
-	}
-
-	// Build version string
-	// Reference: fw_ops.cpp:2072-2077
-	var version string
-	ver0 := tmp & 0xff
-	if productID != 0xf {
-		ver1 := (offs4 >> 16) & 0xff
-		ver2 := offs4 & 0xffff
-		version = fmt.Sprintf("%d.%d.%d", ver0, ver1, ver2)
-	} else {
-		// For type 0xf, version is handled differently
-		// Reference: fw_ops.cpp:2110-2117
-		if verOffset+0x10+4 <= len(data) {
-			strLen := int((data[verOffset+0xc+1]) & 0xff)
-			if verOffset+0x10+strLen <= len(data) && strLen > 0 {
-				version = string(data[verOffset+0x10 : verOffset+0x10+strLen])
-			}
-		}
-	}
-
-	// Get CPU architecture if product ID >= 0x10
-	// Reference: fw_ops.cpp:2084-2089
-	cpu := ""
-	if productID >= 0x10 && verOffset+12 <= len(data) {
-		suppCpuArch := (offs8 >> 8) & 0xf
-
-		// Parse CPU architecture
-		// Reference: mlxfwops_com.h enum ExpRomCpuArch
-		switch suppCpuArch {
-		case 0x0:
-			// ERC_UNSPECIFIED
-			cpu = ""
-		case 0x1:
-			// ERC_AMD64
-			cpu = "AMD64"
-		case 0x2:
-			// ERC_AARCH64
-			cpu = "AARCH64"
-		case 0x3:
-			// ERC_AMD64_AARCH64
-			cpu = "AMD64,AARCH64"
-		case 0x4:
-			// ERC_IA32
-			cpu = "IA32"
-		}
-	}
-
-	return &interfaces.RomInfo{
-		Type:    romType,
-		Version: version,
-		CPU:     cpu,
-	}
-}
-
 // checkDevFwFromSignature checks IMAGE_SIGNATURE sections for dev_fw flag
 // Based on mstflint's Fs3Operations::GetImgSigInfo
 func (p *Parser) checkDevFwFromSignature() bool {
@@ -0,0 +1,66 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+)
+
+// ImageView returns a reader that presents the failsafe image copy at imageStart the way
+// the copy at primaryStart is seen by the parser.
+//
+// Pointers inside an image copy are relative to its chunk, while the device data area
+// (DTOC, MFG_INFO, DEV_INFO) lives at fixed flash addresses shared by both copies.
+// Mirroring mstflint's address convertor, addresses below imageStart are shifted by
+// imageStart-primaryStart and everything above is passed through untouched. The view
+// keeps the size of the whole flash so the DTOC location is computed the same way.
+func (r *FirmwareReader) ImageView(primaryStart, imageStart uint32) *FirmwareReader {
+	return &FirmwareReader{
+		src: &shiftedSource{
+			src:    r,
+			size:   r.size,
+			window: int64(imageStart),
+			delta:  int64(imageStart) - int64(primaryStart),
+		},
+		name:        fmt.Sprintf("%s@0x%08x", r.name, imageStart),
+		size:        r.size,
+		compression: r.compression,
+		imageFormat: r.imageFormat,
+		logger:      r.logger,
+	}
+}
+
+// shiftedSource remaps [0, window) to [delta, window+delta) and passes the rest through
+type shiftedSource struct {
+	src    io.ReaderAt
+	size   int64
+	window int64
+	delta  int64
+}
+
+// ReadAt implements io.ReaderAt, splitting reads that cross the window boundary
+func (s *shiftedSource) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		if off >= s.size {
+			return n, io.EOF
+		}
+
+		phys := off
+		chunk := len(p)
+		if off < s.window {
+			phys = off + s.delta
+			if rem := s.window - off; int64(chunk) > rem {
+				chunk = int(rem)
+			}
+		}
+
+		m, err := s.src.ReadAt(p[:chunk], phys)
+		n += m
+		if err != nil {
+			return n, err
+		}
+		p = p[chunk:]
+		off += int64(chunk)
+	}
+	return n, nil
+}
@@ -0,0 +1,25 @@
+//go:build linux
+
+package parser
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/ansel1/merry/v2"
+)
+
+// mapFile maps the whole file into memory. The mapping is private and writable so
+// a caller that modifies a returned slice only touches its own copy-on-write page
+// and never the file on disk. The returned function releases the mapping.
+func mapFile(file *os.File, size int64) ([]byte, func() error, error) {
+	if size <= 0 || int64(int(size)) != size {
+		return nil, nil, merry.Errorf("cannot map %d bytes", size)
+	}
+
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(size), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_PRIVATE)
+	if err != nil {
+		return nil, nil, merry.Wrap(err)
+	}
+	return data, func() error { return merry.Wrap(syscall.Munmap(data)) }, nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package parser
+
+import (
+	"os"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+)
+
+// mapFile is only implemented on Linux; other platforms use the read path
+func mapFile(file *os.File, size int64) ([]byte, func() error, error) {
+	return nil, nil, pkgerrors.NotSupportedError("memory-mapped firmware reading")
+}
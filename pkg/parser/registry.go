@@ -0,0 +1,100 @@
+package parser
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/ansel1/merry/v2"
+	"go.uber.org/zap"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+)
+
+// Format describes a firmware format that Open can detect and parse.
+// Format packages register themselves from init(), so importing
+// pkg/parser/fs3 and pkg/parser/fs4 makes the built-in formats available.
+type Format struct {
+	// Name is a short identifier used in logs and errors (e.g. "FS4")
+	Name string
+
+	// Priority orders probing: formats with a lower value are probed first.
+	// Formats with weak signatures should use a higher value than formats
+	// whose signature they might also match.
+	Priority int
+
+	// Probe reports whether the image is in this format
+	Probe func(reader *FirmwareReader, logger *zap.Logger) bool
+
+	// New returns an unparsed parser for an image Probe accepted
+	New func(reader *FirmwareReader, logger *zap.Logger) (interfaces.FirmwareParser, error)
+}
+
+var (
+	formatsMu sync.RWMutex
+	formats   []Format
+)
+
+// RegisterFormat makes a firmware format available to Open and DetectFormat
+func RegisterFormat(format Format) {
+	if format.Name == "" || format.Probe == nil || format.New == nil {
+		panic("parser: RegisterFormat requires Name, Probe and New")
+	}
+
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	for _, f := range formats {
+		if f.Name == format.Name {
+			panic("parser: RegisterFormat called twice for " + format.Name)
+		}
+	}
+	formats = append(formats, format)
+	// Stable sort keeps registration order for equal priorities
+	sort.SliceStable(formats, func(i, j int) bool {
+		return formats[i].Priority < formats[j].Priority
+	})
+}
+
+// Formats returns the registered formats in probing order
+func Formats() []Format {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	return append([]Format(nil), formats...)
+}
+
+// DetectFormat returns the first registered format that accepts the image
+func DetectFormat(reader *FirmwareReader, logger *zap.Logger) (Format, error) {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	for _, f := range Formats() {
+		if f.Probe(reader, logger) {
+			logger.Debug("Detected firmware format", zap.String("format", f.Name))
+			return f, nil
+		}
+	}
+	return Format{}, merry.Wrap(pkgerrors.ErrInvalidMagic,
+		merry.WithMessagef("no registered firmware format matches %s", reader.Name()))
+}
+
+// Open detects the firmware format of the image and returns its parsed parser.
+// The reader stays owned by the caller and must outlive the returned parser.
+func Open(reader *FirmwareReader, logger *zap.Logger) (interfaces.FirmwareParser, error) {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	format, err := DetectFormat(reader, logger)
+	if err != nil {
+		return nil, merry.Prepend(err, "failed to detect firmware format")
+	}
+
+	fwParser, err := format.New(reader, logger)
+	if err != nil {
+		return nil, merry.Prepend(err, "failed to create "+format.Name+" parser")
+	}
+	if err := fwParser.Parse(); err != nil {
+		return nil, merry.Prepend(err, "failed to parse firmware")
+	}
+	return fwParser, nil
+}
@@ -0,0 +1,116 @@
+package parser
+
+import (
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+// stubParser is a minimal FirmwareParser for registry tests
+type stubParser struct {
+	interfaces.FirmwareParser
+	format types.FirmwareFormat
+	parsed bool
+}
+
+func (p *stubParser) Parse() error                    { p.parsed = true; return nil }
+func (p *stubParser) GetFormat() types.FirmwareFormat { return p.format }
+
+// withFormats replaces the registry for the duration of a test
+func withFormats(t *testing.T) {
+	t.Helper()
+	formatsMu.Lock()
+	saved := formats
+	formats = nil
+	formatsMu.Unlock()
+	t.Cleanup(func() {
+		formatsMu.Lock()
+		formats = saved
+		formatsMu.Unlock()
+	})
+}
+
+func stubFormat(name string, priority int, format types.FirmwareFormat, match bool) Format {
+	return Format{
+		Name:     name,
+		Priority: priority,
+		Probe:    func(*FirmwareReader, *zap.Logger) bool { return match },
+		New: func(*FirmwareReader, *zap.Logger) (interfaces.FirmwareParser, error) {
+			return &stubParser{format: format}, nil
+		},
+	}
+}
+
+func TestRegisterFormat_Priority(t *testing.T) {
+	withFormats(t)
+	RegisterFormat(stubFormat("weak", 10, types.FormatFS3, true))
+	RegisterFormat(stubFormat("strong", 0, types.FormatFS4, true))
+	RegisterFormat(stubFormat("strong2", 0, types.FormatFS5, true))
+
+	var names []string
+	for _, f := range Formats() {
+		names = append(names, f.Name)
+	}
+	want := []string{"strong", "strong2", "weak"}
+	if len(names) != len(want) {
+		t.Fatalf("Formats() = %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("Formats() = %v, want %v", names, want)
+		}
+	}
+}
+
+func TestRegisterFormat_Duplicate(t *testing.T) {
+	withFormats(t)
+	RegisterFormat(stubFormat("dup", 0, types.FormatFS4, true))
+
+	defer func() {
+		if recover() == nil {
+			t.Error("RegisterFormat() did not panic on a duplicate name")
+		}
+	}()
+	RegisterFormat(stubFormat("dup", 1, types.FormatFS4, true))
+}
+
+func TestOpen(t *testing.T) {
+	withFormats(t)
+	logger := zaptest.NewLogger(t)
+	reader := NewFirmwareReaderFromBytes(make([]byte, 0x100), logger)
+
+	RegisterFormat(stubFormat("no-match", 0, types.FormatFS4, false))
+	RegisterFormat(stubFormat("match", 5, types.FormatFS3, true))
+
+	fwParser, err := Open(reader, logger)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	stub, ok := fwParser.(*stubParser)
+	if !ok {
+		t.Fatalf("Open() returned %T, want *stubParser", fwParser)
+	}
+	if stub.format != types.FormatFS3 {
+		t.Errorf("Open() picked format %v, want %v", stub.format, types.FormatFS3)
+	}
+	if !stub.parsed {
+		t.Error("Open() did not parse the firmware")
+	}
+}
+
+func TestOpen_NoMatch(t *testing.T) {
+	withFormats(t)
+	reader := NewFirmwareReaderFromBytes(make([]byte, 0x100), zap.NewNop())
+	RegisterFormat(stubFormat("no-match", 0, types.FormatFS4, false))
+
+	_, err := Open(reader, nil)
+	if !errors.Is(err, pkgerrors.ErrInvalidMagic) {
+		t.Errorf("Open() error = %v, want ErrInvalidMagic", err)
+	}
+}
@@ -12,7 +12,9 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/Civil/mlx5fw-go/pkg/annotations"
+	"github.com/Civil/mlx5fw-go/pkg/imgfmt"
 	"github.com/Civil/mlx5fw-go/pkg/parser"
+	"github.com/Civil/mlx5fw-go/pkg/parser/fs4"
 	"github.com/Civil/mlx5fw-go/pkg/types"
 	"github.com/Civil/mlx5fw-go/pkg/types/extracted"
 )
@@ -23,6 +25,10 @@ type Options struct {
 	OutputFile string
 	VerifyCRC  bool
 	BinaryOnly bool // Force binary-only mode, ignore JSON files
+	// OutputFormat selects raw binary (default), Intel HEX or S-record output
+	OutputFormat imgfmt.Format
+	// Key re-encrypts images that were decrypted on extraction
+	Key []byte
 }
 
 // Reassembler handles firmware reassembly
@@ -44,7 +50,8 @@ func (r *Reassembler) Reassemble() error {
 	r.logger.Info("Starting reassemble command",
 		zap.String("inputDir", r.options.InputDir),
 		zap.String("outputFile", r.options.OutputFile),
-		zap.Bool("verifyCRC", r.options.VerifyCRC))
+		zap.Bool("verifyCRC", r.options.VerifyCRC),
+		zap.String("outputFormat", string(r.options.OutputFormat)))
 
 	// Load metadata
 	metadataPath := filepath.Join(r.options.InputDir, "firmware_metadata.json")
@@ -221,7 +228,7 @@ func (r *Reassembler) getSectionFileName(section extracted.SectionMetadata, file
 	return fmt.Sprintf("%s_0x%08x.bin", fileName, section.Offset())
 }
 
-func (r *Reassembler) reassembleFirmware(output io.WriteSeeker, metadata *extracted.FirmwareMetadata) error {
+func (r *Reassembler) reassembleFirmware(output io.Writer, metadata *extracted.FirmwareMetadata) error {
 	// Initialize CRC calculator
 	crcCalc := parser.NewCRCCalculator()
 
@@ -363,8 +370,26 @@ func (r *Reassembler) reassembleFirmware(output io.WriteSeeker, metadata *extrac
         r.logger.Debug("No HW pointers present; skipping CRC update")
     }
 
+	// Restore the encryption of images that were decrypted on extraction
+	if metadata.Encryption != nil {
+		if len(r.options.Key) == 0 {
+			return fmt.Errorf("image was decrypted on extraction, a key is required to encrypt it again")
+		}
+		// Sections edited after extraction may extend the area, so recompute it
+		layout, err := fs4.EncryptionLayoutOf(firmwareData, metadata.Encryption.ITOCAddr)
+		if err != nil {
+			return fmt.Errorf("failed to compute the encrypted area: %w", err)
+		}
+		if err := fs4.EncryptImage(firmwareData, r.options.Key, layout); err != nil {
+			return fmt.Errorf("failed to encrypt firmware: %w", err)
+		}
+		r.logger.Info("Encrypted firmware",
+			zap.String("scheme", layout.Scheme),
+			zap.Int("regions", len(layout.Regions)))
+	}
+
 	// Write the complete firmware
-	if _, err := output.Write(firmwareData); err != nil {
+	if err := imgfmt.Encode(r.options.OutputFormat, output, firmwareData); err != nil {
 		return fmt.Errorf("failed to write firmware data: %w", err)
 	}
 
@@ -0,0 +1,340 @@
+// Package rom edits the chain of PCI expansion ROM images stored in the
+// ROM_CODE section. Each image starts with the 0x55AA signature and points to
+// a PCIR data structure holding its length and the last-image indicator. A
+// ROM is the run of images starting at one that carries an mlxsign record,
+// which names its type (PXE, UEFI, ...), version and CPU architectures.
+package rom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+
+	"github.com/ansel1/merry/v2"
+	"go.uber.org/zap"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+	"github.com/Civil/mlx5fw-go/pkg/parser"
+)
+
+// PCI expansion ROM header and PCIR data structure offsets, from the PCI
+// Firmware Specification
+const (
+	headerSize            = 0x1A
+	pcirPointerOffset     = 0x18
+	pcirSize              = 0x18
+	pcirImageLengthOffset = 0x10
+	pcirCodeTypeOffset    = 0x14
+	pcirIndicatorOffset   = 0x15
+
+	// efiMachineTypeOffset is the EfiMachineType of an EFI image header
+	efiMachineTypeOffset = 0x0A
+
+	// checksumOffset is where iPXE-based (FlexBoot) legacy images keep the
+	// byte that makes the image sum to zero
+	checksumOffset = 0x06
+
+	// ImageUnit is the unit of the PCIR image length
+	ImageUnit = 512
+
+	lastImageFlag = 0x80
+	codeTypeX86   = 0x00
+	codeTypeEFI   = 0x03
+)
+
+var (
+	romSignature  = []byte{0x55, 0xAA}
+	pcirSignature = []byte("PCIR")
+)
+
+// efiMachines maps the mlxsign CPU names to EFI machine types
+var efiMachines = map[string]uint16{
+	"AMD64":   0x8664,
+	"AARCH64": 0xAA64,
+	"IA32":    0x014C,
+}
+
+// image is one PCI expansion ROM image
+type image struct {
+	data     []byte
+	pcir     int
+	codeType uint8
+}
+
+// ROM is one expansion ROM of the chain
+type ROM struct {
+	Type    string `json:"type"`
+	Version string `json:"version"`
+	CPU     string `json:"cpu"`
+	// Offset and Size locate the ROM in the chain it was parsed from
+	Offset int `json:"offset"`
+	Size   int `json:"size"`
+	Images int `json:"images"`
+
+	images []image
+}
+
+// matches reports whether r has type romType and, when cpu is set, CPU cpu
+func (r *ROM) matches(romType, cpu string) bool {
+	return strings.EqualFold(r.Type, romType) && (cpu == "" || strings.EqualFold(r.CPU, cpu))
+}
+
+// Parse walks the image chain at the start of data up to the image flagged
+// as the last one; anything after it is padding
+func Parse(data []byte) ([]ROM, error) {
+	var roms []ROM
+	for offset := 0; ; {
+		img, err := parseImage(data, offset)
+		if err != nil {
+			return nil, err
+		}
+
+		info := parser.ParseRomInfo(img.data, zap.NewNop())
+		if len(info) > 0 || len(roms) == 0 {
+			r := ROM{Offset: offset}
+			if len(info) > 0 {
+				r.Type, r.Version, r.CPU = info[0].Type, info[0].Version, info[0].CPU
+			}
+			roms = append(roms, r)
+		}
+		last := &roms[len(roms)-1]
+		last.images = append(last.images, img)
+		last.Images++
+		last.Size += len(img.data)
+
+		offset += len(img.data)
+		if img.data[img.pcir+pcirIndicatorOffset]&lastImageFlag != 0 {
+			return roms, nil
+		}
+		if offset >= len(data) {
+			return nil, merry.Wrap(pkgerrors.ErrInvalidData,
+				merry.WithMessagef("ROM image chain ends at 0x%x without a last-image indicator", offset))
+		}
+	}
+}
+
+// parseImage checks the headers of the image at offset and returns it
+func parseImage(data []byte, offset int) (image, error) {
+	if offset+headerSize > len(data) {
+		return image{}, pkgerrors.DataTooShortError(offset+headerSize, len(data), "ROM image header")
+	}
+	if !bytes.Equal(data[offset:offset+2], romSignature) {
+		return image{}, merry.Wrap(pkgerrors.ErrInvalidData,
+			merry.WithMessagef("no 0x55AA ROM signature at 0x%x", offset))
+	}
+	pcir := int(binary.LittleEndian.Uint16(data[offset+pcirPointerOffset:]))
+	if offset+pcir+pcirSize > len(data) {
+		return image{}, pkgerrors.DataTooShortError(offset+pcir+pcirSize, len(data), "PCIR data structure")
+	}
+	if !bytes.Equal(data[offset+pcir:offset+pcir+4], pcirSignature) {
+		return image{}, merry.Wrap(pkgerrors.ErrInvalidData,
+			merry.WithMessagef("no PCIR signature at 0x%x", offset+pcir))
+	}
+	size := int(binary.LittleEndian.Uint16(data[offset+pcir+pcirImageLengthOffset:])) * ImageUnit
+	if size < pcir+pcirSize {
+		return image{}, merry.Wrap(pkgerrors.ErrInvalidData,
+			merry.WithMessagef("ROM image at 0x%x is shorter than its headers", offset))
+	}
+	if offset+size > len(data) {
+		return image{}, pkgerrors.DataTooShortError(offset+size, len(data), "ROM image")
+	}
+	return image{
+		data:     data[offset : offset+size],
+		pcir:     pcir,
+		codeType: data[offset+pcir+pcirCodeTypeOffset],
+	}, nil
+}
+
+// Validate parses a ROM file to be inserted and checks every ROM against its
+// mlxsign record: the type must be known and UEFI ROMs must contain an EFI
+// image for each CPU the record lists
+func Validate(data []byte) ([]ROM, error) {
+	roms, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range roms {
+		if r.Type == "" {
+			return nil, merry.Wrap(pkgerrors.ErrInvalidData,
+				merry.WithMessagef("ROM at 0x%x has no mlxsign record of a known type", r.Offset))
+		}
+		if !strings.HasPrefix(r.Type, "UEFI") {
+			continue
+		}
+
+		machines := make(map[uint16]bool)
+		for _, img := range r.images {
+			if img.codeType == codeTypeEFI {
+				machines[binary.LittleEndian.Uint16(img.data[efiMachineTypeOffset:])] = true
+			}
+		}
+		if len(machines) == 0 {
+			return nil, merry.Wrap(pkgerrors.ErrInvalidData,
+				merry.WithMessagef("%s ROM at 0x%x has no EFI image", r.Type, r.Offset))
+		}
+		for _, cpu := range strings.Split(r.CPU, ",") {
+			if machine, ok := efiMachines[cpu]; ok && !machines[machine] {
+				return nil, merry.Wrap(pkgerrors.ErrInvalidData,
+					merry.WithMessagef("%s ROM at 0x%x has no EFI image for %s", r.Type, r.Offset, cpu))
+			}
+		}
+	}
+	return roms, nil
+}
+
+// Build concatenates the ROMs into a chain, flagging only its final image as
+// the last one
+func Build(roms []ROM) []byte {
+	var chain []byte
+	for i, r := range roms {
+		for j, img := range r.images {
+			start := len(chain)
+			chain = append(chain, img.data...)
+			setLastImage(chain[start:], img, i == len(roms)-1 && j == len(r.images)-1)
+		}
+	}
+	return chain
+}
+
+// setLastImage sets or clears the last-image indicator of the copy dst of img.
+// Legacy images that summed to zero are kept that way through the checksum
+// byte.
+func setLastImage(dst []byte, img image, last bool) {
+	indicator := dst[img.pcir+pcirIndicatorOffset]
+	updated := indicator &^ lastImageFlag
+	if last {
+		updated |= lastImageFlag
+	}
+	if updated == indicator {
+		return
+	}
+	if img.codeType == codeTypeX86 && sum(dst) == 0 {
+		dst[checksumOffset] += indicator - updated
+	}
+	dst[img.pcir+pcirIndicatorOffset] = updated
+}
+
+// sum returns the 8-bit sum of data
+func sum(data []byte) byte {
+	var s byte
+	for _, b := range data {
+		s += b
+	}
+	return s
+}
+
+// Add appends the ROMs of file to chain. A ROM of the same type and CPU as one
+// already present is an error; Replace swaps those. Like Replace and Remove,
+// it keeps the padding after the last image, which ends with the in-section
+// CRC of ROM_CODE.
+func Add(chain, file []byte) ([]byte, error) {
+	roms, err := Parse(chain)
+	if err != nil {
+		return nil, err
+	}
+	padding := tail(chain, roms)
+	added, err := Validate(file)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range added {
+		if find(roms, a.Type, a.CPU) >= 0 {
+			return nil, pkgerrors.InvalidParameterError("ROM",
+				describe(&a)+" is already present, replace it instead")
+		}
+	}
+	return append(Build(append(roms, added...)), padding...), nil
+}
+
+// Replace swaps each ROM of chain with the ROM of file of the same type and
+// CPU, keeping its position. Every ROM of file must replace one.
+func Replace(chain, file []byte) ([]byte, error) {
+	roms, err := Parse(chain)
+	if err != nil {
+		return nil, err
+	}
+	padding := tail(chain, roms)
+	replacements, err := Validate(file)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range replacements {
+		i := find(roms, r.Type, r.CPU)
+		if i < 0 {
+			return nil, pkgerrors.InvalidParameterError("ROM",
+				"the image has no "+describe(&r)+" to replace, add it instead")
+		}
+		roms[i] = r
+	}
+	return append(Build(roms), padding...), nil
+}
+
+// Remove drops the ROMs of type romType and, when cpu is set, CPU cpu from
+// chain. Removing every ROM is an error, since the ROM_CODE section itself
+// has to stay.
+func Remove(chain []byte, romType, cpu string) ([]byte, error) {
+	roms, err := Parse(chain)
+	if err != nil {
+		return nil, err
+	}
+	padding := tail(chain, roms)
+	var kept []ROM
+	for _, r := range roms {
+		if !r.matches(romType, cpu) {
+			kept = append(kept, r)
+		}
+	}
+	if len(kept) == len(roms) {
+		return nil, merry.Wrap(pkgerrors.ErrSectionNotFound, merry.WithMessagef("no %s ROM in the chain", strings.TrimSpace(romType+" "+cpu)))
+	}
+	if len(kept) == 0 {
+		return nil, pkgerrors.NotSupportedError("removing the last ROM of the ROM_CODE section")
+	}
+	return append(Build(kept), padding...), nil
+}
+
+// Extract returns the ROMs of type romType and, when cpu is set, CPU cpu as a
+// chain of their own; an empty romType selects the whole chain
+func Extract(chain []byte, romType, cpu string) ([]byte, error) {
+	roms, err := Parse(chain)
+	if err != nil {
+		return nil, err
+	}
+	if romType == "" {
+		return Build(roms), nil
+	}
+	var selected []ROM
+	for _, r := range roms {
+		if r.matches(romType, cpu) {
+			selected = append(selected, r)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, merry.Wrap(pkgerrors.ErrSectionNotFound, merry.WithMessagef("no %s ROM in the chain", strings.TrimSpace(romType+" "+cpu)))
+	}
+	return Build(selected), nil
+}
+
+// tail returns the part of chain after its last image, given its ROMs
+func tail(chain []byte, roms []ROM) []byte {
+	last := roms[len(roms)-1]
+	return chain[last.Offset+last.Size:]
+}
+
+// find returns the index of the ROM with the given type and CPU, or -1
+func find(roms []ROM, romType, cpu string) int {
+	for i := range roms {
+		if strings.EqualFold(roms[i].Type, romType) && strings.EqualFold(roms[i].CPU, cpu) {
+			return i
+		}
+	}
+	return -1
+}
+
+// describe names a ROM for error messages
+func describe(r *ROM) string {
+	if r.CPU == "" {
+		return r.Type + " ROM"
+	}
+	return r.Type + " ROM for " + r.CPU
+}
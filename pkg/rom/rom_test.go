@@ -0,0 +1,221 @@
+package rom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+)
+
+// mlxsign product IDs and CPU codes used by the test ROMs
+const (
+	productPXE  = 0x10
+	productUEFI = 0x11
+	cpuAMD64    = 0x1
+	cpuBoth     = 0x3
+)
+
+// testImage describes one image of a test ROM
+type testImage struct {
+	codeType uint8
+	machine  uint16
+	// product is the mlxsign product ID; zero leaves the record out
+	product uint16
+	cpu     uint32
+	version uint32
+}
+
+// makeImage builds a one-unit image whose legacy checksum is valid
+func makeImage(ti testImage) []byte {
+	img := make([]byte, ImageUnit)
+	copy(img, romSignature)
+	const pcir = 0x40
+	binary.LittleEndian.PutUint16(img[pcirPointerOffset:], pcir)
+	copy(img[pcir:], pcirSignature)
+	binary.LittleEndian.PutUint16(img[pcir+pcirImageLengthOffset:], 1)
+	img[pcir+pcirCodeTypeOffset] = ti.codeType
+	img[pcir+pcirIndicatorOffset] = lastImageFlag
+	if ti.codeType == codeTypeEFI {
+		binary.LittleEndian.PutUint16(img[efiMachineTypeOffset:], ti.machine)
+	}
+	if ti.product != 0 {
+		record := img[0x100:]
+		copy(record, "mlxsign:")
+		binary.LittleEndian.PutUint32(record[8:], uint32(ti.product)<<16|ti.version)
+		binary.LittleEndian.PutUint32(record[12:], 1<<16|2)
+		binary.LittleEndian.PutUint32(record[16:], ti.cpu<<8)
+	}
+	if ti.codeType == codeTypeX86 {
+		img[checksumOffset] = -sum(img)
+	}
+	return img
+}
+
+// makeChain concatenates images, clearing the last-image indicator of all but
+// the final one the way a ROM build does
+func makeChain(images ...testImage) []byte {
+	var chain []byte
+	for i, ti := range images {
+		img := makeImage(ti)
+		if i < len(images)-1 {
+			img[0x40+pcirIndicatorOffset] = 0
+			if ti.codeType == codeTypeX86 {
+				img[checksumOffset] += lastImageFlag
+			}
+		}
+		chain = append(chain, img...)
+	}
+	return chain
+}
+
+var (
+	pxeImage     = testImage{codeType: codeTypeX86, product: productPXE, cpu: cpuAMD64, version: 3}
+	uefiX64Image = testImage{codeType: codeTypeEFI, machine: 0x8664, product: productUEFI, cpu: cpuBoth, version: 14}
+	uefiARMImage = testImage{codeType: codeTypeEFI, machine: 0xAA64}
+)
+
+// checkChain parses chain and compares the ROM types and versions
+func checkChain(t *testing.T, chain []byte, want ...string) []ROM {
+	t.Helper()
+	roms, err := Parse(chain)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	var got []string
+	for _, r := range roms {
+		got = append(got, r.Type+" "+r.Version)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Parse() ROMs = %v, want %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("Parse() ROMs = %v, want %v", got, want)
+		}
+	}
+
+	// Only the final image is flagged and legacy images still sum to zero
+	for i, r := range roms {
+		for j, img := range r.images {
+			last := i == len(roms)-1 && j == len(r.images)-1
+			if (img.data[img.pcir+pcirIndicatorOffset]&lastImageFlag != 0) != last {
+				t.Errorf("%s image %d last-image indicator = %v, want %v", r.Type, j, !last, last)
+			}
+			if img.codeType == codeTypeX86 && sum(img.data) != 0 {
+				t.Errorf("%s image %d checksum is broken", r.Type, j)
+			}
+		}
+	}
+	return roms
+}
+
+func TestParse(t *testing.T) {
+	chain := append(makeChain(pxeImage, uefiX64Image, uefiARMImage), bytes.Repeat([]byte{0xFF}, 0x100)...)
+	roms := checkChain(t, chain, "PXE 3.1.2", "UEFI 14.1.2")
+	if roms[1].CPU != "AMD64,AARCH64" || roms[1].Images != 2 || roms[1].Offset != ImageUnit || roms[1].Size != 2*ImageUnit {
+		t.Errorf("Parse() UEFI ROM = %+v", roms[1])
+	}
+}
+
+func TestEdit(t *testing.T) {
+	pxe := makeChain(pxeImage)
+	uefi := makeChain(uefiX64Image, uefiARMImage)
+
+	chain, err := Add(pxe, uefi)
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	checkChain(t, chain, "PXE 3.1.2", "UEFI 14.1.2")
+
+	newPXE := pxeImage
+	newPXE.version = 4
+	chain, err = Replace(chain, makeChain(newPXE))
+	if err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+	checkChain(t, chain, "PXE 4.1.2", "UEFI 14.1.2")
+
+	extracted, err := Extract(chain, "uefi", "")
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	if !bytes.Equal(extracted, uefi) {
+		t.Error("Extract() of the UEFI ROM differs from the added file")
+	}
+
+	chain, err = Remove(chain, "UEFI", "")
+	if err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	checkChain(t, chain, "PXE 4.1.2")
+}
+
+func TestEdit_KeepsPadding(t *testing.T) {
+	// ROM_CODE sections with an in-section CRC keep it in their last dword
+	padding := append(bytes.Repeat([]byte{0xFF}, 0xFC), 0xDE, 0xAD, 0xBE, 0xEF)
+	chain := append(makeChain(pxeImage, uefiX64Image, uefiARMImage), padding...)
+	newPXE := pxeImage
+	newPXE.version = 4
+
+	tests := []struct {
+		name string
+		edit func() ([]byte, error)
+		want []string
+	}{
+		{"add", func() ([]byte, error) {
+			return Add(append(makeChain(pxeImage), padding...), makeChain(uefiX64Image, uefiARMImage))
+		}, []string{"PXE 3.1.2", "UEFI 14.1.2"}},
+		{"replace", func() ([]byte, error) { return Replace(chain, makeChain(newPXE)) }, []string{"PXE 4.1.2", "UEFI 14.1.2"}},
+		{"remove", func() ([]byte, error) { return Remove(chain, "UEFI", "") }, []string{"PXE 3.1.2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.edit()
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			roms := checkChain(t, got, tt.want...)
+			last := roms[len(roms)-1]
+			if !bytes.Equal(got[last.Offset+last.Size:], padding) {
+				t.Errorf("padding after the chain = %x, want %x", got[last.Offset+last.Size:], padding)
+			}
+		})
+	}
+}
+
+func TestEdit_Errors(t *testing.T) {
+	pxe := makeChain(pxeImage)
+	uefiARMOnly := makeChain(uefiX64Image)
+	binary.LittleEndian.PutUint16(uefiARMOnly[efiMachineTypeOffset:], 0xAA64)
+	noLast := makeChain(pxeImage)
+	noLast[0x40+pcirIndicatorOffset] = 0
+	noSign := makeChain(pxeImage)
+	noSign[0] = 0
+
+	tests := []struct {
+		name string
+		edit func() ([]byte, error)
+		want error
+	}{
+		{"duplicate add", func() ([]byte, error) { return Add(pxe, pxe) }, pkgerrors.ErrInvalidParameter},
+		{"replace without match", func() ([]byte, error) { return Replace(pxe, makeChain(uefiX64Image, uefiARMImage)) },
+			pkgerrors.ErrInvalidParameter},
+		{"no mlxsign record", func() ([]byte, error) { return Add(pxe, makeChain(uefiARMImage)) }, pkgerrors.ErrInvalidData},
+		{"missing CPU image", func() ([]byte, error) { return Add(pxe, uefiARMOnly) }, pkgerrors.ErrInvalidData},
+		{"no last-image indicator", func() ([]byte, error) { return Add(pxe, noLast) }, pkgerrors.ErrInvalidData},
+		{"no ROM signature", func() ([]byte, error) { return Add(pxe, noSign) }, pkgerrors.ErrInvalidData},
+		{"truncated image", func() ([]byte, error) { return Add(pxe, pxe[:0x100]) }, pkgerrors.ErrDataTooShort},
+		{"remove missing", func() ([]byte, error) { return Remove(pxe, "UEFI", "") }, pkgerrors.ErrSectionNotFound},
+		{"remove last ROM", func() ([]byte, error) { return Remove(pxe, "PXE", "AMD64") }, pkgerrors.ErrNotSupported},
+		{"extract missing", func() ([]byte, error) { return Extract(pxe, "PXE", "AARCH64") }, pkgerrors.ErrSectionNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.edit(); !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
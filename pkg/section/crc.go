@@ -0,0 +1,101 @@
+package section
+
+import (
+	"encoding/binary"
+
+	"github.com/ansel1/merry/v2"
+	"go.uber.org/zap"
+
+	"github.com/Civil/mlx5fw-go/pkg/errors"
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/parser"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+// UpdateSectionCRC recomputes the CRC protecting a section whose contents were
+// changed in place in data, without moving or resizing it.
+//
+// CRC_IN_SECTION trailers follow types.GetInSectionCRCPolicy, so sections whose
+// trailer is a blank sentinel keep it. For CRC_IN_ITOC_ENTRY sections the
+// section CRC of the ITOC (or, for device data, DTOC) entry is rewritten
+// together with the entry's own CRC.
+func UpdateSectionCRC(data []byte, fwParser interfaces.FirmwareParser, section interfaces.SectionInterface) error {
+	end := section.Offset() + uint64(section.Size())
+	if end > uint64(len(data)) {
+		return errors.DataTooShortError(int(end), len(data), types.GetSectionTypeName(section.Type()))
+	}
+
+	crcCalc := parser.NewCRCCalculator()
+	switch section.CRCType() {
+	case types.CRCNone:
+		return nil
+
+	case types.CRCInSection:
+		size := section.Size()
+		if size < 8 {
+			return errors.DataTooShortError(8, int(size), "section for CRC")
+		}
+		sectionData := data[section.Offset():end]
+		crcDwords := int(size/DwordSize) - 1
+
+		var newCRC uint16
+		switch types.GetInSectionCRCPolicy(section.Type()) {
+		case types.InSectionCRCPolicyBlank:
+			return nil
+		case types.InSectionCRCPolicyHardware:
+			newCRC = crcCalc.CalculateHardwareCRC(sectionData[:crcDwords*DwordSize])
+		default:
+			newCRC = crcCalc.CalculateImageCRC(sectionData, crcDwords)
+		}
+
+		// CRC lives in the lower 16 bits of the last dword
+		trailer := sectionData[crcDwords*DwordSize:]
+		binary.BigEndian.PutUint32(trailer, binary.BigEndian.Uint32(trailer)&0xFFFF0000|uint32(newCRC))
+		return nil
+
+	case types.CRCInITOCEntry:
+		return updateTOCEntryCRC(data, fwParser, section)
+
+	default:
+		return merry.Errorf("unknown CRC type: %d", section.CRCType())
+	}
+}
+
+// updateTOCEntryCRC rewrites the section CRC and the entry CRC of the TOC
+// entry that describes section
+func updateTOCEntryCRC(data []byte, fwParser interfaces.FirmwareParser, section interfaces.SectionInterface) error {
+	tocAddr := fwParser.GetITOCAddress()
+	if section.IsDeviceData() {
+		tocAddr = fwParser.GetDTOCAddress()
+	}
+
+	entries, err := parser.NewTOCReader(zap.NewNop()).ReadTOCRawEntries(data, tocAddr, section.IsDeviceData())
+	if err != nil {
+		return merry.Wrap(err)
+	}
+
+	crcCalc := parser.NewCRCCalculator()
+	for i, entry := range entries {
+		if uint64(entry.GetFlashAddr()) != section.Offset() || entry.GetType() != section.Type()&0xFF {
+			continue
+		}
+
+		start := uint64(entry.GetFlashAddr())
+		size := uint64(entry.GetSize())
+		if start+size > uint64(len(data)) {
+			return errors.DataTooShortError(int(start+size), len(data), types.GetSectionTypeName(section.Type()))
+		}
+		entry.SectionCRC = crcCalc.CalculateImageCRC(data[start:start+size], int(size/DwordSize))
+
+		raw, err := entry.Marshal()
+		if err != nil {
+			return merry.Wrap(err)
+		}
+		entryOffset := tocAddr + ITOCEntrySize + uint32(i)*ITOCEntrySize
+		copy(data[entryOffset:entryOffset+ITOCEntrySize], raw)
+		binary.BigEndian.PutUint16(data[entryOffset+30:], crcCalc.CalculateImageCRC(data[entryOffset:entryOffset+28], CRCDwordSize))
+		return nil
+	}
+
+	return errors.SectionNotFoundError(types.GetSectionTypeName(section.Type())+" TOC entry", section.Offset())
+}
@@ -0,0 +1,91 @@
+package section
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/parser"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+// tocParser is a FirmwareParser stub that only knows the TOC addresses
+type tocParser struct {
+	interfaces.FirmwareParser
+	itocAddr uint32
+}
+
+func (p *tocParser) GetITOCAddress() uint32 { return p.itocAddr }
+func (p *tocParser) GetDTOCAddress() uint32 { return 0 }
+
+func TestUpdateSectionCRC_ITOCEntry(t *testing.T) {
+	const itocAddr, sectionAddr, sectionSize = 0x1000, 0x2000, 0x100
+	crc := parser.NewCRCCalculator()
+
+	data := make([]byte, 0x3000)
+	binary.BigEndian.PutUint32(data[itocAddr:], types.ITOCSignature)
+	entry := &types.ITOCEntry{
+		Type:            uint8(types.SectionTypeImageInfo),
+		SizeDwords:      sectionSize / 4,
+		FlashAddrDwords: sectionAddr,
+	}
+	raw, err := entry.Marshal()
+	require.NoError(t, err)
+	copy(data[itocAddr+ITOCEntrySize:], raw)
+	data[itocAddr+2*ITOCEntrySize] = 0xFF // end marker
+
+	for i := 0; i < sectionSize; i++ {
+		data[sectionAddr+i] = byte(i)
+	}
+
+	section := interfaces.NewBaseSectionWithOptions(types.SectionTypeImageInfo, sectionAddr, sectionSize,
+		interfaces.WithCRC(types.CRCInITOCEntry, 0))
+	require.NoError(t, UpdateSectionCRC(data, &tocParser{itocAddr: itocAddr}, section))
+
+	entryData := data[itocAddr+ITOCEntrySize : itocAddr+2*ITOCEntrySize]
+	updated := &types.ITOCEntry{}
+	require.NoError(t, updated.Unmarshal(entryData))
+	assert.Equal(t, crc.CalculateImageCRC(data[sectionAddr:sectionAddr+sectionSize], sectionSize/4), updated.SectionCRC)
+	assert.Equal(t, crc.CalculateImageCRC(entryData[:28], CRCDwordSize), binary.BigEndian.Uint16(entryData[30:]))
+
+	// A section without a TOC entry is reported
+	other := interfaces.NewBaseSectionWithOptions(types.SectionTypeImageInfo, 0x2800, sectionSize,
+		interfaces.WithCRC(types.CRCInITOCEntry, 0))
+	assert.Error(t, UpdateSectionCRC(data, &tocParser{itocAddr: itocAddr}, other))
+}
+
+func TestUpdateSectionCRC_InSection(t *testing.T) {
+	crc := parser.NewCRCCalculator()
+
+	tests := []struct {
+		name        string
+		sectionType uint16
+		wantBlank   bool
+	}{
+		{name: "software CRC", sectionType: types.SectionTypeToolsArea},
+		{name: "blank trailer kept", sectionType: types.SectionTypeImageInfo, wantBlank: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := make([]byte, 0x40)
+			for i := range data {
+				data[i] = byte(i * 5)
+			}
+			binary.BigEndian.PutUint32(data[0x3c:], 0xFFFFFFFF)
+
+			section := interfaces.NewBaseSectionWithOptions(tt.sectionType, 0, uint32(len(data)),
+				interfaces.WithCRC(types.CRCInSection, 0))
+			require.NoError(t, UpdateSectionCRC(data, nil, section))
+
+			want := 0xFFFF0000 | uint32(crc.CalculateImageCRC(data, 0x3c/4))
+			if tt.wantBlank {
+				want = 0xFFFFFFFF
+			}
+			assert.Equal(t, want, binary.BigEndian.Uint32(data[0x3c:]))
+		})
+	}
+}
@@ -2,6 +2,8 @@ package section
 
 import (
 	"encoding/binary"
+	"fmt"
+	"sort"
 
 	"github.com/Civil/mlx5fw-go/pkg/errors"
 	"github.com/Civil/mlx5fw-go/pkg/interfaces"
@@ -25,6 +27,9 @@ const (
 
 	// CRC sizes
 	CRCDwordSize = 7 // 28 bytes = 7 dwords for ITOC/DTOC CRC
+
+	// Flash sector size; relocated sections keep their alignment up to it
+	SectorSize = 0x1000
 )
 
 // Replacer handles section replacement with proper firmware structure updates
@@ -45,94 +50,79 @@ func NewReplacer(fwParser *fs4.Parser, firmwareData []byte, logger *zap.Logger)
 	}
 }
 
-// ReplaceSection replaces a section and updates all affected structures
+// ReplaceSection replaces a section and updates all affected structures.
+//
+// When the size changes, the ITOC sections placed after the target are packed
+// behind it (see buildRelocationMap), their ITOC entries and any HW pointers
+// to them are rewritten and every affected CRC is recomputed. The result is
+// reparsed and rejected if a section that verified before no longer does.
+// HASHES_TABLE digests and image signatures are not updated.
 func (r *Replacer) ReplaceSection(targetSection interfaces.CompleteSectionInterface, newData []byte) ([]byte, error) {
 	oldSize := targetSection.Size()
 	newSize := uint32(len(newData))
-	sizeDiff := int32(newSize) - int32(oldSize)
+	sizeDiff := int64(newSize) - int64(oldSize)
 
-	// Check firmware size limit
-	fwSizeLimit := r.determineFirmwareSizeLimit()
-	if sizeDiff > 0 {
-		newTotalSize := len(r.firmwareData) + int(sizeDiff)
-		if newTotalSize > fwSizeLimit {
-			return nil, merry.Errorf("firmware size would exceed limit: %d bytes > %d MB limit",
-				newTotalSize, fwSizeLimit/(1024*1024))
+	if sizeDiff != 0 {
+		if targetSection.IsDeviceData() || targetSection.IsFromHWPointer() {
+			return nil, errors.NotSupportedError("resizing " + types.GetSectionTypeName(targetSection.Type()))
 		}
-	}
-
-	// Create a working copy
-	workingData := make([]byte, len(r.firmwareData))
-	copy(workingData, r.firmwareData)
-
-	if sizeDiff == 0 {
-		// Simple case: same size replacement
-		copy(workingData[targetSection.Offset():], newData)
-
-		// Update CRC
-		err := r.updateSectionCRC(workingData, targetSection, newSize)
-		if err != nil {
-			return nil, merry.Wrap(err)
+		if newSize%DwordSize != 0 {
+			return nil, errors.InvalidParameterError("replacement data",
+				fmt.Sprintf("size 0x%x is not a multiple of %d bytes", newSize, DwordSize))
 		}
-
-		// Pad firmware to proper size
-		workingData = r.padFirmware(workingData)
-		return workingData, nil
+		r.logger.Info("Section size changed, relocating sections",
+			zap.Int64("sizeDiff", sizeDiff))
 	}
 
-	// Complex case: size changed, need to relocate sections
-	r.logger.Info("Section size changed, relocating sections",
-		zap.Int32("sizeDiff", sizeDiff))
-
-	// Get parser internals through reflection or exposed methods
+	// An image whose DTOC would move when padded keeps its size
+	fwSizeLimit := r.determineFirmwareSizeLimit()
+	if !r.paddingKeepsDTOC() {
+		fwSizeLimit = len(r.firmwareData)
+	}
 	itocAddr := r.parser.GetITOCAddress()
 	dtocAddr := r.parser.GetDTOCAddress()
 
 	// Build relocation map
-	relocMap, err := r.buildRelocationMap(targetSection, sizeDiff, itocAddr, dtocAddr)
+	relocMap, err := r.buildRelocationMap(targetSection, newSize, itocAddr, dtocAddr, uint32(fwSizeLimit))
 	if err != nil {
 		return nil, merry.Wrap(err)
 	}
 
-	// Extend firmware if needed
-	if sizeDiff > 0 {
-		maxNewEnd := uint32(0)
-		for oldOffset, reloc := range relocMap {
-			sectionEnd := reloc.newOffset + reloc.size
-			if sectionEnd > maxNewEnd {
-				maxNewEnd = sectionEnd
-			}
-			r.logger.Debug("Relocation entry",
-				zap.Uint32("oldOffset", oldOffset),
-				zap.Uint32("newOffset", reloc.newOffset),
-				zap.Uint32("size", reloc.size))
-		}
+	// Create a working copy padded to the size limit up front, so that the
+	// packed sections always fit and the space they free reads as erased flash
+	workingData := make([]byte, len(r.firmwareData), max(len(r.firmwareData), fwSizeLimit))
+	copy(workingData, r.firmwareData)
+	if r.paddingKeepsDTOC() {
+		workingData = r.padFirmware(workingData)
+	}
 
-		if maxNewEnd > uint32(len(workingData)) {
-			newWorkingData := make([]byte, maxNewEnd)
-			copy(newWorkingData, workingData)
-			workingData = newWorkingData
-			r.logger.Info("Extended firmware size", zap.Uint32("newSize", maxNewEnd))
-		}
+	targetEnd := targetSection.Offset() + uint64(max(oldSize, newSize))
+	if targetEnd > uint64(len(workingData)) {
+		return nil, errors.DataTooShortError(int(targetEnd), len(workingData), types.GetSectionTypeName(targetSection.Type()))
 	}
 
-	// Relocate sections
-	err = r.relocateSections(workingData, relocMap, targetSection.Offset(), sizeDiff)
-	if err != nil {
+	// Erase the old contents, relocate the following sections and write the
+	// new contents
+	fill(workingData[targetSection.Offset():targetSection.Offset()+uint64(oldSize)], 0xFF)
+	if err := r.relocateSections(workingData, relocMap); err != nil {
 		return nil, merry.Wrap(err)
 	}
-
-	// Copy new data for target section
 	copy(workingData[targetSection.Offset():], newData)
 
+	// Update CRC
+	if err := r.updateSectionCRC(workingData, targetSection, newSize); err != nil {
+		return nil, merry.Wrap(err)
+	}
+
 	// Update all firmware structures
 	err = r.updateFirmwareStructures(workingData, relocMap, targetSection, newSize, itocAddr, dtocAddr)
 	if err != nil {
 		return nil, merry.Wrap(err)
 	}
 
-	// Pad firmware to proper size
-	workingData = r.padFirmware(workingData)
+	if err := r.validate(workingData); err != nil {
+		return nil, merry.Prepend(err, "modified firmware failed validation")
+	}
 	return workingData, nil
 }
 
@@ -155,6 +145,13 @@ func (r *Replacer) determineFirmwareSizeLimit() int {
 	return FirmwareSize32MB
 }
 
+// paddingKeepsDTOC reports whether the DTOC stays where the parser looks for
+// it once the image is padded: in the last sector of the first 32MB. Smaller
+// images have it in their own last sector instead.
+func (r *Replacer) paddingKeepsDTOC() bool {
+	return !r.parser.IsDTOCHeaderValid() || r.parser.GetDTOCAddress() == FirmwareSize32MB-SectorSize
+}
+
 // padFirmware pads the firmware to 32MB or 64MB with 0xFF
 func (r *Replacer) padFirmware(data []byte) []byte {
 	targetSize := r.determineFirmwareSizeLimit()
@@ -164,9 +161,8 @@ func (r *Replacer) padFirmware(data []byte) []byte {
 		return data[:targetSize]
 	}
 
-	// Create padded firmware
-	paddedData := make([]byte, targetSize)
-	copy(paddedData, data)
+	// Create padded firmware, reusing spare capacity of the working copy
+	paddedData := extendZeroed(data, targetSize)
 
 	// Fill remaining with 0xFF
 	for i := currentSize; i < targetSize; i++ {
@@ -180,6 +176,18 @@ func (r *Replacer) padFirmware(data []byte) []byte {
 	return paddedData
 }
 
+// extendZeroed grows data to size zeroed bytes, in place when the capacity allows
+func extendZeroed(data []byte, size int) []byte {
+	if size <= cap(data) {
+		extended := data[:size]
+		clear(extended[len(data):])
+		return extended
+	}
+	extended := make([]byte, size)
+	copy(extended, data)
+	return extended
+}
+
 // relocationInfo stores relocation information for a section
 type relocationInfo struct {
 	newOffset   uint32
@@ -191,9 +199,23 @@ type relocationInfo struct {
 	entryIndex  int
 }
 
-// buildRelocationMap builds a map of all sections that need relocation
-func (r *Replacer) buildRelocationMap(targetSection interfaces.SectionInterface, sizeDiff int32, itocAddr, dtocAddr uint32) (map[uint32]*relocationInfo, error) {
+// region is a byte range [start, end) of the image
+type region struct {
+	start, end uint64
+	name       string
+}
+
+// buildRelocationMap maps the offset of every ITOC and DTOC entry to its
+// placement after the target section is resized to newSize.
+//
+// The ITOC sections after the target are packed behind it in their original
+// order. Each one keeps the alignment of its old offset up to a flash sector
+// and skips the regions that do not move: the HW pointers, the TOCs, device
+// data, BOOT2, TOOLS_AREA and the sections before the target. A section that
+// would end past limit is an error.
+func (r *Replacer) buildRelocationMap(targetSection interfaces.SectionInterface, newSize uint32, itocAddr, dtocAddr, limit uint32) (map[uint32]*relocationInfo, error) {
 	relocMap := make(map[uint32]*relocationInfo)
+	targetOffset := uint32(targetSection.Offset())
 
 	// Process ITOC sections
 	itocSections, err := r.readTOCSections(itocAddr, false)
@@ -201,64 +223,174 @@ func (r *Replacer) buildRelocationMap(targetSection interfaces.SectionInterface,
 		return nil, merry.Wrap(err)
 	}
 
+	var movable []uint32
 	for i, entry := range itocSections {
-		if entry.GetFlashAddr() == 0 || entry.GetType() == 0xFF {
+		addr := entry.GetFlashAddr()
+		if addr == 0 || entry.GetType() == 0xFF {
+			continue
+		}
+		// Don't duplicate entries sharing an offset
+		if _, exists := relocMap[addr]; exists {
 			continue
 		}
 
 		reloc := &relocationInfo{
-			newOffset:   entry.GetFlashAddr(),
+			newOffset:   addr,
 			size:        entry.GetSize(),
 			sectionType: entry.GetType(),
-			crcType:     r.tocReader.GetCRCTypeLegacy(entry),
+			crcType:     entry.GetCRCType(),
 			isITOC:      true,
 			entryIndex:  i,
 		}
-
-		// Relocate if after target section
-		if entry.GetFlashAddr() > uint32(targetSection.Offset()) {
-			reloc.newOffset = uint32(int32(entry.GetFlashAddr()) + sizeDiff)
+		if addr > targetOffset && !targetSection.IsDeviceData() {
+			movable = append(movable, addr)
 		}
-
-		relocMap[entry.GetFlashAddr()] = reloc
+		relocMap[addr] = reloc
 	}
 
-	// Process DTOC sections
+	// Process DTOC sections; device data never moves
+	var dtocSections []*types.ITOCEntry
 	if dtocAddr > 0 {
-		dtocSections, err := r.readTOCSections(dtocAddr, true)
+		dtocSections, err = r.readTOCSections(dtocAddr, true)
 		if err == nil { // DTOC might not exist
 			for i, entry := range dtocSections {
-				if entry.GetFlashAddr() == 0 || entry.GetType() == 0xFF {
+				addr := entry.GetFlashAddr()
+				if addr == 0 || entry.GetType() == 0xFF {
 					continue
 				}
-
-				// Don't duplicate entries already in relocMap
-				if _, exists := relocMap[entry.GetFlashAddr()]; exists {
+				if _, exists := relocMap[addr]; exists {
 					continue
 				}
-
-				reloc := &relocationInfo{
-					newOffset:   entry.GetFlashAddr(),
+				relocMap[addr] = &relocationInfo{
+					newOffset:   addr,
 					size:        entry.GetSize(),
 					sectionType: entry.GetType() | 0xE000, // DTOC type mapping
-					crcType:     r.tocReader.GetCRCTypeLegacy(entry),
+					crcType:     entry.GetCRCType(),
 					isDTOC:      true,
 					entryIndex:  i,
 				}
+			}
+		}
+	}
 
-				// Relocate if after target section
-				if entry.GetFlashAddr() > uint32(targetSection.Offset()) {
-					reloc.newOffset = uint32(int32(entry.GetFlashAddr()) + sizeDiff)
-				}
+	if reloc, exists := relocMap[targetOffset]; exists {
+		reloc.size = newSize
+	}
+	if newSize == targetSection.Size() {
+		return relocMap, nil
+	}
 
-				relocMap[entry.GetFlashAddr()] = reloc
+	// Parsed sizes are authoritative for sections whose entry size is not,
+	// such as HASHES_TABLE
+	for _, sectionList := range r.parser.GetSections() {
+		for _, s := range sectionList {
+			if reloc, exists := relocMap[uint32(s.Offset())]; exists && s.Offset() != uint64(targetOffset) {
+				reloc.size = max(reloc.size, s.Size())
 			}
 		}
 	}
 
+	fixed := r.fixedRegions(relocMap, movable, targetOffset, itocAddr, len(itocSections), dtocAddr, len(dtocSections))
+	targetEnd := uint64(targetOffset) + uint64(newSize)
+	if targetEnd > uint64(limit) {
+		return nil, merry.Errorf("%s would end at 0x%x, beyond the %d MB firmware size limit",
+			types.GetSectionTypeName(targetSection.Type()), targetEnd, limit/(1024*1024))
+	}
+	if f := overlapping(fixed, uint64(targetOffset), targetEnd); f != nil {
+		return nil, merry.Errorf("%s would end at 0x%x, overlapping %s at 0x%x",
+			types.GetSectionTypeName(targetSection.Type()), targetEnd, f.name, f.start)
+	}
+
+	sort.Slice(movable, func(i, j int) bool { return movable[i] < movable[j] })
+	cursor := targetEnd
+	for _, oldOffset := range movable {
+		reloc := relocMap[oldOffset]
+		align := sectionAlignment(oldOffset)
+		offset := alignUp(cursor, align)
+		for f := overlapping(fixed, offset, offset+uint64(reloc.size)); f != nil; f = overlapping(fixed, offset, offset+uint64(reloc.size)) {
+			offset = alignUp(f.end, align)
+		}
+		if offset+uint64(reloc.size) > uint64(limit) {
+			return nil, merry.Errorf("no room to relocate %s (0x%x bytes) within the %d MB firmware size limit",
+				types.GetSectionTypeName(reloc.sectionType), reloc.size, limit/(1024*1024))
+		}
+
+		reloc.newOffset = uint32(offset)
+		cursor = offset + uint64(reloc.size)
+		r.logger.Debug("Relocation entry",
+			zap.String("type", types.GetSectionTypeName(reloc.sectionType)),
+			zap.Uint32("oldOffset", oldOffset),
+			zap.Uint32("newOffset", reloc.newOffset),
+			zap.Uint32("size", reloc.size))
+	}
+
 	return relocMap, nil
 }
 
+// fixedRegions returns the parts of the image the relocation must not touch:
+// the magic pattern and HW pointers, the TOC headers and entry arrays and
+// every parsed section that is neither the target nor movable
+func (r *Replacer) fixedRegions(relocMap map[uint32]*relocationInfo, movable []uint32, targetOffset, itocAddr uint32, itocEntries int, dtocAddr uint32, dtocEntries int) []region {
+	magicOffset := uint64(r.parser.GetMagicOffset())
+	fixed := []region{
+		{magicOffset, magicOffset + types.HWPointersOffsetFromMagic + types.HWPointersSize, "HW pointers"},
+		// The header, the entries and the end entry
+		{uint64(itocAddr), uint64(itocAddr) + uint64(itocEntries+2)*ITOCEntrySize, "ITOC"},
+	}
+	if dtocAddr > 0 {
+		fixed = append(fixed, region{uint64(dtocAddr), uint64(dtocAddr) + uint64(dtocEntries+2)*ITOCEntrySize, "DTOC"})
+	}
+
+	moving := make(map[uint64]bool, len(movable)+1)
+	moving[uint64(targetOffset)] = true
+	for _, offset := range movable {
+		moving[uint64(offset)] = true
+	}
+	for _, sectionList := range r.parser.GetSections() {
+		for _, s := range sectionList {
+			if !moving[s.Offset()] && s.Size() > 0 {
+				fixed = append(fixed, region{s.Offset(), s.Offset() + uint64(s.Size()), s.TypeName()})
+			}
+		}
+	}
+	for offset, reloc := range relocMap {
+		if !moving[uint64(offset)] && reloc.size > 0 {
+			fixed = append(fixed, region{uint64(offset), uint64(offset) + uint64(reloc.size), types.GetSectionTypeName(reloc.sectionType)})
+		}
+	}
+	return fixed
+}
+
+// overlapping returns the first region that overlaps [start, end)
+func overlapping(regions []region, start, end uint64) *region {
+	for i := range regions {
+		if start < regions[i].end && end > regions[i].start {
+			return &regions[i]
+		}
+	}
+	return nil
+}
+
+// sectionAlignment returns the alignment of offset, capped at a flash sector
+func sectionAlignment(offset uint32) uint64 {
+	if offset == 0 {
+		return SectorSize
+	}
+	return min(uint64(offset&-offset), SectorSize)
+}
+
+// alignUp rounds offset up to a multiple of align, a power of two
+func alignUp(offset, align uint64) uint64 {
+	return (offset + align - 1) &^ (align - 1)
+}
+
+// fill sets every byte of data to b
+func fill(data []byte, b byte) {
+	for i := range data {
+		data[i] = b
+	}
+}
+
 // readTOCSections reads all entries from a TOC
 func (r *Replacer) readTOCSections(tocAddr uint32, isDTOC bool) ([]*types.ITOCEntry, error) {
 	if tocAddr == 0 || tocAddr >= uint32(len(r.firmwareData)) {
@@ -269,61 +401,41 @@ func (r *Replacer) readTOCSections(tocAddr uint32, isDTOC bool) ([]*types.ITOCEn
 	return r.tocReader.ReadTOCRawEntries(r.firmwareData, tocAddr, isDTOC)
 }
 
-// relocateSections moves section data to new offsets
-func (r *Replacer) relocateSections(workingData []byte, relocMap map[uint32]*relocationInfo, targetOffset uint64, sizeDiff int32) error {
-	// Create sorted list of sections by offset
-	var sortedOffsets []uint32
-	for offset := range relocMap {
-		sortedOffsets = append(sortedOffsets, offset)
+// relocateSections moves section data to new offsets. All moved sections are
+// read before any is written, so the order of the moves does not matter, and
+// the space they leave is erased to 0xFF.
+func (r *Replacer) relocateSections(workingData []byte, relocMap map[uint32]*relocationInfo) error {
+	type move struct {
+		oldOffset uint32
+		reloc     *relocationInfo
+		data      []byte
 	}
 
-	// Sort offsets
-	for i := 0; i < len(sortedOffsets)-1; i++ {
-		for j := i + 1; j < len(sortedOffsets); j++ {
-			if sortedOffsets[i] > sortedOffsets[j] {
-				sortedOffsets[i], sortedOffsets[j] = sortedOffsets[j], sortedOffsets[i]
-			}
+	var moves []move
+	for oldOffset, reloc := range relocMap {
+		if oldOffset == reloc.newOffset {
+			continue
 		}
-	}
-
-	// Move sections (from end to beginning if growing, beginning to end if shrinking)
-	if sizeDiff > 0 {
-		// Growing - move from end to beginning
-		for i := len(sortedOffsets) - 1; i >= 0; i-- {
-			oldOffset := sortedOffsets[i]
-			reloc := relocMap[oldOffset]
-
-			if oldOffset <= uint32(targetOffset) || oldOffset == reloc.newOffset {
-				continue
+		for _, end := range []uint64{uint64(oldOffset) + uint64(reloc.size), uint64(reloc.newOffset) + uint64(reloc.size)} {
+			if end > uint64(len(workingData)) {
+				return errors.DataTooShortError(int(end), len(workingData), types.GetSectionTypeName(reloc.sectionType))
 			}
-
-			// Move section data
-			sectionData := make([]byte, reloc.size)
-			copy(sectionData, workingData[oldOffset:oldOffset+reloc.size])
-			copy(workingData[reloc.newOffset:], sectionData)
-
-			r.logger.Debug("Relocated section",
-				zap.Uint32("oldOffset", oldOffset),
-				zap.Uint32("newOffset", reloc.newOffset),
-				zap.Uint32("size", reloc.size))
 		}
-	} else {
-		// Shrinking - move from beginning to end
-		for _, oldOffset := range sortedOffsets {
-			reloc := relocMap[oldOffset]
-
-			if oldOffset <= uint32(targetOffset) || oldOffset == reloc.newOffset {
-				continue
-			}
+		data := make([]byte, reloc.size)
+		copy(data, workingData[oldOffset:oldOffset+reloc.size])
+		moves = append(moves, move{oldOffset, reloc, data})
+	}
 
-			// Move section data
-			copy(workingData[reloc.newOffset:], workingData[oldOffset:oldOffset+reloc.size])
+	for _, m := range moves {
+		fill(workingData[m.oldOffset:m.oldOffset+m.reloc.size], 0xFF)
+	}
+	for _, m := range moves {
+		copy(workingData[m.reloc.newOffset:], m.data)
 
-			r.logger.Debug("Relocated section",
-				zap.Uint32("oldOffset", oldOffset),
-				zap.Uint32("newOffset", reloc.newOffset),
-				zap.Uint32("size", reloc.size))
-		}
+		r.logger.Debug("Relocated section",
+			zap.Uint32("oldOffset", m.oldOffset),
+			zap.Uint32("newOffset", m.reloc.newOffset),
+			zap.Uint32("size", m.reloc.size))
 	}
 
 	return nil
@@ -338,10 +450,10 @@ func (r *Replacer) updateFirmwareStructures(workingData []byte, relocMap map[uin
 	}
 
 	// Update DTOC entries
-	if dtocAddr > 0 {
-		err = r.updateTOCEntries(workingData, relocMap, dtocAddr, true, nil, 0)
+	if dtocAddr > 0 && targetSection.IsDeviceData() {
+		err = r.updateTOCEntries(workingData, relocMap, dtocAddr, true, targetSection, newSize)
 		if err != nil {
-			r.logger.Warn("Failed to update DTOC", zap.Error(err))
+			return merry.Wrap(err)
 		}
 	}
 
@@ -351,22 +463,13 @@ func (r *Replacer) updateFirmwareStructures(workingData []byte, relocMap map[uin
 		return merry.Wrap(err)
 	}
 
-	// Update CRCs for all affected sections
-	for oldOffset, reloc := range relocMap {
-		if oldOffset >= uint32(targetSection.Offset()) {
-			// TODO: Fix CRC update for relocated sections
-			// This needs to be refactored to use SectionInterface instead of the legacy Section struct
-			// For now, skip CRC update for relocated sections
-			r.logger.Warn("CRC update for relocated sections not yet implemented",
-				zap.Uint16("type", reloc.sectionType),
-				zap.Uint32("offset", reloc.newOffset))
-		}
-	}
-
 	return nil
 }
 
-// updateTOCEntries updates TOC entries with new offsets and sizes
+// updateTOCEntries rewrites the TOC entries of the target and of the moved
+// sections with their new offset, size and section CRC, and recomputes the
+// entry CRCs. Moved sections are copied verbatim, so their in-section CRCs
+// stay valid.
 func (r *Replacer) updateTOCEntries(workingData []byte, relocMap map[uint32]*relocationInfo, tocAddr uint32, isDTOC bool, targetSection interfaces.SectionInterface, newSize uint32) error {
 	if tocAddr == 0 {
 		return nil
@@ -381,62 +484,46 @@ func (r *Replacer) updateTOCEntries(workingData []byte, relocMap map[uint32]*rel
 	entriesOffset := tocAddr + ITOCEntrySize
 
 	for i, entry := range entries {
-		if entry.GetFlashAddr() == 0 || entry.GetType() == 0xFF {
+		oldOffset := entry.GetFlashAddr()
+		if oldOffset == 0 || entry.GetType() == 0xFF {
 			continue
 		}
 
 		// Check if this entry needs updating
-		reloc, exists := relocMap[entry.GetFlashAddr()]
-		if !exists {
+		reloc, exists := relocMap[oldOffset]
+		if !exists || (isDTOC && !reloc.isDTOC) || (!isDTOC && !reloc.isITOC) {
 			continue
 		}
-
-		// Only update if this TOC type matches
-		if (isDTOC && !reloc.isDTOC) || (!isDTOC && !reloc.isITOC) {
+		isTarget := uint64(oldOffset) == targetSection.Offset() && entry.GetType() == targetSection.Type()&0xFF
+		if !isTarget && reloc.newOffset == oldOffset {
 			continue
 		}
 
 		// Update entry fields
-		if reloc.newOffset != entry.GetFlashAddr() {
-			entry.SetFlashAddr(reloc.newOffset)
-		}
-
-		// Update size for target section
-		if targetSection != nil && entry.GetFlashAddr() == uint32(targetSection.Offset()) {
+		entry.SetFlashAddr(reloc.newOffset)
+		sectionSize := entry.GetSize()
+		if isTarget {
+			sectionSize = newSize
 			entry.SetSize(newSize)
-			r.logger.Info("Updating target section in ITOC",
-				zap.Uint32("offset", entry.GetFlashAddr()),
-				zap.Uint32("oldSize", reloc.size),
+			r.logger.Info("Updating target section in TOC",
+				zap.Uint32("offset", oldOffset),
+				zap.Uint32("oldSize", targetSection.Size()),
 				zap.Uint32("newSize", newSize))
 		}
 
-		// Calculate section CRC if it's stored in ITOC entry
+		// Calculate section CRC if it's stored in the TOC entry
 		if reloc.crcType == types.CRCInITOCEntry {
-			// Read section data at new offset
-			sectionOffset := reloc.newOffset
-			sectionSize := reloc.size
-			if targetSection != nil && entry.GetFlashAddr() == uint32(targetSection.Offset()) {
-				sectionSize = newSize
+			end := uint64(reloc.newOffset) + uint64(sectionSize)
+			if end > uint64(len(workingData)) {
+				return errors.DataTooShortError(int(end), len(workingData), types.GetSectionTypeName(reloc.sectionType))
 			}
+			entry.SectionCRC = crcCalc.CalculateImageCRC(workingData[reloc.newOffset:end], int(sectionSize/DwordSize))
 
-			if sectionOffset+sectionSize <= uint32(len(workingData)) {
-				sectionData := workingData[sectionOffset : sectionOffset+sectionSize]
-
-				// Align to dwords and calculate CRC
-				alignedSize := (sectionSize + 3) & ^uint32(3)
-				paddedData := make([]byte, alignedSize)
-				copy(paddedData, sectionData)
-
-				sizeInDwords := alignedSize / DwordSize
-				sectionCRC := crcCalc.CalculateImageCRC(paddedData, int(sizeInDwords))
-				entry.SectionCRC = sectionCRC
-
-				r.logger.Debug("Calculated section CRC for ITOC entry",
-					zap.Uint16("type", reloc.sectionType),
-					zap.Uint16("crc", sectionCRC),
-					zap.Uint32("offset", sectionOffset),
-					zap.Uint32("size", sectionSize))
-			}
+			r.logger.Debug("Calculated section CRC for TOC entry",
+				zap.Uint16("type", reloc.sectionType),
+				zap.Uint16("crc", entry.SectionCRC),
+				zap.Uint32("offset", reloc.newOffset),
+				zap.Uint32("size", sectionSize))
 		}
 
 		// Serialize entry back to binary
@@ -455,17 +542,6 @@ func (r *Replacer) updateTOCEntries(workingData []byte, relocMap map[uint32]*rel
 		binary.BigEndian.PutUint16(workingData[entryOffset+30:entryOffset+32], entryCRC)
 	}
 
-	// Update TOC header CRC
-	// ITOC/DTOC header uses Software CRC16 (CalculateImageCRC) on first 28 bytes
-	// The CRC is stored as 16-bit value in the lower half of the 32-bit field
-	headerCRCData := workingData[tocAddr : tocAddr+28]
-	headerCRC := crcCalc.CalculateImageCRC(headerCRCData, CRCDwordSize)
-
-	// Keep upper 16 bits unchanged, update lower 16 bits with CRC
-	currentCRCField := binary.BigEndian.Uint32(workingData[tocAddr+28 : tocAddr+32])
-	newCRCField := (currentCRCField & 0xFFFF0000) | uint32(headerCRC)
-	binary.BigEndian.PutUint32(workingData[tocAddr+28:tocAddr+32], newCRCField)
-
 	return nil
 }
 
@@ -487,7 +563,9 @@ func (r *Replacer) serializeITOCEntry(entry *types.ITOCEntry, data []byte) error
 	return nil
 }
 
-// updateHWPointers updates hardware pointers that reference relocated sections
+// updateHWPointers updates hardware pointers that reference relocated
+// sections. The entries are rewritten in place, so their reserved bytes are
+// kept, and the CRC covers the first 6 bytes as the hardware checks it.
 func (r *Replacer) updateHWPointers(workingData []byte, relocMap map[uint32]*relocationInfo) error {
 	// Find magic pattern to locate HW pointers
 	magicOffset, err := r.findMagicPattern(workingData)
@@ -495,59 +573,77 @@ func (r *Replacer) updateHWPointers(workingData []byte, relocMap map[uint32]*rel
 		return merry.Wrap(err)
 	}
 
-	hwPointersOffset := magicOffset + 0x18
-	if hwPointersOffset+128 > uint32(len(workingData)) {
+	hwPointersOffset := magicOffset + types.HWPointersOffsetFromMagic
+	if hwPointersOffset+types.HWPointersSize > uint32(len(workingData)) {
 		return merry.Wrap(errors.ErrInvalidParameter, merry.WithMessagef("HW pointers offset %d out of bounds", hwPointersOffset))
 	}
 
-	// Read HW pointers using annotation-based unmarshaling
-	hwPointers := &types.FS4HWPointers{}
-	hwPointersData := workingData[hwPointersOffset : hwPointersOffset+128]
-	if err := hwPointers.Unmarshal(hwPointersData); err != nil {
-		return merry.Wrap(err)
-	}
-
 	crcCalc := parser.NewCRCCalculator()
-	updated := false
-
-	// Helper to update a pointer
-	updatePointer := func(name string, ptr *types.HWPointerEntry) {
-		if ptr.Ptr == 0 || ptr.Ptr == 0xFFFFFFFF {
-			return
+	for i := range types.FS4HWPointerNames {
+		entry := workingData[hwPointersOffset+uint32(i)*8:][:8]
+		ptr := binary.BigEndian.Uint32(entry)
+		if ptr == 0 || ptr == 0xFFFFFFFF {
+			continue
 		}
 
-		if reloc, exists := relocMap[ptr.Ptr]; exists && reloc.newOffset != ptr.Ptr {
-			r.logger.Debug("Updating HW pointer",
-				zap.String("name", name),
-				zap.Uint32("old", ptr.Ptr),
-				zap.Uint32("new", reloc.newOffset))
-
-			ptr.Ptr = reloc.newOffset
-			// Recalculate pointer CRC
-			ptrBytes := make([]byte, 4)
-			binary.BigEndian.PutUint32(ptrBytes, ptr.Ptr)
-			ptr.CRC = crcCalc.CalculateHardwareCRC(ptrBytes)
-			updated = true
+		reloc, exists := relocMap[ptr]
+		if !exists || reloc.newOffset == ptr {
+			continue
 		}
+		r.logger.Debug("Updating HW pointer",
+			zap.String("name", types.FS4HWPointerNames[i]),
+			zap.Uint32("old", ptr),
+			zap.Uint32("new", reloc.newOffset))
+
+		binary.BigEndian.PutUint32(entry, reloc.newOffset)
+		binary.BigEndian.PutUint16(entry[6:], crcCalc.CalculateHardwareCRC(entry[:6]))
 	}
 
-	// Update all pointers
-	updatePointer("Boot2Ptr", &hwPointers.Boot2Ptr)
-	updatePointer("TOCPtr", &hwPointers.TOCPtr)
-	updatePointer("ToolsPtr", &hwPointers.ToolsPtr)
-	updatePointer("ImageInfoSectionPtr", &hwPointers.ImageInfoSectionPtr)
-	updatePointer("HashesTablePtr", &hwPointers.HashesTablePtr)
-	// Add other pointers as needed
+	return nil
+}
 
-	if updated {
-		// Write back HW pointers
-		r.writeHWPointers(workingData[hwPointersOffset:], hwPointers)
-		r.logger.Info("Updated HW pointers")
+// validate reparses the modified image and checks that its TOC headers and
+// every section that verified in the original image still verify
+func (r *Replacer) validate(data []byte) error {
+	newParser := fs4.NewParser(parser.NewFirmwareReaderFromBytes(data, r.logger), r.logger)
+	if err := newParser.Parse(); err != nil {
+		return merry.Prepend(err, "failed to reparse")
+	}
+	if r.parser.IsITOCHeaderValid() && !newParser.IsITOCHeaderValid() {
+		return merry.Wrap(errors.ErrCRCMismatch, merry.WithMessage("ITOC header CRC mismatch"))
+	}
+	if r.parser.IsDTOCHeaderValid() && !newParser.IsDTOCHeaderValid() {
+		return merry.Wrap(errors.ErrCRCMismatch, merry.WithMessage("DTOC header CRC mismatch"))
 	}
 
+	after := sectionStatuses(newParser)
+	for key, status := range sectionStatuses(r.parser) {
+		if status == "OK" && after[key] != "OK" {
+			if after[key] == "" {
+				return errors.SectionNotFoundError(key, 0)
+			}
+			return merry.Wrap(errors.ErrCRCMismatch, merry.WithMessagef("%s: %s", key, after[key]))
+		}
+	}
 	return nil
 }
 
+// sectionStatuses verifies every section of a parsed image, keyed by section
+// name and index
+func sectionStatuses(fwParser *fs4.Parser) map[string]string {
+	statuses := make(map[string]string)
+	for _, sectionList := range fwParser.GetSections() {
+		for i, s := range sectionList {
+			status, err := fwParser.VerifySectionNew(s)
+			if err != nil {
+				status = err.Error()
+			}
+			statuses[fmt.Sprintf("%s:%d", s.TypeName(), i)] = status
+		}
+	}
+	return statuses
+}
+
 // findMagicPattern finds the FS4 magic pattern
 func (r *Replacer) findMagicPattern(data []byte) (uint32, error) {
 	// Use the same search offsets as FirmwareReader for consistency
@@ -613,7 +709,16 @@ func (r *Replacer) updateSectionCRC(workingData []byte, section interfaces.Secti
 			crcData = paddedData
 		}
 
-		newCRC := crcCalc.CalculateImageCRC(crcData, int(crcSizeInDwords))
+		var newCRC uint16
+		switch types.GetInSectionCRCPolicy(section.Type()) {
+		case types.InSectionCRCPolicyBlank:
+			// The trailer is a sentinel, keep the one supplied with the data
+			return nil
+		case types.InSectionCRCPolicyHardware:
+			newCRC = crcCalc.CalculateHardwareCRC(crcData)
+		default:
+			newCRC = crcCalc.CalculateImageCRC(crcData, int(crcSizeInDwords))
+		}
 
 		// Write CRC in lower 16 bits of last dword
 		lastDwordOffset := section.Offset() + uint64(newSize) - 4
@@ -1,11 +1,13 @@
 package section
 
 import (
+	"bytes"
 	"encoding/binary"
 	"testing"
 
 	"github.com/Civil/mlx5fw-go/pkg/interfaces"
 	"github.com/Civil/mlx5fw-go/pkg/parser"
+	"github.com/Civil/mlx5fw-go/pkg/parser/fs4"
 	"github.com/Civil/mlx5fw-go/pkg/types"
 	"github.com/Civil/mlx5fw-go/pkg/types/sections"
 	"github.com/stretchr/testify/assert"
@@ -374,13 +376,14 @@ func TestRelocateSections(t *testing.T) {
 		logger: logger,
 	}
 
-	err := r.relocateSections(workingData, relocMap, 150, 50)
+	err := r.relocateSections(workingData, relocMap)
 	require.NoError(t, err)
 
-	// Verify sections were moved
+	// Verify sections were moved and their old space erased
 	assert.Equal(t, section1Data, workingData[100:100+len(section1Data)])
 	assert.Equal(t, section2Data, workingData[250:250+len(section2Data)])
 	assert.Equal(t, section3Data, workingData[350:350+len(section3Data)])
+	assert.Equal(t, bytes.Repeat([]byte{0xFF}, len(section2Data)), workingData[200:200+len(section2Data)])
 }
 
 func TestUpdateHWPointers(t *testing.T) {
@@ -510,3 +513,146 @@ func TestPadFirmware(t *testing.T) {
 		})
 	}
 }
+
+func TestExtendZeroed(t *testing.T) {
+	backing := make([]byte, 8, 16)
+	for i := range backing[:cap(backing)] {
+		backing[:cap(backing)][i] = 0xAA
+	}
+
+	// Growing within capacity reuses the buffer and zeroes the new bytes
+	extended := extendZeroed(backing, 12)
+	require.Len(t, extended, 12)
+	assert.Equal(t, &backing[0], &extended[0])
+	assert.Equal(t, []byte{0, 0, 0, 0}, extended[8:])
+
+	// Growing past capacity allocates and keeps the original content
+	grown := extendZeroed(backing, 20)
+	require.Len(t, grown, 20)
+	assert.Equal(t, backing, grown[:8])
+	assert.Equal(t, make([]byte, 12), grown[8:])
+}
+
+const relocITOCAddr = 0x2000
+
+// imageSignaturePtr is the index of the IMAGE_SIGNATURE_PTR HW pointer
+const imageSignaturePtr = 11
+
+// createRelocationImage builds a 32MB FS4 image with an ITOC listing
+// MAIN_CODE at 0x4000 (CRC in section), PCI_CODE at 0x6000 (CRC in the ITOC
+// entry) and IRON_PREP_CODE at 0x6400 (CRC in section), which the
+// IMAGE_SIGNATURE_PTR HW pointer also points to
+func createRelocationImage(t *testing.T) []byte {
+	t.Helper()
+	crc := parser.NewCRCCalculator()
+	data := bytes.Repeat([]byte{0xFF}, FirmwareSize32MB)
+	binary.BigEndian.PutUint64(data, types.MagicPattern)
+
+	putHWPointer := func(i int, ptr uint32) {
+		entry := data[types.HWPointersOffsetFromMagic+i*8:][:8]
+		binary.BigEndian.PutUint32(entry, ptr)
+		binary.BigEndian.PutUint16(entry[4:], 0)
+		binary.BigEndian.PutUint16(entry[6:], crc.CalculateHardwareCRC(entry[:6]))
+	}
+	putHWPointer(2, relocITOCAddr)
+	putHWPointer(imageSignaturePtr, 0x6400)
+
+	header := data[relocITOCAddr : relocITOCAddr+types.ITOCHeaderSize]
+	clear(header)
+	binary.BigEndian.PutUint32(header, types.ITOCSignature)
+	fs4.UpdateITOCHeaderCRC(header, crc)
+
+	for i, s := range []struct {
+		sectionType uint16
+		addr, size  uint32
+		crcType     types.CRCType
+	}{
+		{types.SectionTypeMainCode, 0x4000, 0x2000, types.CRCInSection},
+		{types.SectionTypePCICode, 0x6000, 0x400, types.CRCInITOCEntry},
+		{types.SectionTypeIronPrepCode, 0x6400, 0x400, types.CRCInSection},
+	} {
+		section := data[s.addr : s.addr+s.size]
+		for j := range section {
+			section[j] = byte(j + i)
+		}
+		entry := &types.ITOCEntry{Type: uint8(s.sectionType), FlashAddrDwords: s.addr, CRCField: uint8(s.crcType)}
+		entry.SetSize(s.size)
+		if s.crcType == types.CRCInSection {
+			binary.BigEndian.PutUint32(section[s.size-4:], uint32(crc.CalculateImageCRC(section, int(s.size/4)-1)))
+		} else {
+			entry.SectionCRC = crc.CalculateImageCRC(section, int(s.size/4))
+		}
+
+		raw, err := entry.Marshal()
+		require.NoError(t, err)
+		binary.BigEndian.PutUint16(raw[30:], crc.CalculateImageCRC(raw[:28], 7))
+		copy(data[relocITOCAddr+types.ITOCHeaderSize+i*types.ITOCEntrySize:], raw)
+	}
+	return data
+}
+
+func parseRelocationImage(t *testing.T, data []byte) *fs4.Parser {
+	t.Helper()
+	fwParser := fs4.NewParser(parser.NewFirmwareReaderFromBytes(data, zaptest.NewLogger(t)), zaptest.NewLogger(t))
+	require.NoError(t, fwParser.Parse())
+	require.True(t, fwParser.IsITOCHeaderValid())
+	return fwParser
+}
+
+func TestReplaceSection_Relocation(t *testing.T) {
+	tests := []struct {
+		name      string
+		newSize   int
+		wantPCI   uint64
+		wantIron  uint64
+		wantError bool
+	}{
+		{name: "same size", newSize: 0x2000, wantPCI: 0x6000, wantIron: 0x6400},
+		// PCI_CODE keeps its sector alignment, IRON_PREP_CODE its 0x400 one
+		{name: "grow", newSize: 0x2804, wantPCI: 0x7000, wantIron: 0x7400},
+		{name: "shrink", newSize: 0x800, wantPCI: 0x5000, wantIron: 0x5400},
+		{name: "unaligned size", newSize: 0x2002, wantError: true},
+		{name: "beyond size limit", newSize: FirmwareSize32MB, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := createRelocationImage(t)
+			fwParser := parseRelocationImage(t, data)
+			target := fwParser.GetSections()[types.SectionTypeMainCode][0]
+
+			newData := bytes.Repeat([]byte{0xA5}, tt.newSize)
+			out, err := NewReplacer(fwParser, data, zaptest.NewLogger(t)).ReplaceSection(target, newData)
+			if tt.wantError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, out, FirmwareSize32MB)
+
+			modified := parseRelocationImage(t, out)
+			for sectionType, want := range map[uint16]struct {
+				offset uint64
+				size   uint32
+			}{
+				types.SectionTypeMainCode:     {0x4000, uint32(tt.newSize)},
+				types.SectionTypePCICode:      {tt.wantPCI, 0x400},
+				types.SectionTypeIronPrepCode: {tt.wantIron, 0x400},
+			} {
+				s := modified.GetSections()[sectionType][0]
+				assert.Equal(t, want.offset, s.Offset(), s.TypeName())
+				assert.Equal(t, want.size, s.Size(), s.TypeName())
+				status, err := modified.VerifySectionNew(s)
+				require.NoError(t, err)
+				assert.Equal(t, "OK", status, s.TypeName())
+			}
+			// The moved sections are copied verbatim
+			assert.Equal(t, data[0x6000:0x6800], out[tt.wantPCI:tt.wantPCI+0x800])
+
+			// The HW pointer follows IRON_PREP_CODE with a valid CRC
+			entry := out[types.HWPointersOffsetFromMagic+imageSignaturePtr*8:][:8]
+			assert.Equal(t, uint32(tt.wantIron), binary.BigEndian.Uint32(entry))
+			assert.Equal(t, parser.NewCRCCalculator().CalculateHardwareCRC(entry[:6]), binary.BigEndian.Uint16(entry[6:]))
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package section
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ansel1/merry/v2"
+
+	"github.com/Civil/mlx5fw-go/pkg/errors"
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/parser"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+// Offsets of the GUID and MAC types.UidEntry blocks, the same in DEV_INFO and
+// MFG_INFO
+const (
+	uidGUIDsOffset = 0x20
+	uidMACsOffset  = 0x30
+	uidEntrySize   = 0x10
+)
+
+// devInfoCRCOffset is the dword holding the embedded DEV_INFO CRC, computed
+// over the bytes before it
+const devInfoCRCOffset = 0x1FC
+
+// MaxMAC is the largest 48-bit MAC address
+const MaxMAC = 1<<48 - 1
+
+// UIDAllocation is a base GUID or MAC and the number of addresses allocated
+// from it
+type UIDAllocation struct {
+	Base  uint64
+	Count uint8
+	// Step is kept as stored when zero
+	Step uint8
+}
+
+// UIDUpdate selects what SetUIDs rewrites; nil allocations are kept
+type UIDUpdate struct {
+	GUIDs *UIDAllocation
+	MACs  *UIDAllocation
+	// MFG also rewrites MFG_INFO
+	MFG bool
+	// Force allows rewriting sections covered by the image signature
+	Force bool
+}
+
+// UpdatedSection is a section SetUIDs, SetVSD or SetPSID rewrote
+type UpdatedSection struct {
+	Section string `json:"section"`
+	Offset  uint64 `json:"offset"`
+	// Signed is set when the image signature covers the section and has to
+	// be regenerated
+	Signed bool `json:"signed,omitempty"`
+}
+
+// SetUIDs rewrites the GUID and MAC allocations of every DEV_INFO copy
+// (DEV_INFO, DEV_INFO1 and DEV_INFO2) and, with update.MFG, of MFG_INFO, in
+// data, like flint's sg and smg. The embedded DEV_INFO CRC and the CRC
+// protecting each section are recomputed. Sections covered by the image
+// signature are refused unless update.Force is set.
+func SetUIDs(data []byte, fwParser interfaces.FirmwareParser, update UIDUpdate) ([]UpdatedSection, error) {
+	if update.GUIDs == nil && update.MACs == nil {
+		return nil, errors.InvalidParameterError("update", "no GUID or MAC allocation given")
+	}
+	if update.MACs != nil && update.MACs.Base > MaxMAC {
+		return nil, errors.InvalidParameterError("MAC", fmt.Sprintf("0x%x is wider than 48 bits", update.MACs.Base))
+	}
+
+	sectionTypes := []uint16{types.SectionTypeDevInfo, types.SectionTypeDevInfo1, types.SectionTypeDevInfo2}
+	if update.MFG {
+		sectionTypes = append(sectionTypes, types.SectionTypeMfgInfo)
+	}
+	targets, err := writableSections(data, fwParser, sectionTypes, update.Force)
+	if err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return nil, errors.SectionNotFoundError("DEV_INFO", 0)
+	}
+
+	var updated []UpdatedSection
+	for _, s := range targets {
+		sectionData := data[s.Offset() : s.Offset()+uint64(s.Size())]
+		if len(sectionData) < uidMACsOffset+uidEntrySize {
+			return nil, errors.DataTooShortError(uidMACsOffset+uidEntrySize, len(sectionData), s.TypeName())
+		}
+		if update.GUIDs != nil {
+			putUIDAllocation(sectionData[uidGUIDsOffset:], update.GUIDs)
+		}
+		if update.MACs != nil {
+			putUIDAllocation(sectionData[uidMACsOffset:], update.MACs)
+		}
+
+		if s.Type() != types.SectionTypeMfgInfo {
+			if len(sectionData) < devInfoCRCOffset+4 {
+				return nil, errors.DataTooShortError(devInfoCRCOffset+4, len(sectionData), s.TypeName())
+			}
+			crc := parser.NewCRCCalculator().CalculateImageCRC(sectionData[:devInfoCRCOffset], devInfoCRCOffset/DwordSize)
+			trailer := sectionData[devInfoCRCOffset:]
+			binary.BigEndian.PutUint32(trailer, binary.BigEndian.Uint32(trailer)&0xFFFF0000|uint32(crc))
+		}
+		if err := UpdateSectionCRC(data, fwParser, s); err != nil {
+			return nil, merry.Prepend(err, "failed to update the "+s.TypeName()+" CRC")
+		}
+		updated = append(updated, UpdatedSection{Section: s.TypeName(), Offset: s.Offset(), Signed: isSigned(fwParser, s)})
+	}
+	return updated, nil
+}
+
+// putUIDAllocation writes an allocation into the types.UidEntry at entry,
+// leaving its reserved bytes alone
+func putUIDAllocation(entry []byte, a *UIDAllocation) {
+	if a.Step != 0 {
+		entry[2] = a.Step
+	}
+	entry[3] = a.Count
+	binary.BigEndian.PutUint64(entry[8:], a.Base)
+}
+
+// writableSections returns the sections of the given types that can be
+// rewritten in place in data. Sections covered by the image signature are an
+// error unless force is set.
+func writableSections(data []byte, fwParser interfaces.FirmwareParser, sectionTypes []uint16, force bool) ([]interfaces.CompleteSectionInterface, error) {
+	if fwParser.GetFormat() == types.FormatFS3 {
+		return nil, errors.NotSupportedError("rewriting sections of " + fwParser.GetFormat().String() + " images")
+	}
+
+	var result []interfaces.CompleteSectionInterface
+	for _, sectionType := range sectionTypes {
+		for _, s := range fwParser.GetSections()[sectionType] {
+			if s.IsFromHWPointer() {
+				continue
+			}
+			if end := s.Offset() + uint64(s.Size()); end > uint64(len(data)) {
+				return nil, errors.DataTooShortError(int(end), len(data), s.TypeName())
+			}
+			if !force && isSigned(fwParser, s) {
+				return nil, merry.Wrap(errors.ErrNotSupported, merry.WithMessagef(
+					"%s at 0x%x is covered by the image signature", s.TypeName(), s.Offset()))
+			}
+			result = append(result, s)
+		}
+	}
+	return result, nil
+}
+
+// isSigned reports whether s is covered by an image signature: the image has
+// an IMAGE_SIGNATURE section and s is not device data, which the signed
+// payload masks
+func isSigned(fwParser interfaces.FirmwareParser, s interfaces.SectionInterface) bool {
+	sections := fwParser.GetSections()
+	signed := len(sections[types.SectionTypeImageSignature256])+len(sections[types.SectionTypeImageSignature512]) > 0
+	return signed && !s.IsDeviceData()
+}
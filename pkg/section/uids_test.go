@@ -0,0 +1,133 @@
+package section
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/parser"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+	"github.com/Civil/mlx5fw-go/pkg/types/sections"
+)
+
+const (
+	uidDTOCAddr    = 0x1000
+	uidDevInfoAddr = 0x2000
+	uidMfgInfoAddr = 0x3000
+	uidMfgInfoSize = 0x140
+)
+
+// uidParser is a FirmwareParser stub with a DTOC and a fixed section list
+type uidParser struct {
+	interfaces.FirmwareParser
+	sections map[uint16][]interfaces.CompleteSectionInterface
+}
+
+func (p *uidParser) GetFormat() types.FirmwareFormat { return types.FormatFS4 }
+func (p *uidParser) GetITOCAddress() uint32          { return 0 }
+func (p *uidParser) GetDTOCAddress() uint32          { return uidDTOCAddr }
+func (p *uidParser) GetSections() map[uint16][]interfaces.CompleteSectionInterface {
+	return p.sections
+}
+
+// createUIDImage builds a DTOC listing DEV_INFO (embedded CRC) and MFG_INFO
+// (CRC in the DTOC entry)
+func createUIDImage(t *testing.T) ([]byte, *uidParser) {
+	t.Helper()
+	data := make([]byte, 0x4000)
+	binary.BigEndian.PutUint32(data[uidDTOCAddr:], types.DTOCSignature)
+	factory := sections.NewDefaultSectionFactory()
+	p := &uidParser{sections: make(map[uint16][]interfaces.CompleteSectionInterface)}
+
+	for i, s := range []struct {
+		sectionType uint16
+		addr, size  uint32
+		crcType     types.CRCType
+	}{
+		{types.SectionTypeDevInfo, uidDevInfoAddr, 0x200, types.CRCInSection},
+		{types.SectionTypeMfgInfo, uidMfgInfoAddr, uidMfgInfoSize, types.CRCInITOCEntry},
+	} {
+		entry := &types.ITOCEntry{Type: uint8(s.sectionType), FlashAddrDwords: s.addr, CRCField: uint8(s.crcType)}
+		entry.SetSize(s.size)
+		raw, err := entry.Marshal()
+		require.NoError(t, err)
+		copy(data[uidDTOCAddr+ITOCEntrySize*(i+1):], raw)
+
+		section, err := factory.CreateSection(s.sectionType, uint64(s.addr), s.size, s.crcType, 0, false, true, entry, false)
+		require.NoError(t, err)
+		p.sections[s.sectionType] = append(p.sections[s.sectionType], section)
+	}
+	data[uidDTOCAddr+ITOCEntrySize*3] = 0xFF // end marker
+
+	// Reserved bytes that must survive the update
+	data[uidDevInfoAddr+uidGUIDsOffset] = 0x5A
+	data[uidDevInfoAddr+uidGUIDsOffset+2] = 1
+	return data, p
+}
+
+func TestSetUIDs(t *testing.T) {
+	data, p := createUIDImage(t)
+
+	updated, err := SetUIDs(data, p, UIDUpdate{
+		GUIDs: &UIDAllocation{Base: 0x0002c90300a1b2c0, Count: 8},
+		MACs:  &UIDAllocation{Base: 0x0002c9a1b2c0, Count: 4, Step: 2},
+		MFG:   true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []UpdatedSection{
+		{Section: "DEV_INFO", Offset: uidDevInfoAddr},
+		{Section: "MFG_INFO", Offset: uidMfgInfoAddr},
+	}, updated)
+
+	var devInfo types.DevInfo
+	require.NoError(t, devInfo.Unmarshal(data[uidDevInfoAddr:]))
+	assert.Equal(t, types.UidEntry{Reserved1: 0x5A00, Step: 1, NumAllocated: 8, UID: 0x0002c90300a1b2c0}, devInfo.Guids)
+	assert.Equal(t, types.UidEntry{Step: 2, NumAllocated: 4, UID: 0x0002c9a1b2c0}, devInfo.Macs)
+
+	// The embedded CRC verifies the way the parser checks it
+	devInfoSection := p.sections[types.SectionTypeDevInfo][0]
+	require.NoError(t, devInfoSection.Parse(data[uidDevInfoAddr:uidDevInfoAddr+0x200]))
+	assert.NoError(t, devInfoSection.VerifyCRC())
+
+	var mfgInfo types.MfgInfo
+	require.NoError(t, mfgInfo.Unmarshal(data[uidMfgInfoAddr:uidMfgInfoAddr+uidMfgInfoSize]))
+	assert.Equal(t, uint64(0x0002c90300a1b2c0), mfgInfo.Guids.UID)
+	assert.Equal(t, uint64(0x0002c9a1b2c0), mfgInfo.Macs.UID)
+
+	entry := &types.ITOCEntry{}
+	require.NoError(t, entry.Unmarshal(data[uidDTOCAddr+2*ITOCEntrySize:]))
+	assert.Equal(t, parser.NewCRCCalculator().CalculateImageCRC(data[uidMfgInfoAddr:uidMfgInfoAddr+uidMfgInfoSize], uidMfgInfoSize/4),
+		entry.SectionCRC)
+}
+
+func TestSetUIDs_Errors(t *testing.T) {
+	guids := &UIDAllocation{Base: 0x0002c90300a1b2c0, Count: 8}
+
+	data, p := createUIDImage(t)
+	_, err := SetUIDs(data, p, UIDUpdate{})
+	assert.Error(t, err, "empty update")
+	_, err = SetUIDs(data, p, UIDUpdate{MACs: &UIDAllocation{Base: 1 << 48}})
+	assert.Error(t, err, "MAC wider than 48 bits")
+
+	// DEV_INFO outside the device data of a signed image is refused unless forced
+	devInfo, err := sections.NewDefaultSectionFactory().CreateSection(types.SectionTypeDevInfo, uidDevInfoAddr, 0x200,
+		types.CRCNone, 0, false, false, nil, false)
+	require.NoError(t, err)
+	p.sections[types.SectionTypeDevInfo] = []interfaces.CompleteSectionInterface{devInfo}
+	p.sections[types.SectionTypeImageSignature256] = []interfaces.CompleteSectionInterface{
+		interfaces.NewBaseSectionWithOptions(types.SectionTypeImageSignature256, 0x3800, 0x140),
+	}
+	_, err = SetUIDs(data, p, UIDUpdate{GUIDs: guids})
+	assert.True(t, errors.Is(err, pkgerrors.ErrNotSupported), "signed DEV_INFO error = %v", err)
+	_, err = SetUIDs(data, p, UIDUpdate{GUIDs: guids, Force: true})
+	assert.NoError(t, err)
+
+	delete(p.sections, types.SectionTypeDevInfo)
+	_, err = SetUIDs(data, p, UIDUpdate{GUIDs: guids})
+	assert.True(t, errors.Is(err, pkgerrors.ErrSectionNotFound), "missing DEV_INFO error = %v", err)
+}
@@ -0,0 +1,104 @@
+package section
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ansel1/merry/v2"
+
+	"github.com/Civil/mlx5fw-go/pkg/errors"
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+// Lengths of the types.ImageInfo VSD and PSID fields; the MFG_INFO PSID has
+// the same length
+const (
+	MaxVSDLength  = 208
+	MaxPSIDLength = 16
+)
+
+// Offsets of the string fields SetVSD and SetPSID rewrite
+const (
+	imageInfoPSIDOffset = 0x24
+	imageInfoVSDOffset  = 0x38
+	mfgInfoPSIDOffset   = 0x0
+)
+
+// stringField is a fixed-size, NUL-padded string field of a section type
+type stringField struct {
+	sectionType uint16
+	offset      int
+	size        int
+}
+
+// SetVSD rewrites the vendor specific data string of IMAGE_INFO in data,
+// like flint's sv, and recomputes the CRC protecting the section. Sections
+// covered by the image signature are rewritten too; UpdatedSection.Signed
+// reports them.
+func SetVSD(data []byte, fwParser interfaces.FirmwareParser, vsd string) ([]UpdatedSection, error) {
+	if err := validateString("VSD", vsd, MaxVSDLength); err != nil {
+		return nil, err
+	}
+	return setStringFields(data, fwParser, []stringField{
+		{types.SectionTypeImageInfo, imageInfoVSDOffset, MaxVSDLength},
+	}, vsd)
+}
+
+// SetPSID rewrites the PSID of IMAGE_INFO and, with mfg, of MFG_INFO in data,
+// and recomputes the CRC protecting each section. Sections covered by the
+// image signature are rewritten too; UpdatedSection.Signed reports them.
+func SetPSID(data []byte, fwParser interfaces.FirmwareParser, psid string, mfg bool) ([]UpdatedSection, error) {
+	if psid == "" {
+		return nil, errors.InvalidParameterError("PSID", "must not be empty")
+	}
+	if err := validateString("PSID", psid, MaxPSIDLength); err != nil {
+		return nil, err
+	}
+	fields := []stringField{{types.SectionTypeImageInfo, imageInfoPSIDOffset, MaxPSIDLength}}
+	if mfg {
+		fields = append(fields, stringField{types.SectionTypeMfgInfo, mfgInfoPSIDOffset, MaxPSIDLength})
+	}
+	return setStringFields(data, fwParser, fields, psid)
+}
+
+// validateString checks that value fits a NUL-padded field of maxLen bytes
+func validateString(name, value string, maxLen int) error {
+	if len(value) > maxLen {
+		return errors.InvalidParameterError(name, fmt.Sprintf("%d bytes exceed the %d-byte field", len(value), maxLen))
+	}
+	if strings.IndexByte(value, 0) >= 0 {
+		return errors.InvalidParameterError(name, "contains a NUL byte")
+	}
+	return nil
+}
+
+// setStringFields writes value, padded with NULs, into every field and
+// updates the CRC of each section it changed
+func setStringFields(data []byte, fwParser interfaces.FirmwareParser, fields []stringField, value string) ([]UpdatedSection, error) {
+	var updated []UpdatedSection
+	for _, field := range fields {
+		targets, err := writableSections(data, fwParser, []uint16{field.sectionType}, true)
+		if err != nil {
+			return nil, err
+		}
+		if len(targets) == 0 {
+			return nil, errors.SectionNotFoundError(types.GetSectionTypeName(field.sectionType), 0)
+		}
+
+		for _, s := range targets {
+			if int(s.Size()) < field.offset+field.size {
+				return nil, errors.DataTooShortError(field.offset+field.size, int(s.Size()), s.TypeName())
+			}
+			dst := data[s.Offset()+uint64(field.offset) : s.Offset()+uint64(field.offset+field.size)]
+			clear(dst)
+			copy(dst, value)
+
+			if err := UpdateSectionCRC(data, fwParser, s); err != nil {
+				return nil, merry.Prepend(err, "failed to update the "+s.TypeName()+" CRC")
+			}
+			updated = append(updated, UpdatedSection{Section: s.TypeName(), Offset: s.Offset(), Signed: isSigned(fwParser, s)})
+		}
+	}
+	return updated, nil
+}
@@ -0,0 +1,111 @@
+package section
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/parser"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+	"github.com/Civil/mlx5fw-go/pkg/types/sections"
+)
+
+const (
+	vsdImageInfoAddr = 0x3800
+	vsdImageInfoSize = 0x400
+)
+
+// createImageInfoImage extends createUIDImage with an ITOC at 0 listing
+// IMAGE_INFO (CRC in the ITOC entry)
+func createImageInfoImage(t *testing.T) ([]byte, *uidParser) {
+	t.Helper()
+	data, p := createUIDImage(t)
+	binary.BigEndian.PutUint32(data, types.ITOCSignature)
+
+	entry := &types.ITOCEntry{Type: uint8(types.SectionTypeImageInfo), FlashAddrDwords: vsdImageInfoAddr,
+		CRCField: uint8(types.CRCInITOCEntry)}
+	entry.SetSize(vsdImageInfoSize)
+	raw, err := entry.Marshal()
+	require.NoError(t, err)
+	copy(data[ITOCEntrySize:], raw)
+	data[2*ITOCEntrySize] = 0xFF // end marker
+
+	imageInfo, err := sections.NewDefaultSectionFactory().CreateSection(types.SectionTypeImageInfo, vsdImageInfoAddr,
+		vsdImageInfoSize, types.CRCInITOCEntry, 0, false, false, entry, false)
+	require.NoError(t, err)
+	p.sections[types.SectionTypeImageInfo] = []interfaces.CompleteSectionInterface{imageInfo}
+
+	// A stale value longer than the new ones
+	copy(data[vsdImageInfoAddr+imageInfoVSDOffset:], strings.Repeat("x", MaxVSDLength))
+	copy(data[vsdImageInfoAddr+imageInfoPSIDOffset:], "MT_0000000000000")
+	return data, p
+}
+
+func TestSetVSDAndPSID(t *testing.T) {
+	data, p := createImageInfoImage(t)
+
+	updated, err := SetVSD(data, p, "OEM build 7")
+	require.NoError(t, err)
+	assert.Equal(t, []UpdatedSection{{Section: "IMAGE_INFO", Offset: vsdImageInfoAddr}}, updated)
+
+	updated, err = SetPSID(data, p, "MT_0000000911", true)
+	require.NoError(t, err)
+	assert.Equal(t, []UpdatedSection{
+		{Section: "IMAGE_INFO", Offset: vsdImageInfoAddr},
+		{Section: "MFG_INFO", Offset: uidMfgInfoAddr},
+	}, updated)
+
+	var imageInfo types.ImageInfo
+	require.NoError(t, imageInfo.Unmarshal(data[vsdImageInfoAddr:vsdImageInfoAddr+vsdImageInfoSize]))
+	assert.Equal(t, "OEM build 7", imageInfo.GetVSDString())
+	assert.Equal(t, "MT_0000000911", imageInfo.GetPSIDString())
+
+	var mfgInfo types.MfgInfo
+	require.NoError(t, mfgInfo.Unmarshal(data[uidMfgInfoAddr:uidMfgInfoAddr+uidMfgInfoSize]))
+	assert.Equal(t, "MT_0000000911", strings.TrimRight(string(mfgInfo.PSID[:]), "\x00"))
+
+	entry := &types.ITOCEntry{}
+	require.NoError(t, entry.Unmarshal(data[ITOCEntrySize:]))
+	assert.Equal(t, parser.NewCRCCalculator().CalculateImageCRC(data[vsdImageInfoAddr:vsdImageInfoAddr+vsdImageInfoSize],
+		vsdImageInfoSize/4), entry.SectionCRC)
+	assert.Equal(t, parser.NewCRCCalculator().CalculateImageCRC(data[ITOCEntrySize:ITOCEntrySize+28], 7),
+		binary.BigEndian.Uint16(data[ITOCEntrySize+30:]))
+
+	// The full field width is accepted
+	_, err = SetVSD(data, p, strings.Repeat("v", MaxVSDLength))
+	assert.NoError(t, err)
+}
+
+func TestSetVSDAndPSID_Errors(t *testing.T) {
+	data, p := createImageInfoImage(t)
+
+	_, err := SetVSD(data, p, strings.Repeat("v", MaxVSDLength+1))
+	assert.True(t, errors.Is(err, pkgerrors.ErrInvalidParameter), "long VSD error = %v", err)
+	_, err = SetPSID(data, p, strings.Repeat("p", MaxPSIDLength+1), false)
+	assert.True(t, errors.Is(err, pkgerrors.ErrInvalidParameter), "long PSID error = %v", err)
+	_, err = SetPSID(data, p, "", false)
+	assert.True(t, errors.Is(err, pkgerrors.ErrInvalidParameter), "empty PSID error = %v", err)
+	_, err = SetPSID(data, p, "MT\x00", false)
+	assert.True(t, errors.Is(err, pkgerrors.ErrInvalidParameter), "PSID with NUL error = %v", err)
+
+	// Signed sections are rewritten and reported
+	p.sections[types.SectionTypeImageSignature256] = []interfaces.CompleteSectionInterface{
+		interfaces.NewBaseSectionWithOptions(types.SectionTypeImageSignature256, 0x3c00, 0x140),
+	}
+	updated, err := SetPSID(data, p, "MT_0000000911", true)
+	require.NoError(t, err)
+	assert.Equal(t, []UpdatedSection{
+		{Section: "IMAGE_INFO", Offset: vsdImageInfoAddr, Signed: true},
+		{Section: "MFG_INFO", Offset: uidMfgInfoAddr},
+	}, updated)
+
+	delete(p.sections, types.SectionTypeImageInfo)
+	_, err = SetVSD(data, p, "OEM")
+	assert.True(t, errors.Is(err, pkgerrors.ErrSectionNotFound), "missing IMAGE_INFO error = %v", err)
+}
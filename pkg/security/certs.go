@@ -0,0 +1,270 @@
+package security
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/ansel1/merry/v2"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+// certificateSectionTypes are the sections that carry X.509 certificates
+var certificateSectionTypes = []uint16{
+	types.SectionTypeNvdaRotCertificates,
+	types.SectionTypeCertChain0,
+	types.SectionTypeDigitalCertPtr,
+	types.SectionTypeDigitalCertRw,
+}
+
+// IsCertificateSection reports whether sections of this type carry X.509 certificates
+func IsCertificateSection(sectionType uint16) bool {
+	for _, t := range certificateSectionTypes {
+		if t == sectionType {
+			return true
+		}
+	}
+	return false
+}
+
+// Certificate is a DER certificate found in the image
+type Certificate struct {
+	// Offset is the image offset of the DER encoding
+	Offset uint64
+	Cert   *x509.Certificate
+}
+
+// CertificateInfo is the JSON description of a certificate
+type CertificateInfo struct {
+	Offset             uint64    `json:"offset"`
+	Subject            string    `json:"subject"`
+	Issuer             string    `json:"issuer"`
+	SerialNumber       string    `json:"serial_number"`
+	NotBefore          time.Time `json:"not_before"`
+	NotAfter           time.Time `json:"not_after"`
+	KeyAlgorithm       string    `json:"key_algorithm"`
+	SignatureAlgorithm string    `json:"signature_algorithm"`
+	IsCA               bool      `json:"is_ca"`
+	SHA1Fingerprint    string    `json:"sha1_fingerprint"`
+	SHA256Fingerprint  string    `json:"sha256_fingerprint"`
+}
+
+// Info returns the JSON description of c
+func (c Certificate) Info() CertificateInfo {
+	sha1Sum := sha1.Sum(c.Cert.Raw)
+	sha256Sum := sha256.Sum256(c.Cert.Raw)
+	return CertificateInfo{
+		Offset:             c.Offset,
+		Subject:            c.Cert.Subject.String(),
+		Issuer:             c.Cert.Issuer.String(),
+		SerialNumber:       c.Cert.SerialNumber.Text(16),
+		NotBefore:          c.Cert.NotBefore.UTC(),
+		NotAfter:           c.Cert.NotAfter.UTC(),
+		KeyAlgorithm:       keyAlgorithm(c.Cert),
+		SignatureAlgorithm: c.Cert.SignatureAlgorithm.String(),
+		IsCA:               c.Cert.IsCA,
+		SHA1Fingerprint:    hex.EncodeToString(sha1Sum[:]),
+		SHA256Fingerprint:  hex.EncodeToString(sha256Sum[:]),
+	}
+}
+
+// keyAlgorithm names the public key algorithm and size of cert, e.g. "RSA-4096"
+func keyAlgorithm(cert *x509.Certificate) string {
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA-%d", key.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ECDSA-" + key.Curve.Params().Name
+	case ed25519.PublicKey:
+		return "Ed25519"
+	default:
+		return cert.PublicKeyAlgorithm.String()
+	}
+}
+
+// ParseDERCertificates finds the DER certificates in data, which may hold
+// them back to back or inside a vendor container with headers and padding.
+// base is the image offset of data.
+func ParseDERCertificates(data []byte, base uint64) []Certificate {
+	var certs []Certificate
+	for i := 0; i+4 <= len(data); i++ {
+		// A certificate is a SEQUENCE with a 2- or 3-byte long-form length
+		if data[i] != 0x30 || (data[i+1] != 0x82 && data[i+1] != 0x83) {
+			continue
+		}
+		lengthBytes := int(data[i+1] & 0x7F)
+		if i+2+lengthBytes > len(data) {
+			continue
+		}
+		length := 0
+		for _, b := range data[i+2 : i+2+lengthBytes] {
+			length = length<<8 | int(b)
+		}
+		end := i + 2 + lengthBytes + length
+		if end > len(data) {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(data[i:end])
+		if err != nil {
+			continue
+		}
+		certs = append(certs, Certificate{Offset: base + uint64(i), Cert: cert})
+		i = end - 1
+	}
+	return certs
+}
+
+// SectionCertificates returns the certificates stored in a certificate
+// section. DIGITAL_CERT_PTR also yields the certificate it points to, with
+// its offset taken as a flash address.
+func SectionCertificates(fwParser interfaces.FirmwareParser, s interfaces.SectionInterface) ([]Certificate, error) {
+	if !IsCertificateSection(s.Type()) {
+		return nil, nil
+	}
+	data, err := fwParser.ReadSectionData(s.Type(), s.Offset(), s.Size())
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+	certs := ParseDERCertificates(data, s.Offset())
+
+	if s.Type() == types.SectionTypeDigitalCertPtr && len(data) >= 0x28 {
+		var ptr types.DigitalCertPtr
+		if err := ptr.Unmarshal(data[:0x28]); err != nil {
+			return nil, merry.Wrap(err)
+		}
+		if ptr.CertSize > 0 && ptr.CertSize <= 0x10000 && ptr.CertOffset != 0xFFFFFFFF {
+			// A pointer outside the image is reported as no certificate
+			if target, err := fwParser.ReadSectionData(s.Type(), uint64(ptr.CertOffset), ptr.CertSize); err == nil {
+				certs = append(certs, ParseDERCertificates(target, uint64(ptr.CertOffset))...)
+			}
+		}
+	}
+	return certs, nil
+}
+
+// LoadCertPool reads one or more PEM certificates into a pool of trust roots
+func LoadCertPool(path string) (*x509.CertPool, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(raw) {
+		return nil, merry.Wrap(pkgerrors.ErrInvalidData, merry.WithMessagef("%s: no PEM certificates found", path))
+	}
+	return pool, nil
+}
+
+// ChainStatus is the verification status of one certificate chain
+type ChainStatus string
+
+const (
+	ChainValid   ChainStatus = "VALID"
+	ChainInvalid ChainStatus = "INVALID"
+)
+
+// ChainResult is the verification of one leaf certificate of a section
+type ChainResult struct {
+	Section string `json:"section"`
+	Offset  uint64 `json:"offset"`
+	Leaf    string `json:"leaf"`
+	// Path lists the subjects from the leaf to the trust root when valid
+	Path   []string    `json:"path,omitempty"`
+	Status ChainStatus `json:"status"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// CertificateReport is the result of VerifyCertificateChains
+type CertificateReport struct {
+	Chains []ChainResult `json:"chains"`
+	// OK is true when the image holds certificates and every chain is valid
+	OK bool `json:"ok"`
+}
+
+// VerifyCertificateChains verifies the certificates of every certificate
+// section against roots at the given time. Within a section, every
+// certificate that issued none of the others is a leaf; the others serve as
+// intermediates. Sections without certificates are skipped.
+func VerifyCertificateChains(fwParser interfaces.FirmwareParser, roots *x509.CertPool, at time.Time) (*CertificateReport, error) {
+	var sections []interfaces.CompleteSectionInterface
+	for _, sectionType := range certificateSectionTypes {
+		sections = append(sections, fwParser.GetSections()[sectionType]...)
+	}
+	sort.SliceStable(sections, func(i, j int) bool { return sections[i].Offset() < sections[j].Offset() })
+
+	report := &CertificateReport{}
+	for _, s := range sections {
+		certs, err := SectionCertificates(fwParser, s)
+		if err != nil {
+			return nil, err
+		}
+
+		intermediates := x509.NewCertPool()
+		for _, c := range certs {
+			intermediates.AddCert(c.Cert)
+		}
+		for _, leaf := range chainLeaves(certs) {
+			result := ChainResult{
+				Section: s.TypeName(),
+				Offset:  leaf.Offset,
+				Leaf:    leaf.Cert.Subject.String(),
+				Status:  ChainValid,
+			}
+			chains, err := leaf.Cert.Verify(x509.VerifyOptions{
+				Roots:         roots,
+				Intermediates: intermediates,
+				CurrentTime:   at,
+				KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+			})
+			if err != nil {
+				result.Status = ChainInvalid
+				result.Error = err.Error()
+			} else {
+				for _, c := range chains[0] {
+					result.Path = append(result.Path, c.Subject.String())
+				}
+			}
+			report.Chains = append(report.Chains, result)
+		}
+	}
+
+	report.OK = len(report.Chains) > 0
+	for _, chain := range report.Chains {
+		if chain.Status != ChainValid {
+			report.OK = false
+		}
+	}
+	return report, nil
+}
+
+// chainLeaves returns the certificates that did not issue any other certificate of certs
+func chainLeaves(certs []Certificate) []Certificate {
+	var leaves []Certificate
+	for i, c := range certs {
+		issuer := false
+		for j, other := range certs {
+			if i != j && bytes.Equal(other.Cert.RawIssuer, c.Cert.RawSubject) &&
+				!bytes.Equal(other.Cert.RawSubject, other.Cert.RawIssuer) {
+				issuer = true
+				break
+			}
+		}
+		if !issuer {
+			leaves = append(leaves, c)
+		}
+	}
+	return leaves
+}
@@ -0,0 +1,155 @@
+package security
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"math/big"
+	"testing"
+	"time"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+// certParser serves ReadSectionData from an in-memory image
+type certParser struct {
+	stubParser
+	data []byte
+}
+
+func (p *certParser) ReadSectionData(_ uint16, offset uint64, size uint32) ([]byte, error) {
+	if offset+uint64(size) > uint64(len(p.data)) {
+		return nil, pkgerrors.ErrInvalidOffset
+	}
+	return p.data[offset : offset+uint64(size)], nil
+}
+
+var testCertTime = time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+// newTestCert creates a certificate for name signed by parent, or a
+// self-signed one when parent is nil
+func newTestCert(t *testing.T, name string, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             testCertTime.AddDate(-1, 0, 0),
+		NotAfter:              testCertTime.AddDate(1, 0, 0),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+	}
+	if isCA {
+		template.KeyUsage = x509.KeyUsageCertSign
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+// createCertImage builds an image whose CERT_CHAIN_0 holds a leaf and its
+// intermediate behind a small header, and whose DIGITAL_CERT_PTR points to
+// the intermediate. It returns the image parser and the root.
+func createCertImage(t *testing.T) (*certParser, *x509.Certificate) {
+	t.Helper()
+	root, rootKey := newTestCert(t, "Test Root", true, nil, nil)
+	inter, interKey := newTestCert(t, "Test Intermediate", true, root, rootKey)
+	leaf, _ := newTestCert(t, "Test Device", false, inter, interKey)
+
+	const chainAddr, chainSize, ptrAddr, ptrSize = 0x1000, 0x1000, 0x2000, 0x28
+	data := bytes.Repeat([]byte{0xFF}, 0x3000)
+	chain := data[chainAddr : chainAddr+chainSize]
+	binary.BigEndian.PutUint32(chain, 2)
+	n := copy(chain[0x10:], leaf.Raw)
+	copy(chain[0x10+n:], inter.Raw)
+
+	ptr := data[ptrAddr : ptrAddr+ptrSize]
+	binary.BigEndian.PutUint32(ptr[4:], uint32(chainAddr+0x10+n))
+	binary.BigEndian.PutUint32(ptr[8:], uint32(len(inter.Raw)))
+
+	fwParser := &certParser{data: data}
+	fwParser.sections = map[uint16][]interfaces.CompleteSectionInterface{
+		types.SectionTypeCertChain0:     {interfaces.NewBaseSectionWithOptions(types.SectionTypeCertChain0, chainAddr, chainSize)},
+		types.SectionTypeDigitalCertPtr: {interfaces.NewBaseSectionWithOptions(types.SectionTypeDigitalCertPtr, ptrAddr, ptrSize)},
+	}
+	return fwParser, root
+}
+
+func TestSectionCertificates(t *testing.T) {
+	fwParser, _ := createCertImage(t)
+
+	chain := fwParser.sections[types.SectionTypeCertChain0][0]
+	certs, err := SectionCertificates(fwParser, chain)
+	if err != nil {
+		t.Fatalf("SectionCertificates() error = %v", err)
+	}
+	if len(certs) != 2 || certs[0].Offset != 0x1010 {
+		t.Fatalf("SectionCertificates() = %+v, want leaf at 0x1010 and intermediate", certs)
+	}
+	info := certs[0].Info()
+	if info.Subject != "CN=Test Device" || info.Issuer != "CN=Test Intermediate" ||
+		info.KeyAlgorithm != "ECDSA-P-256" || info.IsCA || len(info.SHA256Fingerprint) != 64 {
+		t.Errorf("Info() = %+v", info)
+	}
+
+	ptr := fwParser.sections[types.SectionTypeDigitalCertPtr][0]
+	certs, err = SectionCertificates(fwParser, ptr)
+	if err != nil {
+		t.Fatalf("SectionCertificates() error = %v", err)
+	}
+	if len(certs) != 1 || certs[0].Cert.Subject.CommonName != "Test Intermediate" {
+		t.Errorf("SectionCertificates(DIGITAL_CERT_PTR) = %+v", certs)
+	}
+}
+
+func TestVerifyCertificateChains(t *testing.T) {
+	fwParser, root := createCertImage(t)
+	otherRoot, _ := newTestCert(t, "Other Root", true, nil, nil)
+
+	tests := []struct {
+		name   string
+		root   *x509.Certificate
+		at     time.Time
+		wantOK bool
+	}{
+		{name: "trusted root", root: root, at: testCertTime, wantOK: true},
+		{name: "untrusted root", root: otherRoot, at: testCertTime},
+		{name: "expired", root: root, at: testCertTime.AddDate(2, 0, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roots := x509.NewCertPool()
+			roots.AddCert(tt.root)
+			report, err := VerifyCertificateChains(fwParser, roots, tt.at)
+			if err != nil {
+				t.Fatalf("VerifyCertificateChains() error = %v", err)
+			}
+			// One chain for the CERT_CHAIN_0 leaf, one for the pointed-to intermediate
+			if len(report.Chains) != 2 || report.OK != tt.wantOK {
+				t.Fatalf("VerifyCertificateChains() = %+v", report)
+			}
+			if tt.wantOK && len(report.Chains[0].Path) != 3 {
+				t.Errorf("leaf path = %v, want leaf, intermediate and root", report.Chains[0].Path)
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+// Package security checks and updates the integrity structures of FS4/FS5
+// images, such as the HASHES_TABLE digests of the ITOC sections.
+package security
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+
+	"github.com/ansel1/merry/v2"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/parser"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+// HashStatus is the verification status of one HTOC entry
+type HashStatus string
+
+const (
+	HashMatch     HashStatus = "MATCH"
+	HashMismatch  HashStatus = "MISMATCH"
+	HashNoSection HashStatus = "NO SECTION"
+)
+
+// HashEntry is one HTOC entry compared with the section it covers
+type HashEntry struct {
+	Index         int        `json:"index"`
+	SectionType   uint16     `json:"section_type"`
+	Section       string     `json:"section"`
+	SectionOffset uint64     `json:"section_offset"`
+	SectionSize   uint32     `json:"section_size"`
+	HashOffset    uint64     `json:"hash_offset"`
+	Expected      string     `json:"expected"`
+	Actual        string     `json:"actual,omitempty"`
+	Status        HashStatus `json:"status"`
+}
+
+// HashesReport is the result of VerifyHashes and UpdateHashes
+type HashesReport struct {
+	TableOffset uint64      `json:"table_offset"`
+	TableSize   uint32      `json:"table_size"`
+	Version     uint32      `json:"version"`
+	Algorithm   string      `json:"algorithm"`
+	Entries     []HashEntry `json:"entries"`
+	// StoredCRC and CalculatedCRC are the table trailer CRC16
+	StoredCRC     uint16 `json:"stored_crc"`
+	CalculatedCRC uint16 `json:"calculated_crc"`
+	// OK is true when every entry matches its section and the table CRC is valid
+	OK bool `json:"ok"`
+}
+
+// HashesTable is a decoded HASHES_TABLE section: a 12-byte header followed by
+// the HTOC (header, entries, then one digest slot per entry)
+type HashesTable struct {
+	Offset  uint64
+	Size    uint32
+	Header  types.FS4HashesTableHeader
+	HTOC    types.FS4HtocHeader
+	Entries []types.FS4HtocEntry
+}
+
+// ReadHashesTable decodes the HASHES_TABLE at offset in data
+func ReadHashesTable(data []byte, offset uint64) (*HashesTable, error) {
+	if offset+types.HashesTableHeaderSize+types.HTOCHeaderSize > uint64(len(data)) {
+		return nil, pkgerrors.DataTooShortError(int(offset)+types.HashesTableHeaderSize+types.HTOCHeaderSize, len(data), "HASHES_TABLE")
+	}
+
+	t := &HashesTable{Offset: offset}
+	if err := t.Header.Unmarshal(data[offset : offset+types.HashesTableHeaderSize]); err != nil {
+		return nil, merry.Wrap(err)
+	}
+	// Per mstflint the table spans (4 + DwSize) dwords
+	t.Size = (4 + t.Header.DwSize) * 4
+	if offset+uint64(t.Size) > uint64(len(data)) {
+		return nil, pkgerrors.DataTooShortError(int(offset)+int(t.Size), len(data), "HASHES_TABLE")
+	}
+
+	htoc := t.htocOffset()
+	tableEnd := offset + uint64(t.Size)
+	if htoc+types.HTOCHeaderSize > tableEnd {
+		return nil, merry.Wrap(pkgerrors.ErrInvalidSize,
+			merry.WithMessagef("HASHES_TABLE of 0x%x bytes is too small for its HTOC header", t.Size))
+	}
+	if err := t.HTOC.Unmarshal(data[htoc : htoc+types.HTOCHeaderSize]); err != nil {
+		return nil, merry.Wrap(err)
+	}
+	if _, err := t.newHash(); err != nil {
+		return nil, err
+	}
+
+	if entriesEnd := htoc + types.HTOCHeaderSize + uint64(t.HTOC.NumOfEntries)*types.HTOCEntrySize; entriesEnd > tableEnd {
+		return nil, merry.Wrap(pkgerrors.ErrInvalidSize,
+			merry.WithMessagef("%d HTOC entries end at 0x%x, past the HASHES_TABLE end at 0x%x", t.HTOC.NumOfEntries, entriesEnd, tableEnd))
+	}
+
+	for i := 0; i < int(t.HTOC.NumOfEntries); i++ {
+		entryOffset := htoc + types.HTOCHeaderSize + uint64(i)*types.HTOCEntrySize
+		var entry types.FS4HtocEntry
+		if err := entry.Unmarshal(data[entryOffset : entryOffset+types.HTOCEntrySize]); err != nil {
+			return nil, merry.Wrap(err)
+		}
+		if hashEnd := htoc + uint64(entry.HashOffset) + uint64(t.HTOC.HashSize); hashEnd > tableEnd {
+			return nil, merry.Wrap(pkgerrors.ErrInvalidOffset,
+				merry.WithMessagef("HTOC entry %d digest at 0x%x exceeds the HASHES_TABLE", i, hashEnd-uint64(t.HTOC.HashSize)))
+		}
+		t.Entries = append(t.Entries, entry)
+	}
+	return t, nil
+}
+
+// htocOffset returns the image offset of the HTOC; digest offsets are relative to it
+func (t *HashesTable) htocOffset() uint64 {
+	return t.Offset + types.HashesTableHeaderSize
+}
+
+// HashOffset returns the image offset of the digest of entry i
+func (t *HashesTable) HashOffset(i int) uint64 {
+	return t.htocOffset() + uint64(t.Entries[i].HashOffset)
+}
+
+// Algorithm returns the name of the digest algorithm used by the table
+func (t *HashesTable) Algorithm() string {
+	switch t.HTOC.HashSize {
+	case sha256.Size:
+		return "SHA256"
+	case sha512.Size384:
+		return "SHA384"
+	case sha512.Size:
+		return "SHA512"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// newHash returns the digest algorithm matching the HTOC hash size
+func (t *HashesTable) newHash() (hash.Hash, error) {
+	switch t.HTOC.HashSize {
+	case sha256.Size:
+		return sha256.New(), nil
+	case sha512.Size384:
+		return sha512.New384(), nil
+	case sha512.Size:
+		return sha512.New(), nil
+	default:
+		return nil, pkgerrors.NotSupportedError(fmt.Sprintf("HTOC hash size %d", t.HTOC.HashSize))
+	}
+}
+
+// digest hashes the image bytes of section
+func (t *HashesTable) digest(data []byte, s interfaces.SectionInterface) ([]byte, error) {
+	end := s.Offset() + uint64(s.Size())
+	if end > uint64(len(data)) {
+		return nil, pkgerrors.DataTooShortError(int(end), len(data), s.TypeName())
+	}
+	h, err := t.newHash()
+	if err != nil {
+		return nil, err
+	}
+	h.Write(data[s.Offset():end])
+	return h.Sum(nil), nil
+}
+
+// FindHashesTable returns the HASHES_TABLE section of a parsed image
+func FindHashesTable(fwParser interfaces.FirmwareParser) (interfaces.CompleteSectionInterface, error) {
+	list := fwParser.GetSections()[types.SectionTypeHashesTable]
+	if len(list) == 0 {
+		return nil, merry.Wrap(pkgerrors.ErrSectionNotFound, merry.WithMessage("image has no HASHES_TABLE"))
+	}
+	return list[0], nil
+}
+
+// hashedSection returns the ITOC section an HTOC entry refers to. Device data
+// can change per board and is never covered by the table.
+func hashedSection(fwParser interfaces.FirmwareParser, sectionType uint16) interfaces.CompleteSectionInterface {
+	for _, s := range fwParser.GetSections()[sectionType] {
+		if !s.IsDeviceData() && !s.IsFromHWPointer() {
+			return s
+		}
+	}
+	return nil
+}
+
+// VerifyHashes recomputes the digest of every section listed in the HTOC of
+// the image in data and compares it with the stored one
+func VerifyHashes(data []byte, fwParser interfaces.FirmwareParser) (*HashesReport, error) {
+	tableSection, err := FindHashesTable(fwParser)
+	if err != nil {
+		return nil, err
+	}
+	table, err := ReadHashesTable(data, tableSection.Offset())
+	if err != nil {
+		return nil, merry.Prepend(err, "failed to read HASHES_TABLE")
+	}
+
+	report := &HashesReport{
+		TableOffset: table.Offset,
+		TableSize:   table.Size,
+		Version:     table.HTOC.Version,
+		Algorithm:   table.Algorithm(),
+	}
+	report.StoredCRC, report.CalculatedCRC = table.crc(data)
+	report.OK = report.StoredCRC == report.CalculatedCRC
+	hashSize := uint64(table.HTOC.HashSize)
+	for i, entry := range table.Entries {
+		hashOffset := table.HashOffset(i)
+		expected := data[hashOffset : hashOffset+hashSize]
+		result := HashEntry{
+			Index:       i,
+			SectionType: uint16(entry.SectionType),
+			Section:     types.GetSectionTypeName(uint16(entry.SectionType)),
+			HashOffset:  hashOffset,
+			Expected:    hex.EncodeToString(expected),
+			Status:      HashNoSection,
+		}
+
+		if s := hashedSection(fwParser, uint16(entry.SectionType)); s != nil {
+			actual, err := table.digest(data, s)
+			if err != nil {
+				return nil, err
+			}
+			result.SectionOffset = s.Offset()
+			result.SectionSize = s.Size()
+			result.Actual = hex.EncodeToString(actual)
+			result.Status = HashMismatch
+			if bytes.Equal(actual, expected) {
+				result.Status = HashMatch
+			}
+		}
+		if result.Status != HashMatch {
+			report.OK = false
+		}
+		report.Entries = append(report.Entries, result)
+	}
+	return report, nil
+}
+
+// UpdateHashes rewrites every HTOC digest of the image in data from the
+// current section contents and fixes the HASHES_TABLE CRC. Entries whose
+// section is missing are left untouched. The returned report describes the
+// table after the update.
+//
+// The caller must pass a parser of the image in data, so that the section
+// offsets match the bytes being hashed.
+func UpdateHashes(data []byte, fwParser interfaces.FirmwareParser) (*HashesReport, error) {
+	tableSection, err := FindHashesTable(fwParser)
+	if err != nil {
+		return nil, err
+	}
+	table, err := ReadHashesTable(data, tableSection.Offset())
+	if err != nil {
+		return nil, merry.Prepend(err, "failed to read HASHES_TABLE")
+	}
+
+	for i, entry := range table.Entries {
+		s := hashedSection(fwParser, uint16(entry.SectionType))
+		if s == nil {
+			continue
+		}
+		digest, err := table.digest(data, s)
+		if err != nil {
+			return nil, err
+		}
+		copy(data[table.HashOffset(i):], digest)
+	}
+
+	// mstflint always checks this trailer, so it is written here rather than
+	// through the in-section CRC policy, which keeps HASHES_TABLE trailers as is
+	_, crc := table.crc(data)
+	binary.BigEndian.PutUint32(data[table.Offset+uint64(table.Size)-4:], uint32(crc))
+	return VerifyHashes(data, fwParser)
+}
+
+// crc returns the stored and calculated CRC16 of the table, which lives in the
+// lower 16 bits of its last dword
+func (t *HashesTable) crc(data []byte) (stored, calculated uint16) {
+	tableData := data[t.Offset : t.Offset+uint64(t.Size)]
+	stored = uint16(binary.BigEndian.Uint32(tableData[len(tableData)-4:]))
+	calculated = parser.NewCRCCalculator().CalculateImageCRC(tableData, len(tableData)/4-1)
+	return stored, calculated
+}
@@ -0,0 +1,193 @@
+package security
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+const (
+	testTableAddr   = 0x1000
+	testBoot3Addr   = 0x2000
+	testBoot3Size   = 0x100
+	testImgInfoAddr = 0x3000
+	testImgInfoSize = 0x400
+	// mstflint table: 28 entry slots before the digests, 64-byte digests
+	testDigestsOffset = 0xf0
+	testTableSize     = types.HashesTableHeaderSize + testDigestsOffset + 2*sha512.Size + 8
+)
+
+// stubParser exposes a fixed section list; the remaining methods are unused
+type stubParser struct {
+	interfaces.FirmwareParser
+	sections    map[uint16][]interfaces.CompleteSectionInterface
+	magicOffset uint32
+	itocAddr    uint32
+}
+
+func (p *stubParser) GetSections() map[uint16][]interfaces.CompleteSectionInterface {
+	return p.sections
+}
+
+func (p *stubParser) GetMagicOffset() uint32 {
+	return p.magicOffset
+}
+
+func (p *stubParser) GetITOCAddress() uint32 {
+	return p.itocAddr
+}
+
+func (p *stubParser) IsEncrypted() bool {
+	return false
+}
+
+// createHashesImage builds an image with a SHA-512 HASHES_TABLE covering
+// BOOT3_CODE and IMAGE_INFO, with valid digests and CRC
+func createHashesImage(t *testing.T) ([]byte, *stubParser) {
+	t.Helper()
+	data := bytes.Repeat([]byte{0xFF}, 0x4000)
+	for i := 0; i < testBoot3Size; i++ {
+		data[testBoot3Addr+i] = byte(i)
+	}
+	for i := 0; i < testImgInfoSize; i++ {
+		data[testImgInfoAddr+i] = byte(i * 7)
+	}
+
+	table := data[testTableAddr : testTableAddr+testTableSize]
+	binary.BigEndian.PutUint32(table[4:], testTableSize/4-4)
+	htoc := table[types.HashesTableHeaderSize:]
+	binary.BigEndian.PutUint32(htoc[0:], 1)
+	binary.BigEndian.PutUint16(htoc[4:], sha512.Size)
+	htoc[7] = 2
+	for i, sectionType := range []uint16{types.SectionTypeBoot3Code, types.SectionTypeImageInfo} {
+		entry := htoc[types.HTOCHeaderSize+i*types.HTOCEntrySize:]
+		entry[0] = byte(sectionType)
+		binary.BigEndian.PutUint16(entry[2:], uint16(testDigestsOffset+i*sha512.Size))
+	}
+
+	fwParser := &stubParser{sections: map[uint16][]interfaces.CompleteSectionInterface{
+		types.SectionTypeHashesTable: {interfaces.NewBaseSectionWithOptions(types.SectionTypeHashesTable, testTableAddr, testTableSize,
+			interfaces.WithCRC(types.CRCInSection, 0), interfaces.WithFromHWPointer())},
+		types.SectionTypeBoot3Code: {interfaces.NewBaseSectionWithOptions(types.SectionTypeBoot3Code, testBoot3Addr, testBoot3Size)},
+		types.SectionTypeImageInfo: {interfaces.NewBaseSectionWithOptions(types.SectionTypeImageInfo, testImgInfoAddr, testImgInfoSize)},
+	}}
+	if _, err := UpdateHashes(data, fwParser); err != nil {
+		t.Fatalf("UpdateHashes() error = %v", err)
+	}
+	return data, fwParser
+}
+
+func TestVerifyHashes(t *testing.T) {
+	data, fwParser := createHashesImage(t)
+
+	report, err := VerifyHashes(data, fwParser)
+	if err != nil {
+		t.Fatalf("VerifyHashes() error = %v", err)
+	}
+	if !report.OK || report.Algorithm != "SHA512" || len(report.Entries) != 2 {
+		t.Fatalf("VerifyHashes() = %+v", report)
+	}
+	digest := sha512.Sum512(data[testImgInfoAddr : testImgInfoAddr+testImgInfoSize])
+	if got := data[report.Entries[1].HashOffset:][:sha512.Size]; !bytes.Equal(got, digest[:]) {
+		t.Errorf("IMAGE_INFO digest = %x, want %x", got, digest)
+	}
+
+	data[testImgInfoAddr+0x10] ^= 0xFF
+	report, err = VerifyHashes(data, fwParser)
+	if err != nil {
+		t.Fatalf("VerifyHashes() error = %v", err)
+	}
+	if report.OK {
+		t.Error("VerifyHashes() of a modified section passed")
+	}
+	for _, e := range report.Entries {
+		want := HashMatch
+		if e.SectionType == types.SectionTypeImageInfo {
+			want = HashMismatch
+		}
+		if e.Status != want {
+			t.Errorf("%s: status %s, want %s", e.Section, e.Status, want)
+		}
+	}
+
+	report, err = UpdateHashes(data, fwParser)
+	if err != nil {
+		t.Fatalf("UpdateHashes() error = %v", err)
+	}
+	if !report.OK {
+		t.Errorf("UpdateHashes() left the table invalid: %+v", report)
+	}
+}
+
+func TestVerifyHashes_TableCRC(t *testing.T) {
+	data, fwParser := createHashesImage(t)
+	data[testTableAddr+types.HashesTableHeaderSize] ^= 0x01
+
+	report, err := VerifyHashes(data, fwParser)
+	if err != nil {
+		t.Fatalf("VerifyHashes() error = %v", err)
+	}
+	if report.OK || report.StoredCRC == report.CalculatedCRC {
+		t.Errorf("VerifyHashes() accepted a table with a bad CRC: %+v", report)
+	}
+}
+
+func TestVerifyHashes_MissingSection(t *testing.T) {
+	data, fwParser := createHashesImage(t)
+	delete(fwParser.sections, types.SectionTypeBoot3Code)
+
+	report, err := VerifyHashes(data, fwParser)
+	if err != nil {
+		t.Fatalf("VerifyHashes() error = %v", err)
+	}
+	if report.OK || report.Entries[0].Status != HashNoSection {
+		t.Errorf("VerifyHashes() = %+v, want BOOT3_CODE without section", report)
+	}
+}
+
+func TestVerifyHashes_NoTable(t *testing.T) {
+	_, err := VerifyHashes(make([]byte, 0x100), &stubParser{})
+	if !errors.Is(err, pkgerrors.ErrSectionNotFound) {
+		t.Errorf("VerifyHashes() error = %v, want ErrSectionNotFound", err)
+	}
+}
+
+func TestReadHashesTable_BadHashOffset(t *testing.T) {
+	data, _ := createHashesImage(t)
+	binary.BigEndian.PutUint16(data[testTableAddr+types.HashesTableHeaderSize+types.HTOCHeaderSize+2:], 0x7F0)
+
+	if _, err := ReadHashesTable(data, testTableAddr); !errors.Is(err, pkgerrors.ErrInvalidOffset) {
+		t.Errorf("ReadHashesTable() error = %v, want ErrInvalidOffset", err)
+	}
+}
+
+func TestReadHashesTable_Truncated(t *testing.T) {
+	tests := []struct {
+		name       string
+		dwSize     uint32
+		numEntries byte
+	}{
+		{"entries past the table", 8, 200},
+		{"no room for the HTOC header", 0, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, _ := createHashesImage(t)
+			binary.BigEndian.PutUint32(data[testTableAddr+4:], tt.dwSize)
+			data[testTableAddr+types.HashesTableHeaderSize+7] = tt.numEntries
+			// End the image with the table so that reads past it cannot succeed
+			data = data[:testTableAddr+max((4+tt.dwSize)*4, types.HashesTableHeaderSize+types.HTOCHeaderSize)]
+
+			if _, err := ReadHashesTable(data, testTableAddr); !errors.Is(err, pkgerrors.ErrInvalidSize) {
+				t.Errorf("ReadHashesTable() error = %v, want ErrInvalidSize", err)
+			}
+		})
+	}
+
+}
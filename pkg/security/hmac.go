@@ -0,0 +1,182 @@
+package security
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"os"
+	"strings"
+
+	"github.com/ansel1/merry/v2"
+	"go.uber.org/zap"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/parser"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+	"github.com/Civil/mlx5fw-go/pkg/types/sections"
+)
+
+// HMACEntry is one HMAC_DIGEST section checked against the covered region
+type HMACEntry struct {
+	Section    string     `json:"section"`
+	Offset     uint64     `json:"offset"`
+	Algorithm  string     `json:"algorithm"`
+	Stored     string     `json:"stored"`
+	Calculated string     `json:"calculated,omitempty"`
+	Status     HashStatus `json:"status"`
+}
+
+// HMACReport is the result of VerifyHMAC
+type HMACReport struct {
+	CriticalSize    uint64      `json:"critical_size"`
+	NonCriticalSize uint64      `json:"non_critical_size"`
+	Digests         []HMACEntry `json:"digests"`
+	// OK is true when the image has at least one HMAC_DIGEST and all match
+	OK bool `json:"ok"`
+}
+
+// hmacCriticalSections are the section types mstflint's IsCriticalSection
+// treats as critical when it splits the ITOC sections for the HMAC
+var hmacCriticalSections = map[uint16]bool{
+	types.SectionTypePCICode:          true,
+	types.SectionTypeMainCode:         true,
+	types.SectionTypeIronPrepCode:     true,
+	types.SectionTypePostIronBootCode: true,
+	types.SectionTypeUpgradeCode:      true,
+	types.SectionTypePCIELinkCode:     true,
+	types.SectionTypePhyUCCode:        true,
+	types.SectionTypePCIEPhyUCCode:    true,
+	types.SectionTypeCCIRInfraCode:    true,
+	types.SectionTypeCCIRAlgoCode:     true,
+	types.SectionTypeImageInfo:        true,
+	types.SectionTypeFWBootCfg:        true,
+	types.SectionTypeFWMainCfg:        true,
+	types.SectionTypeHWBootCfg:        true,
+	types.SectionTypeHWMainCfg:        true,
+}
+
+// HMACPayload returns the data covered by HMAC_DIGEST, as mstflint's
+// PrepItocSectionsForHmac collects it for FwSignWithHmac: the data of the
+// critical ITOC sections in ITOC order, followed by the data of the other
+// ITOC sections. HMAC_DIGEST itself is left out. It also returns the size of
+// the critical part. The ITOC must be readable, so an encrypted image has to
+// be decrypted first.
+func HMACPayload(data []byte, fwParser interfaces.FirmwareParser) ([]byte, uint64, error) {
+	if fwParser.IsEncrypted() {
+		return nil, 0, pkgerrors.NotSupportedError("computing the HMAC of an encrypted image without its image key")
+	}
+	entries, err := parser.NewTOCReader(zap.NewNop()).ReadTOCRawEntries(data, fwParser.GetITOCAddress(), false)
+	if err != nil {
+		return nil, 0, merry.Prepend(err, "failed to read ITOC entries")
+	}
+
+	var critical, nonCritical []byte
+	for _, entry := range entries {
+		sectionType := uint16(entry.GetType())
+		if sectionType == types.SectionTypeHMACDigest {
+			continue
+		}
+		start := uint64(entry.GetFlashAddr())
+		end := start + uint64(entry.GetSize())
+		if end > uint64(len(data)) {
+			return nil, 0, pkgerrors.DataTooShortError(int(end), len(data), types.GetSectionTypeName(sectionType))
+		}
+		if hmacCriticalSections[sectionType] {
+			critical = append(critical, data[start:end]...)
+		} else {
+			nonCritical = append(nonCritical, data[start:end]...)
+		}
+	}
+	return append(critical, nonCritical...), uint64(len(critical)), nil
+}
+
+// newHMACHash returns the hash constructor for a digest size
+func newHMACHash(digestSize int) (func() hash.Hash, error) {
+	switch digestSize {
+	case sha256.Size:
+		return sha256.New, nil
+	case sha512.Size384:
+		return sha512.New384, nil
+	case sha512.Size:
+		return sha512.New, nil
+	default:
+		return nil, pkgerrors.NotSupportedError(fmt.Sprintf("HMAC digest size %d", digestSize))
+	}
+}
+
+// VerifyHMAC checks every HMAC_DIGEST section of the image in data with key.
+// The hash is chosen by the digest size stored in the section.
+func VerifyHMAC(data []byte, fwParser interfaces.FirmwareParser, key []byte) (*HMACReport, error) {
+	payload, criticalSize, err := HMACPayload(data, fwParser)
+	if err != nil {
+		return nil, err
+	}
+	digestSections := fwParser.GetSections()[types.SectionTypeHMACDigest]
+	if len(digestSections) == 0 {
+		return nil, merry.Wrap(pkgerrors.ErrSectionNotFound, merry.WithMessage("image has no HMAC_DIGEST"))
+	}
+
+	report := &HMACReport{CriticalSize: criticalSize, NonCriticalSize: uint64(len(payload)) - criticalSize}
+	for _, s := range digestSections {
+		raw, err := sectionData(data, s)
+		if err != nil {
+			return nil, err
+		}
+		section := sections.NewHMACDigestSection(interfaces.NewBaseSectionWithOptions(s.Type(), s.Offset(), s.Size()))
+		if err := section.Parse(raw); err != nil {
+			return nil, err
+		}
+		stored := section.DigestBytes()
+		if stored == nil {
+			return nil, merry.Wrap(pkgerrors.ErrInvalidData, merry.WithMessagef("HMAC_DIGEST at 0x%x has no valid digest", s.Offset()))
+		}
+
+		entry := HMACEntry{
+			Section:   s.TypeName(),
+			Offset:    s.Offset(),
+			Algorithm: sections.HMACAlgorithm(uint32(len(stored))),
+			Stored:    hex.EncodeToString(stored),
+			Status:    HashMismatch,
+		}
+		newHash, err := newHMACHash(len(stored))
+		if err != nil {
+			return nil, err
+		}
+		mac := hmac.New(newHash, key)
+		mac.Write(payload)
+		calculated := mac.Sum(nil)
+		entry.Calculated = hex.EncodeToString(calculated)
+		if hmac.Equal(calculated, stored) {
+			entry.Status = HashMatch
+		}
+		report.Digests = append(report.Digests, entry)
+	}
+
+	report.OK = true
+	for _, entry := range report.Digests {
+		if entry.Status != HashMatch {
+			report.OK = false
+		}
+	}
+	return report, nil
+}
+
+// LoadKeyFile reads a symmetric key file (an HMAC or image encryption key)
+// holding either the raw key bytes or the key as hex text
+func LoadKeyFile(path string) ([]byte, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+	if key, err := hex.DecodeString(strings.TrimSpace(string(raw))); err == nil && len(key) > 0 {
+		return key, nil
+	}
+	if len(raw) == 0 {
+		return nil, merry.Wrap(pkgerrors.ErrInvalidData, merry.WithMessagef("%s: empty key", path))
+	}
+	return raw, nil
+}
@@ -0,0 +1,147 @@
+package security
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+const testHMACAddr = 0x5000
+
+var testHMACKey = []byte("0123456789abcdef0123456789abcdef")
+
+// createHMACImage adds an HMAC-SHA384 HMAC_DIGEST, computed with
+// testHMACKey, to the signed test image
+func createHMACImage(t *testing.T) ([]byte, *stubParser) {
+	t.Helper()
+	data, fwParser := createSignedImage(t, mustKey(t))
+	fwParser.sections[types.SectionTypeHMACDigest] = []interfaces.CompleteSectionInterface{
+		interfaces.NewBaseSectionWithOptions(types.SectionTypeHMACDigest, testHMACAddr, types.HMACDigestSize),
+	}
+	writeTestITOC(t, data, fwParser)
+
+	payload, _, err := HMACPayload(data, fwParser)
+	if err != nil {
+		t.Fatalf("HMACPayload() error = %v", err)
+	}
+	mac := hmac.New(sha512.New384, testHMACKey)
+	mac.Write(payload)
+
+	binary.BigEndian.PutUint32(data[testHMACAddr:], 1)
+	binary.BigEndian.PutUint32(data[testHMACAddr+4:], sha512.Size384)
+	copy(data[testHMACAddr+8:], mac.Sum(nil))
+	return data, fwParser
+}
+
+func TestVerifyHMAC(t *testing.T) {
+	data, fwParser := createHMACImage(t)
+
+	report, err := VerifyHMAC(data, fwParser, testHMACKey)
+	if err != nil {
+		t.Fatalf("VerifyHMAC() error = %v", err)
+	}
+	if !report.OK || len(report.Digests) != 1 {
+		t.Fatalf("VerifyHMAC() = %+v, want one matching digest", report)
+	}
+	if report.Digests[0].Algorithm != "HMAC-SHA384" {
+		t.Errorf("Algorithm = %s, want HMAC-SHA384", report.Digests[0].Algorithm)
+	}
+
+	report, err = VerifyHMAC(data, fwParser, []byte("wrong key"))
+	if err != nil {
+		t.Fatalf("VerifyHMAC() error = %v", err)
+	}
+	if report.OK || report.Digests[0].Status != HashMismatch {
+		t.Errorf("VerifyHMAC() with a wrong key = %+v, want mismatch", report.Digests[0])
+	}
+
+	data[testSigImageInfoAddr+0x20] ^= 0xFF
+	if report, _ := VerifyHMAC(data, fwParser, testHMACKey); report.OK {
+		t.Error("HMAC still matches after IMAGE_INFO was modified")
+	}
+}
+
+func TestHMACPayload(t *testing.T) {
+	data, fwParser := createHMACImage(t)
+
+	payload, criticalSize, err := HMACPayload(data, fwParser)
+	if err != nil {
+		t.Fatalf("HMACPayload() error = %v", err)
+	}
+	// IMAGE_INFO is critical; the signature and the public keys follow in
+	// ITOC order; HMAC_DIGEST and the device data are left out
+	var want []byte
+	want = append(want, data[testSigImageInfoAddr:testSigImageInfoAddr+0x400]...)
+	want = append(want, data[testSigAddr:testSigAddr+testSigSize]...)
+	want = append(want, data[testSigKeysAddr:testSigKeysAddr+testSigKeysSize]...)
+	if criticalSize != 0x400 || !bytes.Equal(payload, want) {
+		t.Errorf("HMACPayload() = 0x%x bytes with 0x%x critical, want 0x%x with 0x400", len(payload), criticalSize, len(want))
+	}
+}
+
+// encryptedStubParser is a stubParser for an image whose ITOC is encrypted
+type encryptedStubParser struct {
+	*stubParser
+}
+
+func (p *encryptedStubParser) IsEncrypted() bool {
+	return true
+}
+
+func TestVerifyHMAC_Errors(t *testing.T) {
+	data, fwParser := createHMACImage(t)
+	if _, err := VerifyHMAC(data, &encryptedStubParser{fwParser}, testHMACKey); !errors.Is(err, pkgerrors.ErrNotSupported) {
+		t.Errorf("VerifyHMAC() of an encrypted image error = %v, want ErrNotSupported", err)
+	}
+
+	data, fwParser = createSignedImage(t, mustKey(t))
+	if _, err := VerifyHMAC(data, fwParser, testHMACKey); !errors.Is(err, pkgerrors.ErrSectionNotFound) {
+		t.Errorf("VerifyHMAC() without HMAC_DIGEST error = %v, want ErrSectionNotFound", err)
+	}
+
+	data, fwParser = createHMACImage(t)
+	binary.BigEndian.PutUint32(data[testHMACAddr+4:], 20)
+	if _, err := VerifyHMAC(data, fwParser, testHMACKey); !errors.Is(err, pkgerrors.ErrNotSupported) {
+		t.Errorf("VerifyHMAC() with a 20-byte digest error = %v, want ErrNotSupported", err)
+	}
+}
+
+func TestLoadKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	hexPath := filepath.Join(dir, "key.hex")
+	if err := os.WriteFile(hexPath, []byte("00112233aabbccdd\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	key, err := LoadKeyFile(hexPath)
+	if err != nil {
+		t.Fatalf("LoadKeyFile() error = %v", err)
+	}
+	if want := []byte{0x00, 0x11, 0x22, 0x33, 0xaa, 0xbb, 0xcc, 0xdd}; string(key) != string(want) {
+		t.Errorf("LoadKeyFile() = %x, want %x", key, want)
+	}
+
+	rawPath := filepath.Join(dir, "key.bin")
+	if err := os.WriteFile(rawPath, []byte{0xde, 0xad, 0xbe, 0xef}, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if key, err := LoadKeyFile(rawPath); err != nil || len(key) != 4 {
+		t.Errorf("LoadKeyFile() raw = %x, %v", key, err)
+	}
+
+	emptyPath := filepath.Join(dir, "empty")
+	if err := os.WriteFile(emptyPath, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadKeyFile(emptyPath); err == nil {
+		t.Error("LoadKeyFile() of an empty file succeeded")
+	}
+}
@@ -0,0 +1,276 @@
+package security
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/parser"
+	"github.com/Civil/mlx5fw-go/pkg/parser/fs4"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+// CheckStatus is the result of one integrity check
+type CheckStatus string
+
+const (
+	CheckOK      CheckStatus = "OK"
+	CheckFail    CheckStatus = "FAIL"
+	CheckSkipped CheckStatus = "SKIPPED"
+)
+
+// IntegrityCheck is one node of the integrity tree. A node fails when its own
+// check fails or any of its children fails.
+type IntegrityCheck struct {
+	Name     string           `json:"name"`
+	Offset   uint64           `json:"offset"`
+	Size     uint64           `json:"size"`
+	Status   CheckStatus      `json:"status"`
+	Message  string           `json:"message,omitempty"`
+	Children []IntegrityCheck `json:"children,omitempty"`
+}
+
+// IntegrityReport is the result of VerifyIntegrity
+type IntegrityReport struct {
+	Format    string           `json:"format"`
+	Encrypted bool             `json:"encrypted"`
+	Checks    []IntegrityCheck `json:"checks"`
+	OK        bool             `json:"ok"`
+}
+
+// newGroup returns a check whose status summarizes its children
+func newGroup(name string, offset, size uint64, children []IntegrityCheck) IntegrityCheck {
+	c := IntegrityCheck{Name: name, Offset: offset, Size: size, Status: CheckOK, Children: children}
+	for _, child := range children {
+		if child.Status == CheckFail {
+			c.Status = CheckFail
+			break
+		}
+	}
+	return c
+}
+
+// VerifyIntegrity runs the flint verify checks on the image in data: the
+// magic pattern, the HW pointer CRCs, BOOT2, the TOOLS_AREA, the ITOC and DTOC
+// header CRCs, the CRC of every section, the HASHES_TABLE digests and the
+// section layout. Checks that cannot run, such as the TOC checks of an
+// encrypted image, are reported as skipped and do not fail the image.
+func VerifyIntegrity(data []byte, fwParser interfaces.FirmwareParser) *IntegrityReport {
+	format := fwParser.GetFormat()
+	report := &IntegrityReport{
+		Format:    format.String(),
+		Encrypted: fwParser.IsEncrypted(),
+	}
+
+	report.Checks = append(report.Checks, checkMagic(data, fwParser.GetMagicOffset()))
+	if format != types.FormatFS3 {
+		report.Checks = append(report.Checks, checkHWPointers(data, fwParser.GetMagicOffset(), types.HWPointerNames(format)))
+	}
+
+	var itocSections, dtocSections []IntegrityCheck
+	var boot2, toolsArea *IntegrityCheck
+	for _, s := range sortedSections(fwParser) {
+		c := checkSection(fwParser, s)
+		switch {
+		case s.Type() == types.SectionTypeBoot2:
+			boot2 = &c
+		case s.Type() == types.SectionTypeToolsArea:
+			toolsArea = &c
+		case s.IsDeviceData():
+			dtocSections = append(dtocSections, c)
+		default:
+			itocSections = append(itocSections, c)
+		}
+	}
+	hwSection := func(c *IntegrityCheck, name string) IntegrityCheck {
+		if c != nil {
+			return *c
+		}
+		if format == types.FormatFS3 {
+			return IntegrityCheck{Name: name, Status: CheckSkipped, Message: "not used by " + format.String()}
+		}
+		return IntegrityCheck{Name: name, Status: CheckFail, Message: "not found"}
+	}
+	report.Checks = append(report.Checks, hwSection(boot2, "BOOT2"), hwSection(toolsArea, "TOOLS_AREA"))
+
+	report.Checks = append(report.Checks, checkTOCHeader(fwParser, "ITOC", uint64(fwParser.GetITOCAddress()), itocSections))
+	if addr := fwParser.GetDTOCAddress(); addr != 0 {
+		report.Checks = append(report.Checks, checkTOCHeader(fwParser, "DTOC", uint64(addr), dtocSections))
+	}
+
+	report.Checks = append(report.Checks, checkHashesTable(data, fwParser), checkLayout(data, fwParser))
+
+	report.OK = true
+	for _, c := range report.Checks {
+		if c.Status == CheckFail {
+			report.OK = false
+		}
+	}
+	return report
+}
+
+// checkMagic checks the magic pattern the parser found the image at
+func checkMagic(data []byte, offset uint32) IntegrityCheck {
+	c := IntegrityCheck{Name: "MAGIC_PATTERN", Offset: uint64(offset), Size: 8, Status: CheckOK}
+	if uint64(offset)+8 > uint64(len(data)) {
+		c.Status, c.Message = CheckFail, "beyond the end of the image"
+		return c
+	}
+	if magic := binary.BigEndian.Uint64(data[offset:]); magic != types.MagicPattern {
+		c.Status, c.Message = CheckFail, fmt.Sprintf("unexpected value 0x%016x", magic)
+	}
+	return c
+}
+
+// checkHWPointers checks the CRC of every used HW pointer entry. As in the
+// reassembler, blank (0 or 0xFFFFFFFF) pointers carry no CRC. names are the
+// pointer names of the image format.
+func checkHWPointers(data []byte, magicOffset uint32, names []string) IntegrityCheck {
+	offset := uint64(magicOffset) + types.HWPointersOffsetFromMagic
+	if offset+types.HWPointersSize > uint64(len(data)) {
+		return IntegrityCheck{Name: "HW_POINTERS", Offset: offset, Size: types.HWPointersSize,
+			Status: CheckFail, Message: "beyond the end of the image"}
+	}
+
+	crc := parser.NewCRCCalculator()
+	var children []IntegrityCheck
+	for i, name := range names {
+		entryOffset := offset + uint64(i*8)
+		c := IntegrityCheck{Name: name, Offset: entryOffset, Size: 8, Status: CheckOK}
+		entry := &types.HWPointerEntry{}
+		if err := entry.UnmarshalWithReserved(data[entryOffset : entryOffset+8]); err != nil {
+			c.Status, c.Message = CheckFail, err.Error()
+		} else if entry.Ptr == 0 || entry.Ptr == 0xFFFFFFFF {
+			c.Status, c.Message = CheckSkipped, "unused"
+		} else if calculated := crc.CalculateHardwareCRC(data[entryOffset : entryOffset+6]); calculated != entry.CRC {
+			c.Status, c.Message = CheckFail, fmt.Sprintf("0x%04X != 0x%04X", calculated, entry.CRC)
+		}
+		children = append(children, c)
+	}
+	return newGroup("HW_POINTERS", offset, types.HWPointersSize, children)
+}
+
+// sortedSections returns the parsed sections ordered by offset, leaving out
+// the HW pointer copies of ITOC sections
+func sortedSections(fwParser interfaces.FirmwareParser) []interfaces.CompleteSectionInterface {
+	var list []interfaces.CompleteSectionInterface
+	for _, sectionList := range fwParser.GetSections() {
+		for _, s := range sectionList {
+			if s.IsFromHWPointer() && hashedSection(fwParser, s.Type()) != nil {
+				continue
+			}
+			list = append(list, s)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Offset() != list[j].Offset() {
+			return list[i].Offset() < list[j].Offset()
+		}
+		return list[i].Type() < list[j].Type()
+	})
+	return list
+}
+
+// checkSection maps the parser's mstflint-style section status to a check
+func checkSection(fwParser interfaces.FirmwareParser, s interfaces.CompleteSectionInterface) IntegrityCheck {
+	c := IntegrityCheck{Name: s.TypeName(), Offset: s.Offset(), Size: uint64(s.Size()), Status: CheckOK}
+	status, err := fwParser.VerifySectionNew(s)
+	switch {
+	case err != nil:
+		c.Status, c.Message = CheckFail, err.Error()
+	case status == "OK":
+	case strings.HasPrefix(status, "FAIL"), status == "ERROR", status == "UNKNOWN CRC TYPE":
+		c.Status, c.Message = CheckFail, strings.Trim(strings.TrimPrefix(status, "FAIL"), " ()")
+	default:
+		// CRC IGNORED, NO ENTRY and the like: nothing to check
+		c.Status, c.Message = CheckSkipped, status
+	}
+	return c
+}
+
+// checkTOCHeader checks the header CRC of the ITOC or DTOC and groups the
+// checks of its sections under it
+func checkTOCHeader(fwParser interfaces.FirmwareParser, name string, addr uint64, sections []IntegrityCheck) IntegrityCheck {
+	header := IntegrityCheck{Name: name + "_HEADER", Offset: addr, Size: types.ITOCHeaderSize, Status: CheckOK}
+	valid, readRaw := fwParser.IsITOCHeaderValid, fwParser.GetITOCRawData
+	if name == "DTOC" {
+		valid, readRaw = fwParser.IsDTOCHeaderValid, fwParser.GetDTOCRawData
+	}
+	switch {
+	case fwParser.IsEncrypted() && name == "ITOC":
+		header.Status, header.Message = CheckSkipped, "encrypted"
+	case !valid():
+		header.Status, header.Message = CheckFail, "invalid CRC"
+		if raw, err := readRaw(); err == nil {
+			if err := fs4.VerifyITOCHeaderCRC(raw, parser.NewCRCCalculator()); err != nil {
+				header.Message = err.Error()
+			}
+		}
+	}
+
+	return newGroup(name, addr, types.ITOCHeaderSize, append([]IntegrityCheck{header}, sections...))
+}
+
+// checkHashesTable checks the HASHES_TABLE digests when the image has one
+func checkHashesTable(data []byte, fwParser interfaces.FirmwareParser) IntegrityCheck {
+	c := IntegrityCheck{Name: "HASHES_TABLE", Status: CheckSkipped}
+	if fwParser.IsEncrypted() {
+		c.Message = "encrypted"
+		return c
+	}
+	report, err := VerifyHashes(data, fwParser)
+	if errors.Is(err, pkgerrors.ErrSectionNotFound) {
+		c.Message = "not present"
+		return c
+	}
+	if err != nil {
+		c.Status, c.Message = CheckFail, err.Error()
+		return c
+	}
+
+	var children []IntegrityCheck
+	tableCRC := IntegrityCheck{Name: "TABLE_CRC", Offset: report.TableOffset, Size: uint64(report.TableSize), Status: CheckOK}
+	if report.StoredCRC != report.CalculatedCRC {
+		tableCRC.Status, tableCRC.Message = CheckFail, fmt.Sprintf("0x%04X != 0x%04X", report.CalculatedCRC, report.StoredCRC)
+	}
+	children = append(children, tableCRC)
+	for _, e := range report.Entries {
+		entry := IntegrityCheck{Name: e.Section, Offset: e.SectionOffset, Size: uint64(e.SectionSize), Status: CheckOK}
+		if e.Status != HashMatch {
+			entry.Status, entry.Message = CheckFail, string(e.Status)
+		}
+		children = append(children, entry)
+	}
+	group := newGroup("HASHES_TABLE", report.TableOffset, uint64(report.TableSize), children)
+	group.Message = report.Algorithm
+	return group
+}
+
+// checkLayout reports sections that extend beyond the image or overlap
+// another section
+func checkLayout(data []byte, fwParser interfaces.FirmwareParser) IntegrityCheck {
+	var children []IntegrityCheck
+	var prev interfaces.CompleteSectionInterface
+	for _, s := range sortedSections(fwParser) {
+		end := s.Offset() + uint64(s.Size())
+		if end > uint64(len(data)) {
+			children = append(children, IntegrityCheck{Name: s.TypeName(), Offset: s.Offset(), Size: uint64(s.Size()),
+				Status: CheckFail, Message: fmt.Sprintf("ends at 0x%x, beyond the image size 0x%x", end, len(data))})
+		}
+		if s.Size() == 0 {
+			continue
+		}
+		if prev != nil && s.Offset() < prev.Offset()+uint64(prev.Size()) {
+			children = append(children, IntegrityCheck{Name: s.TypeName(), Offset: s.Offset(), Size: uint64(s.Size()),
+				Status: CheckFail, Message: fmt.Sprintf("overlaps %s at 0x%08x", prev.TypeName(), prev.Offset())})
+		}
+		if prev == nil || end > prev.Offset()+uint64(prev.Size()) {
+			prev = s
+		}
+	}
+	return newGroup("LAYOUT", 0, uint64(len(data)), children)
+}
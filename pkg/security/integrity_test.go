@@ -0,0 +1,159 @@
+package security
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/parser"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+// integrityStubParser adds TOC state and fixed section statuses to stubParser
+type integrityStubParser struct {
+	*stubParser
+	format    types.FirmwareFormat
+	statuses  map[uint64]string
+	itocValid bool
+}
+
+func (p *integrityStubParser) GetFormat() types.FirmwareFormat { return p.format }
+func (p *integrityStubParser) GetITOCAddress() uint32          { return 0x2000 }
+func (p *integrityStubParser) GetDTOCAddress() uint32          { return 0 }
+func (p *integrityStubParser) IsITOCHeaderValid() bool         { return p.itocValid }
+func (p *integrityStubParser) GetITOCRawData() ([]byte, error) {
+	return bytes.Repeat([]byte{0xFF}, types.ITOCHeaderSize), nil
+}
+
+func (p *integrityStubParser) VerifySectionNew(s interfaces.SectionVerifier) (string, error) {
+	if status, ok := p.statuses[s.Offset()]; ok {
+		return status, nil
+	}
+	return "OK", nil
+}
+
+// createIntegrityImage builds an image with valid BOOT2 and TOC HW pointers
+// and BOOT2, TOOLS_AREA, IMAGE_INFO and MAIN_CODE sections
+func createIntegrityImage(t *testing.T) ([]byte, *integrityStubParser) {
+	t.Helper()
+	data := bytes.Repeat([]byte{0xFF}, 0x8000)
+	binary.BigEndian.PutUint64(data, types.MagicPattern)
+	crc := parser.NewCRCCalculator()
+	for i, ptr := range []uint32{0, 0x1000, 0x2000} {
+		entry := data[types.HWPointersOffsetFromMagic+i*8:]
+		binary.BigEndian.PutUint32(entry, ptr)
+		binary.BigEndian.PutUint16(entry[4:], 0)
+		binary.BigEndian.PutUint16(entry[6:], crc.CalculateHardwareCRC(entry[:6]))
+	}
+
+	section := func(sectionType uint16, offset uint64, size uint32) []interfaces.CompleteSectionInterface {
+		return []interfaces.CompleteSectionInterface{interfaces.NewBaseSectionWithOptions(sectionType, offset, size)}
+	}
+	fwParser := &integrityStubParser{
+		stubParser: &stubParser{sections: map[uint16][]interfaces.CompleteSectionInterface{
+			types.SectionTypeBoot2:     section(types.SectionTypeBoot2, 0x1000, 0x110),
+			types.SectionTypeToolsArea: section(types.SectionTypeToolsArea, 0x1800, types.ToolsAreaSize),
+			types.SectionTypeImageInfo: section(types.SectionTypeImageInfo, 0x3000, 0x400),
+			types.SectionTypeMainCode:  section(types.SectionTypeMainCode, 0x4000, 0x2000),
+		}},
+		format:    types.FormatFS4,
+		statuses:  map[uint64]string{0x3000: "CRC IGNORED"},
+		itocValid: true,
+	}
+	return data, fwParser
+}
+
+// findCheck returns the top-level check called name
+func findCheck(t *testing.T, report *IntegrityReport, name string) IntegrityCheck {
+	t.Helper()
+	for _, c := range report.Checks {
+		if c.Name == name {
+			return c
+		}
+	}
+	t.Fatalf("no %s check in %+v", name, report.Checks)
+	return IntegrityCheck{}
+}
+
+func TestVerifyIntegrity(t *testing.T) {
+	data, fwParser := createIntegrityImage(t)
+
+	report := VerifyIntegrity(data, fwParser)
+	if !report.OK {
+		t.Fatalf("VerifyIntegrity() of a valid image = %+v", report)
+	}
+	hw := findCheck(t, report, "HW_POINTERS")
+	if len(hw.Children) != 16 || hw.Children[1].Status != CheckOK || hw.Children[0].Status != CheckSkipped {
+		t.Errorf("HW_POINTERS = %+v", hw)
+	}
+	itoc := findCheck(t, report, "ITOC")
+	if len(itoc.Children) != 3 || itoc.Children[1].Status != CheckSkipped || itoc.Children[1].Message != "CRC IGNORED" {
+		t.Errorf("ITOC = %+v", itoc)
+	}
+	if c := findCheck(t, report, "HASHES_TABLE"); c.Status != CheckSkipped {
+		t.Errorf("HASHES_TABLE of an image without one = %+v", c)
+	}
+}
+
+func TestVerifyIntegrity_HWPointerNames(t *testing.T) {
+	tests := []struct {
+		format types.FirmwareFormat
+		want   []string
+	}{
+		{types.FormatFS4, []string{"BOOT_RECORD_PTR", "BOOT2_PTR", "TOC_PTR"}},
+		{types.FormatFS5, []string{"BOOT2_PTR", "TOC_PTR", "TOOLS_PTR"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format.String(), func(t *testing.T) {
+			data, fwParser := createIntegrityImage(t)
+			fwParser.format = tt.format
+
+			hw := findCheck(t, VerifyIntegrity(data, fwParser), "HW_POINTERS")
+			for i, name := range tt.want {
+				if hw.Children[i].Name != name {
+					t.Errorf("HW pointer %d = %s, want %s", i, hw.Children[i].Name, name)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyIntegrity_Failures(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(data []byte, p *integrityStubParser)
+		check  string
+	}{
+		{"magic", func(data []byte, p *integrityStubParser) { data[0] = 0 }, "MAGIC_PATTERN"},
+		{"hw pointer crc", func(data []byte, p *integrityStubParser) { data[0x20+7] ^= 1 }, "HW_POINTERS"},
+		{"boot2 missing", func(data []byte, p *integrityStubParser) { delete(p.sections, types.SectionTypeBoot2) }, "BOOT2"},
+		{"tools area crc", func(data []byte, p *integrityStubParser) { p.statuses[0x1800] = "FAIL (0x0001 != 0x0002)" }, "TOOLS_AREA"},
+		{"itoc header", func(data []byte, p *integrityStubParser) { p.itocValid = false }, "ITOC"},
+		{"section crc", func(data []byte, p *integrityStubParser) { p.statuses[0x4000] = "FAIL (0x0001 != 0x0002)" }, "ITOC"},
+		{"overlap", func(data []byte, p *integrityStubParser) {
+			p.sections[types.SectionTypeDbgFWINI] = []interfaces.CompleteSectionInterface{
+				interfaces.NewBaseSectionWithOptions(types.SectionTypeDbgFWINI, 0x3200, 0x100)}
+		}, "LAYOUT"},
+		{"out of bounds", func(data []byte, p *integrityStubParser) {
+			p.sections[types.SectionTypeDbgFWINI] = []interfaces.CompleteSectionInterface{
+				interfaces.NewBaseSectionWithOptions(types.SectionTypeDbgFWINI, 0x7000, 0x2000)}
+		}, "LAYOUT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, fwParser := createIntegrityImage(t)
+			tt.modify(data, fwParser)
+
+			report := VerifyIntegrity(data, fwParser)
+			if report.OK {
+				t.Fatal("VerifyIntegrity() passed")
+			}
+			for _, c := range report.Checks {
+				if want := c.Name == tt.check; (c.Status == CheckFail) != want {
+					t.Errorf("%s: status %s, message %q", c.Name, c.Status, c.Message)
+				}
+			}
+		})
+	}
+}
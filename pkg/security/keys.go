@@ -0,0 +1,226 @@
+package security
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ansel1/merry/v2"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+// UUID is a 128-bit key pair or signature UUID as stored in the image
+// (four big-endian dwords)
+type UUID [16]byte
+
+// String returns the UUID in canonical 8-4-4-4-12 form
+func (u UUID) String() string {
+	h := hex.EncodeToString(u[:])
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// IsZero reports whether all UUID bytes are zero
+func (u UUID) IsZero() bool {
+	return u == UUID{}
+}
+
+// ParseUUID parses a UUID in canonical form or as 32 hex digits
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	raw, err := hex.DecodeString(strings.ReplaceAll(s, "-", ""))
+	if err != nil || len(raw) != len(u) {
+		return u, pkgerrors.InvalidParameterError("uuid", fmt.Sprintf("%q is not a 128-bit UUID", s))
+	}
+	copy(u[:], raw)
+	return u, nil
+}
+
+// PublicKey is an RSA public key stored in a PUBLIC_KEYS_2048/4096 section
+type PublicKey struct {
+	UUID UUID
+	Key  *rsa.PublicKey
+	// Source names where the key came from, e.g. "PUBLIC_KEYS_4096[1]"
+	Source string
+	// AuthConfig is the raw component_authentication_configuration dword that
+	// follows the modulus in file_public_keys_3 slots, 0 when the slot has none
+	AuthConfig uint32
+}
+
+// Layout of one public key slot, shared by mstflint's file_public_keys,
+// file_public_keys_2 and file_public_keys_3: exponent, key pair UUID, modulus.
+// The slot size differs between variants, so it is derived from the section size.
+const (
+	publicKeyExpOffset     = 0x0
+	publicKeyUUIDOffset    = 0x4
+	publicKeyModulusOffset = 0x14
+	publicKeySlots         = 8
+)
+
+// publicKeyModulusSize returns the modulus size of the keys in a PUBLIC_KEYS section
+func publicKeyModulusSize(sectionType uint16) int {
+	if sectionType == types.SectionTypePublicKeys4096 {
+		return 512
+	}
+	return 256
+}
+
+// ParsePublicKeys decodes the populated key slots of a PUBLIC_KEYS_2048 or
+// PUBLIC_KEYS_4096 section. Slots whose exponent or modulus is blank are skipped.
+func ParsePublicKeys(sectionType uint16, data []byte) ([]PublicKey, error) {
+	modulusSize := publicKeyModulusSize(sectionType)
+	slotSize := len(data) / publicKeySlots
+	if slotSize < publicKeyModulusOffset+modulusSize {
+		return nil, pkgerrors.DataTooShortError(publicKeySlots*(publicKeyModulusOffset+modulusSize), len(data), types.GetSectionTypeName(sectionType))
+	}
+
+	var keys []PublicKey
+	for i := 0; i < publicKeySlots; i++ {
+		slot := data[i*slotSize : (i+1)*slotSize]
+		if publicKeySlotEmpty(slot, modulusSize) {
+			continue
+		}
+
+		modulus := slot[publicKeyModulusOffset : publicKeyModulusOffset+modulusSize]
+		key := PublicKey{
+			UUID:   publicKeySlotUUID(slot),
+			Key:    &rsa.PublicKey{N: new(big.Int).SetBytes(modulus), E: int(binary.BigEndian.Uint32(slot[publicKeyExpOffset:]))},
+			Source: fmt.Sprintf("%s[%d]", types.GetSectionTypeName(sectionType), i),
+		}
+		if authOffset := publicKeyModulusOffset + modulusSize; slotSize >= authOffset+4 {
+			key.AuthConfig = binary.BigEndian.Uint32(slot[authOffset:])
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// publicKeySlotEmpty reports whether a key slot has a blank exponent or modulus
+func publicKeySlotEmpty(slot []byte, modulusSize int) bool {
+	exp := binary.BigEndian.Uint32(slot[publicKeyExpOffset:])
+	return exp == 0 || exp == 0xFFFFFFFF || isBlank(slot[publicKeyModulusOffset:publicKeyModulusOffset+modulusSize])
+}
+
+// publicKeySlotUUID returns the key pair UUID of a key slot
+func publicKeySlotUUID(slot []byte) UUID {
+	var uuid UUID
+	copy(uuid[:], slot[publicKeyUUIDOffset:publicKeyModulusOffset])
+	return uuid
+}
+
+// EmbeddedPublicKeys returns the keys of every PUBLIC_KEYS section of the image in data
+func EmbeddedPublicKeys(data []byte, fwParser interfaces.FirmwareParser) ([]PublicKey, error) {
+	var keys []PublicKey
+	for _, sectionType := range []uint16{types.SectionTypePublicKeys2048, types.SectionTypePublicKeys4096} {
+		for _, s := range fwParser.GetSections()[sectionType] {
+			end := s.Offset() + uint64(s.Size())
+			if end > uint64(len(data)) {
+				return nil, pkgerrors.DataTooShortError(int(end), len(data), s.TypeName())
+			}
+			sectionKeys, err := ParsePublicKeys(sectionType, data[s.Offset():end])
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, sectionKeys...)
+		}
+	}
+	return keys, nil
+}
+
+// LoadPublicKeyPEM reads an RSA public key from a PEM file. PKIX and PKCS#1
+// public keys, certificates and PKCS#1/PKCS#8 private keys are accepted.
+func LoadPublicKeyPEM(path string) (*rsa.PublicKey, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, merry.Wrap(pkgerrors.ErrInvalidData, merry.WithMessagef("%s: no PEM block found", path))
+	}
+
+	var key any
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+			key = cert.PublicKey
+		}
+	case "RSA PRIVATE KEY", "PRIVATE KEY":
+		var priv *rsa.PrivateKey
+		if priv, err = parsePrivateKey(block); err == nil {
+			key = &priv.PublicKey
+		}
+	default:
+		return nil, pkgerrors.NotSupportedError("PEM block " + block.Type)
+	}
+	if err != nil {
+		return nil, merry.Prepend(err, path)
+	}
+
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, pkgerrors.NotSupportedError(fmt.Sprintf("%T public key", key))
+	}
+	return rsaKey, nil
+}
+
+// LoadPrivateKeyPEM reads a PKCS#1 ("RSA PRIVATE KEY") or PKCS#8
+// ("PRIVATE KEY") RSA private key from a PEM file
+func LoadPrivateKeyPEM(path string) (*rsa.PrivateKey, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, merry.Wrap(pkgerrors.ErrInvalidData, merry.WithMessagef("%s: no PEM block found", path))
+	}
+	if block.Type != "RSA PRIVATE KEY" && block.Type != "PRIVATE KEY" {
+		return nil, pkgerrors.NotSupportedError("PEM block " + block.Type + " as a private key")
+	}
+
+	key, err := parsePrivateKey(block)
+	if err != nil {
+		return nil, merry.Prepend(err, path)
+	}
+	return key, nil
+}
+
+// parsePrivateKey decodes a PKCS#1 or PKCS#8 RSA private key block
+func parsePrivateKey(block *pem.Block) (*rsa.PrivateKey, error) {
+	if block.Type == "RSA PRIVATE KEY" {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, pkgerrors.NotSupportedError(fmt.Sprintf("%T private key", key))
+	}
+	return rsaKey, nil
+}
+
+// isBlank reports whether data is erased flash (all 0xFF) or all zeros
+func isBlank(data []byte) bool {
+	allFF, allZero := true, true
+	for _, b := range data {
+		allFF = allFF && b == 0xFF
+		allZero = allZero && b == 0
+	}
+	return allFF || allZero
+}
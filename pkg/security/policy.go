@@ -0,0 +1,185 @@
+package security
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ansel1/merry/v2"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+)
+
+// PolicyRule is one security policy check, written as "name" or "name=value"
+type PolicyRule struct {
+	Name  string
+	Value uint64
+}
+
+// String returns the rule in its textual form
+func (r PolicyRule) String() string {
+	if policyRules[r.Name].hasValue {
+		return fmt.Sprintf("%s=%d", r.Name, r.Value)
+	}
+	return r.Name
+}
+
+// PolicyResult is the outcome of one rule
+type PolicyResult struct {
+	Rule    string `json:"rule"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message,omitempty"`
+}
+
+// policyCheck evaluates a rule and returns why it failed, or "" when it passed
+type policyCheck func(r *SecurityReport, value uint64) string
+
+var policyRules = map[string]struct {
+	hasValue bool
+	check    policyCheck
+}{
+	"no-debug-fw": {check: func(r *SecurityReport, _ uint64) string {
+		return failIf(r.Flags.DebugFW, "image is debug FW")
+	}},
+	"no-dev-fw": {check: func(r *SecurityReport, _ uint64) string {
+		return failIf(r.Flags.DevFW, "image is signed with a development key")
+	}},
+	"secure-fw": {check: func(r *SecurityReport, _ uint64) string {
+		return failIf(!r.Flags.SecureFW, "image is not secure FW")
+	}},
+	"signed": {check: func(r *SecurityReport, _ uint64) string {
+		return failIf(len(r.signedKeySizes()) == 0, "image is not signed")
+	}},
+	"min-key-size": {hasValue: true, check: func(r *SecurityReport, value uint64) string {
+		sizes := r.signedKeySizes()
+		if len(sizes) == 0 {
+			return "image is not signed"
+		}
+		for _, size := range sizes {
+			if uint64(size) < value {
+				return fmt.Sprintf("image is signed with a %d-bit key", size)
+			}
+		}
+		return ""
+	}},
+	"hashes-valid": {check: func(r *SecurityReport, _ uint64) string {
+		switch {
+		case !r.HashesTable.Present:
+			return "image has no HASHES_TABLE"
+		case !r.HashesTable.Valid:
+			return "HASHES_TABLE does not match the image"
+		}
+		return ""
+	}},
+	"encrypted": {check: func(r *SecurityReport, _ uint64) string {
+		return failIf(r.Encryption == EncryptionNone, "image is not encrypted")
+	}},
+	"min-security-version": {hasValue: true, check: func(r *SecurityReport, value uint64) string {
+		if r.SecurityVersion == nil {
+			return "image has no security version"
+		}
+		return failIf(uint64(*r.SecurityVersion) < value, fmt.Sprintf("security version is %d", *r.SecurityVersion))
+	}},
+	"not-forbidden": {check: func(r *SecurityReport, _ uint64) string {
+		return failIf(r.SecurityVersionForbidden, "security version is listed in FORBIDDEN_VERSIONS")
+	}},
+}
+
+func failIf(failed bool, message string) string {
+	if failed {
+		return message
+	}
+	return ""
+}
+
+// PolicyRuleNames returns the names of the supported rules, "=N" marking rules that take a value
+func PolicyRuleNames() []string {
+	names := make([]string, 0, len(policyRules))
+	for name, rule := range policyRules {
+		if rule.hasValue {
+			name += "=N"
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParsePolicyRule parses a rule such as "no-debug-fw" or "min-key-size=4096"
+func ParsePolicyRule(spec string) (PolicyRule, error) {
+	name, value, hasValue := strings.Cut(strings.TrimSpace(spec), "=")
+	rule, ok := policyRules[name]
+	if !ok {
+		return PolicyRule{}, pkgerrors.InvalidParameterError("policy", fmt.Sprintf("unknown rule %q", name))
+	}
+	if rule.hasValue != hasValue {
+		if rule.hasValue {
+			return PolicyRule{}, pkgerrors.InvalidParameterError("policy", fmt.Sprintf("rule %q needs a value, e.g. %s=N", name, name))
+		}
+		return PolicyRule{}, pkgerrors.InvalidParameterError("policy", fmt.Sprintf("rule %q takes no value", name))
+	}
+
+	parsed := PolicyRule{Name: name}
+	if hasValue {
+		v, err := strconv.ParseUint(value, 0, 64)
+		if err != nil {
+			return PolicyRule{}, pkgerrors.InvalidParameterError("policy", fmt.Sprintf("rule %q: invalid value %q", name, value))
+		}
+		parsed.Value = v
+	}
+	return parsed, nil
+}
+
+// LoadPolicyFile reads one rule per line; blank lines and lines starting with # are skipped
+func LoadPolicyFile(path string) ([]PolicyRule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, merry.Wrap(err)
+	}
+	defer f.Close()
+
+	var rules []PolicyRule
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		rule, err := ParsePolicyRule(text)
+		if err != nil {
+			return nil, merry.Prependf(err, "%s:%d", path, line)
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, merry.Wrap(err)
+	}
+	return rules, nil
+}
+
+// ApplyPolicy evaluates rules against the report, stores the results in
+// r.Policy and reports whether every rule passed
+func (r *SecurityReport) ApplyPolicy(rules []PolicyRule) bool {
+	passed := true
+	r.Policy = nil
+	for _, rule := range rules {
+		message := policyRules[rule.Name].check(r, rule.Value)
+		r.Policy = append(r.Policy, PolicyResult{Rule: rule.String(), Passed: message == "", Message: message})
+		passed = passed && message == ""
+	}
+	return passed
+}
+
+// signedKeySizes returns the key sizes of the signed IMAGE_SIGNATURE sections
+func (r *SecurityReport) signedKeySizes() []int {
+	var sizes []int
+	for _, s := range r.Signatures {
+		if s.Signed {
+			sizes = append(sizes, s.KeySize)
+		}
+	}
+	return sizes
+}
@@ -0,0 +1,366 @@
+package security
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+	"strings"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+// IMAGE_INFO security bits of the first dword, as in mstflint's image_layout_image_info
+const (
+	imageInfoDebugTokensSupported = 1 << 7
+	imageInfoMCCEn                = 1 << 8
+	imageInfoCSTokensSupported    = 1 << 12
+	imageInfoDebugFW              = 1 << 13
+	imageInfoSignedFW             = 1 << 14
+	imageInfoSecureFW             = 1 << 15
+)
+
+// Encryption states of an image
+const (
+	EncryptionNone         = "NONE"
+	EncryptionITOCReadable = "ENCRYPTED_ITOC_READABLE"
+	EncryptionFull         = "ENCRYPTED"
+)
+
+// SecurityFlags are the FW security mode bits
+type SecurityFlags struct {
+	MCCEn    bool `json:"mcc_en"`
+	DebugFW  bool `json:"debug_fw"`
+	SignedFW bool `json:"signed_fw"`
+	SecureFW bool `json:"secure_fw"`
+	DevFW    bool `json:"dev_fw"`
+}
+
+// TokenFlags are the token types the image accepts. CS and debug tokens come
+// from IMAGE_INFO; the others from the authentication configuration of the
+// embedded public keys. That configuration has a single challenge-response
+// token enable (the cr_token key flag) and no bit of its own for the remote
+// RMCS and RMDT tokens, so an image does not say whether it accepts those.
+type TokenFlags struct {
+	CSToken               bool `json:"cs_token"`
+	DbgToken              bool `json:"dbg_token"`
+	CryptoToCommissioning bool `json:"crypto_to_commissioning"`
+}
+
+// SignatureInfo describes one IMAGE_SIGNATURE section
+type SignatureInfo struct {
+	Section     string `json:"section"`
+	Offset      uint64 `json:"offset"`
+	Algorithm   string `json:"algorithm"`
+	KeySize     int    `json:"key_size"`
+	KeypairUUID string `json:"keypair_uuid"`
+	Signed      bool   `json:"signed"`
+}
+
+// PublicKeyInfo describes one embedded public key
+type PublicKeyInfo struct {
+	Source  string   `json:"source"`
+	UUID    string   `json:"uuid"`
+	KeySize int      `json:"key_size"`
+	Auth    []string `json:"auth,omitempty"`
+}
+
+// HashesTableInfo summarizes the HASHES_TABLE
+type HashesTableInfo struct {
+	Present   bool   `json:"present"`
+	Valid     bool   `json:"valid"`
+	Algorithm string `json:"algorithm,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// SecurityReport is the security posture of an image
+type SecurityReport struct {
+	// Attributes is the mstflint-style summary, e.g. "secure-fw, debug"
+	Attributes   string        `json:"attributes"`
+	SecurityMode uint32        `json:"security_mode"`
+	Flags        SecurityFlags `json:"flags"`
+	Tokens       TokenFlags    `json:"tokens"`
+	// SecurityVersion is read through the FW security version HW pointer
+	SecurityVersion          *uint32         `json:"security_version,omitempty"`
+	ForbiddenVersions        []uint32        `json:"forbidden_versions,omitempty"`
+	SecurityVersionForbidden bool            `json:"security_version_forbidden"`
+	Signatures               []SignatureInfo `json:"signatures"`
+	PublicKeys               []PublicKeyInfo `json:"public_keys"`
+	HashesTable              HashesTableInfo `json:"hashes_table"`
+	Encryption               string          `json:"encryption"`
+	Policy                   []PolicyResult  `json:"policy,omitempty"`
+}
+
+// hwPointersSource is implemented by parsers of images with FS4 HW pointers
+type hwPointersSource interface {
+	GetHWPointersRaw() ([]byte, *types.FS4HWPointers, error)
+}
+
+// BuildSecurityReport collects the security posture of the image in data
+func BuildSecurityReport(data []byte, fwParser interfaces.FirmwareParser) (*SecurityReport, error) {
+	report := &SecurityReport{
+		Signatures: []SignatureInfo{},
+		PublicKeys: []PublicKeyInfo{},
+		Encryption: encryptionState(fwParser),
+	}
+
+	if err := report.readImageInfo(data, fwParser); err != nil {
+		return nil, err
+	}
+	if err := report.readSignatures(data, fwParser); err != nil {
+		return nil, err
+	}
+	if err := report.readPublicKeys(data, fwParser); err != nil {
+		return nil, err
+	}
+	report.SecurityVersion = ReadSecurityVersion(data, fwParser)
+	report.ForbiddenVersions = ReadForbiddenVersions(data, fwParser)
+	if report.SecurityVersion != nil {
+		report.SecurityVersionForbidden = isForbidden(report.ForbiddenVersions, *report.SecurityVersion)
+	}
+	report.readHashesTable(data, fwParser)
+
+	report.SecurityMode = report.securityMode()
+	report.Attributes = report.attributes()
+	return report, nil
+}
+
+// encryptionState tells fully encrypted images apart from encrypted images
+// whose ITOC the parser found at the alternate location
+func encryptionState(fwParser interfaces.FirmwareParser) string {
+	if !fwParser.IsEncrypted() {
+		return EncryptionNone
+	}
+	for _, list := range fwParser.GetSections() {
+		for _, s := range list {
+			if !s.IsFromHWPointer() {
+				return EncryptionITOCReadable
+			}
+		}
+	}
+	return EncryptionFull
+}
+
+// sectionData returns the bytes of s within data
+func sectionData(data []byte, s interfaces.SectionInterface) ([]byte, error) {
+	end := s.Offset() + uint64(s.Size())
+	if end > uint64(len(data)) {
+		return nil, pkgerrors.DataTooShortError(int(end), len(data), s.TypeName())
+	}
+	return data[s.Offset():end], nil
+}
+
+func (r *SecurityReport) readImageInfo(data []byte, fwParser interfaces.FirmwareParser) error {
+	sections := fwParser.GetSections()[types.SectionTypeImageInfo]
+	if len(sections) == 0 {
+		return nil
+	}
+	raw, err := sectionData(data, sections[0])
+	if err != nil {
+		return err
+	}
+	if len(raw) < 4 {
+		return pkgerrors.DataTooShortError(4, len(raw), "IMAGE_INFO")
+	}
+
+	mode := binary.BigEndian.Uint32(raw)
+	r.Flags.MCCEn = mode&imageInfoMCCEn != 0
+	r.Flags.DebugFW = mode&imageInfoDebugFW != 0
+	r.Flags.SignedFW = mode&imageInfoSignedFW != 0
+	r.Flags.SecureFW = mode&imageInfoSecureFW != 0
+	r.Tokens.CSToken = mode&imageInfoCSTokensSupported != 0
+	r.Tokens.DbgToken = mode&imageInfoDebugTokensSupported != 0
+	return nil
+}
+
+func (r *SecurityReport) readSignatures(data []byte, fwParser interfaces.FirmwareParser) error {
+	for _, s := range signatureSections(fwParser) {
+		scheme := signatureSchemes[s.Type()]
+		_, keypairUUID, sig, err := readSignature(data, s, scheme)
+		if err != nil {
+			return err
+		}
+		r.Signatures = append(r.Signatures, SignatureInfo{
+			Section:     s.TypeName(),
+			Offset:      s.Offset(),
+			Algorithm:   scheme.algorithm,
+			KeySize:     scheme.size * 8,
+			KeypairUUID: keypairUUID.String(),
+			Signed:      !isBlank(sig),
+		})
+		if isDevKeypair(keypairUUID) {
+			r.Flags.DevFW = true
+		}
+	}
+	return nil
+}
+
+// isDevKeypair applies mstflint's GetImgSigInfo test for development key pair UUIDs
+func isDevKeypair(uuid UUID) bool {
+	var dw [4]uint32
+	for i := range dw {
+		dw[i] = binary.BigEndian.Uint32(uuid[i*4:])
+	}
+	return (dw[0] != 0 || dw[1] != 0 || dw[2] != 0) && dw[3] == 0 && dw[2]&0xFFFF == 0
+}
+
+func (r *SecurityReport) readPublicKeys(data []byte, fwParser interfaces.FirmwareParser) error {
+	keys, err := EmbeddedPublicKeys(data, fwParser)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		info := PublicKeyInfo{
+			Source:  key.Source,
+			UUID:    key.UUID.String(),
+			KeySize: key.Key.Size() * 8,
+		}
+		if key.AuthConfig != 0 && key.AuthConfig != 0xFFFFFFFF {
+			var raw [4]byte
+			binary.BigEndian.PutUint32(raw[:], key.AuthConfig)
+			var auth types.FS4ComponentAuthenticationConfiguration
+			if err := auth.Unmarshal(raw[:]); err != nil {
+				return err
+			}
+			info.Auth = authFlags(&auth)
+			r.Tokens.CSToken = r.Tokens.CSToken || auth.CSTokenEn != 0
+			r.Tokens.CryptoToCommissioning = r.Tokens.CryptoToCommissioning || auth.BTCTokenEn != 0
+		}
+		r.PublicKeys = append(r.PublicKeys, info)
+	}
+	return nil
+}
+
+// authFlags names the enabled bits of a key's authentication configuration
+func authFlags(auth *types.FS4ComponentAuthenticationConfiguration) []string {
+	var flags []string
+	for _, f := range []struct {
+		set  uint8
+		name string
+	}{
+		{auth.FWEn, "fw"},
+		{auth.CSTokenEn, "cs_token"},
+		{auth.CRTokenEn, "cr_token"},
+		{auth.BTCTokenEn, "btc_token"},
+		{auth.FRCEn, "frc"},
+		{auth.MLNXNVConfigEn, "mlnx_nvconfig"},
+		{auth.VendorNVConfigEn, "vendor_nvconfig"},
+	} {
+		if f.set != 0 {
+			flags = append(flags, f.name)
+		}
+	}
+	return flags
+}
+
+// ReadSecurityVersion returns the FW security version, the dword at the FW
+// security version HW pointer, or nil when the image has none. The FS5
+// (Gilboa) HW pointers have no security version pointer: the FS4 slot is
+// reserved there.
+func ReadSecurityVersion(data []byte, fwParser interfaces.FirmwareParser) *uint32 {
+	hw, ok := fwParser.(hwPointersSource)
+	if !ok || fwParser.GetFormat() == types.FormatFS5 {
+		return nil
+	}
+	_, pointers, err := hw.GetHWPointersRaw()
+	if err != nil || pointers == nil {
+		return nil
+	}
+	ptr := uint64(pointers.FWSecurityVersionPtr.Ptr)
+	if ptr == 0 || ptr == 0xFFFFFFFF || ptr+4 > uint64(len(data)) {
+		return nil
+	}
+	version := binary.BigEndian.Uint32(data[ptr:])
+	if version == 0xFFFFFFFF {
+		return nil
+	}
+	return &version
+}
+
+// ReadForbiddenVersions returns the sorted versions listed in the
+// FORBIDDEN_VERSIONS sections of the image
+func ReadForbiddenVersions(data []byte, fwParser interfaces.FirmwareParser) []uint32 {
+	var versions []uint32
+	for _, s := range fwParser.GetSections()[types.SectionTypeForbiddenVersions] {
+		raw, err := sectionData(data, s)
+		if err != nil || len(raw) < 8 {
+			continue
+		}
+		// A blank section has an all-ones count
+		count := binary.BigEndian.Uint32(raw)
+		if uint64(count) > uint64(len(raw)-8)/4 {
+			continue
+		}
+		for i := uint32(0); i < count; i++ {
+			versions = append(versions, binary.BigEndian.Uint32(raw[8+4*i:]))
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+	return versions
+}
+
+// isForbidden reports whether version is listed in forbidden
+func isForbidden(forbidden []uint32, version uint32) bool {
+	for _, v := range forbidden {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *SecurityReport) readHashesTable(data []byte, fwParser interfaces.FirmwareParser) {
+	hashes, err := VerifyHashes(data, fwParser)
+	switch {
+	case errors.Is(err, pkgerrors.ErrSectionNotFound):
+		return
+	case err != nil:
+		r.HashesTable = HashesTableInfo{Present: true, Error: err.Error()}
+	default:
+		r.HashesTable = HashesTableInfo{Present: true, Valid: hashes.OK, Algorithm: hashes.Algorithm}
+	}
+}
+
+// securityMode encodes the flags as an mstflint security mode mask (types.SMMFlags)
+func (r *SecurityReport) securityMode() uint32 {
+	var mode uint32
+	for _, f := range []struct {
+		set  bool
+		mask uint32
+	}{
+		{r.Flags.MCCEn, types.SMMFlags.MCC_EN},
+		{r.Flags.DebugFW, types.SMMFlags.DEBUG_FW},
+		{r.Flags.SignedFW, types.SMMFlags.SIGNED_FW},
+		{r.Flags.SecureFW, types.SMMFlags.SECURE_FW},
+		{r.Flags.DevFW, types.SMMFlags.DEV_FW},
+		{r.Tokens.CSToken, types.SMMFlags.CS_TOKEN},
+		{r.Tokens.DbgToken, types.SMMFlags.DBG_TOKEN},
+		{r.Tokens.CryptoToCommissioning, types.SMMFlags.CRYPTO_TO_COMMISSIONING},
+	} {
+		if f.set {
+			mode |= f.mask
+		}
+	}
+	return mode
+}
+
+// attributes builds the query-style security attributes string
+func (r *SecurityReport) attributes() string {
+	var attrs []string
+	switch {
+	case r.Flags.SecureFW || r.Encryption != EncryptionNone:
+		attrs = append(attrs, "secure-fw")
+	case r.Flags.SignedFW:
+		attrs = append(attrs, "signed-fw")
+	default:
+		return "N/A"
+	}
+	if r.Flags.DebugFW {
+		attrs = append(attrs, "debug")
+	}
+	if r.Flags.DevFW {
+		attrs = append(attrs, "dev")
+	}
+	return strings.Join(attrs, ", ")
+}
@@ -0,0 +1,150 @@
+package security
+
+import (
+	"encoding/binary"
+	"slices"
+	"testing"
+
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+// hwStubParser adds a format and FS4 HW pointers to stubParser
+type hwStubParser struct {
+	*stubParser
+	format   types.FirmwareFormat
+	pointers types.FS4HWPointers
+}
+
+func (p *hwStubParser) GetFormat() types.FirmwareFormat { return p.format }
+
+func (p *hwStubParser) GetHWPointersRaw() ([]byte, *types.FS4HWPointers, error) {
+	return nil, &p.pointers, nil
+}
+
+// createReportImage builds a signed debug image with security version 7,
+// which its FORBIDDEN_VERSIONS section lists
+func createReportImage(t *testing.T) ([]byte, *hwStubParser) {
+	t.Helper()
+	const forbiddenAddr, versionAddr = 0x4000, 0x4100
+	data, stub := createSignedImage(t, mustKey(t))
+	binary.BigEndian.PutUint32(data[testSigImageInfoAddr:], imageInfoSignedFW|imageInfoDebugFW)
+
+	binary.BigEndian.PutUint32(data[forbiddenAddr:], 2)
+	binary.BigEndian.PutUint32(data[forbiddenAddr+8:], 7)
+	binary.BigEndian.PutUint32(data[forbiddenAddr+12:], 3)
+	stub.sections[types.SectionTypeForbiddenVersions] = []interfaces.CompleteSectionInterface{
+		interfaces.NewBaseSectionWithOptions(types.SectionTypeForbiddenVersions, forbiddenAddr, 0x40),
+	}
+	binary.BigEndian.PutUint32(data[versionAddr:], 7)
+
+	fwParser := &hwStubParser{stubParser: stub, format: types.FormatFS4}
+	fwParser.pointers.FWSecurityVersionPtr.Ptr = versionAddr
+	return data, fwParser
+}
+
+func TestBuildSecurityReport(t *testing.T) {
+	data, fwParser := createReportImage(t)
+
+	report, err := BuildSecurityReport(data, fwParser)
+	if err != nil {
+		t.Fatalf("BuildSecurityReport() error = %v", err)
+	}
+
+	if report.Attributes != "signed-fw, debug" {
+		t.Errorf("Attributes = %q, want %q", report.Attributes, "signed-fw, debug")
+	}
+	if want := types.SMMFlags.SIGNED_FW | types.SMMFlags.DEBUG_FW; report.SecurityMode != want {
+		t.Errorf("SecurityMode = 0x%x, want 0x%x", report.SecurityMode, want)
+	}
+	if report.SecurityVersion == nil || *report.SecurityVersion != 7 || !report.SecurityVersionForbidden {
+		t.Errorf("security version = %v, forbidden %v (%v)", report.SecurityVersion, report.SecurityVersionForbidden, report.ForbiddenVersions)
+	}
+	if len(report.Signatures) != 1 || report.Signatures[0].KeySize != 2048 || !report.Signatures[0].Signed {
+		t.Errorf("Signatures = %+v", report.Signatures)
+	}
+	if len(report.PublicKeys) != 1 || report.PublicKeys[0].UUID != testKeypairUUID.String() {
+		t.Errorf("PublicKeys = %+v", report.PublicKeys)
+	}
+	if report.HashesTable.Present || report.Encryption != EncryptionNone {
+		t.Errorf("HashesTable = %+v, Encryption = %s", report.HashesTable, report.Encryption)
+	}
+}
+
+func TestReadSecurityVersion_FS5(t *testing.T) {
+	data, fwParser := createReportImage(t)
+	fwParser.format = types.FormatFS5
+
+	if version := ReadSecurityVersion(data, fwParser); version != nil {
+		t.Errorf("ReadSecurityVersion() of an FS5 image = %d, want nil", *version)
+	}
+}
+
+func TestBuildSecurityReport_ChallengeResponseToken(t *testing.T) {
+	data, fwParser := createReportImage(t)
+	// Slots with room for the authentication configuration after the modulus
+	const slotSize = publicKeyModulusOffset + 256 + 4
+	fwParser.sections[types.SectionTypePublicKeys2048] = []interfaces.CompleteSectionInterface{
+		interfaces.NewBaseSectionWithOptions(types.SectionTypePublicKeys2048, testSigKeysAddr, publicKeySlots*slotSize),
+	}
+	auth := &types.FS4ComponentAuthenticationConfiguration{AuthType: 3, CRTokenEn: 1, FWEn: 1}
+	raw, err := auth.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	copy(data[testSigKeysAddr+slotSize-4:testSigKeysAddr+slotSize], raw)
+
+	report, err := BuildSecurityReport(data, fwParser)
+	if err != nil {
+		t.Fatalf("BuildSecurityReport() error = %v", err)
+	}
+	if len(report.PublicKeys) != 1 || !slices.Contains(report.PublicKeys[0].Auth, "cr_token") {
+		t.Errorf("PublicKeys = %+v, want a key with cr_token", report.PublicKeys)
+	}
+	// The CR token bit does not say which remote tokens are accepted
+	if mask := types.SMMFlags.RMCS_TOKEN | types.SMMFlags.RMDT_TOKEN; report.SecurityMode&mask != 0 {
+		t.Errorf("SecurityMode = 0x%x has RMCS/RMDT bits", report.SecurityMode)
+	}
+}
+
+func TestApplyPolicy(t *testing.T) {
+	data, fwParser := createReportImage(t)
+	report, err := BuildSecurityReport(data, fwParser)
+	if err != nil {
+		t.Fatalf("BuildSecurityReport() error = %v", err)
+	}
+
+	tests := []struct {
+		rule       string
+		wantPassed bool
+	}{
+		{rule: "signed", wantPassed: true},
+		{rule: "no-dev-fw", wantPassed: true},
+		{rule: "min-key-size=2048", wantPassed: true},
+		{rule: "min-key-size=4096"},
+		{rule: "no-debug-fw"},
+		{rule: "hashes-valid"},
+		{rule: "not-forbidden"},
+		{rule: "min-security-version=0x8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			rule, err := ParsePolicyRule(tt.rule)
+			if err != nil {
+				t.Fatalf("ParsePolicyRule() error = %v", err)
+			}
+			if got := report.ApplyPolicy([]PolicyRule{rule}); got != tt.wantPassed {
+				t.Errorf("ApplyPolicy() = %v, want %v (%+v)", got, tt.wantPassed, report.Policy)
+			}
+		})
+	}
+}
+
+func TestParsePolicyRule_Invalid(t *testing.T) {
+	for _, spec := range []string{"no-such-rule", "min-key-size", "signed=1", "min-key-size=big"} {
+		if _, err := ParsePolicyRule(spec); err == nil {
+			t.Errorf("ParsePolicyRule(%q) accepted an invalid rule", spec)
+		}
+	}
+}
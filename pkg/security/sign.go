@@ -0,0 +1,174 @@
+package security
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/ansel1/merry/v2"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/section"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+// SignOptions controls SignImage
+type SignOptions struct {
+	// KeypairUUID identifies the signing key; it is stored in the signature
+	// section and, with WritePublicKey, in the public key slot
+	KeypairUUID UUID
+	// WritePublicKey stores the public key in the PUBLIC_KEYS section of the
+	// same size, in the slot with the same UUID or else the first empty one
+	WritePublicKey bool
+}
+
+// SignImage signs the image in data in place with signer, which must hold an
+// RSA 2048 or 4096 key (an *rsa.PrivateKey, a CommandSigner or any other
+// crypto.Signer producing PKCS#1 v1.5 signatures). The key size selects
+// IMAGE_SIGNATURE_256 or IMAGE_SIGNATURE_512, which must exist in the image.
+//
+// The public key (optional) and the HASHES_TABLE (when present) are updated
+// first, as both are part of the signed payload; the signature section CRC is
+// fixed last, which is safe since neither the section nor its ITOC entry is
+// signed. The returned report is the verification of the new signature.
+func SignImage(data []byte, fwParser interfaces.FirmwareParser, signer crypto.Signer, opts SignOptions) (*SignatureReport, error) {
+	if opts.KeypairUUID.IsZero() {
+		return nil, pkgerrors.InvalidParameterError("keypair UUID", "must not be zero")
+	}
+	pubKey, ok := signer.Public().(*rsa.PublicKey)
+	if !ok {
+		return nil, pkgerrors.NotSupportedError(fmt.Sprintf("signing with a %T key", signer.Public()))
+	}
+
+	var sigType, keysType uint16 = types.SectionTypeImageSignature256, types.SectionTypePublicKeys2048
+	if pubKey.Size() == 512 {
+		sigType, keysType = types.SectionTypeImageSignature512, types.SectionTypePublicKeys4096
+	} else if pubKey.Size() != 256 {
+		return nil, pkgerrors.NotSupportedError(fmt.Sprintf("RSA-%d keys", pubKey.Size()*8))
+	}
+	scheme := signatureSchemes[sigType]
+
+	sigSections := fwParser.GetSections()[sigType]
+	if len(sigSections) == 0 {
+		return nil, merry.Wrap(pkgerrors.ErrSectionNotFound,
+			merry.WithMessagef("image has no %s section for a %d-bit key", types.GetSectionTypeName(sigType), pubKey.Size()*8))
+	}
+	sigSection := sigSections[0]
+
+	if opts.WritePublicKey {
+		if err := writePublicKey(data, fwParser, keysType, pubKey, opts.KeypairUUID); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := FindHashesTable(fwParser); err == nil {
+		if _, err := UpdateHashes(data, fwParser); err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, pkgerrors.ErrSectionNotFound) {
+		return nil, err
+	}
+
+	payload, _, err := SignedPayload(data, fwParser)
+	if err != nil {
+		return nil, err
+	}
+	h := scheme.hash.New()
+	h.Write(payload)
+	sig, err := signer.Sign(rand.Reader, h.Sum(nil), scheme.hash)
+	if err != nil {
+		return nil, merry.Prepend(err, "failed to sign image")
+	}
+	if len(sig) != scheme.size {
+		return nil, merry.Wrap(pkgerrors.ErrInvalidSize,
+			merry.WithMessagef("signer returned %d bytes, want %d", len(sig), scheme.size))
+	}
+
+	signatureUUID, err := newSignatureUUID()
+	if err != nil {
+		return nil, err
+	}
+	if sigSection.Offset()+signatureOffset+uint64(scheme.size) > uint64(len(data)) || sigSection.Size() < signatureOffset+uint32(scheme.size) {
+		return nil, pkgerrors.DataTooShortError(signatureOffset+scheme.size, int(sigSection.Size()), sigSection.TypeName())
+	}
+	raw := data[sigSection.Offset():]
+	copy(raw[0x0:0x10], signatureUUID[:])
+	copy(raw[0x10:signatureOffset], opts.KeypairUUID[:])
+	copy(raw[signatureOffset:], sig)
+	if err := section.UpdateSectionCRC(data, fwParser, sigSection); err != nil {
+		return nil, merry.Prepend(err, "failed to update signature CRC")
+	}
+
+	report, err := VerifySignatures(data, fwParser, []PublicKey{{UUID: opts.KeypairUUID, Key: pubKey, Source: "signer"}})
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range report.Signatures {
+		if entry.Offset == sigSection.Offset() && entry.Status != SignatureValid {
+			return nil, merry.Errorf("new %s does not verify (%s)", entry.Section, entry.Status)
+		}
+	}
+	return report, nil
+}
+
+// writePublicKey stores pubKey in the PUBLIC_KEYS section of keysType and
+// fixes the section CRC
+func writePublicKey(data []byte, fwParser interfaces.FirmwareParser, keysType uint16, pubKey *rsa.PublicKey, uuid UUID) error {
+	list := fwParser.GetSections()[keysType]
+	if len(list) == 0 {
+		return merry.Wrap(pkgerrors.ErrSectionNotFound,
+			merry.WithMessagef("image has no %s section", types.GetSectionTypeName(keysType)))
+	}
+	keysSection := list[0]
+	end := keysSection.Offset() + uint64(keysSection.Size())
+	if end > uint64(len(data)) {
+		return pkgerrors.DataTooShortError(int(end), len(data), keysSection.TypeName())
+	}
+	sectionData := data[keysSection.Offset():end]
+
+	modulusSize := publicKeyModulusSize(keysType)
+	slotSize := len(sectionData) / publicKeySlots
+	if slotSize < publicKeyModulusOffset+modulusSize {
+		return pkgerrors.DataTooShortError(publicKeySlots*(publicKeyModulusOffset+modulusSize), len(sectionData), keysSection.TypeName())
+	}
+
+	// Replace the key with the same UUID, else take the first empty slot
+	slot := -1
+	for i := 0; i < publicKeySlots && slot < 0; i++ {
+		if publicKeySlotUUID(sectionData[i*slotSize:]) == uuid {
+			slot = i
+		}
+	}
+	for i := 0; i < publicKeySlots && slot < 0; i++ {
+		if publicKeySlotEmpty(sectionData[i*slotSize:], modulusSize) {
+			slot = i
+		}
+	}
+	if slot < 0 {
+		return merry.Errorf("%s has no free key slot for %s", keysSection.TypeName(), uuid)
+	}
+
+	raw := sectionData[slot*slotSize:]
+	binary.BigEndian.PutUint32(raw[publicKeyExpOffset:], uint32(pubKey.E))
+	copy(raw[publicKeyUUIDOffset:publicKeyModulusOffset], uuid[:])
+	pubKey.N.FillBytes(raw[publicKeyModulusOffset : publicKeyModulusOffset+modulusSize])
+	if err := section.UpdateSectionCRC(data, fwParser, keysSection); err != nil {
+		return merry.Prepend(err, "failed to update public keys CRC")
+	}
+	return nil
+}
+
+// newSignatureUUID returns a random (version 4) UUID identifying one signature
+func newSignatureUUID() (UUID, error) {
+	var u UUID
+	if _, err := rand.Read(u[:]); err != nil {
+		return u, merry.Wrap(err)
+	}
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return u, nil
+}
@@ -0,0 +1,182 @@
+package security
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/parser"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+var testSignUUID = UUID{0xca, 0xfe, 0xba, 0xbe, 0, 0, 0x40, 0, 0x80, 0, 0, 0, 0, 0, 0, 0x01}
+
+func TestSignImage(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, fwParser := createSignedImage(t, oldKey)
+
+	report, err := SignImage(data, fwParser, newKey, SignOptions{KeypairUUID: testSignUUID, WritePublicKey: true})
+	if err != nil {
+		t.Fatalf("SignImage() error = %v", err)
+	}
+	if !report.OK {
+		t.Fatalf("SignImage() report = %+v", report)
+	}
+
+	// The new key goes to the first free slot next to the existing one
+	keys, err := EmbeddedPublicKeys(data, fwParser)
+	if err != nil {
+		t.Fatalf("EmbeddedPublicKeys() error = %v", err)
+	}
+	if len(keys) != 2 || keys[1].UUID != testSignUUID || keys[1].Key.N.Cmp(newKey.N) != 0 {
+		t.Fatalf("EmbeddedPublicKeys() = %+v", keys)
+	}
+	if got := verifyStatus(t, data, fwParser, keys); got != SignatureValid {
+		t.Errorf("status with the embedded keys = %s, want VALID", got)
+	}
+	if got := verifyStatus(t, data, fwParser, []PublicKey{{Key: &oldKey.PublicKey}}); got != SignatureInvalid {
+		t.Errorf("status with the old key = %s, want INVALID", got)
+	}
+}
+
+func TestSignImage_SectionCRC(t *testing.T) {
+	tests := []struct {
+		name    string
+		crcType types.CRCType
+	}{
+		// The entry CRC fields change after signing and must not be signed
+		{"CRC in ITOC entry", types.CRCInITOCEntry},
+		// The section ends with the signature, which must not get a CRC
+		{"CRC in section", types.CRCInSection},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, fwParser := createSignedImage(t, mustKey(t))
+			for _, sectionType := range []uint16{types.SectionTypeImageSignature256, types.SectionTypePublicKeys2048} {
+				old := fwParser.sections[sectionType][0]
+				fwParser.sections[sectionType] = []interfaces.CompleteSectionInterface{
+					interfaces.NewBaseSectionWithOptions(sectionType, old.Offset(), old.Size(), interfaces.WithCRC(tt.crcType, 0)),
+				}
+			}
+
+			newKey := mustKey(t)
+			report, err := SignImage(data, fwParser, newKey, SignOptions{KeypairUUID: testSignUUID, WritePublicKey: true})
+			if err != nil {
+				t.Fatalf("SignImage() error = %v", err)
+			}
+			if !report.OK {
+				t.Fatalf("SignImage() report = %+v", report)
+			}
+			if got := verifyStatus(t, data, fwParser, []PublicKey{{Key: &newKey.PublicKey}}); got != SignatureValid {
+				t.Errorf("status after signing = %s, want VALID", got)
+			}
+
+			if tt.crcType != types.CRCInITOCEntry {
+				return
+			}
+			entries, err := parser.NewTOCReader(zap.NewNop()).ReadTOCRawEntries(data, testSigITOCAddr, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			crc := parser.NewCRCCalculator()
+			for _, entry := range entries {
+				if entry.GetType() != types.SectionTypeImageSignature256 {
+					continue
+				}
+				want := crc.CalculateImageCRC(data[testSigAddr:testSigAddr+testSigSize], testSigSize/4)
+				if entry.SectionCRC != want {
+					t.Errorf("signature ITOC entry CRC = 0x%04x, want 0x%04x", entry.SectionCRC, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSignImage_WrongKeySize(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, fwParser := createSignedImage(t, mustKey(t))
+	if _, err := SignImage(data, fwParser, key, SignOptions{KeypairUUID: testSignUUID}); err == nil {
+		t.Error("SignImage() accepted an RSA-1024 key")
+	}
+}
+
+// TestCommandSignerHelper is the external signer used by TestSignImage_CommandSigner;
+// it signs stdin with the PKCS#1 key named by MLX5FW_TEST_SIGNER_KEY
+func TestCommandSignerHelper(t *testing.T) {
+	keyPath := os.Getenv("MLX5FW_TEST_SIGNER_KEY")
+	if keyPath == "" {
+		t.Skip("only run as an external signer")
+	}
+	key, err := LoadPrivateKeyPEM(keyPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	digest, err := io.ReadAll(os.Stdin)
+	if err != nil || os.Getenv(SignHashEnv) != "SHA256" {
+		fmt.Fprintln(os.Stderr, "bad input", err)
+		os.Exit(1)
+	}
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Stdout.Write(sig)
+	os.Exit(0)
+}
+
+func TestSignImage_CommandSigner(t *testing.T) {
+	key := mustKey(t)
+	keyPath := filepath.Join(t.TempDir(), "key.pem")
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MLX5FW_TEST_SIGNER_KEY", keyPath)
+
+	data, fwParser := createSignedImage(t, mustKey(t))
+	signer := &CommandSigner{
+		Command:   []string{os.Args[0], "-test.run=^TestCommandSignerHelper$"},
+		PublicKey: &key.PublicKey,
+	}
+	report, err := SignImage(data, fwParser, signer, SignOptions{KeypairUUID: testKeypairUUID})
+	if err != nil {
+		t.Fatalf("SignImage() error = %v", err)
+	}
+	if !report.OK {
+		t.Errorf("SignImage() report = %+v", report)
+	}
+}
+
+func mustKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
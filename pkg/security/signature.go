@@ -0,0 +1,216 @@
+package security
+
+import (
+	"crypto"
+	"crypto/rsa"
+	_ "crypto/sha256" // registers crypto.SHA256
+	_ "crypto/sha512" // registers crypto.SHA512
+
+	"github.com/ansel1/merry/v2"
+	"go.uber.org/zap"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/parser"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+// SignatureStatus is the verification status of one IMAGE_SIGNATURE section
+type SignatureStatus string
+
+const (
+	SignatureValid    SignatureStatus = "VALID"
+	SignatureInvalid  SignatureStatus = "INVALID"
+	SignatureUnsigned SignatureStatus = "UNSIGNED"
+	SignatureNoKey    SignatureStatus = "NO KEY"
+)
+
+// Layout of IMAGE_SIGNATURE_256/512 (types.FS4ImageSignatureStruct and
+// types.FS4ImageSignature2Struct): signature UUID, key pair UUID, signature
+const signatureOffset = 0x20
+
+// signedPayloadMagicSize is the size of the magic pattern at the image start,
+// which mstflint masks before hashing the image
+const signedPayloadMagicSize = 0x10
+
+// SignatureEntry is one IMAGE_SIGNATURE section checked against the payload
+type SignatureEntry struct {
+	Section       string          `json:"section"`
+	Offset        uint64          `json:"offset"`
+	Algorithm     string          `json:"algorithm"`
+	SignatureUUID string          `json:"signature_uuid"`
+	KeypairUUID   string          `json:"keypair_uuid"`
+	KeySource     string          `json:"key_source,omitempty"`
+	Status        SignatureStatus `json:"status"`
+}
+
+// SignatureReport is the result of VerifySignatures
+type SignatureReport struct {
+	PayloadOffset uint64           `json:"payload_offset"`
+	PayloadSize   uint64           `json:"payload_size"`
+	Signatures    []SignatureEntry `json:"signatures"`
+	// OK is true when the image carries at least one signature and all are valid
+	OK bool `json:"ok"`
+}
+
+// signatureScheme describes the RSA key size and digest of a signature section
+type signatureScheme struct {
+	size      int
+	hash      crypto.Hash
+	algorithm string
+}
+
+// signatureSchemes maps the signature section types to their schemes:
+// 2048-bit keys sign SHA-256 digests, 4096-bit keys SHA-512 digests
+var signatureSchemes = map[uint16]signatureScheme{
+	types.SectionTypeImageSignature256: {size: 256, hash: crypto.SHA256, algorithm: "RSA2048-SHA256"},
+	types.SectionTypeImageSignature512: {size: 512, hash: crypto.SHA512, algorithm: "RSA4096-SHA512"},
+}
+
+// SignedPayload rebuilds the bytes covered by the image signatures, following
+// mstflint's FwExtract4MBImage: the image from its magic pattern to the end of
+// the last non-device-data section, with the magic pattern, the signature
+// sections and any device data inside that range masked with 0xFF. The ITOC
+// entries of the signature sections are masked too, as mstflint's
+// MaskItocSectionAndEntry does: their CRC fields change whenever the section
+// is written. It returns the payload and its offset in data.
+func SignedPayload(data []byte, fwParser interfaces.FirmwareParser) ([]byte, uint64, error) {
+	masked := func(sectionType uint16) bool {
+		_, isSignature := signatureSchemes[sectionType]
+		return isSignature
+	}
+	start := uint64(fwParser.GetMagicOffset())
+	end := start
+	for _, list := range fwParser.GetSections() {
+		for _, s := range list {
+			if !s.IsDeviceData() && s.Offset()+uint64(s.Size()) > end {
+				end = s.Offset() + uint64(s.Size())
+			}
+		}
+	}
+	if end <= start+signedPayloadMagicSize {
+		return nil, 0, merry.Wrap(pkgerrors.ErrInvalidData, merry.WithMessage("image has no sections to sign"))
+	}
+	if end > uint64(len(data)) {
+		return nil, 0, pkgerrors.DataTooShortError(int(end), len(data), "signed image")
+	}
+
+	payload := make([]byte, end-start)
+	copy(payload, data[start:end])
+	mask := func(offset, size uint64) {
+		if offset < start || offset >= end {
+			return
+		}
+		from := offset - start
+		to := min(from+size, uint64(len(payload)))
+		for i := from; i < to; i++ {
+			payload[i] = 0xFF
+		}
+	}
+
+	mask(start, signedPayloadMagicSize)
+	for sectionType, list := range fwParser.GetSections() {
+		for _, s := range list {
+			if masked(sectionType) || s.IsDeviceData() {
+				mask(s.Offset(), uint64(s.Size()))
+			}
+		}
+	}
+
+	itocAddr := fwParser.GetITOCAddress()
+	entries, err := parser.NewTOCReader(zap.NewNop()).ReadTOCRawEntries(data, itocAddr, false)
+	if err != nil {
+		return nil, 0, merry.Prepend(err, "failed to read ITOC entries")
+	}
+	for i, entry := range entries {
+		if masked(entry.GetType()) {
+			mask(uint64(itocAddr)+uint64(i+1)*types.ITOCEntrySize, types.ITOCEntrySize)
+		}
+	}
+	return payload, start, nil
+}
+
+// signatureSections returns the IMAGE_SIGNATURE sections of the image, 256 first
+func signatureSections(fwParser interfaces.FirmwareParser) []interfaces.CompleteSectionInterface {
+	var result []interfaces.CompleteSectionInterface
+	for _, sectionType := range []uint16{types.SectionTypeImageSignature256, types.SectionTypeImageSignature512} {
+		for _, s := range fwParser.GetSections()[sectionType] {
+			if !s.IsDeviceData() {
+				result = append(result, s)
+			}
+		}
+	}
+	return result
+}
+
+// readSignature decodes the UUIDs and the raw signature of a signature section
+func readSignature(data []byte, s interfaces.SectionInterface, scheme signatureScheme) (signatureUUID, keypairUUID UUID, sig []byte, err error) {
+	end := s.Offset() + signatureOffset + uint64(scheme.size)
+	if end > uint64(len(data)) || uint64(s.Size()) < signatureOffset+uint64(scheme.size) {
+		return signatureUUID, keypairUUID, nil, pkgerrors.DataTooShortError(signatureOffset+scheme.size, int(s.Size()), s.TypeName())
+	}
+
+	raw := data[s.Offset():end]
+	copy(signatureUUID[:], raw[0x0:0x10])
+	copy(keypairUUID[:], raw[0x10:signatureOffset])
+	return signatureUUID, keypairUUID, raw[signatureOffset:], nil
+}
+
+// VerifySignatures checks every IMAGE_SIGNATURE_256/512 section of the image
+// in data against keys. A signature is checked with the key whose UUID matches
+// its key pair UUID; a key with a zero UUID (e.g. loaded from a PEM file)
+// matches any signature of its size.
+func VerifySignatures(data []byte, fwParser interfaces.FirmwareParser, keys []PublicKey) (*SignatureReport, error) {
+	payload, payloadOffset, err := SignedPayload(data, fwParser)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &SignatureReport{PayloadOffset: payloadOffset, PayloadSize: uint64(len(payload))}
+	digests := make(map[crypto.Hash][]byte)
+	for _, s := range signatureSections(fwParser) {
+		scheme := signatureSchemes[s.Type()]
+		signatureUUID, keypairUUID, sig, err := readSignature(data, s, scheme)
+		if err != nil {
+			return nil, err
+		}
+
+		entry := SignatureEntry{
+			Section:       s.TypeName(),
+			Offset:        s.Offset(),
+			Algorithm:     scheme.algorithm,
+			SignatureUUID: signatureUUID.String(),
+			KeypairUUID:   keypairUUID.String(),
+			Status:        SignatureNoKey,
+		}
+		if isBlank(sig) {
+			entry.Status = SignatureUnsigned
+		} else {
+			if digests[scheme.hash] == nil {
+				h := scheme.hash.New()
+				h.Write(payload)
+				digests[scheme.hash] = h.Sum(nil)
+			}
+			for _, key := range keys {
+				if key.Key.Size() != scheme.size || (!key.UUID.IsZero() && key.UUID != keypairUUID) {
+					continue
+				}
+				entry.KeySource = key.Source
+				entry.Status = SignatureInvalid
+				if rsa.VerifyPKCS1v15(key.Key, scheme.hash, digests[scheme.hash], sig) == nil {
+					entry.Status = SignatureValid
+					break
+				}
+			}
+		}
+		report.Signatures = append(report.Signatures, entry)
+	}
+
+	report.OK = len(report.Signatures) > 0
+	for _, entry := range report.Signatures {
+		if entry.Status != SignatureValid {
+			report.OK = false
+		}
+	}
+	return report, nil
+}
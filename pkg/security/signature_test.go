@@ -0,0 +1,212 @@
+package security
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+const (
+	testSigImageInfoAddr = 0x1000
+	testSigKeysAddr      = 0x2000
+	testSigKeysSize      = 8 * 0x114
+	testSigAddr          = 0x3000
+	testSigSize          = signatureOffset + 256
+	testSigMfgInfoAddr   = 0x7000
+	testSigITOCAddr      = 0x800
+)
+
+var testKeypairUUID = UUID{0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff, 0x00}
+
+// writeTestITOC writes an ITOC at testSigITOCAddr with an entry for each
+// section of fwParser that is not device data, in section type order
+func writeTestITOC(t *testing.T, data []byte, fwParser *stubParser) {
+	t.Helper()
+	var list []interfaces.CompleteSectionInterface
+	for _, sections := range fwParser.sections {
+		for _, s := range sections {
+			if !s.IsDeviceData() {
+				list = append(list, s)
+			}
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Type() < list[j].Type() })
+
+	binary.BigEndian.PutUint32(data[testSigITOCAddr:], types.ITOCSignature)
+	for i, s := range list {
+		entry := &types.ITOCEntry{
+			Type:            uint8(s.Type()),
+			SizeDwords:      s.Size() / 4,
+			FlashAddrDwords: uint32(s.Offset()),
+		}
+		raw, err := entry.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		copy(data[testSigITOCAddr+(i+1)*types.ITOCEntrySize:], raw)
+	}
+	end := data[testSigITOCAddr+(len(list)+1)*types.ITOCEntrySize:][:types.ITOCEntrySize]
+	for i := range end {
+		end[i] = 0xFF
+	}
+	fwParser.itocAddr = testSigITOCAddr
+}
+
+// createSignedImage builds an image with IMAGE_INFO, a PUBLIC_KEYS_2048
+// section holding the public half of key and an IMAGE_SIGNATURE_256 made with key
+func createSignedImage(t *testing.T, key *rsa.PrivateKey) ([]byte, *stubParser) {
+	t.Helper()
+	data := bytes.Repeat([]byte{0xFF}, 0x8000)
+	binary.BigEndian.PutUint64(data, types.MagicPattern)
+	for i := 0; i < 0x400; i++ {
+		data[testSigImageInfoAddr+i] = byte(i)
+		data[testSigMfgInfoAddr+i] = byte(i * 3)
+	}
+
+	slot := data[testSigKeysAddr:]
+	binary.BigEndian.PutUint32(slot[publicKeyExpOffset:], uint32(key.E))
+	copy(slot[publicKeyUUIDOffset:], testKeypairUUID[:])
+	key.N.FillBytes(slot[publicKeyModulusOffset : publicKeyModulusOffset+256])
+
+	fwParser := &stubParser{sections: map[uint16][]interfaces.CompleteSectionInterface{
+		types.SectionTypeImageInfo:         {interfaces.NewBaseSectionWithOptions(types.SectionTypeImageInfo, testSigImageInfoAddr, 0x400)},
+		types.SectionTypePublicKeys2048:    {interfaces.NewBaseSectionWithOptions(types.SectionTypePublicKeys2048, testSigKeysAddr, testSigKeysSize)},
+		types.SectionTypeImageSignature256: {interfaces.NewBaseSectionWithOptions(types.SectionTypeImageSignature256, testSigAddr, testSigSize)},
+		types.SectionTypeMfgInfo: {interfaces.NewBaseSectionWithOptions(types.SectionTypeMfgInfo, testSigMfgInfoAddr, 0x400,
+			interfaces.WithDeviceData())},
+	}}
+	writeTestITOC(t, data, fwParser)
+
+	payload, _, err := SignedPayload(data, fwParser)
+	if err != nil {
+		t.Fatalf("SignedPayload() error = %v", err)
+	}
+	digest := sha256.Sum256(payload)
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	copy(data[testSigAddr+0x10:], testKeypairUUID[:])
+	copy(data[testSigAddr+signatureOffset:], sig)
+	return data, fwParser
+}
+
+func verifyStatus(t *testing.T, data []byte, fwParser *stubParser, keys []PublicKey) SignatureStatus {
+	t.Helper()
+	report, err := VerifySignatures(data, fwParser, keys)
+	if err != nil {
+		t.Fatalf("VerifySignatures() error = %v", err)
+	}
+	if len(report.Signatures) != 1 {
+		t.Fatalf("VerifySignatures() = %+v, want one signature", report)
+	}
+	if report.OK != (report.Signatures[0].Status == SignatureValid) {
+		t.Errorf("VerifySignatures() OK = %v with status %s", report.OK, report.Signatures[0].Status)
+	}
+	return report.Signatures[0].Status
+}
+
+func TestVerifySignatures_EmbeddedKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, fwParser := createSignedImage(t, key)
+
+	keys, err := EmbeddedPublicKeys(data, fwParser)
+	if err != nil {
+		t.Fatalf("EmbeddedPublicKeys() error = %v", err)
+	}
+	if len(keys) != 1 || keys[0].UUID != testKeypairUUID || keys[0].Key.N.Cmp(key.N) != 0 {
+		t.Fatalf("EmbeddedPublicKeys() = %+v", keys)
+	}
+
+	if got := verifyStatus(t, data, fwParser, keys); got != SignatureValid {
+		t.Errorf("status = %s, want VALID", got)
+	}
+
+	// Device data and the magic pattern are not signed
+	data[testSigMfgInfoAddr] ^= 0xFF
+	data[0] ^= 0xFF
+	if got := verifyStatus(t, data, fwParser, keys); got != SignatureValid {
+		t.Errorf("status after a device data change = %s, want VALID", got)
+	}
+
+	data[testSigImageInfoAddr+0x10] ^= 0xFF
+	if got := verifyStatus(t, data, fwParser, keys); got != SignatureInvalid {
+		t.Errorf("status after an IMAGE_INFO change = %s, want INVALID", got)
+	}
+}
+
+func TestVerifySignatures_KeyMatching(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, fwParser := createSignedImage(t, key)
+
+	other := PublicKey{UUID: UUID{0x01}, Key: &key.PublicKey}
+	if got := verifyStatus(t, data, fwParser, []PublicKey{other}); got != SignatureNoKey {
+		t.Errorf("status with a key of another UUID = %s, want NO KEY", got)
+	}
+
+	path := filepath.Join(t.TempDir(), "key.pem")
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pemKey, err := LoadPublicKeyPEM(path)
+	if err != nil {
+		t.Fatalf("LoadPublicKeyPEM() error = %v", err)
+	}
+	if got := verifyStatus(t, data, fwParser, []PublicKey{{Key: pemKey, Source: path}}); got != SignatureValid {
+		t.Errorf("status with a PEM key = %s, want VALID", got)
+	}
+}
+
+func TestVerifySignatures_Unsigned(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, fwParser := createSignedImage(t, key)
+	copy(data[testSigAddr+signatureOffset:], bytes.Repeat([]byte{0xFF}, 256))
+
+	if got := verifyStatus(t, data, fwParser, nil); got != SignatureUnsigned {
+		t.Errorf("status = %s, want UNSIGNED", got)
+	}
+
+	delete(fwParser.sections, types.SectionTypeImageSignature256)
+	report, err := VerifySignatures(data, fwParser, nil)
+	if err != nil {
+		t.Fatalf("VerifySignatures() error = %v", err)
+	}
+	if report.OK {
+		t.Error("VerifySignatures() of an image without signatures passed")
+	}
+}
+
+func TestParseUUID(t *testing.T) {
+	u, err := ParseUUID(testKeypairUUID.String())
+	if err != nil || u != testKeypairUUID {
+		t.Errorf("ParseUUID(%q) = %v, %v", testKeypairUUID.String(), u, err)
+	}
+	if _, err := ParseUUID("1234"); err == nil {
+		t.Error("ParseUUID() accepted a short UUID")
+	}
+}
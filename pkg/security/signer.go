@@ -0,0 +1,62 @@
+package security
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/ansel1/merry/v2"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+)
+
+// SignHashEnv is the environment variable that tells a CommandSigner program
+// which digest it receives ("SHA256" or "SHA512")
+const SignHashEnv = "MLX5FW_SIGN_HASH"
+
+// CommandSigner is a crypto.Signer that delegates signing to an external
+// program, e.g. an HSM client or a PKCS#11 wrapper. The program gets the raw
+// digest on stdin and SignHashEnv in its environment, and must write the raw
+// RSA PKCS#1 v1.5 signature to stdout.
+type CommandSigner struct {
+	// Command is the program and its arguments
+	Command []string
+	// PublicKey is the public half of the key the program signs with
+	PublicKey *rsa.PublicKey
+}
+
+// Public implements crypto.Signer
+func (s *CommandSigner) Public() crypto.PublicKey {
+	return s.PublicKey
+}
+
+// Sign implements crypto.Signer; rand is unused
+func (s *CommandSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if len(s.Command) == 0 {
+		return nil, pkgerrors.InvalidParameterError("signer command", "must not be empty")
+	}
+	var hashName string
+	switch opts.HashFunc() {
+	case crypto.SHA256:
+		hashName = "SHA256"
+	case crypto.SHA512:
+		hashName = "SHA512"
+	default:
+		return nil, pkgerrors.NotSupportedError("signing " + opts.HashFunc().String() + " digests")
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(s.Command[0], s.Command[1:]...)
+	cmd.Env = append(os.Environ(), SignHashEnv+"="+hashName)
+	cmd.Stdin = bytes.NewReader(digest)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, merry.Prependf(err, "signer %s failed: %s", s.Command[0], strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
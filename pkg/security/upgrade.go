@@ -0,0 +1,160 @@
+package security
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ansel1/merry/v2"
+
+	pkgerrors "github.com/Civil/mlx5fw-go/pkg/errors"
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+// ImageVersion identifies one side of an upgrade. Fields that are unknown,
+// e.g. when only a FW version was given, are left empty.
+type ImageVersion struct {
+	FWVersion         string   `json:"fw_version"`
+	SecurityVersion   *uint32  `json:"security_version,omitempty"`
+	PSID              string   `json:"psid,omitempty"`
+	DeviceID          uint16   `json:"device_id,omitempty"`
+	ForbiddenVersions []uint32 `json:"forbidden_versions,omitempty"`
+}
+
+// ReadImageVersion reads the versions and identity of the image in data
+func ReadImageVersion(data []byte, fwParser interfaces.FirmwareParser) (*ImageVersion, error) {
+	sections := fwParser.GetSections()[types.SectionTypeImageInfo]
+	if len(sections) == 0 {
+		return nil, pkgerrors.SectionNotFoundError("IMAGE_INFO", 0)
+	}
+	raw, err := sectionData(data, sections[0])
+	if err != nil {
+		return nil, err
+	}
+	var info types.ImageInfo
+	if err := info.Unmarshal(raw); err != nil {
+		return nil, merry.Prepend(err, "failed to parse IMAGE_INFO")
+	}
+
+	v, err := NewImageVersion(info.GetFWVersionString())
+	if err != nil {
+		return nil, err
+	}
+	v.PSID = info.GetPSIDString()
+	v.DeviceID = info.PCIDeviceID
+	v.SecurityVersion = ReadSecurityVersion(data, fwParser)
+	v.ForbiddenVersions = ReadForbiddenVersions(data, fwParser)
+	return v, nil
+}
+
+// NewImageVersion returns the ImageVersion of a "major.minor.subminor" FW version
+func NewImageVersion(fwVersion string) (*ImageVersion, error) {
+	parts := strings.Split(fwVersion, ".")
+	if len(parts) != 3 {
+		return nil, pkgerrors.InvalidParameterError("version", fmt.Sprintf("%q is not major.minor.subminor", fwVersion))
+	}
+	// Each field is 16 bits wide in IMAGE_INFO
+	var fields [3]uint64
+	for i := range fields {
+		n, err := strconv.ParseUint(parts[i], 10, 16)
+		if err != nil {
+			return nil, pkgerrors.InvalidParameterError("version", fmt.Sprintf("%q is not major.minor.subminor", fwVersion))
+		}
+		fields[i] = n
+	}
+	return &ImageVersion{FWVersion: fmt.Sprintf("%d.%d.%04d", fields[0], fields[1], fields[2])}, nil
+}
+
+// forbiddenBy reports whether other's FORBIDDEN_VERSIONS list the security
+// version of v. The section is a count followed by dword entries
+// (types.ForbiddenVersions), so its entries are compared with the dword FW
+// security version, as in the security report; a major.minor.subminor FW
+// version is three 16-bit IMAGE_INFO fields and does not fit an entry.
+func (v *ImageVersion) forbiddenBy(other *ImageVersion) bool {
+	return v.SecurityVersion != nil && isForbidden(other.ForbiddenVersions, *v.SecurityVersion)
+}
+
+// Upgrade check statuses
+const (
+	UpgradeCheckPass    = "PASS"
+	UpgradeCheckFail    = "FAIL"
+	UpgradeCheckSkipped = "SKIPPED"
+)
+
+// Upgrade verdicts
+const (
+	UpgradeAllowed  = "ALLOWED"
+	UpgradeRejected = "REJECTED"
+)
+
+// UpgradeCheck is the outcome of one anti-rollback or compatibility check
+type UpgradeCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// UpgradeVerdict tells whether the device would accept the target image
+type UpgradeVerdict struct {
+	Verdict string         `json:"verdict"`
+	From    *ImageVersion  `json:"from"`
+	To      *ImageVersion  `json:"to"`
+	Checks  []UpgradeCheck `json:"checks"`
+}
+
+// CheckUpgrade checks whether an upgrade from one image to another would be
+// rejected: because either version is listed in the other image's
+// FORBIDDEN_VERSIONS, because the security version would decrease, or
+// because the PSID or PCI device ID differ. Checks whose inputs are unknown
+// are skipped.
+func CheckUpgrade(from, to *ImageVersion) *UpgradeVerdict {
+	verdict := &UpgradeVerdict{Verdict: UpgradeAllowed, From: from, To: to}
+	add := func(name, status, message string) {
+		verdict.Checks = append(verdict.Checks, UpgradeCheck{Name: name, Status: status, Message: message})
+		if status == UpgradeCheckFail {
+			verdict.Verdict = UpgradeRejected
+		}
+	}
+
+	switch {
+	case from.forbiddenBy(to):
+		add("forbidden_version", UpgradeCheckFail,
+			fmt.Sprintf("current security version %d is listed in FORBIDDEN_VERSIONS of the target", *from.SecurityVersion))
+	case to.forbiddenBy(from):
+		add("forbidden_version", UpgradeCheckFail,
+			fmt.Sprintf("target security version %d is listed in FORBIDDEN_VERSIONS of the current image", *to.SecurityVersion))
+	default:
+		add("forbidden_version", UpgradeCheckPass, "")
+	}
+
+	switch {
+	case from.SecurityVersion == nil || to.SecurityVersion == nil:
+		add("security_version", UpgradeCheckSkipped, "security version unknown")
+	case *to.SecurityVersion < *from.SecurityVersion:
+		add("security_version", UpgradeCheckFail,
+			fmt.Sprintf("security version would decrease from %d to %d", *from.SecurityVersion, *to.SecurityVersion))
+	default:
+		add("security_version", UpgradeCheckPass, "")
+	}
+
+	switch {
+	case from.PSID == "" || to.PSID == "":
+		add("psid", UpgradeCheckSkipped, "PSID unknown")
+	case from.PSID != to.PSID:
+		add("psid", UpgradeCheckFail, fmt.Sprintf("PSID differs: %s vs %s", from.PSID, to.PSID))
+	default:
+		add("psid", UpgradeCheckPass, "")
+	}
+
+	switch {
+	case from.DeviceID == 0 || to.DeviceID == 0:
+		add("device_id", UpgradeCheckSkipped, "device ID unknown")
+	case from.DeviceID != to.DeviceID:
+		add("device_id", UpgradeCheckFail, fmt.Sprintf("device ID differs: 0x%x vs 0x%x", from.DeviceID, to.DeviceID))
+	default:
+		add("device_id", UpgradeCheckPass, "")
+	}
+
+	return verdict
+}
@@ -0,0 +1,97 @@
+package security
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestReadImageVersion(t *testing.T) {
+	data, fwParser := createReportImage(t)
+	info := data[testSigImageInfoAddr:]
+	binary.BigEndian.PutUint16(info[0x4:], 28)
+	binary.BigEndian.PutUint16(info[0x8:], 39)
+	binary.BigEndian.PutUint16(info[0xa:], 1002)
+	binary.BigEndian.PutUint16(info[0x1c:], 0x1021)
+
+	v, err := ReadImageVersion(data, fwParser)
+	if err != nil {
+		t.Fatalf("ReadImageVersion() error = %v", err)
+	}
+	if v.FWVersion != "28.39.1002" || v.DeviceID != 0x1021 || v.SecurityVersion == nil || *v.SecurityVersion != 7 {
+		t.Errorf("ReadImageVersion() = %+v", v)
+	}
+	if len(v.ForbiddenVersions) != 2 {
+		t.Errorf("ForbiddenVersions = %v, want 2 entries", v.ForbiddenVersions)
+	}
+}
+
+func TestCheckUpgrade(t *testing.T) {
+	securityVersion := func(n uint32) *uint32 { return &n }
+	version := func(fw string, sv *uint32, psid string, forbidden ...uint32) *ImageVersion {
+		v, err := NewImageVersion(fw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v.SecurityVersion, v.PSID, v.DeviceID, v.ForbiddenVersions = sv, psid, 0x1021, forbidden
+		return v
+	}
+	current := version("28.39.1002", securityVersion(3), "MT_0000000838")
+
+	tests := []struct {
+		name     string
+		to       *ImageVersion
+		wantFail string
+	}{
+		{name: "allowed", to: version("28.40.1000", securityVersion(3), "MT_0000000838")},
+		{name: "current security version forbidden", to: version("28.40.1000", securityVersion(4), "MT_0000000838", 3), wantFail: "forbidden_version"},
+		{name: "only security versions are matched", to: version("28.40.1000", securityVersion(4), "MT_0000000838", 28, 39, 1002)},
+		{name: "security version decrease", to: version("28.38.1000", securityVersion(2), "MT_0000000838"), wantFail: "security_version"},
+		{name: "PSID mismatch", to: version("28.40.1000", securityVersion(3), "MT_0000000999"), wantFail: "psid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verdict := CheckUpgrade(current, tt.to)
+			var failed []string
+			for _, c := range verdict.Checks {
+				if c.Status == UpgradeCheckFail {
+					failed = append(failed, c.Name)
+				}
+			}
+			if tt.wantFail == "" {
+				if verdict.Verdict != UpgradeAllowed || len(failed) != 0 {
+					t.Errorf("CheckUpgrade() = %+v, want ALLOWED", verdict)
+				}
+				return
+			}
+			if verdict.Verdict != UpgradeRejected || len(failed) != 1 || failed[0] != tt.wantFail {
+				t.Errorf("CheckUpgrade() failed checks = %v, want [%s]", failed, tt.wantFail)
+			}
+		})
+	}
+}
+
+func TestCheckUpgrade_FromVersionOnly(t *testing.T) {
+	from, err := NewImageVersion("28.39.1002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	to, _ := NewImageVersion("28.40.1000")
+	to.PSID = "MT_0000000838"
+
+	verdict := CheckUpgrade(from, to)
+	if verdict.Verdict != UpgradeAllowed {
+		t.Fatalf("CheckUpgrade() = %+v", verdict)
+	}
+	for _, c := range verdict.Checks[1:] {
+		if c.Status != UpgradeCheckSkipped {
+			t.Errorf("check %s = %s, want SKIPPED", c.Name, c.Status)
+		}
+	}
+
+	for _, bad := range []string{"28.39", "70000.1.1", "28.65536.1000", "28.39.70000", "28.x.1000"} {
+		if _, err := NewImageVersion(bad); err == nil {
+			t.Errorf("NewImageVersion(%q) succeeded", bad)
+		}
+	}
+}
@@ -8,8 +8,8 @@ import (
 type FS4HashesTableHeader struct {
 	LoadAddress uint32 `offset:"0x0,endian:be"` // offset 0x0: hard-coded to 0
 	DwSize      uint32 `offset:"0x4,endian:be"` // offset 0x4: num of payload DWs + 1
-	CRC         uint16 `offset:"0x8,endian:be"` // offset 0x8: calculated over first 2 DWs
-	Reserved    uint16 `offset:"0xa,endian:be"` // padding to 12 bytes
+	Reserved    uint16 `offset:"0x8,endian:be"` // offset 0x8.16-0x8.31
+	CRC         uint16 `offset:"0xa,endian:be"` // offset 0x8.0-0x8.15: calculated over first 2 DWs
 }
 
 // Unmarshal unmarshals binary data
@@ -23,10 +23,11 @@ func (h *FS4HashesTableHeader) Marshal() ([]byte, error) {
 }
 
 // FS4HtocEntry represents a single entry in the HTOC (Hash Table of Contents) for FS4 with annotations
+// Field positions follow mstflint's bit numbering, where bit 0 is the LSB of a big-endian dword
 type FS4HtocEntry struct {
-	HashOffset  uint16 `offset:"0x0,endian:be"` // bits 0-15: offset to hash
-	SectionType uint8  `offset:"0x2,endian:be"` // bits 16-23: section type
-	Reserved    uint8  `offset:"0x3,endian:be"` // bits 24-31: reserved
+	SectionType uint8  `offset:"0x0,endian:be"` // bits 24-31: section type
+	Reserved    uint8  `offset:"0x1,endian:be"` // bits 16-23: reserved
+	HashOffset  uint16 `offset:"0x2,endian:be"` // bits 0-15: hash offset from the HTOC start
 	_           uint32 `offset:"0x4,endian:be"` // padding to 8 bytes
 }
 
@@ -43,9 +44,9 @@ func (h *FS4HtocEntry) Marshal() ([]byte, error) {
 // FS4HtocHeader represents the HTOC header for FS4 with annotations
 type FS4HtocHeader struct {
 	Version      uint32   `offset:"0x0,endian:be"` // offset 0x0
-	NumOfEntries uint8    `offset:"0x4,endian:be"` // offset 0x4, bits 0-7
-	HashType     uint8    `offset:"0x5,endian:be"` // offset 0x4, bits 8-15
-	HashSize     uint16   `offset:"0x6,endian:be"` // offset 0x4, bits 16-31
+	HashSize     uint16   `offset:"0x4,endian:be"` // offset 0x4, bits 16-31
+	HashType     uint8    `offset:"0x6,endian:be"` // offset 0x4, bits 8-15
+	NumOfEntries uint8    `offset:"0x7,endian:be"` // offset 0x4, bits 0-7
 	Reserved     [8]uint8 `offset:"0x8"`           // padding to 16 bytes
 }
 
@@ -21,6 +21,15 @@ func (b *FirmwareBootVersion) Unmarshal(data []byte) error {
 	return annotations.UnmarshalStruct(data, b)
 }
 
+// IsFS3 reports whether the image format version is that of an FS3 image.
+// FS3 images share the magic pattern with FS4/FS5 and differ only in this
+// field: mstflint's FwOperations::IsFS3OrFS4Image (mlxfwops/lib/fw_ops.cpp)
+// tells them apart by it, with FS3 images written before the field was
+// introduced carrying 0.
+func (b *FirmwareBootVersion) IsFS3() bool {
+	return b.ImageFormatVersion == ImageFormatVersionFS3 || b.ImageFormatVersion == ImageFormatVersionFS2
+}
+
 // Marshal marshals FirmwareBootVersion structure into binary data
 func (b *FirmwareBootVersion) Marshal() ([]byte, error) {
 	return annotations.MarshalStruct(b)
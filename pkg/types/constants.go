@@ -9,6 +9,9 @@ const (
 	FirmwareSize32MB = 0x02000000 // 32MB
 	FirmwareSize64MB = 0x04000000 // 64MB
 
+	// Upper bound for any uncompressed firmware image we are willing to buffer
+	MaxFirmwareSize = 0x08000000 // 128MB
+
 	// DTOC location for different firmware sizes
 	DTOCOffset8MB  = 0x007ff000
 	DTOCOffset16MB = 0x00fff000
@@ -72,9 +75,22 @@ const (
 	HashesTableMagic   = 0x484153485F5441424C // "HASH_TABL"
 	HashTableEntrySize = 64                   // 32-byte hash + metadata
 
+	// HASHES_TABLE layout, based on mstflint's image_layout_layouts.h
+	HashesTableHeaderSize = 0xc  // Load address, DW size and header CRC
+	HTOCHeaderSize        = 0x10 // Version, hash size/type and number of entries
+	HTOCEntrySize         = 0x8  // Section type and hash offset
+
+	// Encryption section layouts (HMACDigest)
+	HMACDigestSize = 0x80 // Digest type/size and up to 64 digest bytes
+
 	// Firmware format identifiers
 	FS3Magic = 0x4D544657 // "MTFW" for FS3
 
+	// FS3 layout constants
+	// Based on mstflint's fs3_ops.h
+	FS3Boot2Offset = 0x38   // BOOT2 follows the magic pattern and boot header
+	FS3SectorSize  = 0x1000 // The ITOC is aligned to a flash sector
+
 	// Boot version offset from magic pattern
 	// Based on mstflint's FS4_BOOT_VERSION_OFFSET in mlxfwops/lib/fw_ops.h:492
 	BootVersionOffset = 0x10 // Offset to boot version structure from magic pattern
@@ -31,7 +31,7 @@ func GetInSectionCRCPolicy(sectionType uint16) InSectionCRCPolicy {
         SectionTypeImageInfo,
         SectionTypeForbiddenVersions,
         SectionTypePublicKeys2048, SectionTypePublicKeys4096,
-        SectionTypeImageSignature512,
+        SectionTypeImageSignature256, SectionTypeImageSignature512,
         SectionTypeHashesTable:
         return InSectionCRCPolicyBlank
 
@@ -16,7 +16,7 @@ func TestGetInSectionCRCPolicy_Mappings(t *testing.T) {
         SectionTypeImageInfo,
         SectionTypeForbiddenVersions,
         SectionTypePublicKeys2048, SectionTypePublicKeys4096,
-        SectionTypeImageSignature512,
+        SectionTypeImageSignature256, SectionTypeImageSignature512,
         SectionTypeHashesTable,
     }
     for _, st := range blanks {
@@ -0,0 +1,138 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Severity is the severity of a parse diagnostic
+type Severity uint8
+
+const (
+	// SeverityInfo marks noteworthy but expected conditions (e.g. an encrypted image)
+	SeverityInfo Severity = iota
+	// SeverityWarning marks conditions the parser recovered from
+	SeverityWarning
+	// SeverityError marks conditions that make the image invalid
+	SeverityError
+)
+
+// String returns the string representation of the severity
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalJSON implements json.Marshaler interface
+func (s Severity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface
+func (s *Severity) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+
+	severity, err := ParseSeverity(str)
+	if err != nil {
+		return err
+	}
+	*s = severity
+	return nil
+}
+
+// ParseSeverity parses the string representation of a severity
+func ParseSeverity(str string) (Severity, error) {
+	switch str {
+	case "info":
+		return SeverityInfo, nil
+	case "warning":
+		return SeverityWarning, nil
+	case "error":
+		return SeverityError, nil
+	default:
+		return 0, fmt.Errorf("unknown severity: %s", str)
+	}
+}
+
+// DiagnosticCode identifies the kind of a parse diagnostic. Codes are stable
+// and meant for programmatic filtering; messages are not.
+type DiagnosticCode string
+
+const (
+	// DiagITOCAlternateLocation: the ITOC was not valid at its HW pointer and was found one sector later
+	DiagITOCAlternateLocation DiagnosticCode = "ITOC_ALTERNATE_LOCATION"
+	// DiagITOCHeaderCRC: the ITOC header CRC does not match
+	DiagITOCHeaderCRC DiagnosticCode = "ITOC_HEADER_CRC"
+	// DiagITOCEntryCRC: an ITOC entry CRC does not match
+	DiagITOCEntryCRC DiagnosticCode = "ITOC_ENTRY_CRC"
+	// DiagITOCNoEnd: the ITOC entry list is not terminated
+	DiagITOCNoEnd DiagnosticCode = "ITOC_NO_END"
+	// DiagEncryptedImage: no readable ITOC was found, the image is treated as encrypted
+	DiagEncryptedImage DiagnosticCode = "ENCRYPTED_IMAGE"
+	// DiagDTOCParseFailed: the DTOC could not be parsed
+	DiagDTOCParseFailed DiagnosticCode = "DTOC_PARSE_FAILED"
+	// DiagDTOCHeaderCRC: the DTOC header CRC does not match
+	DiagDTOCHeaderCRC DiagnosticCode = "DTOC_HEADER_CRC"
+	// DiagBoot2Missing: BOOT2 could not be located or parsed
+	DiagBoot2Missing DiagnosticCode = "BOOT2_MISSING"
+	// DiagToolsAreaMissing: TOOLS_AREA could not be located or parsed
+	DiagToolsAreaMissing DiagnosticCode = "TOOLS_AREA_MISSING"
+	// DiagHashesTableInvalid: the HW pointer names a HASHES_TABLE that could not be parsed
+	DiagHashesTableInvalid DiagnosticCode = "HASHES_TABLE_INVALID"
+	// DiagSectionInvalid: a TOC entry could not be turned into a section
+	DiagSectionInvalid DiagnosticCode = "SECTION_INVALID"
+)
+
+// Diagnostic is a condition found while parsing an image
+type Diagnostic struct {
+	Severity Severity       `json:"severity"`
+	Code     DiagnosticCode `json:"code"`
+	Offset   uint64         `json:"offset"`
+	Section  string         `json:"section,omitempty"`
+	Message  string         `json:"message"`
+}
+
+// String returns a one-line representation of the diagnostic
+func (d Diagnostic) String() string {
+	where := fmt.Sprintf("0x%08x", d.Offset)
+	if d.Section != "" {
+		where = d.Section + " @ " + where
+	}
+	return fmt.Sprintf("%s %s (%s): %s", d.Severity, d.Code, where, d.Message)
+}
+
+// Diagnostics is the ordered list of diagnostics produced by a parser
+type Diagnostics []Diagnostic
+
+// Add appends a diagnostic; section may be empty
+func (d *Diagnostics) Add(severity Severity, code DiagnosticCode, offset uint64, section string, format string, args ...any) {
+	*d = append(*d, Diagnostic{
+		Severity: severity,
+		Code:     code,
+		Offset:   offset,
+		Section:  section,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// AtLeast returns the diagnostics with the given severity or higher
+func (d Diagnostics) AtLeast(severity Severity) Diagnostics {
+	var result Diagnostics
+	for _, diag := range d {
+		if diag.Severity >= severity {
+			result = append(result, diag)
+		}
+	}
+	return result
+}
@@ -0,0 +1,43 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDiagnostics_JSON(t *testing.T) {
+	var diags Diagnostics
+	diags.Add(SeverityWarning, DiagITOCAlternateLocation, 0x6000, "ITOC", "moved from 0x%x", 0x5000)
+	diags.Add(SeverityInfo, DiagEncryptedImage, 0, "", "encrypted")
+
+	data, err := json.Marshal(diags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"severity":"warning","code":"ITOC_ALTERNATE_LOCATION","offset":24576,"section":"ITOC","message":"moved from 0x5000"},` +
+		`{"severity":"info","code":"ENCRYPTED_IMAGE","offset":0,"message":"encrypted"}]`
+	if string(data) != want {
+		t.Errorf("Marshal() = %s, want %s", data, want)
+	}
+
+	var decoded Diagnostics
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 2 || decoded[0] != diags[0] || decoded[1] != diags[1] {
+		t.Errorf("round trip = %+v, want %+v", decoded, diags)
+	}
+}
+
+func TestDiagnostics_AtLeast(t *testing.T) {
+	diags := Diagnostics{
+		{Severity: SeverityInfo, Code: DiagEncryptedImage},
+		{Severity: SeverityWarning, Code: DiagBoot2Missing},
+		{Severity: SeverityError, Code: DiagITOCEntryCRC},
+	}
+	for severity, want := range map[Severity]int{SeverityInfo: 3, SeverityWarning: 2, SeverityError: 1} {
+		if got := len(diags.AtLeast(severity)); got != want {
+			t.Errorf("AtLeast(%s) = %d entries, want %d", severity, got, want)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package types
+
+// EncryptedRegion is a contiguous range of an image encrypted with the image key
+type EncryptedRegion struct {
+	Offset uint32 `json:"offset"`
+	Size   uint32 `json:"size"`
+}
+
+// EncryptionLayout describes which parts of an encrypted image were decrypted
+// with a user-supplied key, so that the same parts can be encrypted again
+type EncryptionLayout struct {
+	Scheme     string            `json:"scheme"`
+	SectorSize uint32            `json:"sector_size"`
+	ITOCAddr   uint32            `json:"itoc_addr"`
+	Regions    []EncryptedRegion `json:"regions"`
+}
@@ -30,6 +30,8 @@ type FirmwareMetadata struct {
 	IsEncrypted  bool               `json:"is_encrypted"`
 	CRCInfo      CRCInfo            `json:"crc_info,omitempty"`
 	Boundaries   FirmwareBoundaries `json:"boundaries,omitempty"`
+	// Encryption is set when the image was decrypted with a key on extraction
+	Encryption *types.EncryptionLayout `json:"encryption,omitempty"`
 }
 
 type FirmwareFileInfo struct {
@@ -16,7 +16,7 @@ type FS3ITOCHeader struct {
     Signature3   uint32 `offset:"byte:12,endian:be"` // 0xC: 0xbacafe00
     Version      uint8  `offset:"bit:152,len:8,endian:be"` // per cibfw_itoc_header_unpack: offset=152 bits
     // 0x11..0x1B reserved
-    ITOCEntryCRC uint16 `offset:"byte:28,endian:be"` // 0x1C: CRC over header (except itself)
+    ITOCEntryCRC uint16 `offset:"bit:240,len:16,endian:be"` // 0x1E: CRC over the first 7 dwords of the header
 }
 
 func (h *FS3ITOCHeader) Unmarshal(data []byte) error { return annotations.UnmarshalStruct(data, h) }
@@ -34,6 +34,30 @@ type FS4HWPointers struct {
 	HashesTablePtr         HWPointerEntry `offset:"byte:120" json:"hashes_table_ptr"`        // offset 0x78
 }
 
+// FS4HWPointerNames are the names of the FS4HWPointers entries in image order
+var FS4HWPointerNames = []string{
+	"BOOT_RECORD_PTR", "BOOT2_PTR", "TOC_PTR", "TOOLS_PTR",
+	"AUTHENTICATION_START_PTR", "AUTHENTICATION_END_PTR", "DIGEST_PTR", "DIGEST_RECOVERY_KEY_PTR",
+	"FW_WINDOW_START_PTR", "FW_WINDOW_END_PTR", "IMAGE_INFO_SECTION_PTR", "IMAGE_SIGNATURE_PTR",
+	"PUBLIC_KEY_PTR", "FW_SECURITY_VERSION_PTR", "GCM_IV_DELTA_PTR", "HASHES_TABLE_PTR",
+}
+
+// FS5HWPointerNames are the names of the FS5HWPointers entries in image order
+var FS5HWPointerNames = []string{
+	"BOOT2_PTR", "TOC_PTR", "TOOLS_PTR", "IMAGE_INFO_SECTION_PTR",
+	"FW_PUBLIC_KEY_PTR", "FW_SIGNATURE_PTR", "PUBLIC_KEY_PTR", "FORBIDDEN_VERSIONS_PTR",
+	"PSC_BL1_PTR", "PSC_HASHES_TABLE_PTR", "NCORE_HASHES_POINTER", "PSC_FW_UPDATE_HANDLE_PTR",
+	"PSC_BCH_POINTER", "RESERVED_PTR13", "RESERVED_PTR14", "NCORE_BCH_POINTER",
+}
+
+// HWPointerNames returns the HW pointer names in image order for format
+func HWPointerNames(format FirmwareFormat) []string {
+	if format == FormatFS5 {
+		return FS5HWPointerNames
+	}
+	return FS4HWPointerNames
+}
+
 // FS5HWPointers represents the Gilboa hardware pointers structure (128 bytes) using annotations
 // Based on fs5_image_layout_hw_pointers_gilboa from mstflint
 type FS5HWPointers struct {
@@ -135,6 +135,12 @@ func TestITOCEntry_GetMethods(t *testing.T) {
 		}
 	})
 
+	t.Run("GetFlashAddr", func(t *testing.T) {
+		if got := entry.GetFlashAddr(); got != 0x2000 {
+			t.Errorf("GetFlashAddr() = 0x%X, want 0x2000", got)
+		}
+	})
+
 	t.Run("GetNoCRC", func(t *testing.T) {
 		if got := entry.GetNoCRC(); !got {
 			t.Error("GetNoCRC() = false, want true")
@@ -10,7 +10,7 @@ func (e *ITOCEntry) ParseFields() {
 func (e *ITOCEntry) GetCRC() uint8         { return e.CRCField }
 func (e *ITOCEntry) SetType(t uint8)       { e.Type = t }
 func (e *ITOCEntry) SetSize(s uint32)      { e.SizeDwords = s / 4 }
-func (e *ITOCEntry) SetFlashAddr(a uint32) { e.FlashAddrDwords = a / 8 }
+func (e *ITOCEntry) SetFlashAddr(a uint32) { e.FlashAddrDwords = a }
 func (e *ITOCEntry) SetParam0(p uint32) {
 	e.Param0Low = uint32(p & 0xF)
 	e.Param0High = p >> 4
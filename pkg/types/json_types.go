@@ -371,6 +371,14 @@ type ForbiddenVersionsJSON struct {
 	Versions []uint32 `json:"versions"`
 }
 
+// HMACDigestJSON represents HMAC_DIGEST section data in JSON
+type HMACDigestJSON struct {
+	DigestType uint32 `json:"digest_type"`
+	DigestSize uint32 `json:"digest_size"`
+	Algorithm  string `json:"algorithm,omitempty"`
+	Digest     string `json:"digest"` // Hex encoded, DigestSize bytes
+}
+
 // HWPointerEntryJSON represents a hardware pointer entry in JSON
 type HWPointerEntryJSON struct {
 	Pointer uint32 `json:"pointer"`
@@ -525,6 +533,9 @@ type SectionJSON struct {
 	DigitalCertPtr   *DigitalCertPtrJSON   `json:"digital_cert_ptr,omitempty"`
 	DigitalCertRW    *DigitalCertRWJSON    `json:"digital_cert_rw,omitempty"`
 
+	// Encryption sections
+	HMACDigest *HMACDigestJSON `json:"hmac_digest,omitempty"`
+
 	// For sections with padding
 	Padding string `json:"padding,omitempty"` // Hex encoded padding data
 }
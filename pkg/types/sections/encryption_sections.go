@@ -0,0 +1,88 @@
+package sections
+
+import (
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/ansel1/merry/v2"
+
+	"github.com/Civil/mlx5fw-go/pkg/interfaces"
+	"github.com/Civil/mlx5fw-go/pkg/types"
+)
+
+// HMACDigestSection represents an HMAC_DIGEST section
+type HMACDigestSection struct {
+	*interfaces.BaseSection
+	Digest *types.HMACDigest
+}
+
+// NewHMACDigestSection creates a new HMAC_DIGEST section
+func NewHMACDigestSection(base *interfaces.BaseSection) *HMACDigestSection {
+	return &HMACDigestSection{
+		BaseSection: base,
+	}
+}
+
+// Parse parses the HMAC_DIGEST section data
+func (s *HMACDigestSection) Parse(data []byte) error {
+	s.SetRawData(data)
+	if len(data) < types.HMACDigestSize {
+		// Keep short sections as raw data only
+		return nil
+	}
+
+	s.Digest = &types.HMACDigest{}
+	if err := s.Digest.Unmarshal(data[:types.HMACDigestSize]); err != nil {
+		return merry.Wrap(err)
+	}
+
+	return nil
+}
+
+// DigestBytes returns the stored digest trimmed to its declared size, or nil
+// when the declared size is invalid
+func (s *HMACDigestSection) DigestBytes() []byte {
+	if s.Digest == nil || s.Digest.DigestSize == 0 || s.Digest.DigestSize > uint32(len(s.Digest.Digest)) {
+		return nil
+	}
+	return s.Digest.Digest[:s.Digest.DigestSize]
+}
+
+// MarshalJSON returns JSON representation of the HMAC_DIGEST section
+func (s *HMACDigestSection) MarshalJSON() ([]byte, error) {
+	sectionJSON := &types.SectionJSON{
+		Type:         s.Type(),
+		TypeName:     s.TypeName(),
+		Offset:       s.Offset(),
+		Size:         s.Size(),
+		CRCType:      s.CRCType().String(),
+		IsEncrypted:  s.IsEncrypted(),
+		IsDeviceData: s.IsDeviceData(),
+		HasRawData:   true, // Reserved bytes and trailers are only kept in the binary
+	}
+
+	if s.Digest != nil {
+		sectionJSON.HMACDigest = &types.HMACDigestJSON{
+			DigestType: s.Digest.DigestType,
+			DigestSize: s.Digest.DigestSize,
+			Algorithm:  HMACAlgorithm(s.Digest.DigestSize),
+			Digest:     hex.EncodeToString(s.DigestBytes()),
+		}
+	}
+
+	return json.Marshal(sectionJSON)
+}
+
+// HMACAlgorithm names the HMAC hash for a digest size, or "" when unknown
+func HMACAlgorithm(digestSize uint32) string {
+	switch digestSize {
+	case 32:
+		return "HMAC-SHA256"
+	case 48:
+		return "HMAC-SHA384"
+	case 64:
+		return "HMAC-SHA512"
+	default:
+		return ""
+	}
+}
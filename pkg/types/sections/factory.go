@@ -111,6 +111,9 @@ func (f *DefaultSectionFactory) CreateSection(sectionType uint16, offset uint64,
 	case types.SectionTypeForbiddenVersions:
 		return NewForbiddenVersionsSection(base), nil
 
+	case types.SectionTypeHMACDigest:
+		return NewHMACDigestSection(base), nil
+
 	case types.SectionTypeDbgFWINI:
 		return NewDBGFwIniSection(base), nil
 
@@ -148,7 +151,8 @@ func (f *DefaultSectionFactory) CreateSection(sectionType uint16, offset uint64,
 	// Add more specific section types as needed
 
 	default:
-		// Return generic section for unknown types
+		// Return generic section for unknown types. ENCRYPTION_KEY_TRANSITION
+		// also ends up here: neither mstflint nor the PRM publish its layout.
 		return NewGenericSection(base), nil
 	}
 }